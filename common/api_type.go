@@ -67,6 +67,8 @@ func ChannelType2APIType(channelType int) (int, bool) {
 		apiType = constant.APITypeJimeng
 	case constant.ChannelTypeWavespeed:
 		apiType = constant.APITypeWavespeed
+	case constant.ChannelTypeDeclarative:
+		apiType = constant.APITypeDeclarative
 	case constant.ChannelTypeMoonshot:
 		apiType = constant.APITypeMoonshot
 	}
@@ -0,0 +1,306 @@
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Prefix 加密值的前缀，不带前缀的值视为旧版本写入的明文
+const Prefix = "enc:v1:"
+
+// maxCachedDataKeys 解包后的数据密钥缓存上限，超过后清空重新解包
+const maxCachedDataKeys = 1024
+
+var (
+	ErrNotConfigured = errors.New("envelope: master key is not configured")
+	ErrMalformed     = errors.New("envelope: malformed ciphertext")
+)
+
+// KeyProvider 主密钥提供方，负责包装与解包数据密钥。内置 local 实现从环境变量或文件读取主密钥，
+// 也可以注册调用外部 KMS 的实现
+type KeyProvider interface {
+	// PrimaryKeyId 当前用于包装新数据密钥的主密钥标识
+	PrimaryKeyId() string
+	WrapKey(keyId string, dataKey []byte) ([]byte, error)
+	UnwrapKey(keyId string, wrapped []byte) ([]byte, error)
+	// Reload 重新加载主密钥，用于在线轮换
+	Reload() error
+}
+
+// Factory 根据环境变量创建主密钥提供方
+type Factory func() (KeyProvider, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]Factory)
+)
+
+// Register 注册主密钥提供方，通过 SECRET_KEY_PROVIDER 选择
+func Register(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[name] = factory
+}
+
+type dataKey struct {
+	keyId   string
+	wrapped string
+	aead    cipher.AEAD
+}
+
+var (
+	mu       sync.RWMutex
+	provider KeyProvider
+	active   *dataKey
+	cache    = make(map[string]cipher.AEAD)
+)
+
+// InitFromEnv 按环境变量初始化加密，未配置主密钥时保持明文存储
+func InitFromEnv() error {
+	name := os.Getenv("SECRET_KEY_PROVIDER")
+	if name == "" {
+		if os.Getenv("SECRET_MASTER_KEY") == "" && os.Getenv("SECRET_MASTER_KEY_FILE") == "" {
+			return nil
+		}
+		name = "local"
+	}
+	factoriesMu.RLock()
+	factory, ok := factories[name]
+	factoriesMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("envelope: unknown key provider %q", name)
+	}
+	p, err := factory()
+	if err != nil {
+		return err
+	}
+	Configure(p)
+	return nil
+}
+
+// Configure 设置主密钥提供方，传入 nil 关闭加密
+func Configure(p KeyProvider) {
+	mu.Lock()
+	defer mu.Unlock()
+	provider = p
+	active = nil
+	cache = make(map[string]cipher.AEAD)
+}
+
+// Enabled 是否配置了主密钥
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return provider != nil
+}
+
+// PrimaryKeyId 当前主密钥标识，未启用时为空
+func PrimaryKeyId() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	if provider == nil {
+		return ""
+	}
+	return provider.PrimaryKeyId()
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// activeDataKey 返回当前用于加密的数据密钥，同一主密钥下的进程内复用一个数据密钥
+func activeDataKey() (*dataKey, error) {
+	mu.RLock()
+	p, current := provider, active
+	mu.RUnlock()
+	if p == nil {
+		return nil, ErrNotConfigured
+	}
+	keyId := p.PrimaryKeyId()
+	if current != nil && current.keyId == keyId {
+		return current, nil
+	}
+
+	plain := make([]byte, 32)
+	if _, err := rand.Read(plain); err != nil {
+		return nil, err
+	}
+	wrapped, err := p.WrapKey(keyId, plain)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(plain)
+	if err != nil {
+		return nil, err
+	}
+	key := &dataKey{keyId: keyId, wrapped: base64.RawURLEncoding.EncodeToString(wrapped), aead: aead}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if provider != p {
+		return nil, errors.New("envelope: key provider changed, retry")
+	}
+	if active != nil && active.keyId == keyId {
+		return active, nil
+	}
+	active = key
+	cache[keyId+":"+key.wrapped] = aead
+	return key, nil
+}
+
+// Encrypt 使用数据密钥加密，数据密钥由主密钥包装后与密文一起保存。空值与已加密的值原样返回，未启用时返回明文
+func Encrypt(plaintext string) (string, error) {
+	if plaintext == "" || IsEncrypted(plaintext) || !Enabled() {
+		return plaintext, nil
+	}
+	key, err := activeDataKey()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, key.aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := key.aead.Seal(nonce, nonce, []byte(plaintext), []byte(key.keyId))
+	return Prefix + key.keyId + ":" + key.wrapped + ":" + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt 解密由 Encrypt 生成的值，不带前缀的值视为明文原样返回
+func Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	keyId, wrapped, sealed, err := parse(value)
+	if err != nil {
+		return "", err
+	}
+	aead, err := unwrap(keyId, wrapped)
+	if err != nil {
+		return "", err
+	}
+	nonceSize := aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return "", ErrMalformed
+	}
+	plain, err := aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], []byte(keyId))
+	if err != nil {
+		return "", fmt.Errorf("envelope: decrypt failed: %w", err)
+	}
+	return string(plain), nil
+}
+
+// IsEncrypted 值是否为加密格式
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+// KeyIdOf 返回加密值使用的主密钥标识，明文返回空
+func KeyIdOf(value string) string {
+	if !IsEncrypted(value) {
+		return ""
+	}
+	keyId, _, _, err := parse(value)
+	if err != nil {
+		return ""
+	}
+	return keyId
+}
+
+// NeedsRewrap 启用加密时，明文或使用旧主密钥加密的非空值需要重新加密
+func NeedsRewrap(value string) bool {
+	if value == "" || !Enabled() {
+		return false
+	}
+	return KeyIdOf(value) != PrimaryKeyId()
+}
+
+// Rewrap 将值解密后使用当前主密钥重新加密
+func Rewrap(value string) (string, error) {
+	plain, err := Decrypt(value)
+	if err != nil {
+		return "", err
+	}
+	return Encrypt(plain)
+}
+
+// Rotate 重新加载主密钥并在新的主密钥下生成数据密钥，返回新的主密钥标识。已有数据需要调用方使用 Rewrap 重新加密
+func Rotate() (string, error) {
+	mu.Lock()
+	p := provider
+	if p == nil {
+		mu.Unlock()
+		return "", ErrNotConfigured
+	}
+	if err := p.Reload(); err != nil {
+		mu.Unlock()
+		return "", err
+	}
+	active = nil
+	cache = make(map[string]cipher.AEAD)
+	mu.Unlock()
+	if _, err := activeDataKey(); err != nil {
+		return "", err
+	}
+	return p.PrimaryKeyId(), nil
+}
+
+func parse(value string) (keyId string, wrapped string, sealed []byte, err error) {
+	parts := strings.Split(strings.TrimPrefix(value, Prefix), ":")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+		return "", "", nil, ErrMalformed
+	}
+	sealed, err = base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", "", nil, ErrMalformed
+	}
+	return parts[0], parts[1], sealed, nil
+}
+
+func unwrap(keyId string, wrapped string) (cipher.AEAD, error) {
+	cacheKey := keyId + ":" + wrapped
+	mu.RLock()
+	p := provider
+	aead, ok := cache[cacheKey]
+	mu.RUnlock()
+	if ok {
+		return aead, nil
+	}
+	if p == nil {
+		return nil, ErrNotConfigured
+	}
+	wrappedBytes, err := base64.RawURLEncoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	plain, err := p.UnwrapKey(keyId, wrappedBytes)
+	if err != nil {
+		// 其他节点可能已经轮换了主密钥，重新加载后再试一次
+		if reloadErr := p.Reload(); reloadErr != nil {
+			return nil, err
+		}
+		if plain, err = p.UnwrapKey(keyId, wrappedBytes); err != nil {
+			return nil, err
+		}
+	}
+	if aead, err = newAEAD(plain); err != nil {
+		return nil, err
+	}
+	mu.Lock()
+	if len(cache) >= maxCachedDataKeys {
+		cache = make(map[string]cipher.AEAD)
+	}
+	cache[cacheKey] = aead
+	mu.Unlock()
+	return aead, nil
+}
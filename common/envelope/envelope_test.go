@@ -0,0 +1,100 @@
+package envelope
+
+import (
+	"strings"
+	"testing"
+)
+
+func configureKeys(t *testing.T, keys *string) {
+	t.Helper()
+	p, err := NewLocalProvider(func() (string, error) { return *keys, nil })
+	if err != nil {
+		t.Fatalf("NewLocalProvider failed: %v", err)
+	}
+	Configure(p)
+	t.Cleanup(func() { Configure(nil) })
+}
+
+func TestEncryptDisabled(t *testing.T) {
+	Configure(nil)
+	value, err := Encrypt("sk-plain")
+	if err != nil || value != "sk-plain" {
+		t.Fatalf("Expected plaintext passthrough, got %q %v", value, err)
+	}
+	if _, err = Decrypt(Prefix + "abc:def:ghi"); err != ErrNotConfigured {
+		t.Errorf("Expected ErrNotConfigured, got %v", err)
+	}
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	keys := "first-master-key"
+	configureKeys(t, &keys)
+
+	encrypted, err := Encrypt("sk-secret\nsk-second")
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if !IsEncrypted(encrypted) || strings.Contains(encrypted, "sk-secret") {
+		t.Fatalf("Expected ciphertext, got %q", encrypted)
+	}
+	again, _ := Encrypt(encrypted)
+	if again != encrypted {
+		t.Error("Expected already encrypted value to be returned as is")
+	}
+	plain, err := Decrypt(encrypted)
+	if err != nil || plain != "sk-secret\nsk-second" {
+		t.Errorf("Decrypt returned %q %v", plain, err)
+	}
+	if plain, _ = Decrypt("legacy-plaintext"); plain != "legacy-plaintext" {
+		t.Errorf("Expected plaintext passthrough, got %q", plain)
+	}
+	if empty, _ := Encrypt(""); empty != "" {
+		t.Errorf("Expected empty value to stay empty, got %q", empty)
+	}
+
+	tampered := encrypted[:len(encrypted)-2] + "AA"
+	if _, err = Decrypt(tampered); err == nil {
+		t.Error("Expected tampered ciphertext to fail")
+	}
+}
+
+func TestRotate(t *testing.T) {
+	keys := "old-master-key"
+	configureKeys(t, &keys)
+	oldId := PrimaryKeyId()
+	encrypted, err := Encrypt("sk-rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if NeedsRewrap(encrypted) || !NeedsRewrap("sk-plaintext") {
+		t.Error("Unexpected NeedsRewrap result before rotation")
+	}
+
+	keys = "new-master-key\nold-master-key"
+	newId, err := Rotate()
+	if err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	if newId == oldId || KeyIdOf(encrypted) != oldId {
+		t.Fatalf("Expected new primary key, got %s (old %s)", newId, oldId)
+	}
+	if !NeedsRewrap(encrypted) {
+		t.Error("Expected value under old key to need rewrap")
+	}
+	rewrapped, err := Rewrap(encrypted)
+	if err != nil || KeyIdOf(rewrapped) != newId {
+		t.Fatalf("Rewrap failed: %q %v", rewrapped, err)
+	}
+
+	// 移除旧密钥后，旧数据无法解密，新数据不受影响
+	keys = "new-master-key"
+	if _, err = Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Decrypt(encrypted); err == nil {
+		t.Error("Expected value under removed key to fail")
+	}
+	if plain, err := Decrypt(rewrapped); err != nil || plain != "sk-rotate" {
+		t.Errorf("Expected rewrapped value to decrypt, got %q %v", plain, err)
+	}
+}
@@ -0,0 +1,134 @@
+package envelope
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+func init() {
+	Register("local", newLocalProviderFromEnv)
+}
+
+// localProvider 使用本地主密钥包装数据密钥。主密钥来自 SECRET_MASTER_KEY（逗号分隔）或 SECRET_MASTER_KEY_FILE（每行一个），
+// 第一个为主密钥，其余仅用于解密旧数据；轮换时将新密钥放在第一位并保留旧密钥，完成重新加密后再移除旧密钥
+type localProvider struct {
+	load func() (string, error)
+
+	mu      sync.RWMutex
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+func newLocalProviderFromEnv() (KeyProvider, error) {
+	return NewLocalProvider(func() (string, error) {
+		if path := os.Getenv("SECRET_MASTER_KEY_FILE"); path != "" {
+			data, err := os.ReadFile(path)
+			return string(data), err
+		}
+		return strings.ReplaceAll(os.Getenv("SECRET_MASTER_KEY"), ",", "\n"), nil
+	})
+}
+
+// NewLocalProvider 创建本地主密钥提供方，load 返回按行分隔的主密钥列表，每次 Reload 时重新调用
+func NewLocalProvider(load func() (string, error)) (KeyProvider, error) {
+	p := &localProvider{load: load}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// parseMasterKey 支持 base64 编码的 32 字节密钥，其他内容通过 SHA-256 派生
+func parseMasterKey(text string) []byte {
+	if decoded, err := base64.StdEncoding.DecodeString(text); err == nil && len(decoded) == 32 {
+		return decoded
+	}
+	sum := sha256.Sum256([]byte(text))
+	return sum[:]
+}
+
+// masterKeyId 主密钥标识取自密钥摘要，不泄露密钥本身
+func masterKeyId(key []byte) string {
+	sum := sha256.Sum256(append([]byte("envelope-key-id:"), key...))
+	return hex.EncodeToString(sum[:4])
+}
+
+func (p *localProvider) Reload() error {
+	text, err := p.load()
+	if err != nil {
+		return fmt.Errorf("envelope: failed to load master keys: %w", err)
+	}
+	keys := make(map[string]cipher.AEAD)
+	primary := ""
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key := parseMasterKey(line)
+		aead, err := newAEAD(key)
+		if err != nil {
+			return err
+		}
+		id := masterKeyId(key)
+		if primary == "" {
+			primary = id
+		}
+		keys[id] = aead
+	}
+	if primary == "" {
+		return errors.New("envelope: no master key configured")
+	}
+	p.mu.Lock()
+	p.primary = primary
+	p.keys = keys
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *localProvider) PrimaryKeyId() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.primary
+}
+
+func (p *localProvider) key(keyId string) (cipher.AEAD, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	aead, ok := p.keys[keyId]
+	if !ok {
+		return nil, fmt.Errorf("envelope: unknown master key %s", keyId)
+	}
+	return aead, nil
+}
+
+func (p *localProvider) WrapKey(keyId string, dataKey []byte) ([]byte, error) {
+	aead, err := p.key(keyId)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, dataKey, []byte(keyId)), nil
+}
+
+func (p *localProvider) UnwrapKey(keyId string, wrapped []byte) ([]byte, error) {
+	aead, err := p.key(keyId)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+	return aead.Open(nil, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], []byte(keyId))
+}
@@ -0,0 +1,200 @@
+// Package hftokenizer 读取 HuggingFace tokenizers 导出的 tokenizer.json，用于估算提示词 token 数。
+// 支持 BPE（含 byte_fallback）与 Unigram 模型，常见的 normalizer 与 pre_tokenizer，以及 added_tokens；
+// 不做 post_processor 与解码，结果可能与官方实现存在少量差异
+package hftokenizer
+
+import (
+	"fmt"
+	"one-api/common"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type tokenizerFile struct {
+	AddedTokens []struct {
+		Id      int    `json:"id"`
+		Content string `json:"content"`
+	} `json:"added_tokens"`
+	Normalizer   *component `json:"normalizer"`
+	PreTokenizer *component `json:"pre_tokenizer"`
+	Model        modelFile  `json:"model"`
+}
+
+// component normalizer 与 pre_tokenizer 共用的配置结构
+type component struct {
+	Type           string       `json:"type"`
+	Normalizers    []*component `json:"normalizers"`
+	Pretokenizers  []*component `json:"pretokenizers"`
+	Pattern        *pattern     `json:"pattern"`
+	Content        string       `json:"content"`
+	Prepend        string       `json:"prepend"`
+	Left           bool         `json:"left"`
+	Right          bool         `json:"right"`
+	Lowercase      *bool        `json:"lowercase"`
+	Behavior       string       `json:"behavior"`
+	Invert         bool         `json:"invert"`
+	AddPrefixSpace *bool        `json:"add_prefix_space"`
+	UseRegex       *bool        `json:"use_regex"`
+	Replacement    string       `json:"replacement"`
+	PrependScheme  string       `json:"prepend_scheme"`
+	Split          *bool        `json:"split"`
+	Individual     bool         `json:"individual_digits"`
+}
+
+type pattern struct {
+	String *string `json:"String"`
+	Regex  *string `json:"Regex"`
+}
+
+// Tokenizer 可并发使用
+type Tokenizer struct {
+	name         string
+	added        *regexp.Regexp
+	normalizer   normalizer
+	preTokenizer preTokenizer
+	model        tokenModel
+	memory       int64
+
+	cacheLock sync.Mutex
+	cache     map[string]int
+}
+
+type tokenModel interface {
+	countWord(word string) int
+	memorySize() int64
+}
+
+const (
+	// 单词计数缓存的上限，超过后清空
+	maxCachedWords = 1 << 16
+	// 只缓存较短的单词，整段文本作为一个单词时缓存没有意义
+	maxCachedWordLen = 64
+)
+
+// Load 从文件加载 tokenizer.json
+func Load(name string, path string) (*Tokenizer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(name, data)
+}
+
+// Parse 解析 tokenizer.json 的内容
+func Parse(name string, data []byte) (*Tokenizer, error) {
+	var file tokenizerFile
+	if err := common.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid tokenizer.json: %w", err)
+	}
+	t := &Tokenizer{name: name, cache: make(map[string]int)}
+	var err error
+	if t.normalizer, err = newNormalizer(file.Normalizer); err != nil {
+		return nil, err
+	}
+	if t.preTokenizer, err = newPreTokenizer(file.PreTokenizer); err != nil {
+		return nil, err
+	}
+	switch file.Model.Type {
+	case "BPE", "":
+		t.model, err = newBPE(&file.Model)
+	case "Unigram":
+		t.model, err = newUnigram(&file.Model)
+	default:
+		err = fmt.Errorf("unsupported tokenizer model type: %s", file.Model.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.memory = t.model.memorySize()
+
+	if len(file.AddedTokens) > 0 {
+		contents := make([]string, 0, len(file.AddedTokens))
+		for _, token := range file.AddedTokens {
+			if token.Content != "" {
+				contents = append(contents, token.Content)
+			}
+		}
+		// 较长的 token 优先匹配
+		sort.Slice(contents, func(i, j int) bool { return len(contents[i]) > len(contents[j]) })
+		for i, content := range contents {
+			contents[i] = regexp.QuoteMeta(content)
+		}
+		if len(contents) > 0 {
+			t.added = regexp.MustCompile(strings.Join(contents, "|"))
+		}
+	}
+	return t, nil
+}
+
+// GetName 返回加载时指定的名称
+func (t *Tokenizer) GetName() string {
+	return t.name
+}
+
+// MemorySize 估算词表占用的内存（字节）
+func (t *Tokenizer) MemorySize() int64 {
+	return t.memory
+}
+
+// Count 返回文本的 token 数
+func (t *Tokenizer) Count(text string) (int, error) {
+	if text == "" {
+		return 0, nil
+	}
+	if t.added == nil {
+		return t.countSection(text, true), nil
+	}
+	count := 0
+	start := 0
+	for _, loc := range t.added.FindAllStringIndex(text, -1) {
+		if loc[0] > start {
+			count += t.countSection(text[start:loc[0]], start == 0)
+		}
+		count++
+		start = loc[1]
+	}
+	if start < len(text) {
+		count += t.countSection(text[start:], start == 0)
+	}
+	return count, nil
+}
+
+func (t *Tokenizer) countSection(text string, first bool) int {
+	if t.normalizer != nil {
+		text = t.normalizer(text)
+	}
+	words := []string{text}
+	if t.preTokenizer != nil {
+		words = t.preTokenizer(text, first)
+	}
+	count := 0
+	for _, word := range words {
+		if word != "" {
+			count += t.countWord(word)
+		}
+	}
+	return count
+}
+
+func (t *Tokenizer) countWord(word string) int {
+	if len(word) > maxCachedWordLen {
+		return t.model.countWord(word)
+	}
+	t.cacheLock.Lock()
+	count, ok := t.cache[word]
+	t.cacheLock.Unlock()
+	if ok {
+		return count
+	}
+	count = t.model.countWord(word)
+	t.cacheLock.Lock()
+	if len(t.cache) >= maxCachedWords {
+		t.cache = make(map[string]int)
+	}
+	t.cache[word] = count
+	t.cacheLock.Unlock()
+	return count
+}
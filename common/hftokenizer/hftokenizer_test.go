@@ -0,0 +1,115 @@
+package hftokenizer
+
+import (
+	"strings"
+	"testing"
+)
+
+// byteLevelTokenizer GPT-2 风格的 ByteLevel BPE，Qwen、DeepSeek、Llama 3 等使用同样的结构
+const byteLevelTokenizer = `{
+	"added_tokens": [{"id": 100, "content": "<|im_end|>", "special": true}],
+	"normalizer": {"type": "NFC"},
+	"pre_tokenizer": {"type": "Sequence", "pretokenizers": [
+		{"type": "Split", "pattern": {"Regex": "\\p{N}{1,3}"}, "behavior": "Isolated", "invert": false},
+		{"type": "ByteLevel", "add_prefix_space": false, "use_regex": true}
+	]},
+	"model": {
+		"type": "BPE",
+		"vocab": {"h": 0, "e": 1, "l": 2, "o": 3, "Ġ": 4, "w": 5, "r": 6, "d": 7, "1": 8, "2": 9,
+			"he": 10, "ll": 11, "hell": 12, "hello": 13, "Ġw": 14, "or": 15, "Ġwor": 16, "12": 17},
+		"merges": ["h e", "l l", "he ll", "hell o", "Ġ w", "o r", "Ġw or", ["1", "2"]]
+	}
+}`
+
+// metaspaceTokenizer Llama 2 风格：normalizer 把空格替换为 ▁，不认识的字符按字节回退
+const metaspaceTokenizer = `{
+	"normalizer": {"type": "Sequence", "normalizers": [
+		{"type": "Prepend", "prepend": "▁"},
+		{"type": "Replace", "pattern": {"String": " "}, "content": "▁"}
+	]},
+	"pre_tokenizer": null,
+	"model": {
+		"type": "BPE",
+		"byte_fallback": true,
+		"unk_token": "<unk>",
+		"vocab": {"<unk>": 0, "<0xE4>": 1, "<0xBD>": 2, "<0xA0>": 3, "▁": 4, "h": 5, "i": 6, "▁h": 7, "▁hi": 8},
+		"merges": ["▁ h", "▁h i"]
+	}
+}`
+
+const unigramTokenizer = `{
+	"pre_tokenizer": {"type": "Metaspace", "replacement": "▁", "prepend_scheme": "always", "split": true},
+	"model": {
+		"type": "Unigram",
+		"unk_id": 0,
+		"vocab": [["<unk>", 0.0], ["▁", -2.0], ["▁hello", -1.0], ["▁he", -3.0], ["llo", -3.0],
+			["h", -5.0], ["e", -5.0], ["l", -5.0], ["o", -5.0], ["▁world", -1.5]]
+	}
+}`
+
+func mustParse(t testing.TB, data string) *Tokenizer {
+	t.Helper()
+	tokenizer, err := Parse("test", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokenizer
+}
+
+func TestCount(t *testing.T) {
+	cases := []struct {
+		name      string
+		tokenizer string
+		text      string
+		expected  int
+	}{
+		{"byte level", byteLevelTokenizer, "hello world", 4},
+		{"added tokens", byteLevelTokenizer, "hello<|im_end|>hello", 3},
+		{"digits split", byteLevelTokenizer, "12121", 4},
+		{"byte fallback", metaspaceTokenizer, "hi 你", 5},
+		{"unigram", unigramTokenizer, "hello world", 2},
+		{"unigram unknown", unigramTokenizer, "hellox", 2},
+	}
+	for _, tc := range cases {
+		count, err := mustParse(t, tc.tokenizer).Count(tc.text)
+		if err != nil || count != tc.expected {
+			t.Errorf("%s: Count(%q) = %d, %v; want %d", tc.name, tc.text, count, err, tc.expected)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	invalid := []string{
+		`{"model": {"type": "WordLevel", "vocab": {}}}`,
+		`{"pre_tokenizer": {"type": "UnknownSplit"}, "model": {"type": "BPE", "vocab": {}, "merges": []}}`,
+		`{"model": {"type": "BPE", "vocab": {"a": 0}, "merges": ["ab"]}}`,
+	}
+	for _, data := range invalid {
+		if _, err := Parse("test", []byte(data)); err == nil {
+			t.Errorf("expected %s to be rejected", data)
+		}
+	}
+	if mustParse(t, byteLevelTokenizer).MemorySize() <= 0 {
+		t.Error("expected memory size to be estimated")
+	}
+}
+
+func BenchmarkCountByteLevel(b *testing.B) {
+	tokenizer := mustParse(b, byteLevelTokenizer)
+	text := strings.Repeat("hello world 1212 ", 512)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = tokenizer.Count(text)
+	}
+}
+
+func BenchmarkCountMetaspace(b *testing.B) {
+	tokenizer := mustParse(b, metaspaceTokenizer)
+	text := strings.Repeat("hi 你 ", 512)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = tokenizer.Count(text)
+	}
+}
@@ -0,0 +1,312 @@
+package hftokenizer
+
+import (
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"math"
+	"one-api/common"
+	"strings"
+	"unicode/utf8"
+)
+
+type modelFile struct {
+	Type         string          `json:"type"`
+	Vocab        json.RawMessage `json:"vocab"`
+	Merges       json.RawMessage `json:"merges"`
+	UnkToken     *string         `json:"unk_token"`
+	FuseUnk      bool            `json:"fuse_unk"`
+	ByteFallback bool            `json:"byte_fallback"`
+	IgnoreMerges bool            `json:"ignore_merges"`
+}
+
+// 估算内存占用时每个词表项与合并规则的额外开销
+const (
+	vocabEntryOverhead = 48
+	mergeEntryOverhead = 24
+)
+
+type mergeRule struct {
+	rank int32
+	id   int32
+}
+
+type bpeModel struct {
+	vocab        map[string]int32
+	merges       map[uint64]mergeRule
+	unkId        int32
+	fuseUnk      bool
+	byteFallback bool
+	ignoreMerges bool
+	byteIds      [256]int32
+	memory       int64
+}
+
+func pairKey(left, right int32) uint64 {
+	return uint64(uint32(left))<<32 | uint64(uint32(right))
+}
+
+func newBPE(file *modelFile) (*bpeModel, error) {
+	m := &bpeModel{unkId: -1, fuseUnk: file.FuseUnk, byteFallback: file.ByteFallback, ignoreMerges: file.IgnoreMerges}
+	if err := common.Unmarshal(file.Vocab, &m.vocab); err != nil {
+		return nil, fmt.Errorf("invalid BPE vocab: %w", err)
+	}
+	for token := range m.vocab {
+		m.memory += int64(len(token)) + vocabEntryOverhead
+	}
+	if file.UnkToken != nil {
+		if id, ok := m.vocab[*file.UnkToken]; ok {
+			m.unkId = id
+		}
+	}
+	for b := 0; b < 256; b++ {
+		m.byteIds[b] = -1
+		if id, ok := m.vocab[fmt.Sprintf("<0x%02X>", b)]; ok {
+			m.byteIds[b] = id
+		}
+	}
+
+	// merges 可以是 "a b" 形式的字符串，也可以是 ["a", "b"] 形式的数组
+	var raw []json.RawMessage
+	if len(file.Merges) > 0 {
+		if err := common.Unmarshal(file.Merges, &raw); err != nil {
+			return nil, fmt.Errorf("invalid BPE merges: %w", err)
+		}
+	}
+	m.merges = make(map[uint64]mergeRule, len(raw))
+	for rank, item := range raw {
+		var left, right string
+		var pair []string
+		var text string
+		if err := common.Unmarshal(item, &pair); err == nil && len(pair) == 2 {
+			left, right = pair[0], pair[1]
+		} else if err := common.Unmarshal(item, &text); err == nil {
+			var found bool
+			if left, right, found = strings.Cut(text, " "); !found {
+				return nil, fmt.Errorf("invalid BPE merge: %q", text)
+			}
+		} else {
+			return nil, fmt.Errorf("invalid BPE merge: %s", item)
+		}
+		leftId, ok1 := m.vocab[left]
+		rightId, ok2 := m.vocab[right]
+		mergedId, ok3 := m.vocab[left+right]
+		if !ok1 || !ok2 || !ok3 {
+			continue
+		}
+		key := pairKey(leftId, rightId)
+		if _, exists := m.merges[key]; !exists {
+			m.merges[key] = mergeRule{rank: int32(rank), id: mergedId}
+		}
+	}
+	m.memory += int64(len(m.merges)) * mergeEntryOverhead
+	return m, nil
+}
+
+func (m *bpeModel) memorySize() int64 {
+	return m.memory
+}
+
+type bpeSymbol struct {
+	id         int32
+	prev, next int
+}
+
+type bpeCandidate struct {
+	rank        int32
+	left        int
+	leftId      int32
+	rightId     int32
+	mergedId    int32
+	rightOffset int
+}
+
+type bpeQueue []bpeCandidate
+
+func (q bpeQueue) Len() int { return len(q) }
+func (q bpeQueue) Less(i, j int) bool {
+	if q[i].rank != q[j].rank {
+		return q[i].rank < q[j].rank
+	}
+	return q[i].left < q[j].left
+}
+func (q bpeQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *bpeQueue) Push(x any)   { *q = append(*q, x.(bpeCandidate)) }
+func (q *bpeQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+func (m *bpeModel) countWord(word string) int {
+	if m.ignoreMerges {
+		if _, ok := m.vocab[word]; ok {
+			return 1
+		}
+	}
+	symbols := make([]bpeSymbol, 0, utf8.RuneCountInString(word))
+	lastUnk := false
+	for _, r := range word {
+		if id, ok := m.vocab[string(r)]; ok {
+			symbols = append(symbols, bpeSymbol{id: id})
+			lastUnk = false
+			continue
+		}
+		if m.byteFallback {
+			var buf [utf8.UTFMax]byte
+			n := utf8.EncodeRune(buf[:], r)
+			fallback := true
+			for _, b := range buf[:n] {
+				if m.byteIds[b] < 0 {
+					fallback = false
+					break
+				}
+			}
+			if fallback {
+				for _, b := range buf[:n] {
+					symbols = append(symbols, bpeSymbol{id: m.byteIds[b]})
+				}
+				lastUnk = false
+				continue
+			}
+		}
+		if m.unkId >= 0 && !(m.fuseUnk && lastUnk) {
+			symbols = append(symbols, bpeSymbol{id: m.unkId})
+		}
+		lastUnk = m.unkId >= 0
+	}
+	if len(symbols) < 2 {
+		return len(symbols)
+	}
+	for i := range symbols {
+		symbols[i].prev = i - 1
+		symbols[i].next = i + 1
+	}
+	symbols[len(symbols)-1].next = -1
+
+	queue := make(bpeQueue, 0, len(symbols))
+	for i := 0; i < len(symbols)-1; i++ {
+		m.pushCandidate(&queue, symbols, i)
+	}
+
+	count := len(symbols)
+	for queue.Len() > 0 {
+		candidate := heap.Pop(&queue).(bpeCandidate)
+		left := &symbols[candidate.left]
+		// 合并后原来的候选可能已经失效
+		if left.id != candidate.leftId || left.next != candidate.rightOffset {
+			continue
+		}
+		right := &symbols[candidate.rightOffset]
+		if right.id != candidate.rightId {
+			continue
+		}
+		left.id = candidate.mergedId
+		left.next = right.next
+		if right.next >= 0 {
+			symbols[right.next].prev = candidate.left
+		}
+		right.id = -1
+		count--
+		if left.prev >= 0 {
+			m.pushCandidate(&queue, symbols, left.prev)
+		}
+		m.pushCandidate(&queue, symbols, candidate.left)
+	}
+	return count
+}
+
+func (m *bpeModel) pushCandidate(queue *bpeQueue, symbols []bpeSymbol, left int) {
+	right := symbols[left].next
+	if right < 0 {
+		return
+	}
+	if rule, ok := m.merges[pairKey(symbols[left].id, symbols[right].id)]; ok {
+		heap.Push(queue, bpeCandidate{rank: rule.rank, left: left, leftId: symbols[left].id, rightId: symbols[right].id, mergedId: rule.id, rightOffset: right})
+	}
+}
+
+type unigramModel struct {
+	pieces       map[string]float64
+	maxPieceLen  int
+	unkScore     float64
+	byteFallback bool
+	memory       int64
+}
+
+func newUnigram(file *modelFile) (*unigramModel, error) {
+	var vocab [][2]any
+	if err := common.Unmarshal(file.Vocab, &vocab); err != nil {
+		return nil, fmt.Errorf("invalid Unigram vocab: %w", err)
+	}
+	m := &unigramModel{pieces: make(map[string]float64, len(vocab)), byteFallback: file.ByteFallback}
+	minScore := math.Inf(1)
+	for _, entry := range vocab {
+		piece, ok1 := entry[0].(string)
+		score, ok2 := entry[1].(float64)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("invalid Unigram vocab entry: %v", entry)
+		}
+		m.pieces[piece] = score
+		m.maxPieceLen = max(m.maxPieceLen, len(piece))
+		minScore = math.Min(minScore, score)
+		m.memory += int64(len(piece)) + vocabEntryOverhead
+	}
+	if len(m.pieces) == 0 {
+		return nil, fmt.Errorf("empty Unigram vocab")
+	}
+	// 与 tokenizers 一致，未知字符的分数比最低分低 10
+	m.unkScore = minScore - 10
+	return m, nil
+}
+
+func (m *unigramModel) memorySize() int64 {
+	return m.memory
+}
+
+// countWord 使用 Viterbi 求分数最高的切分
+func (m *unigramModel) countWord(word string) int {
+	n := len(word)
+	bestScore := make([]float64, n+1)
+	bestCount := make([]int, n+1)
+	for i := 1; i <= n; i++ {
+		bestScore[i] = math.Inf(-1)
+	}
+	for start := 0; start < n; start++ {
+		if math.IsInf(bestScore[start], -1) || !utf8.RuneStart(word[start]) {
+			continue
+		}
+		_, runeLen := utf8.DecodeRuneInString(word[start:])
+		matchedSingle := false
+		for end := start + 1; end <= n && end-start <= m.maxPieceLen; end++ {
+			if end < n && !utf8.RuneStart(word[end]) {
+				continue
+			}
+			score, ok := m.pieces[word[start:end]]
+			if !ok {
+				continue
+			}
+			if end-start == runeLen {
+				matchedSingle = true
+			}
+			if score += bestScore[start]; score > bestScore[end] {
+				bestScore[end] = score
+				bestCount[end] = bestCount[start] + 1
+			}
+		}
+		if !matchedSingle {
+			// 未知字符按 unk 处理，byte_fallback 时每个字节一个 token
+			tokens := 1
+			if m.byteFallback {
+				tokens = runeLen
+			}
+			end := start + runeLen
+			if score := bestScore[start] + m.unkScore; score > bestScore[end] {
+				bestScore[end] = score
+				bestCount[end] = bestCount[start] + tokens
+			}
+		}
+	}
+	return bestCount[n]
+}
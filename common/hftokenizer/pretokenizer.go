@@ -0,0 +1,338 @@
+package hftokenizer
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/dlclark/regexp2"
+	"golang.org/x/text/unicode/norm"
+)
+
+type normalizer func(text string) string
+
+// preTokenizer 把文本切分为单词，first 表示是否位于文本开头（added_tokens 之后的片段不是开头）
+type preTokenizer func(text string, first bool) []string
+
+const gpt2Pattern = `'s|'t|'re|'ve|'m|'ll|'d| ?\p{L}+| ?\p{N}+| ?[^\s\p{L}\p{N}]+|\s+(?!\S)|\s+`
+
+var (
+	gpt2Regexp       = regexp2.MustCompile(gpt2Pattern, regexp2.None)
+	whitespaceRegexp = regexp2.MustCompile(`\w+|[^\w\s]+`, regexp2.None)
+	byteToRune       = bytesToUnicode()
+)
+
+// bytesToUnicode GPT-2 ByteLevel 使用的字节到可见字符的映射
+func bytesToUnicode() [256]rune {
+	var table [256]rune
+	n := 0
+	for b := 0; b < 256; b++ {
+		if (b >= '!' && b <= '~') || (b >= 0xA1 && b <= 0xAC) || (b >= 0xAE && b <= 0xFF) {
+			table[b] = rune(b)
+		} else {
+			table[b] = rune(256 + n)
+			n++
+		}
+	}
+	return table
+}
+
+func compilePattern(p *pattern) (*regexp2.Regexp, error) {
+	if p == nil {
+		return nil, fmt.Errorf("missing pattern")
+	}
+	if p.Regex != nil {
+		return regexp2.Compile(*p.Regex, regexp2.None)
+	}
+	if p.String != nil {
+		return regexp2.Compile(regexp2.Escape(*p.String), regexp2.None)
+	}
+	return nil, fmt.Errorf("empty pattern")
+}
+
+func newNormalizer(c *component) (normalizer, error) {
+	if c == nil {
+		return nil, nil
+	}
+	switch c.Type {
+	case "Sequence":
+		steps := make([]normalizer, 0, len(c.Normalizers))
+		for _, child := range c.Normalizers {
+			step, err := newNormalizer(child)
+			if err != nil {
+				return nil, err
+			}
+			if step != nil {
+				steps = append(steps, step)
+			}
+		}
+		return func(text string) string {
+			for _, step := range steps {
+				text = step(text)
+			}
+			return text
+		}, nil
+	case "NFC":
+		return norm.NFC.String, nil
+	case "NFD":
+		return norm.NFD.String, nil
+	case "NFKD":
+		return norm.NFKD.String, nil
+	case "NFKC", "Precompiled":
+		// Precompiled 为 SentencePiece 的字符映射表，近似为 NFKC
+		return norm.NFKC.String, nil
+	case "Lowercase":
+		return strings.ToLower, nil
+	case "BertNormalizer":
+		if c.Lowercase != nil && !*c.Lowercase {
+			return nil, nil
+		}
+		return strings.ToLower, nil
+	case "Strip":
+		return func(text string) string {
+			if c.Left {
+				text = strings.TrimLeftFunc(text, unicode.IsSpace)
+			}
+			if c.Right {
+				text = strings.TrimRightFunc(text, unicode.IsSpace)
+			}
+			return text
+		}, nil
+	case "Prepend":
+		return func(text string) string {
+			if text == "" {
+				return text
+			}
+			return c.Prepend + text
+		}, nil
+	case "Replace":
+		re, err := compilePattern(c.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Replace normalizer: %w", err)
+		}
+		content := strings.ReplaceAll(c.Content, "$", "$$")
+		return func(text string) string {
+			replaced, err := re.Replace(text, content, -1, -1)
+			if err != nil {
+				return text
+			}
+			return replaced
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported normalizer type: %s", c.Type)
+}
+
+func newPreTokenizer(c *component) (preTokenizer, error) {
+	if c == nil {
+		return nil, nil
+	}
+	switch c.Type {
+	case "Sequence":
+		steps := make([]preTokenizer, 0, len(c.Pretokenizers))
+		for _, child := range c.Pretokenizers {
+			step, err := newPreTokenizer(child)
+			if err != nil {
+				return nil, err
+			}
+			if step != nil {
+				steps = append(steps, step)
+			}
+		}
+		return func(text string, first bool) []string {
+			words := []string{text}
+			for _, step := range steps {
+				next := make([]string, 0, len(words))
+				for i, word := range words {
+					next = append(next, step(word, first && i == 0)...)
+				}
+				words = next
+			}
+			return words
+		}, nil
+	case "ByteLevel":
+		useRegex := c.UseRegex == nil || *c.UseRegex
+		addPrefixSpace := c.AddPrefixSpace != nil && *c.AddPrefixSpace
+		return func(text string, first bool) []string {
+			if addPrefixSpace && first && !strings.HasPrefix(text, " ") {
+				text = " " + text
+			}
+			words := []string{text}
+			if useRegex {
+				words = splitMatches(gpt2Regexp, text)
+			}
+			for i, word := range words {
+				words[i] = byteLevelEncode(word)
+			}
+			return words
+		}, nil
+	case "Split":
+		re, err := compilePattern(c.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Split pre_tokenizer: %w", err)
+		}
+		behavior, invert := c.Behavior, c.Invert
+		return func(text string, _ bool) []string {
+			return splitWithBehavior(re, text, behavior, invert)
+		}, nil
+	case "Metaspace":
+		replacement := c.Replacement
+		if replacement == "" {
+			replacement = "▁"
+		}
+		scheme := c.PrependScheme
+		if scheme == "" {
+			scheme = "always"
+			if c.AddPrefixSpace != nil && !*c.AddPrefixSpace {
+				scheme = "never"
+			}
+		}
+		split := c.Split == nil || *c.Split
+		return func(text string, first bool) []string {
+			text = strings.ReplaceAll(text, " ", replacement)
+			if (scheme == "always" || (scheme == "first" && first)) && !strings.HasPrefix(text, replacement) {
+				text = replacement + text
+			}
+			if !split {
+				return []string{text}
+			}
+			return splitBefore(text, replacement)
+		}, nil
+	case "Whitespace":
+		return func(text string, _ bool) []string {
+			return splitMatches(whitespaceRegexp, text)
+		}, nil
+	case "WhitespaceSplit":
+		return func(text string, _ bool) []string {
+			return strings.Fields(text)
+		}, nil
+	case "Digits":
+		individual := c.Individual
+		return func(text string, _ bool) []string {
+			return splitRunes(text, unicode.IsDigit, individual)
+		}, nil
+	case "Punctuation":
+		return func(text string, _ bool) []string {
+			return splitRunes(text, unicode.IsPunct, true)
+		}, nil
+	case "BertPreTokenizer":
+		return func(text string, _ bool) []string {
+			var words []string
+			for _, field := range strings.Fields(text) {
+				words = append(words, splitRunes(field, unicode.IsPunct, true)...)
+			}
+			return words
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported pre_tokenizer type: %s", c.Type)
+}
+
+func byteLevelEncode(word string) string {
+	var sb strings.Builder
+	sb.Grow(len(word) * 2)
+	for i := 0; i < len(word); i++ {
+		sb.WriteRune(byteToRune[word[i]])
+	}
+	return sb.String()
+}
+
+// splitMatches 返回所有匹配的片段，未匹配的部分也作为单独的片段保留
+func splitMatches(re *regexp2.Regexp, text string) []string {
+	var words []string
+	for _, segment := range matchSegments(re, text) {
+		words = append(words, segment.text)
+	}
+	return words
+}
+
+type segment struct {
+	text    string
+	isMatch bool
+}
+
+func matchSegments(re *regexp2.Regexp, text string) []segment {
+	var segments []segment
+	runes := []rune(text)
+	last := 0
+	match, _ := re.FindStringMatch(text)
+	for match != nil {
+		// regexp2 的位置以 rune 计
+		if match.Index > last {
+			segments = append(segments, segment{text: string(runes[last:match.Index])})
+		}
+		if match.Length > 0 {
+			segments = append(segments, segment{text: match.String(), isMatch: true})
+		}
+		last = match.Index + match.Length
+		match, _ = re.FindNextMatch(match)
+	}
+	if last < len(runes) {
+		segments = append(segments, segment{text: string(runes[last:])})
+	}
+	return segments
+}
+
+func splitWithBehavior(re *regexp2.Regexp, text string, behavior string, invert bool) []string {
+	segments := matchSegments(re, text)
+	if invert {
+		for i := range segments {
+			segments[i].isMatch = !segments[i].isMatch
+		}
+	}
+	var words []string
+	pendingNext := ""
+	for _, seg := range segments {
+		switch {
+		case !seg.isMatch:
+			words = append(words, pendingNext+seg.text)
+			pendingNext = ""
+		case behavior == "Removed":
+		case behavior == "MergedWithPrevious" && len(words) > 0:
+			words[len(words)-1] += seg.text
+		case behavior == "MergedWithNext":
+			pendingNext += seg.text
+		default:
+			words = append(words, seg.text)
+		}
+	}
+	if pendingNext != "" {
+		words = append(words, pendingNext)
+	}
+	return words
+}
+
+// splitBefore 在每个 sep 之前切分，sep 保留在后一个片段的开头
+func splitBefore(text string, sep string) []string {
+	var words []string
+	for len(text) > len(sep) {
+		idx := strings.Index(text[len(sep):], sep)
+		if idx < 0 {
+			break
+		}
+		idx += len(sep)
+		words = append(words, text[:idx])
+		text = text[idx:]
+	}
+	if text != "" {
+		words = append(words, text)
+	}
+	return words
+}
+
+// splitRunes 把满足 isolate 的字符切分出来，individual 为 false 时连续的字符作为一个片段
+func splitRunes(text string, isolate func(rune) bool, individual bool) []string {
+	var words []string
+	start := 0
+	prevIsolated := false
+	for i, r := range text {
+		isolated := isolate(r)
+		if i > start && (isolated != prevIsolated || (isolated && individual)) {
+			words = append(words, text[start:i])
+			start = i
+		}
+		prevIsolated = isolated
+	}
+	if start < len(text) {
+		words = append(words, text[start:])
+	}
+	return words
+}
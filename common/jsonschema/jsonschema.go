@@ -0,0 +1,350 @@
+// Package jsonschema 实现结构化输出校验所需的 JSON Schema 子集：
+// type、enum、const、properties、required、additionalProperties、items、prefixItems、
+// anyOf、oneOf、allOf、not、长度与数值范围、pattern 以及指向 #/$defs、#/definitions 的 $ref
+package jsonschema
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"one-api/common"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ValidationError 校验失败的位置与原因
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// ValidateJSON 解析 JSON 文本并按 schema 校验
+func ValidateJSON(schema any, data []byte) error {
+	var value any
+	if err := common.Unmarshal(data, &value); err != nil {
+		return &ValidationError{Message: "invalid json: " + err.Error()}
+	}
+	return Validate(schema, value)
+}
+
+// Validate 按 schema 校验已解析的 JSON 值，schema 为 nil 或 true 时总是通过
+func Validate(schema any, value any) error {
+	v := &validator{root: schema}
+	return v.validate(schema, value, "$", 0)
+}
+
+type validator struct {
+	root any
+}
+
+const maxRefDepth = 64
+
+func (v *validator) fail(path string, format string, args ...any) error {
+	return &ValidationError{Path: path, Message: fmt.Sprintf(format, args...)}
+}
+
+func (v *validator) resolveRef(ref string) (any, error) {
+	if ref == "#" {
+		return v.root, nil
+	}
+	pointer, ok := strings.CutPrefix(ref, "#/")
+	if !ok {
+		return nil, fmt.Errorf("unsupported $ref: %s", ref)
+	}
+	current := v.root
+	for _, token := range strings.Split(pointer, "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unresolvable $ref: %s", ref)
+		}
+		if current, ok = m[token]; !ok {
+			return nil, fmt.Errorf("unresolvable $ref: %s", ref)
+		}
+	}
+	return current, nil
+}
+
+func (v *validator) validate(schema any, value any, path string, depth int) error {
+	if depth > maxRefDepth {
+		return v.fail(path, "schema nesting too deep")
+	}
+	switch s := schema.(type) {
+	case nil:
+		return nil
+	case bool:
+		if !s {
+			return v.fail(path, "value is not allowed")
+		}
+		return nil
+	case map[string]any:
+		return v.validateObjectSchema(s, value, path, depth)
+	default:
+		return errors.New("invalid schema")
+	}
+}
+
+func (v *validator) validateObjectSchema(s map[string]any, value any, path string, depth int) error {
+	if ref, ok := s["$ref"].(string); ok {
+		target, err := v.resolveRef(ref)
+		if err != nil {
+			return v.fail(path, "%s", err.Error())
+		}
+		if err = v.validate(target, value, path, depth+1); err != nil {
+			return err
+		}
+	}
+	if t, ok := s["type"]; ok {
+		if err := v.validateType(t, value, path); err != nil {
+			return err
+		}
+	}
+	if enum, ok := s["enum"].([]any); ok {
+		matched := false
+		for _, candidate := range enum {
+			if jsonEqual(candidate, value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return v.fail(path, "value is not one of the allowed values")
+		}
+	}
+	if constant, ok := s["const"]; ok && !jsonEqual(constant, value) {
+		return v.fail(path, "value does not match const")
+	}
+	if err := v.validateCombinators(s, value, path, depth); err != nil {
+		return err
+	}
+	switch val := value.(type) {
+	case map[string]any:
+		return v.validateObject(s, val, path, depth)
+	case []any:
+		return v.validateArray(s, val, path, depth)
+	case string:
+		return v.validateString(s, val, path)
+	case float64:
+		return v.validateNumber(s, val, path)
+	}
+	return nil
+}
+
+func (v *validator) validateType(t any, value any, path string) error {
+	var types []string
+	switch tv := t.(type) {
+	case string:
+		types = []string{tv}
+	case []any:
+		for _, item := range tv {
+			if name, ok := item.(string); ok {
+				types = append(types, name)
+			}
+		}
+	}
+	actual := typeOf(value)
+	for _, name := range types {
+		if name == actual || (name == "number" && actual == "integer") {
+			return nil
+		}
+	}
+	return v.fail(path, "expected %s, got %s", strings.Join(types, " or "), actual)
+}
+
+func (v *validator) validateCombinators(s map[string]any, value any, path string, depth int) error {
+	if allOf, ok := s["allOf"].([]any); ok {
+		for _, sub := range allOf {
+			if err := v.validate(sub, value, path, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	if anyOf, ok := s["anyOf"].([]any); ok {
+		var firstErr error
+		matched := false
+		for _, sub := range anyOf {
+			err := v.validate(sub, value, path, depth+1)
+			if err == nil {
+				matched = true
+				break
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		if !matched {
+			return v.fail(path, "value does not match any schema in anyOf (%v)", firstErr)
+		}
+	}
+	if oneOf, ok := s["oneOf"].([]any); ok {
+		count := 0
+		for _, sub := range oneOf {
+			if v.validate(sub, value, path, depth+1) == nil {
+				count++
+			}
+		}
+		if count != 1 {
+			return v.fail(path, "value must match exactly one schema in oneOf, matched %d", count)
+		}
+	}
+	if not, ok := s["not"]; ok && v.validate(not, value, path, depth+1) == nil {
+		return v.fail(path, "value must not match schema in not")
+	}
+	return nil
+}
+
+func (v *validator) validateObject(s map[string]any, obj map[string]any, path string, depth int) error {
+	if required, ok := s["required"].([]any); ok {
+		for _, item := range required {
+			name, _ := item.(string)
+			if _, exists := obj[name]; !exists {
+				return v.fail(path, "missing required property %q", name)
+			}
+		}
+	}
+	properties, _ := s["properties"].(map[string]any)
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		childPath := path + "." + key
+		if propSchema, ok := properties[key]; ok {
+			if err := v.validate(propSchema, obj[key], childPath, depth+1); err != nil {
+				return err
+			}
+			continue
+		}
+		if additional, ok := s["additionalProperties"]; ok {
+			if allowed, isBool := additional.(bool); isBool && !allowed {
+				return v.fail(path, "additional property %q is not allowed", key)
+			}
+			if err := v.validate(additional, obj[key], childPath, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	if min, ok := number(s["minProperties"]); ok && float64(len(obj)) < min {
+		return v.fail(path, "expected at least %v properties", min)
+	}
+	if max, ok := number(s["maxProperties"]); ok && float64(len(obj)) > max {
+		return v.fail(path, "expected at most %v properties", max)
+	}
+	return nil
+}
+
+func (v *validator) validateArray(s map[string]any, arr []any, path string, depth int) error {
+	if min, ok := number(s["minItems"]); ok && float64(len(arr)) < min {
+		return v.fail(path, "expected at least %v items", min)
+	}
+	if max, ok := number(s["maxItems"]); ok && float64(len(arr)) > max {
+		return v.fail(path, "expected at most %v items", max)
+	}
+	prefixItems, _ := s["prefixItems"].([]any)
+	for i, item := range arr {
+		childPath := fmt.Sprintf("%s[%d]", path, i)
+		if i < len(prefixItems) {
+			if err := v.validate(prefixItems[i], item, childPath, depth+1); err != nil {
+				return err
+			}
+			continue
+		}
+		if items, ok := s["items"]; ok {
+			if err := v.validate(items, item, childPath, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	if unique, _ := s["uniqueItems"].(bool); unique {
+		for i := range arr {
+			for j := i + 1; j < len(arr); j++ {
+				if jsonEqual(arr[i], arr[j]) {
+					return v.fail(path, "items must be unique")
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (v *validator) validateString(s map[string]any, str string, path string) error {
+	length := float64(len([]rune(str)))
+	if min, ok := number(s["minLength"]); ok && length < min {
+		return v.fail(path, "expected at least %v characters", min)
+	}
+	if max, ok := number(s["maxLength"]); ok && length > max {
+		return v.fail(path, "expected at most %v characters", max)
+	}
+	if pattern, ok := s["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return v.fail(path, "invalid pattern %q", pattern)
+		}
+		if !re.MatchString(str) {
+			return v.fail(path, "value does not match pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+func (v *validator) validateNumber(s map[string]any, n float64, path string) error {
+	if min, ok := number(s["minimum"]); ok && n < min {
+		return v.fail(path, "expected >= %v", min)
+	}
+	if max, ok := number(s["maximum"]); ok && n > max {
+		return v.fail(path, "expected <= %v", max)
+	}
+	if min, ok := number(s["exclusiveMinimum"]); ok && n <= min {
+		return v.fail(path, "expected > %v", min)
+	}
+	if max, ok := number(s["exclusiveMaximum"]); ok && n >= max {
+		return v.fail(path, "expected < %v", max)
+	}
+	if multiple, ok := number(s["multipleOf"]); ok && multiple > 0 {
+		if q := n / multiple; math.Abs(q-math.Round(q)) > 1e-9 {
+			return v.fail(path, "expected a multiple of %v", multiple)
+		}
+	}
+	return nil
+}
+
+func number(value any) (float64, bool) {
+	n, ok := value.(float64)
+	return n, ok
+}
+
+func typeOf(value any) string {
+	switch val := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if val == math.Trunc(val) && !math.IsInf(val, 0) {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func jsonEqual(a, b any) bool {
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,87 @@
+package jsonschema
+
+import (
+	"one-api/common"
+	"testing"
+)
+
+const weatherSchema = `{
+	"type": "object",
+	"properties": {
+		"city": {"type": "string", "minLength": 1},
+		"unit": {"enum": ["c", "f"]},
+		"days": {"type": "integer", "minimum": 1, "maximum": 7},
+		"tags": {"type": "array", "items": {"$ref": "#/$defs/tag"}}
+	},
+	"required": ["city", "unit"],
+	"additionalProperties": false,
+	"$defs": {"tag": {"type": "string", "pattern": "^[a-z]+$"}}
+}`
+
+func TestValidateJSON(t *testing.T) {
+	var schema any
+	if err := common.Unmarshal([]byte(weatherSchema), &schema); err != nil {
+		t.Fatal(err)
+	}
+	valid := []string{
+		`{"city":"Paris","unit":"c"}`,
+		`{"city":"Paris","unit":"f","days":3,"tags":["rain","wind"]}`,
+	}
+	for _, data := range valid {
+		if err := ValidateJSON(schema, []byte(data)); err != nil {
+			t.Errorf("expected %s to be valid: %v", data, err)
+		}
+	}
+	invalid := []string{
+		`{"city":"Paris"}`,
+		`{"city":"","unit":"c"}`,
+		`{"city":"Paris","unit":"k"}`,
+		`{"city":"Paris","unit":"c","days":1.5}`,
+		`{"city":"Paris","unit":"c","days":9}`,
+		`{"city":"Paris","unit":"c","tags":["Rain"]}`,
+		`{"city":"Paris","unit":"c","extra":true}`,
+		`["Paris"]`,
+		`{"city":"Paris",`,
+	}
+	for _, data := range invalid {
+		if err := ValidateJSON(schema, []byte(data)); err == nil {
+			t.Errorf("expected %s to be invalid", data)
+		}
+	}
+}
+
+func TestValidateCombinators(t *testing.T) {
+	var schema any
+	_ = common.Unmarshal([]byte(`{"anyOf":[{"type":"string"},{"type":"null"}],"not":{"const":"x"}}`), &schema)
+	if err := Validate(schema, nil); err != nil {
+		t.Errorf("expected null to match anyOf: %v", err)
+	}
+	if err := Validate(schema, "x"); err == nil {
+		t.Error("expected const x to be rejected by not")
+	}
+	if err := Validate(schema, 1.0); err == nil {
+		t.Error("expected number to be rejected by anyOf")
+	}
+}
+
+func TestRepair(t *testing.T) {
+	cases := map[string]string{
+		"```json\n{\"a\": 1}\n```":            `{"a": 1}`,
+		"Here you go: {\"a\": [1, 2]} Enjoy!": `{"a": [1, 2]}`,
+		`{"a": [1, 2,], "b": {"c": 3,},}`:     `{"a": [1, 2], "b": {"c": 3}}`,
+		`{"a": "trunc`:                        `{"a": "trunc"}`,
+		`{"a": {"b": [1, 2`:                   `{"a": {"b": [1, 2]}}`,
+	}
+	for input, expected := range cases {
+		repaired, ok := Repair(input)
+		if !ok || repaired != expected {
+			t.Errorf("Repair(%q) = %q, %v; want %q", input, repaired, ok, expected)
+		}
+	}
+	// 截断在键之后时无法得到合法 JSON
+	for _, input := range []string{"no json here", `{"a": 1, "b":`} {
+		if repaired, ok := Repair(input); ok {
+			t.Errorf("expected %q to be unrepairable, got %q", input, repaired)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Repair 尝试修复模型输出中常见的 JSON 格式问题：Markdown 代码块、JSON 前后的说明文字、
+// 对象或数组末尾多余的逗号以及因截断而未闭合的字符串和括号。无法修复为合法 JSON 时返回 false
+func Repair(text string) (string, bool) {
+	candidate := strings.TrimSpace(text)
+	if json.Valid([]byte(candidate)) {
+		return candidate, true
+	}
+	candidate = stripCodeFence(candidate)
+	if start := strings.IndexAny(candidate, "{["); start >= 0 {
+		candidate = candidate[start:]
+	} else {
+		return "", false
+	}
+	if end := strings.LastIndexAny(candidate, "}]"); end >= 0 && json.Valid([]byte(candidate[:end+1])) {
+		return candidate[:end+1], true
+	}
+	repaired := closeJSON(removeTrailingCommas(candidate))
+	if json.Valid([]byte(repaired)) {
+		return repaired, true
+	}
+	return "", false
+}
+
+func stripCodeFence(text string) string {
+	start := strings.Index(text, "```")
+	if start < 0 {
+		return text
+	}
+	body := text[start+3:]
+	// 跳过代码块语言标记，例如 ```json
+	if newline := strings.IndexByte(body, '\n'); newline >= 0 && !strings.ContainsAny(body[:newline], "{[") {
+		body = body[newline+1:]
+	}
+	if end := strings.Index(body, "```"); end >= 0 {
+		body = body[:end]
+	}
+	return strings.TrimSpace(body)
+}
+
+// removeTrailingCommas 删除字符串之外、紧跟在 } 或 ] 之前的逗号
+func removeTrailingCommas(text string) string {
+	var sb strings.Builder
+	inString, escaped := false, false
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+		if inString {
+			sb.WriteByte(ch)
+			switch {
+			case escaped:
+				escaped = false
+			case ch == '\\':
+				escaped = true
+			case ch == '"':
+				inString = false
+			}
+			continue
+		}
+		if ch == '"' {
+			inString = true
+		}
+		if ch == ',' {
+			j := i + 1
+			for j < len(text) && strings.IndexByte(" \t\r\n", text[j]) >= 0 {
+				j++
+			}
+			if j < len(text) && (text[j] == '}' || text[j] == ']') {
+				continue
+			}
+		}
+		sb.WriteByte(ch)
+	}
+	return sb.String()
+}
+
+// closeJSON 为截断的 JSON 补全未闭合的字符串与括号，并去掉末尾悬空的逗号或冒号
+func closeJSON(text string) string {
+	var stack []byte
+	inString, escaped := false, false
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case ch == '\\':
+				escaped = true
+			case ch == '"':
+				inString = false
+			}
+			continue
+		}
+		switch ch {
+		case '"':
+			inString = true
+		case '{':
+			stack = append(stack, '}')
+		case '[':
+			stack = append(stack, ']')
+		case '}', ']':
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	result := text
+	if inString {
+		result = strings.TrimSuffix(result, "\\") + "\""
+	}
+	result = strings.TrimRight(result, " \t\r\n")
+	result = strings.TrimRight(result, ",:")
+	for i := len(stack) - 1; i >= 0; i-- {
+		result += string(stack[i])
+	}
+	return result
+}
@@ -0,0 +1,152 @@
+package logsink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FileDir 文件投递目标只允许写入该目录下的文件，相对路径基于该目录解析。
+// 可通过 LOG_SINK_FILE_DIR 配置
+var FileDir = "./logs/sinks"
+
+func init() {
+	if dir := strings.TrimSpace(os.Getenv("LOG_SINK_FILE_DIR")); dir != "" {
+		FileDir = dir
+	}
+	Register("file", newFileSink)
+}
+
+// filePath 解析文件投递目标的路径，并检查是否位于 FileDir 之内
+func filePath(path string) (string, error) {
+	if path == "" {
+		return "", errors.New("path is required")
+	}
+	dir, err := filepath.Abs(FileDir)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	path = filepath.Clean(path)
+	if rel, err := filepath.Rel(dir, path); err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path must be a file under %s", FileDir)
+	}
+	return path, nil
+}
+
+// fileSink 将日志写入按大小滚动的 NDJSON 文件
+// 滚动后的文件依次命名为 path.1、path.2 ...，数字越大越旧
+type fileSink struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu     sync.Mutex
+	file   *os.File // 为 nil 且未关闭时在下次写入前重新打开
+	size   int64
+	closed bool
+}
+
+func newFileSink(cfg Config) (Sink, error) {
+	path, err := filePath(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxSizeMB <= 0 {
+		cfg.MaxSizeMB = 100
+	}
+	if cfg.MaxBackups <= 0 {
+		cfg.MaxBackups = 10
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	s := &fileSink{
+		path:       path,
+		maxSize:    int64(cfg.MaxSizeMB) * 1024 * 1024,
+		maxBackups: cfg.MaxBackups,
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *fileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	s.file = file
+	s.size = info.Size()
+	return nil
+}
+
+// rotate 滚动当前文件，任一步骤失败时 s.file 为 nil，下次写入时重新打开
+func (s *fileSink) rotate() error {
+	err := s.file.Close()
+	s.file = nil
+	if err != nil {
+		return err
+	}
+	_ = os.Remove(fmt.Sprintf("%s.%d", s.path, s.maxBackups))
+	for i := s.maxBackups - 1; i >= 1; i-- {
+		_ = os.Rename(fmt.Sprintf("%s.%d", s.path, i), fmt.Sprintf("%s.%d", s.path, i+1))
+	}
+	if err := os.Rename(s.path, s.path+".1"); err != nil {
+		return err
+	}
+	return s.open()
+}
+
+func (s *fileSink) Write(_ context.Context, records [][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errors.New("file sink is closed")
+	}
+	for _, record := range records {
+		// record 可能被多个投递目标共享，不能原地追加
+		line := make([]byte, 0, len(record)+1)
+		line = append(append(line, record...), '\n')
+		if s.file == nil {
+			if err := s.open(); err != nil {
+				return err
+			}
+		}
+		if s.size > 0 && s.size+int64(len(line)) > s.maxSize {
+			if err := s.rotate(); err != nil {
+				return err
+			}
+		}
+		n, err := s.file.Write(line)
+		s.size += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *fileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
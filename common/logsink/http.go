@@ -0,0 +1,149 @@
+package logsink
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+func init() {
+	Register("http", newHTTPSink)
+	Register("kafka", newKafkaSink)
+	Register("clickhouse", newClickHouseSink)
+}
+
+// httpSink 以 NDJSON 格式批量 POST 到指定地址
+// kafka、clickhouse 也基于它实现，只是请求地址与请求体格式不同
+type httpSink struct {
+	client      *http.Client
+	url         string
+	contentType string
+	headers     map[string]string
+	username    string
+	password    string
+	encode      func(records [][]byte) []byte
+}
+
+func newHTTPClient(cfg Config) *http.Client {
+	return &http.Client{Timeout: time.Duration(cfg.TimeoutSeconds) * time.Second}
+}
+
+func encodeNDJSON(records [][]byte) []byte {
+	var buf bytes.Buffer
+	for _, record := range records {
+		buf.Write(record)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+func newHTTPSink(cfg Config) (Sink, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("url is required")
+	}
+	return &httpSink{
+		client:      newHTTPClient(cfg),
+		url:         cfg.URL,
+		contentType: "application/x-ndjson",
+		headers:     cfg.Headers,
+		username:    cfg.Username,
+		password:    cfg.Password,
+		encode:      encodeNDJSON,
+	}, nil
+}
+
+// newKafkaSink 通过 Kafka REST Proxy（v2 JSON 格式）写入指定 topic
+func newKafkaSink(cfg Config) (Sink, error) {
+	if cfg.URL == "" || cfg.Topic == "" {
+		return nil, errors.New("url and topic are required")
+	}
+	return &httpSink{
+		client:      newHTTPClient(cfg),
+		url:         strings.TrimSuffix(cfg.URL, "/") + "/topics/" + url.PathEscape(cfg.Topic),
+		contentType: "application/vnd.kafka.json.v2+json",
+		headers:     cfg.Headers,
+		username:    cfg.Username,
+		password:    cfg.Password,
+		encode: func(records [][]byte) []byte {
+			var buf bytes.Buffer
+			buf.WriteString(`{"records":[`)
+			for i, record := range records {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				buf.WriteString(`{"value":`)
+				buf.Write(record)
+				buf.WriteByte('}')
+			}
+			buf.WriteString(`]}`)
+			return buf.Bytes()
+		},
+	}, nil
+}
+
+// newClickHouseSink 通过 ClickHouse HTTP 接口以 JSONEachRow 格式写入
+// 未知字段会被忽略，因此表结构只需包含关心的列
+func newClickHouseSink(cfg Config) (Sink, error) {
+	if cfg.URL == "" || cfg.Table == "" {
+		return nil, errors.New("url and table are required")
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	query := u.Query()
+	query.Set("query", fmt.Sprintf("INSERT INTO %s FORMAT JSONEachRow", cfg.Table))
+	query.Set("input_format_skip_unknown_fields", "1")
+	u.RawQuery = query.Encode()
+	headers := make(map[string]string, len(cfg.Headers)+2)
+	for k, v := range cfg.Headers {
+		headers[k] = v
+	}
+	if cfg.Username != "" {
+		headers["X-ClickHouse-User"] = cfg.Username
+		headers["X-ClickHouse-Key"] = cfg.Password
+	}
+	return &httpSink{
+		client:      newHTTPClient(cfg),
+		url:         u.String(),
+		contentType: "application/x-ndjson",
+		headers:     headers,
+		encode:      encodeNDJSON,
+	}, nil
+}
+
+func (s *httpSink) Write(ctx context.Context, records [][]byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(s.encode(records)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", s.contentType)
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	if s.username != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+func (s *httpSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
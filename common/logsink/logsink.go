@@ -0,0 +1,211 @@
+package logsink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"one-api/common"
+	"sync"
+)
+
+// Config 单个日志投递目标的配置
+type Config struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"` // file, http, kafka, clickhouse
+	Enabled bool   `json:"enabled"`
+	// LogTypes 需要投递的日志类型，为空表示全部
+	LogTypes []int `json:"log_types"`
+
+	BufferSize      int `json:"buffer_size"`       // 缓冲区容量，满后丢弃新日志，默认 10000
+	BatchSize       int `json:"batch_size"`        // 单批最大条数，默认 500
+	FlushIntervalMs int `json:"flush_interval_ms"` // 最长攒批时间，默认 1000
+	MaxRetries      int `json:"max_retries"`       // 写入失败重试次数，默认 3
+	TimeoutSeconds  int `json:"timeout_seconds"`   // 单次写入超时，默认 10
+
+	// file
+	Path       string `json:"path"`
+	MaxSizeMB  int    `json:"max_size_mb"`
+	MaxBackups int    `json:"max_backups"`
+
+	// http / kafka / clickhouse
+	URL      string            `json:"url"`
+	Headers  map[string]string `json:"headers"`
+	Topic    string            `json:"topic"`
+	Table    string            `json:"table"`
+	Username string            `json:"username"`
+	Password string            `json:"password"`
+}
+
+func (c *Config) setDefaults() {
+	if c.BufferSize <= 0 {
+		c.BufferSize = 10000
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 500
+	}
+	if c.FlushIntervalMs <= 0 {
+		c.FlushIntervalMs = 1000
+	}
+	if c.MaxRetries < 0 {
+		c.MaxRetries = 0
+	} else if c.MaxRetries == 0 {
+		c.MaxRetries = 3
+	}
+	if c.TimeoutSeconds <= 0 {
+		c.TimeoutSeconds = 10
+	}
+	if c.Name == "" {
+		c.Name = c.Type
+	}
+}
+
+func (c *Config) accepts(logType int) bool {
+	if len(c.LogTypes) == 0 {
+		return true
+	}
+	for _, t := range c.LogTypes {
+		if t == logType {
+			return true
+		}
+	}
+	return false
+}
+
+// Sink 日志投递目标，Write 接收一批已编码为 JSON 的日志
+type Sink interface {
+	Write(ctx context.Context, records [][]byte) error
+	Close() error
+}
+
+type Factory func(cfg Config) (Sink, error)
+
+var factories = map[string]Factory{}
+
+// Register 注册一种投递目标类型
+func Register(sinkType string, factory Factory) {
+	factories[sinkType] = factory
+}
+
+// Validate 校验投递目标配置，用于保存配置前提示错误
+func Validate(configs []Config) error {
+	for _, cfg := range configs {
+		if _, ok := factories[cfg.Type]; !ok {
+			return fmt.Errorf("log sink %s: unknown type %s", cfg.Name, cfg.Type)
+		}
+		if cfg.Type == "file" {
+			if _, err := filePath(cfg.Path); err != nil {
+				return fmt.Errorf("log sink %s: %w", cfg.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+var (
+	mu          sync.RWMutex
+	pipelines   []*pipeline
+	fingerprint string
+)
+
+// Apply 按配置重建投递管道，配置未变化时不做任何操作
+// 旧管道会在后台排空后关闭
+func Apply(configs []Config) {
+	data, _ := json.Marshal(configs)
+	mu.Lock()
+	if string(data) == fingerprint {
+		mu.Unlock()
+		return
+	}
+	fingerprint = string(data)
+	var created []*pipeline
+	for _, cfg := range configs {
+		if !cfg.Enabled {
+			continue
+		}
+		cfg.setDefaults()
+		factory, ok := factories[cfg.Type]
+		if !ok {
+			common.SysError(fmt.Sprintf("log sink %s: unknown type %s", cfg.Name, cfg.Type))
+			continue
+		}
+		sink, err := factory(cfg)
+		if err != nil {
+			common.SysError(fmt.Sprintf("log sink %s: %s", cfg.Name, err.Error()))
+			continue
+		}
+		created = append(created, newPipeline(cfg, sink))
+	}
+	old := pipelines
+	pipelines = created
+	mu.Unlock()
+
+	for _, p := range old {
+		go p.close()
+	}
+	if len(created) > 0 || len(old) > 0 {
+		common.SysLog(fmt.Sprintf("log sinks reloaded, %d active", len(created)))
+	}
+}
+
+// Active 是否存在启用的投递目标
+func Active() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return len(pipelines) > 0
+}
+
+// Dispatch 将日志放入各投递目标的缓冲区，不会阻塞调用方
+func Dispatch(logType int, record any) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if len(pipelines) == 0 {
+		return
+	}
+	var data []byte
+	for _, p := range pipelines {
+		if !p.cfg.accepts(logType) {
+			continue
+		}
+		if data == nil {
+			var err error
+			data, err = common.Marshal(record)
+			if err != nil {
+				common.SysError("log sink: failed to encode record: " + err.Error())
+				return
+			}
+		}
+		p.enqueue(data)
+	}
+}
+
+// Close 排空并关闭所有投递目标
+func Close() {
+	mu.Lock()
+	old := pipelines
+	pipelines = nil
+	fingerprint = ""
+	mu.Unlock()
+	for _, p := range old {
+		p.close()
+	}
+}
+
+type Stat struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Queued  int    `json:"queued"`
+	Written int64  `json:"written"`
+	Dropped int64  `json:"dropped"`
+	Failed  int64  `json:"failed"`
+}
+
+// Stats 返回各投递目标的运行状态
+func Stats() []Stat {
+	mu.RLock()
+	defer mu.RUnlock()
+	stats := make([]Stat, 0, len(pipelines))
+	for _, p := range pipelines {
+		stats = append(stats, p.stat())
+	}
+	return stats
+}
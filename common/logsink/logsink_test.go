@@ -0,0 +1,250 @@
+package logsink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func setTestFileDir(t *testing.T) string {
+	t.Helper()
+	old := FileDir
+	FileDir = t.TempDir()
+	t.Cleanup(func() { FileDir = old })
+	return FileDir
+}
+
+func TestFileSinkRotate(t *testing.T) {
+	path := filepath.Join(setTestFileDir(t), "logs", "consume.ndjson")
+	sink, err := newFileSink(Config{Path: path, MaxSizeMB: 1, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := sink.(*fileSink)
+	fs.maxSize = 64 // 便于测试滚动
+
+	record := []byte(`{"id":1,"content":"0123456789"}`)
+	for i := 0; i < 10; i++ {
+		if err := sink.Write(context.Background(), [][]byte{record}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("expected %s to exist: %v", name, err)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			if !json.Valid([]byte(line)) {
+				t.Errorf("invalid json line in %s: %q", name, line)
+			}
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected at most 2 backups")
+	}
+}
+
+func TestFileSinkReopenAfterRotateFailure(t *testing.T) {
+	path := filepath.Join(setTestFileDir(t), "consume.ndjson")
+	sink, err := newFileSink(Config{Path: path, MaxSizeMB: 1, MaxBackups: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	fs := sink.(*fileSink)
+	fs.maxSize = 64
+
+	record := []byte(`{"id":1,"content":"0123456789012345678901234567890123456789"}`)
+	if err = sink.Write(context.Background(), [][]byte{record}); err != nil {
+		t.Fatal(err)
+	}
+	// 以非空目录占用备份文件名，使滚动时的重命名失败
+	if err = os.MkdirAll(filepath.Join(path+".1", "busy"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = sink.Write(context.Background(), [][]byte{record}); err == nil {
+		t.Fatal("expected rotate failure")
+	}
+	if fs.file != nil {
+		t.Fatal("expected file to be released after rotate failure")
+	}
+	if err = os.RemoveAll(path + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err = sink.Write(context.Background(), [][]byte{record}); err != nil {
+		t.Fatalf("expected sink to recover, got %v", err)
+	}
+	if _, err = os.Stat(path + ".1"); err != nil {
+		t.Errorf("expected rotated backup: %v", err)
+	}
+}
+
+func TestFileSinkPathRestricted(t *testing.T) {
+	dir := setTestFileDir(t)
+	for _, path := range []string{"", ".", "../escape.ndjson", "/etc/new-api.ndjson", filepath.Join(dir, "..", "x.ndjson")} {
+		if _, err := newFileSink(Config{Path: path}); err == nil {
+			t.Errorf("expected path %q to be rejected", path)
+		}
+	}
+	got, err := filePath("sub/consume.ndjson")
+	if err != nil || got != filepath.Join(dir, "sub", "consume.ndjson") {
+		t.Errorf("relative path resolved to %q, %v", got, err)
+	}
+	if err = Validate([]Config{{Name: "audit", Type: "file", Path: "../x"}}); err == nil {
+		t.Error("expected Validate to reject file sink outside FileDir")
+	}
+}
+
+type recordingServer struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   []string
+}
+
+func (s *recordingServer) handler(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.mu.Lock()
+	s.requests = append(s.requests, r)
+	s.bodies = append(s.bodies, string(body))
+	s.mu.Unlock()
+}
+
+func TestHTTPSinks(t *testing.T) {
+	rs := &recordingServer{}
+	server := httptest.NewServer(http.HandlerFunc(rs.handler))
+	defer server.Close()
+
+	records := [][]byte{[]byte(`{"id":1}`), []byte(`{"id":2}`)}
+	tests := []struct {
+		name        string
+		cfg         Config
+		factory     Factory
+		path        string
+		contentType string
+		body        string
+	}{
+		{
+			name:        "http",
+			cfg:         Config{URL: server.URL + "/bulk"},
+			factory:     newHTTPSink,
+			path:        "/bulk",
+			contentType: "application/x-ndjson",
+			body:        "{\"id\":1}\n{\"id\":2}\n",
+		},
+		{
+			name:        "kafka",
+			cfg:         Config{URL: server.URL, Topic: "new-api-logs"},
+			factory:     newKafkaSink,
+			path:        "/topics/new-api-logs",
+			contentType: "application/vnd.kafka.json.v2+json",
+			body:        `{"records":[{"value":{"id":1}},{"value":{"id":2}}]}`,
+		},
+		{
+			name:        "clickhouse",
+			cfg:         Config{URL: server.URL, Table: "logs", Username: "default", Password: "secret"},
+			factory:     newClickHouseSink,
+			path:        "/",
+			contentType: "application/x-ndjson",
+			body:        "{\"id\":1}\n{\"id\":2}\n",
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.setDefaults()
+			sink, err := tt.factory(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := sink.Write(context.Background(), records); err != nil {
+				t.Fatal(err)
+			}
+			req := rs.requests[i]
+			if req.URL.Path != tt.path {
+				t.Errorf("path = %s, want %s", req.URL.Path, tt.path)
+			}
+			if got := req.Header.Get("Content-Type"); got != tt.contentType {
+				t.Errorf("content type = %s, want %s", got, tt.contentType)
+			}
+			if rs.bodies[i] != tt.body {
+				t.Errorf("body = %q, want %q", rs.bodies[i], tt.body)
+			}
+		})
+	}
+	if q := rs.requests[2].URL.Query().Get("query"); q != "INSERT INTO logs FORMAT JSONEachRow" {
+		t.Errorf("clickhouse query = %q", q)
+	}
+	if rs.requests[2].Header.Get("X-ClickHouse-User") != "default" {
+		t.Errorf("clickhouse user header missing")
+	}
+}
+
+type blockingSink struct {
+	release chan struct{}
+	mu      sync.Mutex
+	count   int
+	fail    bool
+}
+
+func (s *blockingSink) Write(_ context.Context, records [][]byte) error {
+	<-s.release
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail {
+		return errors.New("unavailable")
+	}
+	s.count += len(records)
+	return nil
+}
+
+func (s *blockingSink) Close() error { return nil }
+
+func TestPipelineDropsWhenFull(t *testing.T) {
+	sink := &blockingSink{release: make(chan struct{})}
+	cfg := Config{Name: "test", BufferSize: 2, BatchSize: 1, FlushIntervalMs: 10}
+	cfg.setDefaults()
+	p := newPipeline(cfg, sink)
+
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		p.enqueue([]byte(`{}`))
+	}
+	if time.Since(start) > 100*time.Millisecond {
+		t.Fatalf("enqueue should never block")
+	}
+	if p.dropped.Load() == 0 {
+		t.Errorf("expected records to be dropped when buffer is full")
+	}
+
+	close(sink.release)
+	p.close()
+	if got := int64(sink.count) + p.dropped.Load(); got != 100 {
+		t.Errorf("written + dropped = %d, want 100", got)
+	}
+}
+
+func TestPipelineRetryAndFail(t *testing.T) {
+	sink := &blockingSink{release: make(chan struct{}), fail: true}
+	close(sink.release)
+	cfg := Config{Name: "test", BatchSize: 10, FlushIntervalMs: 10, MaxRetries: -1}
+	cfg.setDefaults()
+	p := newPipeline(cfg, sink)
+	p.enqueue([]byte(`{}`))
+	p.close()
+	if p.failed.Load() != 1 {
+		t.Errorf("failed = %d, want 1", p.failed.Load())
+	}
+}
@@ -0,0 +1,130 @@
+package logsink
+
+import (
+	"context"
+	"fmt"
+	"one-api/common"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// pipeline 为单个投递目标提供有界缓冲、攒批与重试
+// 缓冲区满时直接丢弃新日志，保证写日志不会拖慢请求
+type pipeline struct {
+	cfg   Config
+	sink  Sink
+	queue chan []byte
+	quit  chan struct{}
+	wg    sync.WaitGroup
+	once  sync.Once
+
+	written     atomic.Int64
+	dropped     atomic.Int64
+	failed      atomic.Int64
+	lastDropLog atomic.Int64
+}
+
+func newPipeline(cfg Config, sink Sink) *pipeline {
+	p := &pipeline{
+		cfg:   cfg,
+		sink:  sink,
+		queue: make(chan []byte, cfg.BufferSize),
+		quit:  make(chan struct{}),
+	}
+	p.wg.Add(1)
+	go p.run()
+	return p
+}
+
+func (p *pipeline) enqueue(record []byte) {
+	select {
+	case p.queue <- record:
+	default:
+		dropped := p.dropped.Add(1)
+		// 每分钟最多提示一次，避免刷屏
+		now := time.Now().Unix()
+		last := p.lastDropLog.Load()
+		if now-last >= 60 && p.lastDropLog.CompareAndSwap(last, now) {
+			common.SysError(fmt.Sprintf("log sink %s: buffer full, %d records dropped so far", p.cfg.Name, dropped))
+		}
+	}
+}
+
+func (p *pipeline) run() {
+	defer p.wg.Done()
+	ticker := time.NewTicker(time.Duration(p.cfg.FlushIntervalMs) * time.Millisecond)
+	defer ticker.Stop()
+	batch := make([][]byte, 0, p.cfg.BatchSize)
+	for {
+		select {
+		case record := <-p.queue:
+			batch = append(batch, record)
+			if len(batch) >= p.cfg.BatchSize {
+				p.flush(batch)
+				batch = make([][]byte, 0, p.cfg.BatchSize)
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				p.flush(batch)
+				batch = make([][]byte, 0, p.cfg.BatchSize)
+			}
+		case <-p.quit:
+			// 排空剩余日志
+			for {
+				select {
+				case record := <-p.queue:
+					batch = append(batch, record)
+					if len(batch) >= p.cfg.BatchSize {
+						p.flush(batch)
+						batch = make([][]byte, 0, p.cfg.BatchSize)
+					}
+				default:
+					if len(batch) > 0 {
+						p.flush(batch)
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+func (p *pipeline) flush(batch [][]byte) {
+	var err error
+	for attempt := 0; attempt <= p.cfg.MaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt*attempt) * 200 * time.Millisecond)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.cfg.TimeoutSeconds)*time.Second)
+		err = p.sink.Write(ctx, batch)
+		cancel()
+		if err == nil {
+			p.written.Add(int64(len(batch)))
+			return
+		}
+	}
+	p.failed.Add(int64(len(batch)))
+	common.SysError(fmt.Sprintf("log sink %s: failed to write %d records: %s", p.cfg.Name, len(batch), err.Error()))
+}
+
+func (p *pipeline) close() {
+	p.once.Do(func() {
+		close(p.quit)
+		p.wg.Wait()
+		if err := p.sink.Close(); err != nil {
+			common.SysError(fmt.Sprintf("log sink %s: failed to close: %s", p.cfg.Name, err.Error()))
+		}
+	})
+}
+
+func (p *pipeline) stat() Stat {
+	return Stat{
+		Name:    p.cfg.Name,
+		Type:    p.cfg.Type,
+		Queued:  len(p.queue),
+		Written: p.written.Load(),
+		Dropped: p.dropped.Load(),
+		Failed:  p.failed.Load(),
+	}
+}
@@ -0,0 +1,227 @@
+package secretref
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// EnvPrefix env:// 引用只允许读取该前缀的环境变量，避免通过渠道引用读取 SQL_DSN 等系统配置。
+// 可通过 SECRET_REF_ENV_PREFIX 配置
+var EnvPrefix = "NEWAPI_SECRET_"
+
+// FileDirs file:// 引用只允许读取这些目录下的文件，可通过 SECRET_REF_FILE_DIRS 配置，多个目录以逗号分隔
+var FileDirs = []string{"/run/secrets"}
+
+// VaultPrefixes vault:// 引用只允许读取这些路径前缀下的密钥，避免读取 VAULT_TOKEN 可访问的其他密钥。
+// 可通过 SECRET_REF_VAULT_PREFIXES 配置，多个前缀以逗号分隔
+var VaultPrefixes = []string{"secret/data/newapi", "secret/newapi"}
+
+func init() {
+	if prefix := strings.TrimSpace(os.Getenv("SECRET_REF_ENV_PREFIX")); prefix != "" {
+		EnvPrefix = prefix
+	}
+	if dirs := os.Getenv("SECRET_REF_FILE_DIRS"); dirs != "" {
+		FileDirs = nil
+		for _, dir := range strings.Split(dirs, ",") {
+			if dir = strings.TrimSpace(dir); dir != "" {
+				FileDirs = append(FileDirs, dir)
+			}
+		}
+	}
+	if prefixes := os.Getenv("SECRET_REF_VAULT_PREFIXES"); prefixes != "" {
+		VaultPrefixes = nil
+		for _, prefix := range strings.Split(prefixes, ",") {
+			if prefix = strings.Trim(strings.TrimSpace(prefix), "/"); prefix != "" {
+				VaultPrefixes = append(VaultPrefixes, prefix)
+			}
+		}
+	}
+	Register("env", ResolverFunc(resolveEnv))
+	Register("file", ResolverFunc(resolveFile))
+	Register("vault", ResolverFunc(resolveVault))
+}
+
+// envName 解析 env:// 引用中的变量名，并检查是否在允许的前缀内
+func envName(ref *url.URL) (string, error) {
+	name := ref.Host + ref.Path
+	if name == "" {
+		return "", errors.New("environment variable name is empty")
+	}
+	if EnvPrefix == "" || !strings.HasPrefix(name, EnvPrefix) {
+		return "", fmt.Errorf("%w: environment variable must start with %s", ErrNotAllowed, EnvPrefix)
+	}
+	return name, nil
+}
+
+// filePath 解析 file:// 引用中的路径，跟随符号链接后检查是否位于允许的目录内
+func filePath(ref *url.URL) (string, error) {
+	path := ref.Path
+	if ref.Host != "" {
+		// file://relative/path 视为相对路径
+		path = filepath.Join(ref.Host, ref.Path)
+	}
+	if path == "" {
+		return "", errors.New("file path is empty")
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	for _, allowed := range FileDirs {
+		dir, err := filepath.Abs(allowed)
+		if err != nil {
+			continue
+		}
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			dir = resolved
+		}
+		if rel, err := filepath.Rel(dir, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("%w: file must be under %s", ErrNotAllowed, strings.Join(FileDirs, ", "))
+}
+
+// vaultPath 解析 vault:// 引用中的 API 路径，拒绝 . 与 .. 路径段，并检查是否位于允许的前缀下
+func vaultPath(ref *url.URL) (string, error) {
+	path := strings.Trim(ref.Host+ref.Path, "/")
+	if path == "" {
+		return "", errors.New("vault path is empty")
+	}
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return "", fmt.Errorf("%w: invalid vault path %s", ErrNotAllowed, path)
+		}
+	}
+	for _, prefix := range VaultPrefixes {
+		prefix = strings.Trim(prefix, "/")
+		if prefix != "" && (path == prefix || strings.HasPrefix(path, prefix+"/")) {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("%w: vault path must be under %s", ErrNotAllowed, strings.Join(VaultPrefixes, ", "))
+}
+
+// resolveEnv 读取环境变量，例如 env://NEWAPI_SECRET_OPENAI_KEY
+func resolveEnv(ctx context.Context, ref *url.URL) (string, error) {
+	name, err := envName(ref)
+	if err != nil {
+		return "", err
+	}
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
+
+// resolveFile 读取文件内容，例如 file:///run/secrets/claude，多个密钥每行一个
+func resolveFile(ctx context.Context, ref *url.URL) (string, error) {
+	path, err := filePath(ref)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+var vaultClient = &http.Client{Timeout: resolveTimeout}
+
+// resolveVault 读取 HashiCorp Vault KV 密钥，例如 vault://secret/data/newapi/openai#api_key。
+// 路径为 /v1/ 之后的 API 路径，同时支持 KV v1 与 v2；# 之后为字段名，省略时要求密钥只有一个字段。
+// 字段值为字符串数组时展开为多个密钥。地址与令牌来自 VAULT_ADDR、VAULT_TOKEN，命名空间来自 VAULT_NAMESPACE
+func resolveVault(ctx context.Context, ref *url.URL) (string, error) {
+	path, err := vaultPath(ref)
+	if err != nil {
+		return "", err
+	}
+	addr := strings.TrimRight(os.Getenv("VAULT_ADDR"), "/")
+	if addr == "" {
+		return "", errors.New("VAULT_ADDR is not set")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, addr+"/v1/"+path, nil)
+	if err != nil {
+		return "", err
+	}
+	if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if namespace := os.Getenv("VAULT_NAMESPACE"); namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+	resp, err := vaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned status %d", resp.StatusCode)
+	}
+	var payload struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err = json.Unmarshal(body, &payload); err != nil {
+		return "", err
+	}
+	data := payload.Data
+	// KV v2 的字段位于 data.data 中
+	if inner, ok := data["data"]; ok {
+		if _, hasMetadata := data["metadata"]; hasMetadata {
+			data = nil
+			if err = json.Unmarshal(inner, &data); err != nil {
+				return "", err
+			}
+		}
+	}
+	field := ref.Fragment
+	if field == "" {
+		if len(data) != 1 {
+			keys := make([]string, 0, len(data))
+			for k := range data {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			return "", fmt.Errorf("vault secret has fields %v, specify one with #field", keys)
+		}
+		for k := range data {
+			field = k
+		}
+	}
+	raw, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("vault secret has no field %s", field)
+	}
+	return decodeSecretValue(raw)
+}
+
+// decodeSecretValue 字符串原样返回，字符串数组按行拼接
+func decodeSecretValue(raw json.RawMessage) (string, error) {
+	var value string
+	if err := json.Unmarshal(raw, &value); err == nil {
+		return value, nil
+	}
+	var list []string
+	if err := json.Unmarshal(raw, &list); err == nil {
+		return strings.Join(list, "\n"), nil
+	}
+	return "", errors.New("secret value must be a string or a list of strings")
+}
@@ -0,0 +1,195 @@
+package secretref
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resolver 解析某一 scheme 的密钥引用，返回密钥内容。多个密钥以换行分隔
+type Resolver interface {
+	Resolve(ctx context.Context, ref *url.URL) (string, error)
+}
+
+// ResolverFunc 函数形式的 Resolver
+type ResolverFunc func(ctx context.Context, ref *url.URL) (string, error)
+
+func (f ResolverFunc) Resolve(ctx context.Context, ref *url.URL) (string, error) {
+	return f(ctx, ref)
+}
+
+var (
+	resolversMu sync.RWMutex
+	resolvers   = make(map[string]Resolver)
+)
+
+// Register 注册 scheme 对应的解析器，同名覆盖，便于测试时替换
+func Register(scheme string, resolver Resolver) {
+	resolversMu.Lock()
+	defer resolversMu.Unlock()
+	resolvers[strings.ToLower(scheme)] = resolver
+}
+
+func getResolver(scheme string) (Resolver, bool) {
+	resolversMu.RLock()
+	defer resolversMu.RUnlock()
+	resolver, ok := resolvers[strings.ToLower(scheme)]
+	return resolver, ok
+}
+
+// resolveTimeout 单次解析的超时时间
+const resolveTimeout = 10 * time.Second
+
+// CacheTTL 解析结果的缓存时间，可通过 SECRET_REF_CACHE_TTL（秒）配置
+var CacheTTL = 5 * time.Minute
+
+func init() {
+	if ttl, err := strconv.Atoi(os.Getenv("SECRET_REF_CACHE_TTL")); err == nil && ttl > 0 {
+		CacheTTL = time.Duration(ttl) * time.Second
+	}
+}
+
+var ErrUnknownScheme = errors.New("secretref: unknown scheme")
+
+// ErrNotAllowed 引用的环境变量、文件或 Vault 路径不在允许范围内
+var ErrNotAllowed = errors.New("secretref: reference not allowed")
+
+// IsReference 判断值是否为已注册 scheme 的单行引用，例如 env://NEWAPI_SECRET_OPENAI_KEY、file:///run/secrets/key
+func IsReference(value string) bool {
+	value = strings.TrimSpace(value)
+	if value == "" || strings.ContainsAny(value, "\n\r ") {
+		return false
+	}
+	idx := strings.Index(value, "://")
+	if idx <= 0 {
+		return false
+	}
+	_, ok := getResolver(value[:idx])
+	return ok
+}
+
+type cacheEntry struct {
+	value      string
+	resolvedAt time.Time
+	err        error
+}
+
+var (
+	cacheMu sync.RWMutex
+	cache   = make(map[string]*cacheEntry)
+)
+
+// Resolve 解析引用，结果缓存 CacheTTL。缓存过期后重新解析失败时继续使用上一次的值，避免外部服务短暂不可用导致渠道不可用
+func Resolve(ref string) (string, error) {
+	ref = strings.TrimSpace(ref)
+	cacheMu.RLock()
+	entry, ok := cache[ref]
+	cacheMu.RUnlock()
+	if ok && time.Since(entry.resolvedAt) < CacheTTL {
+		return entry.value, entry.err
+	}
+	value, err := resolve(ref)
+	if err != nil && ok && entry.err == nil {
+		// 保留上一次的值，等到下个周期再重试
+		value, err = entry.value, nil
+	}
+	cacheMu.Lock()
+	cache[ref] = &cacheEntry{value: value, resolvedAt: time.Now(), err: err}
+	cacheMu.Unlock()
+	return value, err
+}
+
+func resolve(ref string) (string, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return "", fmt.Errorf("secretref: invalid reference: %w", err)
+	}
+	resolver, ok := getResolver(u.Scheme)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownScheme, u.Scheme)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+	value, err := resolver.Resolve(ctx, u)
+	if err != nil {
+		return "", fmt.Errorf("secretref: failed to resolve %s: %w", Redact(ref), err)
+	}
+	value = strings.Trim(value, "\r\n")
+	if strings.TrimSpace(value) == "" {
+		return "", fmt.Errorf("secretref: %s resolved to an empty value", Redact(ref))
+	}
+	return value, nil
+}
+
+// CheckAllowed 检查引用是否在允许范围内，不实际读取密钥，用于保存渠道与覆盖规则前的校验
+func CheckAllowed(ref string) error {
+	u, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return fmt.Errorf("secretref: invalid reference: %w", err)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "env":
+		_, err = envName(u)
+	case "file":
+		_, err = filePath(u)
+	case "vault":
+		_, err = vaultPath(u)
+	default:
+		if _, ok := getResolver(u.Scheme); !ok {
+			err = fmt.Errorf("%w: %s", ErrUnknownScheme, u.Scheme)
+		}
+	}
+	return err
+}
+
+// Invalidate 清除引用的缓存，下次使用时重新解析
+func Invalidate(ref string) {
+	cacheMu.Lock()
+	delete(cache, strings.TrimSpace(ref))
+	cacheMu.Unlock()
+}
+
+// Refresh 重新解析全部已缓存的引用，失败的引用保留旧值
+func Refresh() {
+	cacheMu.RLock()
+	refs := make([]string, 0, len(cache))
+	for ref := range cache {
+		refs = append(refs, ref)
+	}
+	cacheMu.RUnlock()
+	for _, ref := range refs {
+		value, err := resolve(ref)
+		cacheMu.Lock()
+		if entry, ok := cache[ref]; ok {
+			if err == nil || entry.err != nil {
+				cache[ref] = &cacheEntry{value: value, resolvedAt: time.Now(), err: err}
+			}
+		}
+		cacheMu.Unlock()
+	}
+}
+
+// Redact 去掉引用中的查询参数与凭证，用于日志
+func Redact(ref string) string {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return "<invalid reference>"
+	}
+	u.User = nil
+	u.RawQuery = ""
+	return u.String()
+}
+
+// SyncRefresh 定期刷新已缓存的引用，外部密钥更新后无需等待请求触发重新解析
+func SyncRefresh(frequency time.Duration) {
+	for {
+		time.Sleep(frequency)
+		Refresh()
+	}
+}
@@ -0,0 +1,166 @@
+package secretref
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsReference(t *testing.T) {
+	cases := map[string]bool{
+		"env://OPENAI_KEY_1":         true,
+		" file:///run/secrets/key\n": true,
+		"vault://secret/data/a#key":  true,
+		"sk-plain":                   false,
+		"https://example.com":        false,
+		"env://A\nenv://B":           false,
+		"":                           false,
+	}
+	for value, expected := range cases {
+		if IsReference(value) != expected {
+			t.Errorf("IsReference(%q) expected %v", value, expected)
+		}
+	}
+}
+
+func TestResolveEnvAndFile(t *testing.T) {
+	t.Setenv("NEWAPI_SECRET_TEST_KEY", "sk-env")
+	if value, err := Resolve("env://NEWAPI_SECRET_TEST_KEY"); err != nil || value != "sk-env" {
+		t.Errorf("Resolve env returned %q %v", value, err)
+	}
+	if _, err := Resolve("env://NEWAPI_SECRET_TEST_MISSING"); err == nil {
+		t.Error("Expected missing env to fail")
+	}
+
+	dir := t.TempDir()
+	oldDirs := FileDirs
+	FileDirs = []string{dir}
+	t.Cleanup(func() { FileDirs = oldDirs })
+	path := filepath.Join(dir, "keys")
+	if err := os.WriteFile(path, []byte("sk-1\nsk-2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := Resolve("file://" + path); err != nil || value != "sk-1\nsk-2" {
+		t.Errorf("Resolve file returned %q %v", value, err)
+	}
+}
+
+func TestResolveRejectsNotAllowed(t *testing.T) {
+	t.Setenv("SQL_DSN", "root:password@tcp(db)/oneapi")
+	dir := t.TempDir()
+	oldDirs := FileDirs
+	FileDirs = []string{filepath.Join(dir, "secrets")}
+	t.Cleanup(func() { FileDirs = oldDirs })
+	if err := os.MkdirAll(FileDirs[0], 0700); err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(dir, "outside")
+	if err := os.WriteFile(outside, []byte("sk-outside"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(FileDirs[0], "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	refs := []string{
+		"env://SQL_DSN",
+		"file:///proc/self/environ",
+		"file://" + outside,
+		"file://" + FileDirs[0] + "/../outside",
+		"file://" + FileDirs[0] + "/link",
+		"vault://secret/data/database#password",
+		"vault://secret/data/newapi/../database#password",
+		"vault://secret/data/newapi/%2e%2e/database",
+		"vault://secret/data/newapi-other/key",
+	}
+	for _, ref := range refs {
+		if err := CheckAllowed(ref); !errors.Is(err, ErrNotAllowed) {
+			t.Errorf("CheckAllowed(%s) expected ErrNotAllowed, got %v", ref, err)
+		}
+		if _, err := Resolve(ref); err == nil {
+			t.Errorf("Resolve(%s) expected to fail", ref)
+		}
+		Invalidate(ref)
+	}
+	if err := CheckAllowed("file://" + FileDirs[0] + "/key"); err != nil {
+		t.Errorf("CheckAllowed returned %v for allowed file", err)
+	}
+	if err := CheckAllowed("vault://secret/data/newapi/openai#api_key"); err != nil {
+		t.Errorf("CheckAllowed returned %v for allowed vault path", err)
+	}
+}
+
+func TestResolveCacheKeepsLastValue(t *testing.T) {
+	current, fail := "sk-first", false
+	Register("teststub", ResolverFunc(func(ctx context.Context, ref *url.URL) (string, error) {
+		if fail {
+			return "", errors.New("unavailable")
+		}
+		return current, nil
+	}))
+	ref := "teststub://key"
+	t.Cleanup(func() { Invalidate(ref) })
+
+	if value, _ := Resolve(ref); value != "sk-first" {
+		t.Fatalf("Expected sk-first, got %q", value)
+	}
+	current = "sk-second"
+	if value, _ := Resolve(ref); value != "sk-first" {
+		t.Errorf("Expected cached value, got %q", value)
+	}
+	Refresh()
+	if value, _ := Resolve(ref); value != "sk-second" {
+		t.Errorf("Expected refreshed value, got %q", value)
+	}
+	fail = true
+	Refresh()
+	if value, err := Resolve(ref); err != nil || value != "sk-second" {
+		t.Errorf("Expected last good value after failed refresh, got %q %v", value, err)
+	}
+	Invalidate(ref)
+	if _, err := Resolve(ref); err == nil {
+		t.Error("Expected error after invalidation")
+	}
+}
+
+func TestResolveVault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "test-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/newapi/openai":
+			_, _ = w.Write([]byte(`{"data":{"data":{"api_key":"sk-vault","keys":["sk-a","sk-b"]},"metadata":{"version":1}}}`))
+		case "/v1/secret/newapi/claude":
+			_, _ = w.Write([]byte(`{"data":{"value":"sk-v1"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "test-token")
+
+	cases := map[string]string{
+		"vault://secret/data/newapi/openai#api_key": "sk-vault",
+		"vault://secret/data/newapi/openai#keys":    "sk-a\nsk-b",
+		"vault://secret/newapi/claude":              "sk-v1",
+	}
+	for ref, expected := range cases {
+		Invalidate(ref)
+		if value, err := Resolve(ref); err != nil || value != expected {
+			t.Errorf("Resolve(%s) returned %q %v", ref, value, err)
+		}
+		Invalidate(ref)
+	}
+	if _, err := Resolve("vault://secret/data/newapi/openai"); err == nil {
+		t.Error("Expected ambiguous field to fail")
+	}
+	Invalidate("vault://secret/data/newapi/openai")
+}
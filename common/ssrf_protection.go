@@ -205,6 +205,15 @@ func isIPListed(ip net.IP, list []string) bool {
 	return false
 }
 
+// IsIPInList 检查IP字符串是否命中列表中的IP或CIDR
+func IsIPInList(ipStr string, list []string) bool {
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return false
+	}
+	return isIPListed(ip, list)
+}
+
 // IsIPAccessAllowed 检查IP是否允许访问
 func (p *SSRFProtection) IsIPAccessAllowed(ip net.IP) bool {
 	// 私有IP限制
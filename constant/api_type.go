@@ -33,6 +33,7 @@ const (
 	APITypeJimeng
 	APITypeWavespeed
 	APITypeAi302
+	APITypeDeclarative
 	APITypeMoonshot // this one is only for count, do not add any channel after this
 	APITypeDummy    // this one is only for count, do not add any channel after this
 )
@@ -52,6 +52,7 @@ const (
 	ChannelTypeVidu           = 52
 	ChannelTypeWavespeed      = 53
 	ChannelTypeAi302          = 54
+	ChannelTypeDeclarative    = 55
 	ChannelTypeDummy          // this one is only for count, do not add any channel after this
 
 )
@@ -112,4 +113,5 @@ var ChannelBaseURLs = []string{
 	"https://api.vidu.cn",                       //52
 	"https://api.wavespeed.ai",                  //53
 	"https://api.302.ai",                        //54
+	"",                                          //55
 }
@@ -13,10 +13,12 @@ const (
 	ContextKeyTokenUnlimited         ContextKey = "token_unlimited_quota"
 	ContextKeyTokenKey               ContextKey = "token_key"
 	ContextKeyTokenId                ContextKey = "token_id"
+	ContextKeyTokenOrgId             ContextKey = "token_org_id"
 	ContextKeyTokenGroup             ContextKey = "token_group"
 	ContextKeyTokenSpecificChannelId ContextKey = "specific_channel_id"
 	ContextKeyTokenModelLimitEnabled ContextKey = "token_model_limit_enabled"
 	ContextKeyTokenModelLimit        ContextKey = "token_model_limit"
+	ContextKeyTokenContextPolicy     ContextKey = "token_context_policy"
 
 	/* channel related keys */
 	ContextKeyChannelId                ContextKey = "channel_id"
@@ -28,6 +30,7 @@ const (
 	ContextKeyChannelOtherSetting      ContextKey = "channel_other_setting"
 	ContextKeyChannelParamOverride     ContextKey = "param_override"
 	ContextKeyChannelHeaderOverride    ContextKey = "header_override"
+	ContextKeyChannelResponseOverride  ContextKey = "response_override"
 	ContextKeyChannelOrganization      ContextKey = "channel_organization"
 	ContextKeyChannelAutoBan           ContextKey = "auto_ban"
 	ContextKeyChannelModelMapping      ContextKey = "model_mapping"
@@ -45,6 +48,17 @@ const (
 	ContextKeyUserGroup   ContextKey = "user_group"
 	ContextKeyUsingGroup  ContextKey = "group"
 	ContextKeyUserName    ContextKey = "username"
+	// ContextKeyUserCreditLimit 后付费用户生效的信用额度，预付费用户为 0
+	ContextKeyUserCreditLimit ContextKey = "user_credit_limit"
 
 	ContextKeySystemPromptOverride ContextKey = "system_prompt_override"
+
+	ContextKeyBodyCapture ContextKey = "body_capture"
+
+	// ContextKeyStructuredOutput 结构化输出校验结果，ContextKeyStructuredOutputRetries 因校验失败而重试的次数
+	ContextKeyStructuredOutput        ContextKey = "structured_output"
+	ContextKeyStructuredOutputRetries ContextKey = "structured_output_retries"
+
+	// ContextKeyContextWindow 超出上下文窗口时实际采用的处理结果
+	ContextKeyContextWindow ContextKey = "context_window"
 )
@@ -0,0 +1,192 @@
+package controller
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func parseUsageAnalyticsQuery(c *gin.Context, isAdmin bool) (*model.UsageAnalyticsQuery, error) {
+	q := &model.UsageAnalyticsQuery{
+		Bucket:    c.Query("bucket"),
+		ModelName: c.Query("model_name"),
+		Group:     c.Query("group"),
+	}
+	q.StartTimestamp, _ = strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	q.EndTimestamp, _ = strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	q.TokenId, _ = strconv.Atoi(c.Query("token_id"))
+	if groupBy := c.Query("group_by"); groupBy != "" {
+		for _, dim := range strings.Split(groupBy, ",") {
+			if dim = strings.TrimSpace(dim); dim != "" {
+				q.GroupBy = append(q.GroupBy, dim)
+			}
+		}
+	}
+	if tz := c.Query("tz"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, errors.New("无效的时区: " + tz)
+		}
+		q.Location = loc
+	}
+	if isAdmin {
+		q.UserId, _ = strconv.Atoi(c.Query("user_id"))
+		q.ChannelId, _ = strconv.Atoi(c.Query("channel"))
+	} else {
+		// 普通用户只能查看自己的数据，且不暴露渠道信息
+		q.UserId = c.GetInt("id")
+		for _, dim := range q.GroupBy {
+			if dim == "channel" {
+				return nil, errors.New("不支持的分组维度: channel")
+			}
+		}
+	}
+	return q, q.Validate()
+}
+
+func GetUsageAnalytics(c *gin.Context) {
+	getUsageAnalytics(c, true)
+}
+
+func GetUserUsageAnalytics(c *gin.Context) {
+	getUsageAnalytics(c, false)
+}
+
+func ExportUsageAnalytics(c *gin.Context) {
+	exportUsageAnalytics(c, true)
+}
+
+func ExportUserUsageAnalytics(c *gin.Context) {
+	exportUsageAnalytics(c, false)
+}
+
+func getUsageAnalytics(c *gin.Context, isAdmin bool) {
+	q, err := parseUsageAnalyticsQuery(c, isAdmin)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	rows, err := model.QueryUsageAnalytics(q)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    rows,
+	})
+}
+
+// exportUsageAnalytics 以 CSV 或 NDJSON 流式导出分析结果
+func exportUsageAnalytics(c *gin.Context, isAdmin bool) {
+	q, err := parseUsageAnalyticsQuery(c, isAdmin)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "ndjson" {
+		common.ApiErrorMsg(c, "不支持的导出格式")
+		return
+	}
+	filename := fmt.Sprintf("usage-%d-%d.%s", q.StartTimestamp, q.EndTimestamp, format)
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+	} else {
+		c.Header("Content-Type", "application/x-ndjson")
+	}
+	c.Status(http.StatusOK)
+
+	var csvWriter *csv.Writer
+	encoder := json.NewEncoder(c.Writer)
+	if format == "csv" {
+		csvWriter = csv.NewWriter(c.Writer)
+		_ = csvWriter.Write(usageAnalyticsCSVHeader(q))
+	}
+	err = model.StreamUsageAnalytics(q, func(rows []*model.UsageAnalyticsRow) error {
+		for _, row := range rows {
+			if csvWriter != nil {
+				if err := csvWriter.Write(usageAnalyticsCSVRecord(q, row)); err != nil {
+					return err
+				}
+			} else if err := encoder.Encode(row); err != nil {
+				return err
+			}
+		}
+		if csvWriter != nil {
+			csvWriter.Flush()
+			if err := csvWriter.Error(); err != nil {
+				return err
+			}
+		}
+		c.Writer.Flush()
+		return c.Request.Context().Err()
+	})
+	if csvWriter != nil {
+		csvWriter.Flush()
+	}
+	if err != nil {
+		common.SysError("failed to export usage analytics: " + err.Error())
+	}
+}
+
+func usageAnalyticsCSVHeader(q *model.UsageAnalyticsQuery) []string {
+	header := []string{"bucket"}
+	for _, dim := range q.GroupBy {
+		switch dim {
+		case "user":
+			header = append(header, "user_id", "username")
+		case "token":
+			header = append(header, "token_id", "token_name")
+		case "model":
+			header = append(header, "model_name")
+		case "channel":
+			header = append(header, "channel_id")
+		default:
+			header = append(header, dim)
+		}
+	}
+	return append(header, "request_count", "quota", "prompt_tokens", "completion_tokens", "use_time")
+}
+
+func usageAnalyticsCSVRecord(q *model.UsageAnalyticsQuery, row *model.UsageAnalyticsRow) []string {
+	bucket := ""
+	if q.Bucket != model.UsageBucketNone {
+		bucket = time.Unix(row.Bucket, 0).In(q.Location).Format(time.RFC3339)
+	}
+	record := []string{bucket}
+	for _, dim := range q.GroupBy {
+		switch dim {
+		case "user":
+			record = append(record, strconv.Itoa(row.UserId), row.Username)
+		case "token":
+			record = append(record, strconv.Itoa(row.TokenId), row.TokenName)
+		case "model":
+			record = append(record, row.ModelName)
+		case "channel":
+			record = append(record, strconv.Itoa(row.ChannelId))
+		case "group":
+			record = append(record, row.Group)
+		case "ip":
+			record = append(record, row.Ip)
+		}
+	}
+	return append(record,
+		strconv.FormatInt(row.RequestCount, 10),
+		strconv.FormatInt(row.Quota, 10),
+		strconv.FormatInt(row.PromptTokens, 10),
+		strconv.FormatInt(row.CompletionTokens, 10),
+		strconv.FormatInt(row.UseTime, 10),
+	)
+}
@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func parseAuditLogFilter(c *gin.Context) *model.AuditLogFilter {
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	return &model.AuditLogFilter{
+		UserId:         userId,
+		ResourceType:   c.Query("resource_type"),
+		ResourceId:     c.Query("resource_id"),
+		Action:         c.Query("action"),
+		Keyword:        c.Query("keyword"),
+		StartTimestamp: startTimestamp,
+		EndTimestamp:   endTimestamp,
+	}
+}
+
+func GetAuditLogs(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	logs, total, err := model.GetAuditLogs(parseAuditLogFilter(c), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(logs)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// ExportAuditLogs 以 CSV 或 NDJSON 流式导出审计日志
+func ExportAuditLogs(c *gin.Context) {
+	filter := parseAuditLogFilter(c)
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "ndjson" {
+		common.ApiErrorMsg(c, "不支持的导出格式")
+		return
+	}
+	filename := fmt.Sprintf("audit-%d.%s", time.Now().Unix(), format)
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+	} else {
+		c.Header("Content-Type", "application/x-ndjson")
+	}
+	c.Status(http.StatusOK)
+
+	var csvWriter *csv.Writer
+	encoder := json.NewEncoder(c.Writer)
+	if format == "csv" {
+		csvWriter = csv.NewWriter(c.Writer)
+		_ = csvWriter.Write([]string{"id", "created_at", "user_id", "username", "ip", "via", "resource_type", "resource_id", "action", "diff"})
+	}
+	err := model.StreamAuditLogs(filter, func(logs []*model.AuditLog) error {
+		for _, log := range logs {
+			if csvWriter != nil {
+				record := []string{
+					strconv.Itoa(log.Id),
+					time.Unix(log.CreatedAt, 0).UTC().Format(time.RFC3339),
+					strconv.Itoa(log.UserId),
+					log.Username,
+					log.Ip,
+					log.Via,
+					log.ResourceType,
+					log.ResourceId,
+					log.Action,
+					log.Diff,
+				}
+				if err := csvWriter.Write(record); err != nil {
+					return err
+				}
+			} else if err := encoder.Encode(log); err != nil {
+				return err
+			}
+		}
+		if csvWriter != nil {
+			csvWriter.Flush()
+			if err := csvWriter.Error(); err != nil {
+				return err
+			}
+		}
+		c.Writer.Flush()
+		return c.Request.Context().Err()
+	})
+	if csvWriter != nil {
+		csvWriter.Flush()
+	}
+	if err != nil {
+		common.SysError("failed to export audit logs: " + err.Error())
+	}
+}
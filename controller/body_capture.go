@@ -0,0 +1,46 @@
+package controller
+
+import (
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetBodyCaptures(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	channelId, _ := strconv.Atoi(c.Query("channel"))
+	captures, total, err := model.GetBodyCaptures(c.Query("request_id"), userId, channelId, c.Query("model_name"),
+		pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(captures)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// GetBodyCapture 返回客户端原始请求与实际发往上游的请求（参数覆盖、模型映射之后）以及上游响应
+func GetBodyCapture(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	capture, err := model.GetBodyCaptureById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"capture":            capture,
+		"client_request":     service.DecompressBody(capture.ClientRequest),
+		"upstream_request":   service.DecompressBody(capture.UpstreamRequest),
+		"upstream_response":  service.DecompressBody(capture.UpstreamResponse),
+		"assembled_response": service.DecompressBody(capture.AssembledResponse),
+	})
+}
@@ -135,7 +135,7 @@ func GetResponseBody(method, url string, channel *model.Channel, headers http.He
 	for k := range headers {
 		req.Header.Add(k, headers.Get(k))
 	}
-	client, err := service.NewProxyHttpClient(channel.GetSetting().Proxy)
+	client, err := service.GetChannelHttpClient(channel.Id, channel.GetSetting())
 	if err != nil {
 		return nil, err
 	}
@@ -348,6 +348,16 @@ func updateChannelMoonshotBalance(channel *model.Channel) (float64, error) {
 }
 
 func updateChannelBalance(channel *model.Channel) (float64, error) {
+	if channel.IsKeyReference() {
+		// 使用解析后的密钥查询，不修改缓存中的渠道
+		key, err := channel.ResolveKey()
+		if err != nil {
+			return 0, err
+		}
+		resolved := *channel
+		resolved.Key = key
+		channel = &resolved
+	}
 	baseURL := constant.ChannelBaseURLs[channel.Type]
 	if channel.GetBaseURL() == "" {
 		channel.BaseURL = &baseURL
@@ -5,9 +5,13 @@ import (
 	"fmt"
 	"net/http"
 	"one-api/common"
+	"one-api/common/secretref"
 	"one-api/constant"
 	"one-api/dto"
 	"one-api/model"
+	"one-api/relay/channel/declarative"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
 	"strconv"
 	"strings"
 
@@ -194,7 +198,12 @@ func FetchUpstreamModels(c *gin.Context) {
 
 	// 获取响应体 - 根据渠道类型决定是否添加 AuthHeader
 	var body []byte
-	key := strings.Split(channel.Key, "\n")[0]
+	resolvedKey, err := channel.ResolveKey()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	key := strings.Split(resolvedKey, "\n")[0]
 	if channel.Type == constant.ChannelTypeGemini {
 		body, err = GetResponseBody("GET", url, channel, GetAuthHeader(key)) // Use AuthHeader since Gemini now forces it
 	} else {
@@ -432,6 +441,7 @@ func GetChannelKey(c *gin.Context) {
 
 	// 记录操作日志
 	model.RecordLog(userId, model.LogTypeSystem, fmt.Sprintf("查看渠道密钥信息 (渠道ID: %d)", channelId))
+	model.RecordAudit(c, model.AuditResourceChannel, channelId, model.AuditActionReadKey, nil, nil)
 
 	// 统一的成功响应格式
 	c.JSON(http.StatusOK, gin.H{
@@ -466,6 +476,32 @@ func validateChannel(channel *model.Channel, isAdd bool) error {
 	if err := channel.ValidateSettings(); err != nil {
 		return fmt.Errorf("渠道额外设置[channel setting] 格式错误：%s", err.Error())
 	}
+	if err := service.ValidateChannelTransport(channel.GetSetting()); err != nil {
+		return fmt.Errorf("渠道连接设置错误：%s", err.Error())
+	}
+	if channel.ResponseOverride != nil && *channel.ResponseOverride != "" {
+		responseOverride := make(map[string]interface{})
+		if err := common.Unmarshal([]byte(*channel.ResponseOverride), &responseOverride); err != nil {
+			return fmt.Errorf("响应改写规则必须是合法的 JSON 格式：%s", err.Error())
+		}
+		if _, err := relaycommon.ParseResponseOverride(responseOverride); err != nil {
+			return fmt.Errorf("响应改写规则错误：%s", err.Error())
+		}
+	}
+
+	// 覆盖规则中的 secret(...) 引用同样需要在允许范围内
+	for _, override := range []*string{channel.HeaderOverride, channel.ParamOverride} {
+		if override == nil || *override == "" {
+			continue
+		}
+		var value interface{}
+		if err := common.Unmarshal([]byte(*override), &value); err != nil {
+			continue
+		}
+		if err := relaycommon.ValidateOverrideSecrets(value); err != nil {
+			return fmt.Errorf("覆盖规则中的密钥引用不允许：%s", err.Error())
+		}
+	}
 
 	// 如果是添加操作，检查 channel 和 key 是否为空
 	if isAdd {
@@ -481,6 +517,22 @@ func validateChannel(channel *model.Channel, isAdd bool) error {
 		}
 	}
 
+	// 外部密钥引用需要在允许范围内且能够解析
+	if channel.IsKeyReference() {
+		if err := secretref.CheckAllowed(channel.Key); err != nil {
+			return fmt.Errorf("密钥引用不允许：%s", err.Error())
+		}
+		if _, err := channel.ResolveKey(); err != nil {
+			return fmt.Errorf("密钥引用解析失败：%s", err.Error())
+		}
+	}
+
+	if channel.Type == constant.ChannelTypeDeclarative {
+		if err := declarative.ValidateSpec(channel.GetOtherSettings().DeclarativeSpec); err != nil {
+			return fmt.Errorf("声明式渠道接口描述错误：%s", err.Error())
+		}
+	}
+
 	// VertexAI 特殊校验
 	if channel.Type == constant.ChannelTypeVertexAi {
 		if channel.Other == "" {
@@ -561,7 +613,7 @@ func AddChannel(c *gin.Context) {
 	case "multi_to_single":
 		addChannelRequest.Channel.ChannelInfo.IsMultiKey = true
 		addChannelRequest.Channel.ChannelInfo.MultiKeyMode = addChannelRequest.MultiKeyMode
-		if addChannelRequest.Channel.Type == constant.ChannelTypeVertexAi && addChannelRequest.Channel.GetOtherSettings().VertexKeyType != dto.VertexKeyTypeAPIKey {
+		if addChannelRequest.Channel.Type == constant.ChannelTypeVertexAi && addChannelRequest.Channel.GetOtherSettings().VertexKeyType != dto.VertexKeyTypeAPIKey && !addChannelRequest.Channel.IsKeyReference() {
 			array, err := getVertexArrayKeys(addChannelRequest.Channel.Key)
 			if err != nil {
 				c.JSON(http.StatusOK, gin.H{
@@ -583,6 +635,10 @@ func AddChannel(c *gin.Context) {
 			}
 			addChannelRequest.Channel.ChannelInfo.MultiKeySize = len(cleanKeys)
 			addChannelRequest.Channel.Key = strings.Join(cleanKeys, "\n")
+			if addChannelRequest.Channel.IsKeyReference() {
+				// 外部密钥引用展开为多个密钥
+				addChannelRequest.Channel.ChannelInfo.MultiKeySize = len(addChannelRequest.Channel.GetKeys())
+			}
 		}
 		keys = []string{addChannelRequest.Channel.Key}
 	case "batch":
@@ -623,6 +679,10 @@ func AddChannel(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	// 批量添加时渠道 id 由数据库分配，审计记录以渠道名称标识
+	for _, channel := range channels {
+		model.RecordAudit(c, model.AuditResourceChannel, channel.Name, model.AuditActionCreate, nil, channel)
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -632,13 +692,16 @@ func AddChannel(c *gin.Context) {
 
 func DeleteChannel(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
+	before, _ := model.GetChannelById(id, false)
 	channel := model.Channel{Id: id}
 	err := channel.Delete()
 	if err != nil {
 		common.ApiError(c, err)
 		return
 	}
+	model.RecordAudit(c, model.AuditResourceChannel, id, model.AuditActionDelete, before, nil)
 	model.InitChannelCache()
+	service.InvalidateChannelHttpClient(id)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -652,6 +715,7 @@ func DeleteDisabledChannel(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	model.RecordAudit(c, model.AuditResourceChannel, "disabled", model.AuditActionDelete, map[string]any{"count": rows}, nil)
 	model.InitChannelCache()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -767,6 +831,10 @@ func DeleteChannelBatch(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	for _, id := range channelBatch.Ids {
+		model.RecordAudit(c, model.AuditResourceChannel, id, model.AuditActionDelete, map[string]any{"id": id}, nil)
+		service.InvalidateChannelHttpClient(id)
+	}
 	model.InitChannelCache()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -821,6 +889,13 @@ func UpdateChannel(c *gin.Context) {
 		switch *channel.KeyMode {
 		case "append":
 			// 追加模式：将新密钥添加到现有密钥列表
+			if originChannel.IsKeyReference() || channel.IsKeyReference() {
+				c.JSON(http.StatusOK, gin.H{
+					"success": false,
+					"message": "外部密钥引用不支持追加模式，请使用覆盖模式",
+				})
+				return
+			}
 			if originChannel.Key != "" {
 				var newKeys []string
 				var existingKeys []string
@@ -883,7 +958,11 @@ func UpdateChannel(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	if updated, err := model.GetChannelById(channel.Id, true); err == nil {
+		model.RecordAudit(c, model.AuditResourceChannel, channel.Id, model.AuditActionUpdate, originChannel, updated)
+	}
 	model.InitChannelCache()
+	service.InvalidateChannelHttpClient(channel.Id)
 	channel.Key = ""
 	clearChannelInfo(&channel.Channel)
 	c.JSON(http.StatusOK, gin.H{
@@ -1423,6 +1502,14 @@ func ManageMultiKeys(c *gin.Context) {
 		return
 
 	case "delete_disabled_keys":
+		// 外部密钥引用的密钥由外部维护，删除后写回会把解析出的明文密钥存入数据库
+		if channel.IsKeyReference() {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "外部密钥引用不支持删除密钥，请在外部密钥源中移除",
+			})
+			return
+		}
 		keys := channel.GetKeys()
 		var remainingKeys []string
 		var deletedCount int
@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"one-api/setting/system_setting"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// resolveLdapUserRoleAndGroup 计算本次登录应同步的角色与分组；配置了映射时目录为唯一来源，未命中的用户降为普通用户与默认分组
+func resolveLdapUserRoleAndGroup(groups []string, settings *system_setting.LDAPSettings) (role int, group string, allowed bool) {
+	role, group, matched := service.ResolveLDAPMapping(groups, settings.GroupMappings)
+	if len(settings.GroupMappings) == 0 {
+		return 0, "", true
+	}
+	if !matched && !settings.AllowUnmappedUsers {
+		return 0, "", false
+	}
+	if role == 0 {
+		role = common.RoleCommonUser
+	}
+	if group == "" {
+		group = "default"
+	}
+	return role, group, true
+}
+
+func LdapLogin(c *gin.Context) {
+	settings := system_setting.GetLDAPSettings()
+	if !settings.Enabled {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "管理员未开启通过 LDAP 登录",
+		})
+		return
+	}
+	var loginRequest LoginRequest
+	err := json.NewDecoder(c.Request.Body).Decode(&loginRequest)
+	if err != nil || loginRequest.Username == "" || loginRequest.Password == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "无效的参数",
+			"success": false,
+		})
+		return
+	}
+	ldapUser, err := service.AuthenticateLDAP(loginRequest.Username, loginRequest.Password)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	role, group, allowed := resolveLdapUserRoleAndGroup(ldapUser.Groups, settings)
+	if !allowed {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "您不属于允许登录的目录组，请联系管理员",
+		})
+		return
+	}
+
+	// 以目录中的唯一标识关联本地账户，用户在 OU 之间移动后仍能登录原账户
+	user := model.User{}
+	if model.IsIdentityTakenByActiveUser(model.IdentityProviderLdap, ldapUser.Id) {
+		err := user.FillUserByIdentity(model.IdentityProviderLdap, ldapUser.Id)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		// 每次登录都重新同步目录组映射，root 用户不受影响
+		if user.Role != common.RoleRootUser {
+			if err = model.UpdateUserRoleAndGroup(&user, role, group); err != nil {
+				common.ApiError(c, err)
+				return
+			}
+		}
+	} else {
+		if !settings.AutoProvision {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "该目录账户尚未开通，请联系管理员",
+			})
+			return
+		}
+		user.Username = ldapUser.Username
+		// 用户名过长或与本地账户冲突时使用生成的用户名，不与同名本地账户自动关联
+		exist, err := model.CheckUserExistOrDeleted(user.Username, "")
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		if exist || len(user.Username) > 12 {
+			user.Username = "ldap_" + strconv.Itoa(model.GetMaxUserId()+1)
+		}
+		user.Email = ldapUser.Email
+		user.DisplayName = ldapUser.DisplayName
+		if user.DisplayName == "" {
+			user.DisplayName = "LDAP User"
+		}
+		if role != 0 {
+			user.Role = role
+		}
+		if group != "" {
+			user.Group = group
+		}
+		err = user.Insert(0)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		err = model.LinkUserIdentity(user.Id, model.IdentityProviderLdap, ldapUser.Id, ldapUser.Username, ldapUser.Email)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+	}
+
+	if user.Status != common.UserStatusEnabled {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "用户已被封禁",
+			"success": false,
+		})
+		return
+	}
+	model.TouchUserIdentity(model.IdentityProviderLdap, ldapUser.Id)
+	if requireTwoFA(&user, c) {
+		return
+	}
+	setupLogin(&user, c)
+}
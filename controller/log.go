@@ -3,6 +3,7 @@ package controller
 import (
 	"net/http"
 	"one-api/common"
+	"one-api/common/logsink"
 	"one-api/model"
 	"strconv"
 
@@ -173,3 +174,7 @@ func DeleteHistoryLogs(c *gin.Context) {
 	})
 	return
 }
+
+func GetLogSinkStats(c *gin.Context) {
+	common.ApiSuccess(c, logsink.Stats())
+}
@@ -76,6 +76,11 @@ func UpdateMidjourneyTaskBulk() {
 				}
 				continue
 			}
+			midjourneyKey, err := midjourneyChannel.ResolveKey()
+			if err != nil {
+				logger.LogError(ctx, fmt.Sprintf("ResolveKey: %v", err))
+				continue
+			}
 			requestUrl := fmt.Sprintf("%s/mj/task/list-by-condition", *midjourneyChannel.BaseURL)
 
 			body, _ := json.Marshal(map[string]any{
@@ -92,7 +97,7 @@ func UpdateMidjourneyTaskBulk() {
 			// 使用带有超时的 context 创建新的请求
 			req = req.WithContext(ctx)
 			req.Header.Set("Content-Type", "application/json")
-			req.Header.Set("mj-api-secret", midjourneyChannel.Key)
+			req.Header.Set("mj-api-secret", midjourneyKey)
 			resp, err := service.GetHttpClient().Do(req)
 			if err != nil {
 				logger.LogError(ctx, fmt.Sprintf("Get Task Do req error: %v", err))
@@ -176,7 +181,7 @@ func UpdateMidjourneyTaskBulk() {
 					logger.LogError(ctx, "UpdateMidjourneyTask task error: "+err.Error())
 				} else {
 					if shouldReturnQuota {
-						err = model.IncreaseUserQuota(task.UserId, task.Quota, false)
+						err = model.RefundTaskQuota(task.UserId, task.OrgId, task.Quota)
 						if err != nil {
 							logger.LogError(ctx, "fail to increase user quota: "+err.Error())
 						}
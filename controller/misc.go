@@ -92,8 +92,11 @@ func GetStatus(c *gin.Context) {
 		"SidebarModulesAdmin": common.OptionMap["SidebarModulesAdmin"],
 
 		"oidc_enabled":                system_setting.GetOIDCSettings().Enabled,
+		"ldap_enabled":                system_setting.GetLDAPSettings().Enabled,
+		"passkey_enabled":             system_setting.GetPasskeySettings().Enabled,
 		"oidc_client_id":              system_setting.GetOIDCSettings().ClientId,
 		"oidc_authorization_endpoint": system_setting.GetOIDCSettings().AuthorizationEndpoint,
+		"oauth_providers":             getEnabledOAuthProviders(),
 		"setup":                       constant.Setup,
 	}
 
@@ -420,6 +420,12 @@ func SyncUpstreamModels(c *gin.Context) {
 		}
 	}
 
+	model.RecordAudit(c, model.AuditResourceModel, "upstream", model.AuditActionSync, nil, gin.H{
+		"created_models":  createdList,
+		"updated_models":  updatedList,
+		"created_vendors": createdVendors,
+		"locale":          req.Locale,
+	})
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data": gin.H{
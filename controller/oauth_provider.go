@@ -0,0 +1,195 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"one-api/setting/system_setting"
+	"strconv"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// getEnabledOAuthProviders 供前端渲染登录按钮的已启用提供方，不包含密钥
+func getEnabledOAuthProviders() []gin.H {
+	providers := make([]gin.H, 0)
+	for _, provider := range system_setting.GetOAuthProviderSettings().Providers {
+		if !provider.Enabled {
+			continue
+		}
+		providers = append(providers, gin.H{
+			"name":                   provider.Name,
+			"display_name":           provider.DisplayName,
+			"icon":                   provider.Icon,
+			"client_id":              provider.ClientId,
+			"authorization_endpoint": provider.AuthorizationEndpoint,
+			"scopes":                 provider.Scopes,
+			"redirect_uri":           service.OAuthRedirectURI(&provider),
+		})
+	}
+	return providers
+}
+
+// OAuthProviderAuth 自定义 OAuth2 / OIDC 提供方的回调，已登录时绑定到当前用户，否则登录或注册
+func OAuthProviderAuth(c *gin.Context) {
+	session := sessions.Default(c)
+	state := c.Query("state")
+	if state == "" || session.Get("oauth_state") == nil || state != session.Get("oauth_state").(string) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": "state is empty or not same",
+		})
+		return
+	}
+	provider, ok := system_setting.GetOAuthProvider(c.Param("provider"))
+	if !ok {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "管理员未开启该登录方式",
+		})
+		return
+	}
+	identity, err := service.FetchOAuthIdentity(provider, c.Query("code"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if session.Get("username") != nil {
+		oauthProviderBind(c, provider, identity)
+		return
+	}
+
+	user := model.User{}
+	if model.IsIdentityTaken(provider.Name, identity.ExternalId) {
+		if err = user.FillUserByIdentity(provider.Name, identity.ExternalId); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		if user.Id == 0 {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "用户已注销",
+			})
+			return
+		}
+	} else {
+		if !common.RegisterEnabled || !provider.AllowRegister {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "管理员关闭了新用户注册",
+			})
+			return
+		}
+		// 外部用户名过长或与本地账户冲突时使用生成的用户名，不与同名本地账户自动关联
+		user.Username = identity.Username
+		exist, err := model.CheckUserExistOrDeleted(user.Username, "")
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		if user.Username == "" || exist || len(user.Username) > 12 {
+			user.Username = provider.Name + "_" + strconv.Itoa(model.GetMaxUserId()+1)
+		}
+		user.DisplayName = identity.DisplayName
+		if user.DisplayName == "" {
+			user.DisplayName = provider.DisplayName + " User"
+		}
+		if identity.Email != "" && !model.IsEmailAlreadyTaken(identity.Email) {
+			user.Email = identity.Email
+		}
+		user.Role = common.RoleCommonUser
+		user.Status = common.UserStatusEnabled
+		affCode := session.Get("aff")
+		inviterId := 0
+		if affCode != nil {
+			inviterId, _ = model.GetUserIdByAffCode(affCode.(string))
+		}
+		if err = user.Insert(inviterId); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		if err = model.LinkUserIdentity(user.Id, provider.Name, identity.ExternalId, identity.Username, identity.Email); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+	}
+
+	if user.Status != common.UserStatusEnabled {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "用户已被封禁",
+			"success": false,
+		})
+		return
+	}
+	model.TouchUserIdentity(provider.Name, identity.ExternalId)
+	setupLogin(&user, c)
+}
+
+func oauthProviderBind(c *gin.Context, provider *system_setting.OAuthProvider, identity *service.OAuthIdentity) {
+	if model.IsIdentityTaken(provider.Name, identity.ExternalId) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("该 %s 账户已被绑定", provider.DisplayName),
+		})
+		return
+	}
+	session := sessions.Default(c)
+	userId, ok := session.Get("id").(int)
+	if !ok {
+		common.ApiErrorMsg(c, "会话已过期，请重新登录")
+		return
+	}
+	if err := model.LinkUserIdentity(userId, provider.Name, identity.ExternalId, identity.Username, identity.Email); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "bind",
+	})
+}
+
+// GetSelfIdentities 获取当前用户绑定的全部外部身份
+func GetSelfIdentities(c *gin.Context) {
+	identities, err := model.GetUserIdentities(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, identities)
+}
+
+// UnbindSelfIdentity 解除当前用户与外部身份的绑定，没有设置密码时不能解除最后一个外部身份
+func UnbindSelfIdentity(c *gin.Context) {
+	userId := c.GetInt("id")
+	provider := c.Param("provider")
+	user, err := model.GetUserById(userId, true)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	identities, err := model.GetUserIdentities(userId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if user.Password == "" && len(identities) <= 1 {
+		common.ApiErrorMsg(c, "请先设置密码或绑定其他登录方式后再解除绑定")
+		return
+	}
+	if err = model.UnlinkUserIdentity(userId, provider); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			common.ApiErrorMsg(c, "未绑定该登录方式")
+			return
+		}
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordLog(userId, model.LogTypeManage, fmt.Sprintf("解除绑定登录方式 %s", provider))
+	common.ApiSuccess(c, nil)
+}
@@ -5,12 +5,13 @@ import (
 	"fmt"
 	"net/http"
 	"one-api/common"
+	"one-api/common/logsink"
 	"one-api/model"
+	"one-api/service"
 	"one-api/setting"
 	"one-api/setting/console_setting"
 	"one-api/setting/ratio_setting"
 	"one-api/setting/system_setting"
-	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,12 +20,9 @@ func GetOptions(c *gin.Context) {
 	var options []*model.Option
 	common.OptionMapRWMutex.Lock()
 	for k, v := range common.OptionMap {
-		if strings.HasSuffix(k, "Token") || strings.HasSuffix(k, "Secret") || strings.HasSuffix(k, "Key") {
-			continue
-		}
 		options = append(options, &model.Option{
 			Key:   k,
-			Value: common.Interface2String(v),
+			Value: model.MaskOptionValue(k, common.Interface2String(v)),
 		})
 	}
 	common.OptionMapRWMutex.Unlock()
@@ -61,6 +59,15 @@ func UpdateOption(c *gin.Context) {
 	default:
 		option.Value = fmt.Sprintf("%v", option.Value)
 	}
+	// 前端提交的密钥占位符还原为已保存的值
+	option.Value, err = model.RestoreMaskedOption(option.Key, option.Value.(string))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
 	switch option.Key {
 	case "GitHubOAuthEnabled":
 		if option.Value == "true" && common.GitHubClientId == "" {
@@ -78,6 +85,58 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "ldap.enabled":
+		if option.Value == "true" {
+			settings := *system_setting.GetLDAPSettings()
+			settings.Enabled = true
+			if err := service.ValidateLDAPSettings(&settings); err != nil {
+				c.JSON(http.StatusOK, gin.H{
+					"success": false,
+					"message": "无法启用 LDAP 登录，" + err.Error(),
+				})
+				return
+			}
+		}
+	case "oauth_providers.providers":
+		var providers []system_setting.OAuthProvider
+		if err := common.UnmarshalJsonStr(option.Value.(string), &providers); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "OAuth 提供方配置格式错误：" + err.Error(),
+			})
+			return
+		}
+		if err := service.ValidateOAuthProviders(providers); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case "log_sink_setting.sinks":
+		var sinks []logsink.Config
+		if err := common.UnmarshalJsonStr(option.Value.(string), &sinks); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "日志投递配置格式错误：" + err.Error(),
+			})
+			return
+		}
+		if err := logsink.Validate(sinks); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case "scim.enabled":
+		if option.Value == "true" && system_setting.GetSCIMSettings().TokenHash == "" {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无法启用 SCIM，请先生成 SCIM 令牌！",
+			})
+			return
+		}
 	case "LinuxDOOAuthEnabled":
 		if option.Value == "true" && common.LinuxDOClientId == "" {
 			c.JSON(http.StatusOK, gin.H{
@@ -174,11 +233,15 @@ func UpdateOption(c *gin.Context) {
 			return
 		}
 	}
+	common.OptionMapRWMutex.RLock()
+	before := common.OptionMap[option.Key]
+	common.OptionMapRWMutex.RUnlock()
 	err = model.UpdateOption(option.Key, option.Value.(string))
 	if err != nil {
 		common.ApiError(c, err)
 		return
 	}
+	model.RecordAudit(c, model.AuditResourceOption, option.Key, model.AuditActionUpdate, before, option.Value)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -0,0 +1,388 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/logger"
+	"one-api/model"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OrganizationMemberRequest struct {
+	UserId     int    `json:"user_id"`
+	Username   string `json:"username"`
+	Role       string `json:"role"`
+	QuotaLimit int    `json:"quota_limit"`
+}
+
+type OrganizationQuotaRequest struct {
+	Quota int `json:"quota"`
+}
+
+type OrganizationAdminRequest struct {
+	Id     int  `json:"id"`
+	Quota  *int `json:"quota"`
+	Status int  `json:"status"`
+}
+
+// getOrganizationMember 校验当前用户是否为路由中组织的成员
+func getOrganizationMember(c *gin.Context) (*model.Organization, *model.OrganizationMember, bool) {
+	orgId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return nil, nil, false
+	}
+	org, err := model.GetOrganizationById(orgId)
+	if err != nil {
+		common.ApiErrorMsg(c, "组织不存在")
+		return nil, nil, false
+	}
+	member, err := model.GetOrganizationMember(orgId, c.GetInt("id"))
+	if err != nil {
+		common.ApiErrorMsg(c, "无权访问该组织")
+		return nil, nil, false
+	}
+	return org, member, true
+}
+
+func validateOrganizationName(name string) error {
+	if utf8.RuneCountInString(name) == 0 || utf8.RuneCountInString(name) > 64 {
+		return errors.New("组织名称长度必须在1-64之间")
+	}
+	return nil
+}
+
+func GetSelfOrganizations(c *gin.Context) {
+	orgs, err := model.GetUserOrganizations(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, orgs)
+}
+
+func CreateOrganization(c *gin.Context) {
+	org := model.Organization{}
+	if err := c.ShouldBindJSON(&org); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := validateOrganizationName(org.Name); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	cleanOrg := model.Organization{
+		Name:    org.Name,
+		OwnerId: c.GetInt("id"),
+	}
+	if err := model.CreateOrganization(&cleanOrg); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, cleanOrg)
+}
+
+func GetOrganization(c *gin.Context) {
+	org, member, ok := getOrganizationMember(c)
+	if !ok {
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"organization": org,
+		"member":       member,
+	})
+}
+
+func UpdateOrganization(c *gin.Context) {
+	org, member, ok := getOrganizationMember(c)
+	if !ok {
+		return
+	}
+	if !member.CanManage() {
+		common.ApiErrorMsg(c, "权限不足")
+		return
+	}
+	req := model.Organization{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := validateOrganizationName(req.Name); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	org.Name = req.Name
+	if err := org.Update(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, org)
+}
+
+func DeleteOrganization(c *gin.Context) {
+	org, member, ok := getOrganizationMember(c)
+	if !ok {
+		return
+	}
+	if member.Role != model.OrgRoleOwner {
+		common.ApiErrorMsg(c, "只有组织所有者可以删除组织")
+		return
+	}
+	if err := model.DeleteOrganization(org.Id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func GetOrganizationMembers(c *gin.Context) {
+	org, _, ok := getOrganizationMember(c)
+	if !ok {
+		return
+	}
+	members, err := model.GetOrganizationMembers(org.Id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, members)
+}
+
+// canManageMember 所有者可以管理所有成员，管理员只能管理普通成员；所有者角色不可通过接口授予
+func canManageMember(operator *model.OrganizationMember, targetRole string, newRole string) bool {
+	if newRole == model.OrgRoleOwner || targetRole == model.OrgRoleOwner {
+		return false
+	}
+	if operator.Role == model.OrgRoleOwner {
+		return true
+	}
+	return operator.Role == model.OrgRoleAdmin && targetRole != model.OrgRoleAdmin && newRole != model.OrgRoleAdmin
+}
+
+func AddOrganizationMember(c *gin.Context) {
+	org, operator, ok := getOrganizationMember(c)
+	if !ok {
+		return
+	}
+	req := OrganizationMemberRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if req.Role == "" {
+		req.Role = model.OrgRoleMember
+	}
+	if !model.IsValidOrgRole(req.Role) || !canManageMember(operator, model.OrgRoleMember, req.Role) {
+		common.ApiErrorMsg(c, "权限不足")
+		return
+	}
+	if req.QuotaLimit < 0 {
+		common.ApiErrorMsg(c, "成员额度上限不能为负数")
+		return
+	}
+	userId := req.UserId
+	if req.Username != "" {
+		var err error
+		userId, err = model.GetUserIdByUsername(req.Username)
+		if err != nil {
+			common.ApiErrorMsg(c, "用户不存在")
+			return
+		}
+	}
+	if _, err := model.GetUserById(userId, false); err != nil {
+		common.ApiErrorMsg(c, "用户不存在")
+		return
+	}
+	member := model.OrganizationMember{
+		OrgId:      org.Id,
+		UserId:     userId,
+		Role:       req.Role,
+		QuotaLimit: req.QuotaLimit,
+	}
+	if err := model.AddOrganizationMember(&member); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, member)
+}
+
+func UpdateOrganizationMember(c *gin.Context) {
+	org, operator, ok := getOrganizationMember(c)
+	if !ok {
+		return
+	}
+	req := OrganizationMemberRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	member, err := model.GetOrganizationMember(org.Id, req.UserId)
+	if err != nil {
+		common.ApiErrorMsg(c, "成员不存在")
+		return
+	}
+	if req.Role == "" {
+		req.Role = member.Role
+	}
+	if !model.IsValidOrgRole(req.Role) {
+		common.ApiErrorMsg(c, "无效的角色")
+		return
+	}
+	// 所有者可以调整自己的额度上限，但不能修改自己的角色
+	isOwnerSelf := member.Role == model.OrgRoleOwner && operator.UserId == member.UserId && req.Role == model.OrgRoleOwner
+	if !isOwnerSelf && !canManageMember(operator, member.Role, req.Role) {
+		common.ApiErrorMsg(c, "权限不足")
+		return
+	}
+	if req.QuotaLimit < 0 {
+		common.ApiErrorMsg(c, "成员额度上限不能为负数")
+		return
+	}
+	member.Role = req.Role
+	member.QuotaLimit = req.QuotaLimit
+	if err = member.Update(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, member)
+}
+
+// RemoveOrganizationMember 移除成员，成员也可以主动退出组织
+func RemoveOrganizationMember(c *gin.Context) {
+	org, operator, ok := getOrganizationMember(c)
+	if !ok {
+		return
+	}
+	userId, _ := strconv.Atoi(c.Param("user_id"))
+	member, err := model.GetOrganizationMember(org.Id, userId)
+	if err != nil {
+		common.ApiErrorMsg(c, "成员不存在")
+		return
+	}
+	if member.Role == model.OrgRoleOwner {
+		common.ApiErrorMsg(c, "不能移除组织所有者")
+		return
+	}
+	if member.UserId != operator.UserId && !canManageMember(operator, member.Role, member.Role) {
+		common.ApiErrorMsg(c, "权限不足")
+		return
+	}
+	if err = model.RemoveOrganizationMember(org.Id, userId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// TransferOrganizationQuota 成员将自己的额度划入组织额度池
+func TransferOrganizationQuota(c *gin.Context) {
+	org, _, ok := getOrganizationMember(c)
+	if !ok {
+		return
+	}
+	req := OrganizationQuotaRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.TransferQuotaToOrganization(c.GetInt("id"), org.Id, req.Quota); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// GetOrganizationTokens 所有者与管理员可以查看全部成员的组织令牌（不含密钥），普通成员只能查看自己的
+func GetOrganizationTokens(c *gin.Context) {
+	org, member, ok := getOrganizationMember(c)
+	if !ok {
+		return
+	}
+	userId := member.UserId
+	if member.CanManage() {
+		userId, _ = strconv.Atoi(c.Query("user_id"))
+	}
+	pageInfo := common.GetPageQuery(c)
+	tokens, total, err := model.GetOrganizationTokens(org.Id, userId, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	for _, token := range tokens {
+		if token.UserId != member.UserId {
+			token.Clean()
+		}
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(tokens)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// GetOrganizationLogs 所有者与管理员可以查看全部成员的日志，普通成员只能查看自己的
+func GetOrganizationLogs(c *gin.Context) {
+	org, member, ok := getOrganizationMember(c)
+	if !ok {
+		return
+	}
+	userId := member.UserId
+	if member.CanManage() {
+		userId, _ = strconv.Atoi(c.Query("user_id"))
+	}
+	pageInfo := common.GetPageQuery(c)
+	logType, _ := strconv.Atoi(c.Query("type"))
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	logs, total, err := model.GetOrganizationLogs(org.Id, userId, logType, startTimestamp, endTimestamp,
+		c.Query("model_name"), c.Query("token_name"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(logs)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func GetAllOrganizations(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	orgs, total, err := model.GetAllOrganizations(c.Query("keyword"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(orgs)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// ManageOrganization 管理员设置组织额度或启用/禁用组织
+func ManageOrganization(c *gin.Context) {
+	req := OrganizationAdminRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	org, err := model.GetOrganizationById(req.Id)
+	if err != nil {
+		common.ApiErrorMsg(c, "组织不存在")
+		return
+	}
+	if req.Quota != nil {
+		if err = model.SetOrganizationQuota(org.Id, *req.Quota); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		model.RecordLog(c.GetInt("id"), model.LogTypeManage, fmt.Sprintf("管理员将组织 %s 的额度设置为 %s", org.Name, logger.FormatQuota(*req.Quota)))
+	}
+	if req.Status == model.OrganizationStatusEnabled || req.Status == model.OrganizationStatusDisabled {
+		if err = model.UpdateOrganizationStatus(org.Id, req.Status); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+	}
+	common.ApiSuccess(c, nil)
+}
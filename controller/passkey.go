@@ -0,0 +1,301 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"one-api/setting/system_setting"
+	"strconv"
+	"strings"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 会话中保存 WebAuthn 仪式状态的键
+const (
+	passkeyRegistrationSessionKey = "passkey_registration"
+	passkeyLoginSessionKey        = "passkey_login"
+	passkeyVerifySessionKey       = "passkey_2fa"
+)
+
+type PasskeyRenameRequest struct {
+	Name string `json:"name"`
+}
+
+// savePasskeyCeremony 将仪式状态保存到会话中，完成时取出并删除，保证挑战只能使用一次
+func savePasskeyCeremony(c *gin.Context, key string, data string) error {
+	session := sessions.Default(c)
+	session.Set(key, data)
+	return session.Save()
+}
+
+func takePasskeyCeremony(c *gin.Context, key string) string {
+	session := sessions.Default(c)
+	data, _ := session.Get(key).(string)
+	session.Delete(key)
+	_ = session.Save()
+	return data
+}
+
+// checkPasskeyChangeAllowed 必须使用通行密钥的用户注册或删除通行密钥前需要通过通行密钥验证
+func checkPasskeyChangeAllowed(c *gin.Context) bool {
+	userId := c.GetInt("id")
+	required := service.PasskeyRequiredForUser(userId, c.GetInt("role"))
+	if !service.PasskeyChangeAllowed(required, required && model.HasPasskey(userId), sessions.Default(c).Get("passkey_verified") == true) {
+		common.ApiErrorMsg(c, "请使用通行密钥登录后再管理通行密钥")
+		return false
+	}
+	return true
+}
+
+// PasskeyRegisterBegin 开始注册通行密钥
+func PasskeyRegisterBegin(c *gin.Context) {
+	if !checkPasskeyChangeAllowed(c) {
+		return
+	}
+	user, err := model.GetUserById(c.GetInt("id"), false)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	options, sessionData, err := service.BeginPasskeyRegistration(user)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = savePasskeyCeremony(c, passkeyRegistrationSessionKey, sessionData); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, options)
+}
+
+// PasskeyRegisterFinish 完成注册，请求体为浏览器 navigator.credentials.create() 的结果，名称通过 name 查询参数传递
+func PasskeyRegisterFinish(c *gin.Context) {
+	if !checkPasskeyChangeAllowed(c) {
+		return
+	}
+	user, err := model.GetUserById(c.GetInt("id"), false)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	name := strings.TrimSpace(c.Query("name"))
+	if len(name) > 64 {
+		common.ApiErrorMsg(c, "名称过长")
+		return
+	}
+	sessionData := takePasskeyCeremony(c, passkeyRegistrationSessionKey)
+	passkey, err := service.FinishPasskeyRegistration(user, sessionData, c.Request, name)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordLog(user.Id, model.LogTypeManage, fmt.Sprintf("注册通行密钥 %s", passkey.Name))
+	common.ApiSuccess(c, passkey)
+}
+
+func GetSelfPasskeys(c *gin.Context) {
+	passkeys, err := model.GetUserPasskeys(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, passkeys)
+}
+
+func RenameSelfPasskey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorMsg(c, "无效的参数")
+		return
+	}
+	var req PasskeyRenameRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "无效的参数")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 64 {
+		common.ApiErrorMsg(c, "名称不能为空且不能超过 64 个字符")
+		return
+	}
+	if err = model.RenamePasskey(id, c.GetInt("id"), req.Name); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			common.ApiErrorMsg(c, "通行密钥不存在")
+			return
+		}
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func DeleteSelfPasskey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorMsg(c, "无效的参数")
+		return
+	}
+	if !checkPasskeyChangeAllowed(c) {
+		return
+	}
+	userId := c.GetInt("id")
+	// 必须使用通行密钥的管理员不能删除最后一个通行密钥，否则将无法访问管理接口
+	if service.PasskeyRequiredForUser(userId, c.GetInt("role")) {
+		count, err := model.CountUserPasskeys(userId)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		if count <= 1 {
+			common.ApiErrorMsg(c, "管理员账户必须保留至少一个通行密钥")
+			return
+		}
+	}
+	if err = model.DeletePasskey(id, userId); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			common.ApiErrorMsg(c, "通行密钥不存在")
+			return
+		}
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordLog(userId, model.LogTypeManage, fmt.Sprintf("删除通行密钥 #%d", id))
+	common.ApiSuccess(c, nil)
+}
+
+// PasskeyLoginBegin 开始无密码登录
+func PasskeyLoginBegin(c *gin.Context) {
+	options, sessionData, err := service.BeginPasskeyLogin()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = savePasskeyCeremony(c, passkeyLoginSessionKey, sessionData); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, options)
+}
+
+// PasskeyLoginFinish 完成无密码登录，通行密钥要求用户验证，本身即为多因素认证，不再要求两步验证
+func PasskeyLoginFinish(c *gin.Context) {
+	sessionData := takePasskeyCeremony(c, passkeyLoginSessionKey)
+	user, err := service.FinishPasskeyLogin(sessionData, c.Request)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if user.Status != common.UserStatusEnabled {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "用户已被封禁",
+			"success": false,
+		})
+		return
+	}
+	c.Set("passkey_verified", true)
+	setupLogin(user, c)
+}
+
+func getPendingTwoFAUser(c *gin.Context) (*model.User, bool) {
+	session := sessions.Default(c)
+	userId, ok := session.Get("pending_user_id").(int)
+	if !ok {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "会话已过期，请重新登录",
+		})
+		return nil, false
+	}
+	user, err := model.GetUserById(userId, false)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "用户不存在",
+		})
+		return nil, false
+	}
+	return user, true
+}
+
+// Verify2FAPasskeyBegin 密码验证通过后，使用通行密钥完成两步验证
+func Verify2FAPasskeyBegin(c *gin.Context) {
+	user, ok := getPendingTwoFAUser(c)
+	if !ok {
+		return
+	}
+	options, sessionData, err := service.BeginPasskeyVerification(user)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = savePasskeyCeremony(c, passkeyVerifySessionKey, sessionData); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, options)
+}
+
+func Verify2FAPasskeyFinish(c *gin.Context) {
+	user, ok := getPendingTwoFAUser(c)
+	if !ok {
+		return
+	}
+	sessionData := takePasskeyCeremony(c, passkeyVerifySessionKey)
+	if err := service.FinishPasskeyVerification(user, sessionData, c.Request); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+
+	// 2FA验证成功，清理pending会话信息并完成登录
+	session := sessions.Default(c)
+	session.Delete("pending_username")
+	session.Delete("pending_user_id")
+	session.Save()
+
+	c.Set("passkey_verified", true)
+	setupLogin(user, c)
+}
+
+// AdminPasskeyStats 管理员获取通行密钥统计信息
+func AdminPasskeyStats(c *gin.Context) {
+	stats, err := model.GetPasskeyStats()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	stats["require_for_admin"] = system_setting.GetPasskeySettings().RequireForAdmin
+	common.ApiSuccess(c, stats)
+}
+
+// AdminDeletePasskeys 管理员清除用户的全部通行密钥，用于设备丢失等场景
+func AdminDeletePasskeys(c *gin.Context) {
+	userId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorMsg(c, "用户ID格式错误")
+		return
+	}
+	targetUser, err := model.GetUserById(userId, false)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	myRole := c.GetInt("role")
+	if (myRole <= targetUser.Role && myRole != common.RoleRootUser) || !canManageUser(c, targetUser) {
+		common.ApiErrorMsg(c, "无权操作同级或更高级用户的通行密钥")
+		return
+	}
+	if err = model.DeleteUserPasskeys(userId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordLog(userId, model.LogTypeManage,
+		fmt.Sprintf("管理员(ID:%d)清除了用户的全部通行密钥", c.GetInt("id")))
+	common.ApiSuccess(c, nil)
+}
@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+type PersonalAccessTokenRequest struct {
+	Name        string   `json:"name"`
+	Scopes      []string `json:"scopes"`
+	AllowIps    string   `json:"allow_ips"`
+	ExpiredTime int64    `json:"expired_time"`
+}
+
+func validateAllowIps(allowIps string) error {
+	for _, line := range strings.Split(allowIps, "\n") {
+		line = strings.TrimSpace(strings.ReplaceAll(line, ",", ""))
+		if line == "" {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(line); err == nil {
+			continue
+		}
+		if !common.IsIP(line) {
+			return fmt.Errorf("无效的 IP 或 CIDR: %s", line)
+		}
+	}
+	return nil
+}
+
+func validatePersonalAccessTokenRequest(req *PersonalAccessTokenRequest) error {
+	req.Name = strings.TrimSpace(req.Name)
+	if utf8.RuneCountInString(req.Name) == 0 || utf8.RuneCountInString(req.Name) > 64 {
+		return errors.New("令牌名称长度必须在1-64之间")
+	}
+	if req.ExpiredTime == 0 {
+		req.ExpiredTime = -1
+	}
+	if req.ExpiredTime != -1 && req.ExpiredTime <= common.GetTimestamp() {
+		return errors.New("过期时间必须晚于当前时间")
+	}
+	return validateAllowIps(req.AllowIps)
+}
+
+func GetSelfPersonalAccessTokens(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	pats, total, err := model.GetUserPersonalAccessTokens(c.GetInt("id"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(pats)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// CreatePersonalAccessToken 创建个人访问令牌，明文只在本次响应中返回
+func CreatePersonalAccessToken(c *gin.Context) {
+	// 令牌不能再派生新的令牌，否则可以绕过有效期与 IP 限制
+	if c.GetBool("use_access_token") {
+		common.ApiErrorMsg(c, "请登录后创建访问令牌")
+		return
+	}
+	if !service.PasskeyVerifiedForUser(c.GetInt("id"), c.GetInt("role"), sessions.Default(c).Get("passkey_verified") == true) {
+		common.ApiErrorMsg(c, "管理员需要使用通行密钥登录后才能创建访问令牌")
+		return
+	}
+	req := PersonalAccessTokenRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := validatePersonalAccessTokenRequest(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	userId := c.GetInt("id")
+	granted, err := model.GetUserPermissions(userId, c.GetInt("role"), false)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if !model.ContainsAllPermissions(granted, req.Scopes) {
+		common.ApiErrorMsg(c, "不能授予自己不具备的权限")
+		return
+	}
+	pat := model.PersonalAccessToken{
+		UserId:      userId,
+		Name:        req.Name,
+		AllowIps:    req.AllowIps,
+		ExpiredTime: req.ExpiredTime,
+	}
+	if err = pat.SetScopes(req.Scopes); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	key, err := model.GeneratePersonalAccessTokenKey()
+	if err != nil {
+		common.ApiErrorMsg(c, "生成失败")
+		common.SysLog("failed to generate personal access token: " + err.Error())
+		return
+	}
+	if err = pat.Insert(key); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordLog(userId, model.LogTypeManage, fmt.Sprintf("创建访问令牌 %s (%s)，权限: %s", pat.Name, pat.TokenPrefix, pat.Scopes))
+	common.ApiSuccess(c, gin.H{
+		"token": pat,
+		"key":   key,
+	})
+}
+
+func RevokeSelfPersonalAccessToken(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	userId := c.GetInt("id")
+	pat, err := model.RevokePersonalAccessToken(id, userId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordLog(userId, model.LogTypeManage, fmt.Sprintf("吊销访问令牌 %s (%s)", pat.Name, pat.TokenPrefix))
+	common.ApiSuccess(c, nil)
+}
+
+func GetAllPersonalAccessTokens(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	pats, total, err := model.GetUserPersonalAccessTokens(userId, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(pats)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// RevokePersonalAccessToken 管理员吊销任意用户的访问令牌
+func RevokePersonalAccessToken(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	pat, err := model.GetPersonalAccessTokenById(id)
+	if err != nil {
+		common.ApiErrorMsg(c, "访问令牌不存在")
+		return
+	}
+	owner, err := model.GetUserById(pat.UserId, false)
+	myRole := c.GetInt("role")
+	if err == nil && owner.Id != c.GetInt("id") && ((!model.CanManageUserRole(myRole, owner.Role) && myRole != common.RoleRootUser) || !canManageUser(c, owner)) {
+		common.ApiErrorMsg(c, "无权吊销同权限等级或更高权限等级用户的访问令牌")
+		return
+	}
+	if _, err = model.RevokePersonalAccessToken(id, 0); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordLog(pat.UserId, model.LogTypeManage, fmt.Sprintf("管理员吊销了访问令牌 %s (%s)", pat.Name, pat.TokenPrefix))
+	common.ApiSuccess(c, nil)
+}
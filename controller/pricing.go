@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"one-api/common"
 	"one-api/model"
 	"one-api/setting"
 	"one-api/setting/ratio_setting"
@@ -51,6 +52,9 @@ func GetPricing(c *gin.Context) {
 
 func ResetModelRatio(c *gin.Context) {
 	defaultStr := ratio_setting.DefaultModelRatio2JSONString()
+	common.OptionMapRWMutex.RLock()
+	before := common.OptionMap["ModelRatio"]
+	common.OptionMapRWMutex.RUnlock()
 	err := model.UpdateOption("ModelRatio", defaultStr)
 	if err != nil {
 		c.JSON(200, gin.H{
@@ -67,6 +71,7 @@ func ResetModelRatio(c *gin.Context) {
 		})
 		return
 	}
+	model.RecordAudit(c, model.AuditResourceOption, "ModelRatio", model.AuditActionReset, before, defaultStr)
 	c.JSON(200, gin.H{
 		"success": true,
 		"message": "重置模型倍率成功",
@@ -130,6 +130,18 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		return
 	}
 
+	// 超出模型上下文窗口时按策略拒绝、截断或改用更大上下文的模型，避免在各渠道间无效重试
+	tokens, contextWindow, newAPIError := service.ApplyContextWindowPolicy(c, relayInfo, meta, tokens)
+	if newAPIError != nil {
+		return
+	}
+	if contextWindow != nil && contextWindow.ToModel != "" {
+		if newAPIError = rerouteForContextWindow(c, relayInfo, group, contextWindow.ToModel); newAPIError != nil {
+			return
+		}
+		originalModel = contextWindow.ToModel
+	}
+
 	relayInfo.SetPromptTokens(tokens)
 
 	priceData, err := helper.ModelPriceHelper(c, relayInfo, tokens, meta)
@@ -144,6 +156,8 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 	if newAPIError != nil {
 		return
 	}
+	// 流式响应按剩余额度限制输出长度，防止未设置 max_tokens 的长输出把额度扣成负数
+	service.InitStreamBudget(c, relayInfo)
 
 	defer func() {
 		// Only return quota if downstream failed and quota was actually pre-consumed
@@ -164,6 +178,12 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		requestBody, _ := common.GetRequestBody(c)
 		c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
 
+		// 渠道配置了响应改写时，在解析用量之后改写写给客户端的内容
+		var overrideWriter *relaycommon.ResponseOverrideWriter
+		if len(common.GetContextKeyStringMap(c, constant.ContextKeyChannelResponseOverride)) > 0 && relayFormat != types.RelayFormatOpenAIRealtime {
+			overrideWriter = relaycommon.NewResponseOverrideWriter(c, relayInfo)
+		}
+
 		switch relayFormat {
 		case types.RelayFormatOpenAIRealtime:
 			newAPIError = relay.WssHelper(c, relayInfo)
@@ -174,6 +194,9 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		default:
 			newAPIError = relayHandler(c, relayInfo)
 		}
+		if overrideWriter != nil {
+			overrideWriter.Finish(c)
+		}
 
 		if newAPIError == nil {
 			return
@@ -234,6 +257,21 @@ func getChannel(c *gin.Context, group, originalModel string, retryCount int) (*m
 	return channel, nil
 }
 
+// rerouteForContextWindow 改用更大上下文的模型并重新选择渠道
+func rerouteForContextWindow(c *gin.Context, info *relaycommon.RelayInfo, group string, modelName string) *types.NewAPIError {
+	channel, selectGroup, err := model.CacheGetRandomSatisfiedChannel(c, group, modelName, 0)
+	if err != nil || channel == nil {
+		return types.NewError(fmt.Errorf("提示词超出上下文窗口，且分组 %s 下模型 %s 无可用渠道", selectGroup, modelName), types.ErrorCodeGetChannelFailed, types.ErrOptionWithSkipRetry())
+	}
+	if newAPIError := middleware.SetupContextForSelectedChannel(c, channel, modelName); newAPIError != nil {
+		return newAPIError
+	}
+	info.OriginModelName = modelName
+	info.Request.SetModelName(modelName)
+	logger.LogInfo(c, fmt.Sprintf("prompt exceeds the context window, rerouted to model %s (channel #%d)", modelName, channel.Id))
+	return nil
+}
+
 func shouldRetry(c *gin.Context, openaiErr *types.NewAPIError, retryTimes int) bool {
 	if openaiErr == nil {
 		return false
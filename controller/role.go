@@ -0,0 +1,256 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/model"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RoleRequest struct {
+	Id          int      `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+type RoleBindRequest struct {
+	UserId            int  `json:"user_id"`
+	RoleId            *int `json:"role_id"`
+	AccessTokenRoleId *int `json:"access_token_role_id"`
+}
+
+// getOperatorPermissions 返回当前操作者的有效权限，由 RequirePermission 写入上下文
+func getOperatorPermissions(c *gin.Context) []string {
+	permissions, ok := c.Get("permissions")
+	if !ok {
+		granted, _ := model.GetRequestPermissions(c)
+		return granted
+	}
+	granted, _ := permissions.([]string)
+	return granted
+}
+
+// canManageUser 读写其他用户时，操作者必须具备目标用户的全部权限
+func canManageUser(c *gin.Context, target *model.User) bool {
+	return model.CanManageUserPermissions(getOperatorPermissions(c), target)
+}
+
+// buildRole 校验请求并生成角色，操作者不能授予自己不具备的权限
+func buildRole(c *gin.Context, req *RoleRequest) (*model.Role, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	if utf8.RuneCountInString(req.Name) == 0 || utf8.RuneCountInString(req.Name) > 64 {
+		return nil, errors.New("角色名称长度必须在1-64之间")
+	}
+	if utf8.RuneCountInString(req.Description) > 255 {
+		return nil, errors.New("角色描述过长")
+	}
+	if !model.ContainsAllPermissions(getOperatorPermissions(c), req.Permissions) {
+		return nil, errors.New("不能授予自己不具备的权限")
+	}
+	role := &model.Role{
+		Id:          req.Id,
+		Name:        req.Name,
+		Description: req.Description,
+	}
+	if err := role.SetPermissions(req.Permissions); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+func GetAllRoles(c *gin.Context) {
+	roles, err := model.GetAllRoles()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, roles)
+}
+
+// GetAllPermissions 列出全部权限点及内置角色的默认权限，供角色编辑页使用
+func GetAllPermissions(c *gin.Context) {
+	common.ApiSuccess(c, gin.H{
+		"permissions": model.AllPermissions,
+		"defaults": gin.H{
+			"admin": model.DefaultRolePermissions(common.RoleAdminUser),
+			"root":  model.DefaultRolePermissions(common.RoleRootUser),
+		},
+	})
+}
+
+func AddRole(c *gin.Context) {
+	req := RoleRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	req.Id = 0
+	role, err := buildRole(c, &req)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = role.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordAudit(c, model.AuditResourceRole, role.Id, model.AuditActionCreate, nil, role)
+	common.ApiSuccess(c, role)
+}
+
+func UpdateRole(c *gin.Context) {
+	req := RoleRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	existing, err := model.GetRoleById(req.Id)
+	if err != nil {
+		common.ApiErrorMsg(c, "角色不存在")
+		return
+	}
+	// 修改角色会影响所有绑定的用户，因此原有权限同样不能超出操作者的权限
+	if !model.ContainsAllPermissions(getOperatorPermissions(c), existing.GetPermissions()) {
+		common.ApiErrorMsg(c, "不能修改包含自己不具备权限的角色")
+		return
+	}
+	role, err := buildRole(c, &req)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = role.Update(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordAudit(c, model.AuditResourceRole, role.Id, model.AuditActionUpdate, existing, role)
+	common.ApiSuccess(c, role)
+}
+
+func DeleteRole(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	role, err := model.GetRoleById(id)
+	if err != nil {
+		common.ApiErrorMsg(c, "角色不存在")
+		return
+	}
+	if !model.ContainsAllPermissions(getOperatorPermissions(c), role.GetPermissions()) {
+		common.ApiErrorMsg(c, "不能删除包含自己不具备权限的角色")
+		return
+	}
+	if err = model.DeleteRoleById(id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordAudit(c, model.AuditResourceRole, id, model.AuditActionDelete, role, nil)
+	common.ApiSuccess(c, nil)
+}
+
+// checkBindableRole 校验角色存在且不超出操作者的权限，roleId 为 0 表示解除绑定
+func checkBindableRole(c *gin.Context, roleId int) error {
+	if roleId == 0 {
+		return nil
+	}
+	role, err := model.GetRoleById(roleId)
+	if err != nil {
+		return errors.New("角色不存在")
+	}
+	if !model.ContainsAllPermissions(getOperatorPermissions(c), role.GetPermissions()) {
+		return errors.New("不能绑定包含自己不具备权限的角色")
+	}
+	return nil
+}
+
+// BindUserRole 为用户或其系统访问令牌绑定自定义角色
+func BindUserRole(c *gin.Context) {
+	req := RoleBindRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	user, err := model.GetUserById(req.UserId, false)
+	if err != nil {
+		common.ApiErrorMsg(c, "用户不存在")
+		return
+	}
+	myRole := c.GetInt("role")
+	if user.Id != c.GetInt("id") && ((!model.CanManageUserRole(myRole, user.Role) && myRole != common.RoleRootUser) || !canManageUser(c, user)) {
+		common.ApiErrorMsg(c, "无权为同权限等级或更高权限等级的用户绑定角色")
+		return
+	}
+	if req.RoleId != nil {
+		if err = checkBindableRole(c, *req.RoleId); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		if err = model.BindUserRole(user.Id, *req.RoleId); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+	}
+	if req.AccessTokenRoleId != nil {
+		if err = checkBindableRole(c, *req.AccessTokenRoleId); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		if err = model.BindAccessTokenRole(user.Id, *req.AccessTokenRoleId); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+	}
+	model.RecordLog(c.GetInt("id"), model.LogTypeManage, fmt.Sprintf("管理员更新了用户 %s 的角色绑定", user.Username))
+	before := gin.H{"role_id": user.RoleId, "access_token_role_id": user.AccessTokenRoleId}
+	after := gin.H{"role_id": user.RoleId, "access_token_role_id": user.AccessTokenRoleId}
+	if req.RoleId != nil {
+		after["role_id"] = *req.RoleId
+	}
+	if req.AccessTokenRoleId != nil {
+		after["access_token_role_id"] = *req.AccessTokenRoleId
+	}
+	model.RecordAudit(c, model.AuditResourceUser, user.Id, model.AuditActionUpdate, before, after)
+	common.ApiSuccess(c, nil)
+}
+
+// BindSelfAccessTokenRole 用户为自己的系统访问令牌绑定角色，令牌权限只会被收窄，不会超出用户本身的权限
+func BindSelfAccessTokenRole(c *gin.Context) {
+	req := RoleBindRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil || req.AccessTokenRoleId == nil {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	// 通过访问令牌调用时不允许修改令牌自身的权限范围
+	if c.GetBool("use_access_token") {
+		common.ApiErrorMsg(c, "请登录后修改访问令牌的权限范围")
+		return
+	}
+	if *req.AccessTokenRoleId != 0 {
+		if _, err := model.GetRoleById(*req.AccessTokenRoleId); err != nil {
+			common.ApiErrorMsg(c, "角色不存在")
+			return
+		}
+	}
+	if err := model.BindAccessTokenRole(c.GetInt("id"), *req.AccessTokenRoleId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// GetSelfPermissions 返回当前用户的有效权限以及据此生成的默认边栏配置
+func GetSelfPermissions(c *gin.Context) {
+	permissions, err := model.GetRequestPermissions(c)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"permissions":    permissions,
+		"sidebar_config": generateDefaultSidebarConfig(permissions),
+	})
+}
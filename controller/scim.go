@@ -0,0 +1,802 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/service"
+	"one-api/setting/ratio_setting"
+	"one-api/setting/system_setting"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	scimDefaultCount = 100
+	scimMaxCount     = 200
+	// scimDefaultGroup 被移出 SCIM 分组的用户回到的分组
+	scimDefaultGroup = "default"
+)
+
+var scimMemberPathRegex = regexp.MustCompile(`(?i)^members\[value eq "([^"]+)"\]$`)
+
+// scimUserState SCIM 可修改的用户属性，用于统一处理 POST、PUT 与 PATCH
+type scimUserState struct {
+	UserName    string
+	DisplayName string
+	Email       string
+	ExternalId  string
+	Active      bool
+	Password    string
+}
+
+func scimJSON(c *gin.Context, status int, obj any) {
+	c.Header("Content-Type", dto.ScimContentType)
+	c.JSON(status, obj)
+}
+
+func scimError(c *gin.Context, status int, scimType string, detail string) {
+	scimJSON(c, status, dto.ScimError{
+		Schemas:  []string{dto.ScimSchemaError},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	})
+}
+
+func scimLocation(resourceType string, id string) string {
+	return strings.TrimSuffix(system_setting.ServerAddress, "/") + "/scim/v2/" + resourceType + "/" + id
+}
+
+func userToScim(user *model.User) *dto.ScimUser {
+	active := user.Status == common.UserStatusEnabled
+	resource := &dto.ScimUser{
+		Schemas:     []string{dto.ScimSchemaUser},
+		Id:          strconv.Itoa(user.Id),
+		ExternalId:  user.ExternalId,
+		UserName:    user.Username,
+		Name:        &dto.ScimName{Formatted: user.DisplayName},
+		DisplayName: user.DisplayName,
+		Active:      &active,
+		Meta: &dto.ScimMeta{
+			ResourceType: "User",
+			Location:     scimLocation("Users", strconv.Itoa(user.Id)),
+		},
+	}
+	if user.Email != "" {
+		resource.Emails = []dto.ScimMultiValue{{Value: user.Email, Type: "work", Primary: true}}
+	}
+	if user.Group != "" {
+		resource.Groups = []dto.ScimMultiValue{{Value: user.Group, Display: user.Group, Ref: scimLocation("Groups", user.Group)}}
+	}
+	return resource
+}
+
+func groupToScim(name string, members []*model.User) *dto.ScimGroup {
+	resource := &dto.ScimGroup{
+		Schemas:     []string{dto.ScimSchemaGroup},
+		Id:          name,
+		DisplayName: name,
+		Meta: &dto.ScimMeta{
+			ResourceType: "Group",
+			Location:     scimLocation("Groups", name),
+		},
+	}
+	for _, member := range members {
+		resource.Members = append(resource.Members, dto.ScimMultiValue{
+			Value:   strconv.Itoa(member.Id),
+			Display: member.Username,
+			Ref:     scimLocation("Users", strconv.Itoa(member.Id)),
+		})
+	}
+	return resource
+}
+
+// scimPagination 解析 startIndex（从 1 开始）与 count
+func scimPagination(c *gin.Context) (startIndex int, count int) {
+	startIndex, _ = strconv.Atoi(c.Query("startIndex"))
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	count, err := strconv.Atoi(c.Query("count"))
+	if err != nil {
+		count = scimDefaultCount
+	}
+	if count < 0 {
+		count = 0
+	}
+	if count > scimMaxCount {
+		count = scimMaxCount
+	}
+	return startIndex, count
+}
+
+func scimListResponse(c *gin.Context, resources any, total int, startIndex int, itemsPerPage int) {
+	scimJSON(c, http.StatusOK, dto.ScimListResponse{
+		Schemas:      []string{dto.ScimSchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: itemsPerPage,
+		Resources:    resources,
+	})
+}
+
+func scimString(raw json.RawMessage) (string, error) {
+	var value any
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return "", err
+		}
+	}
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+	return "", errors.New("expected a string value")
+}
+
+// scimBool 解析布尔值，部分身份提供方（例如 Entra ID）在 PATCH 中以 "True"/"False" 字符串传递
+func scimBool(raw json.RawMessage) (bool, error) {
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return false, err
+	}
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		return strconv.ParseBool(strings.ToLower(v))
+	}
+	return false, errors.New("expected a boolean value")
+}
+
+func scimPrimaryEmail(emails []dto.ScimMultiValue) string {
+	for _, email := range emails {
+		if email.Primary {
+			return email.Value
+		}
+	}
+	if len(emails) > 0 {
+		return emails[0].Value
+	}
+	return ""
+}
+
+func scimDisplayName(resource *dto.ScimUser) string {
+	if resource.DisplayName != "" {
+		return resource.DisplayName
+	}
+	if resource.Name != nil {
+		if resource.Name.Formatted != "" {
+			return resource.Name.Formatted
+		}
+		if name := strings.TrimSpace(resource.Name.GivenName + " " + resource.Name.FamilyName); name != "" {
+			return name
+		}
+	}
+	return resource.UserName
+}
+
+func newScimUserState(user *model.User) *scimUserState {
+	return &scimUserState{
+		UserName:    user.Username,
+		DisplayName: user.DisplayName,
+		Email:       user.Email,
+		ExternalId:  user.ExternalId,
+		Active:      user.Status == common.UserStatusEnabled,
+	}
+}
+
+func (s *scimUserState) applyResource(resource *dto.ScimUser) {
+	s.UserName = resource.UserName
+	s.DisplayName = scimDisplayName(resource)
+	s.Email = scimPrimaryEmail(resource.Emails)
+	s.ExternalId = resource.ExternalId
+	if resource.Active != nil {
+		s.Active = *resource.Active
+	}
+	s.Password = resource.Password
+}
+
+// setAttribute 修改单个属性，未知属性（例如企业扩展属性）会被忽略
+func (s *scimUserState) setAttribute(path string, raw json.RawMessage, remove bool) error {
+	var err error
+	switch {
+	case path == "username":
+		if remove {
+			return errors.New("userName is required")
+		}
+		s.UserName, err = scimString(raw)
+	case path == "displayname" || path == "name.formatted":
+		s.DisplayName, err = scimString(raw)
+	case path == "name":
+		var name dto.ScimName
+		if !remove {
+			err = json.Unmarshal(raw, &name)
+		}
+		if name.Formatted != "" {
+			s.DisplayName = name.Formatted
+		}
+	case path == "externalid":
+		s.ExternalId, err = scimString(raw)
+	case path == "active":
+		if remove {
+			return errors.New("active cannot be removed")
+		}
+		s.Active, err = scimBool(raw)
+	case path == "password":
+		s.Password, err = scimString(raw)
+	case path == "emails":
+		var emails []dto.ScimMultiValue
+		if !remove {
+			err = json.Unmarshal(raw, &emails)
+		}
+		s.Email = scimPrimaryEmail(emails)
+	case path == "emails.value" || strings.HasPrefix(path, "emails[") && strings.HasSuffix(path, "].value"):
+		s.Email, err = scimString(raw)
+	}
+	return err
+}
+
+func (s *scimUserState) applyPatch(operation dto.ScimPatchOperation) error {
+	op := strings.ToLower(operation.Op)
+	if op != "add" && op != "replace" && op != "remove" {
+		return fmt.Errorf("unsupported op: %s", operation.Op)
+	}
+	path := strings.ToLower(strings.TrimSpace(operation.Path))
+	if path == "" {
+		if op == "remove" {
+			return errors.New("path is required for remove")
+		}
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(operation.Value, &values); err != nil {
+			return err
+		}
+		for key, value := range values {
+			if err := s.setAttribute(strings.ToLower(key), value, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return s.setAttribute(path, operation.Value, op == "remove")
+}
+
+func getScimUser(c *gin.Context) (*model.User, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		scimError(c, http.StatusNotFound, dto.ScimErrorNoTarget, "user not found")
+		return nil, false
+	}
+	user, err := model.GetUserById(id, false)
+	if err != nil {
+		scimError(c, http.StatusNotFound, dto.ScimErrorNoTarget, "user not found")
+		return nil, false
+	}
+	return user, true
+}
+
+// saveScimUser 将修改写回数据库，停用时立即禁用用户的全部令牌
+func saveScimUser(c *gin.Context, user *model.User, state *scimUserState) bool {
+	if user.Role == common.RoleRootUser {
+		scimError(c, http.StatusForbidden, dto.ScimErrorMutability, "root user cannot be managed through SCIM")
+		return false
+	}
+	updates := make(map[string]interface{})
+	if state.UserName != user.Username {
+		if state.UserName == "" {
+			scimError(c, http.StatusBadRequest, dto.ScimErrorInvalidValue, "userName is required")
+			return false
+		}
+		exist, err := model.CheckUserExistOrDeleted(state.UserName, "")
+		if err != nil {
+			scimError(c, http.StatusInternalServerError, "", err.Error())
+			return false
+		}
+		if exist {
+			scimError(c, http.StatusConflict, dto.ScimErrorUniqueness, "userName already exists")
+			return false
+		}
+		updates["username"] = state.UserName
+	}
+	if state.DisplayName != user.DisplayName {
+		updates["display_name"] = state.DisplayName
+	}
+	if state.Email != user.Email {
+		updates["email"] = state.Email
+	}
+	if state.ExternalId != user.ExternalId {
+		updates["external_id"] = state.ExternalId
+	}
+	if state.Password != "" {
+		hashedPassword, err := common.Password2Hash(state.Password)
+		if err != nil {
+			scimError(c, http.StatusInternalServerError, "", err.Error())
+			return false
+		}
+		updates["password"] = hashedPassword
+	}
+	wasActive := user.Status == common.UserStatusEnabled
+	if state.Active && !wasActive {
+		updates["status"] = common.UserStatusEnabled
+	}
+	if err := model.UpdateUserFields(user.Id, updates); err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return false
+	}
+	if !state.Active && wasActive {
+		count, err := model.DeactivateUser(user.Id)
+		if err != nil {
+			scimError(c, http.StatusInternalServerError, "", err.Error())
+			return false
+		}
+		model.RecordLog(user.Id, model.LogTypeManage, fmt.Sprintf("SCIM 停用用户，同时禁用了 %d 个令牌", count))
+	} else if state.Active && !wasActive {
+		model.RecordLog(user.Id, model.LogTypeManage, "SCIM 启用用户，此前被禁用的令牌需要手动启用")
+	}
+	return true
+}
+
+func respondScimUser(c *gin.Context, userId int, status int) {
+	user, err := model.GetUserById(userId, false)
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	c.Header("Location", scimLocation("Users", strconv.Itoa(user.Id)))
+	scimJSON(c, status, userToScim(user))
+}
+
+func ScimServiceProviderConfig(c *gin.Context) {
+	scimJSON(c, http.StatusOK, gin.H{
+		"schemas":        []string{dto.ScimSchemaServiceConfig},
+		"patch":          gin.H{"supported": true},
+		"bulk":           gin.H{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         gin.H{"supported": true, "maxResults": scimMaxCount},
+		"changePassword": gin.H{"supported": true},
+		"sort":           gin.H{"supported": false},
+		"etag":           gin.H{"supported": false},
+		"authenticationSchemes": []gin.H{{
+			"type":        "oauthbearertoken",
+			"name":        "OAuth Bearer Token",
+			"description": "Authentication scheme using the SCIM bearer token generated in system settings",
+		}},
+	})
+}
+
+func ScimResourceTypes(c *gin.Context) {
+	resources := []gin.H{
+		{"schemas": []string{dto.ScimSchemaResourceType}, "id": "User", "name": "User", "endpoint": "/Users", "schema": dto.ScimSchemaUser},
+		{"schemas": []string{dto.ScimSchemaResourceType}, "id": "Group", "name": "Group", "endpoint": "/Groups", "schema": dto.ScimSchemaGroup},
+	}
+	scimListResponse(c, resources, len(resources), 1, len(resources))
+}
+
+func ScimListUsers(c *gin.Context) {
+	conditions, err := model.ParseScimFilter(c.Query("filter"))
+	if err != nil {
+		scimError(c, http.StatusBadRequest, dto.ScimErrorInvalidFilter, err.Error())
+		return
+	}
+	startIndex, count := scimPagination(c)
+	users, total, err := model.GetScimUsers(conditions, startIndex-1, count)
+	if err != nil {
+		if errors.Is(err, model.ErrInvalidScimFilter) {
+			scimError(c, http.StatusBadRequest, dto.ScimErrorInvalidFilter, err.Error())
+			return
+		}
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	resources := make([]*dto.ScimUser, 0, len(users))
+	for _, user := range users {
+		resources = append(resources, userToScim(user))
+	}
+	scimListResponse(c, resources, int(total), startIndex, len(resources))
+}
+
+func ScimGetUser(c *gin.Context) {
+	user, ok := getScimUser(c)
+	if !ok {
+		return
+	}
+	scimJSON(c, http.StatusOK, userToScim(user))
+}
+
+func ScimCreateUser(c *gin.Context) {
+	var resource dto.ScimUser
+	if err := json.NewDecoder(c.Request.Body).Decode(&resource); err != nil {
+		scimError(c, http.StatusBadRequest, dto.ScimErrorInvalidSyntax, err.Error())
+		return
+	}
+	if resource.UserName == "" {
+		scimError(c, http.StatusBadRequest, dto.ScimErrorInvalidValue, "userName is required")
+		return
+	}
+	exist, err := model.CheckUserExistOrDeleted(resource.UserName, "")
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	if exist {
+		scimError(c, http.StatusConflict, dto.ScimErrorUniqueness, "userName already exists")
+		return
+	}
+	state := &scimUserState{Active: true}
+	state.applyResource(&resource)
+	if state.Password == "" {
+		// 未下发密码的用户只能通过单点登录方式登录
+		state.Password = common.GetRandomString(32)
+	}
+	user := model.User{
+		Username:    state.UserName,
+		Password:    state.Password,
+		DisplayName: state.DisplayName,
+		Email:       state.Email,
+		ExternalId:  state.ExternalId,
+		Status:      common.UserStatusEnabled,
+	}
+	if !state.Active {
+		user.Status = common.UserStatusDisabled
+	}
+	if err = user.Insert(0); err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	respondScimUser(c, user.Id, http.StatusCreated)
+}
+
+func ScimReplaceUser(c *gin.Context) {
+	user, ok := getScimUser(c)
+	if !ok {
+		return
+	}
+	var resource dto.ScimUser
+	if err := json.NewDecoder(c.Request.Body).Decode(&resource); err != nil {
+		scimError(c, http.StatusBadRequest, dto.ScimErrorInvalidSyntax, err.Error())
+		return
+	}
+	state := newScimUserState(user)
+	state.applyResource(&resource)
+	if !saveScimUser(c, user, state) {
+		return
+	}
+	respondScimUser(c, user.Id, http.StatusOK)
+}
+
+func ScimPatchUser(c *gin.Context) {
+	user, ok := getScimUser(c)
+	if !ok {
+		return
+	}
+	var req dto.ScimPatchRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		scimError(c, http.StatusBadRequest, dto.ScimErrorInvalidSyntax, err.Error())
+		return
+	}
+	state := newScimUserState(user)
+	for _, operation := range req.Operations {
+		if err := state.applyPatch(operation); err != nil {
+			scimError(c, http.StatusBadRequest, dto.ScimErrorInvalidValue, err.Error())
+			return
+		}
+	}
+	if !saveScimUser(c, user, state) {
+		return
+	}
+	respondScimUser(c, user.Id, http.StatusOK)
+}
+
+func ScimDeleteUser(c *gin.Context) {
+	user, ok := getScimUser(c)
+	if !ok {
+		return
+	}
+	if user.Role == common.RoleRootUser {
+		scimError(c, http.StatusForbidden, dto.ScimErrorMutability, "root user cannot be managed through SCIM")
+		return
+	}
+	count, err := model.DisableUserTokens(user.Id)
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	if err = user.Delete(); err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	model.RecordLog(user.Id, model.LogTypeManage, fmt.Sprintf("SCIM 删除用户，同时禁用了 %d 个令牌", count))
+	c.Status(http.StatusNoContent)
+}
+
+// scimGroupNames SCIM 分组即分组倍率中配置的用户分组
+func scimGroupNames() []string {
+	groups := ratio_setting.GetGroupRatioCopy()
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func scimGroupMemberIds(raw json.RawMessage) ([]int, error) {
+	var members []dto.ScimMultiValue
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &members); err != nil {
+			return nil, err
+		}
+	}
+	return scimMemberIds(members)
+}
+
+func scimMemberIds(members []dto.ScimMultiValue) ([]int, error) {
+	ids := make([]int, 0, len(members))
+	for _, member := range members {
+		id, err := strconv.Atoi(member.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid member: %s", member.Value)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// replaceScimGroupMembers 设置分组的完整成员列表，不在列表中的原成员回到默认分组
+func replaceScimGroupMembers(group string, ids []int) error {
+	members, err := model.GetUsersByGroup(group)
+	if err != nil {
+		return err
+	}
+	keep := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		keep[id] = true
+	}
+	var removed []int
+	for _, member := range members {
+		if !keep[member.Id] {
+			removed = append(removed, member.Id)
+		}
+	}
+	if err = model.MoveUsersGroup(removed, group, scimDefaultGroup); err != nil {
+		return err
+	}
+	return model.MoveUsersGroup(ids, "", group)
+}
+
+// applyScimGroupPatch 用户只能属于一个分组，加入新分组会离开原分组
+func applyScimGroupPatch(group string, operation dto.ScimPatchOperation) error {
+	op := strings.ToLower(operation.Op)
+	path := strings.TrimSpace(operation.Path)
+	lowerPath := strings.ToLower(path)
+	switch {
+	case op == "remove" && lowerPath == "members":
+		if len(operation.Value) == 0 {
+			return replaceScimGroupMembers(group, nil)
+		}
+		ids, err := scimGroupMemberIds(operation.Value)
+		if err != nil {
+			return err
+		}
+		return model.MoveUsersGroup(ids, group, scimDefaultGroup)
+	case op == "remove" && scimMemberPathRegex.MatchString(path):
+		id, err := strconv.Atoi(scimMemberPathRegex.FindStringSubmatch(path)[1])
+		if err != nil {
+			return err
+		}
+		return model.MoveUsersGroup([]int{id}, group, scimDefaultGroup)
+	case (op == "add" || op == "replace") && lowerPath == "members":
+		ids, err := scimGroupMemberIds(operation.Value)
+		if err != nil {
+			return err
+		}
+		if op == "add" {
+			return model.MoveUsersGroup(ids, "", group)
+		}
+		return replaceScimGroupMembers(group, ids)
+	case (op == "add" || op == "replace") && lowerPath == "displayname":
+		name, err := scimString(operation.Value)
+		if err != nil {
+			return err
+		}
+		if name != group {
+			return errors.New("groups cannot be renamed")
+		}
+		return nil
+	case (op == "add" || op == "replace") && path == "":
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(operation.Value, &values); err != nil {
+			return err
+		}
+		for key, value := range values {
+			err := applyScimGroupPatch(group, dto.ScimPatchOperation{Op: op, Path: key, Value: value})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case op == "add" || op == "replace":
+		// 忽略 externalId 等不支持的属性
+		return nil
+	}
+	return fmt.Errorf("unsupported operation: %s %s", operation.Op, operation.Path)
+}
+
+func respondScimGroup(c *gin.Context, group string, status int) {
+	var members []*model.User
+	if !strings.Contains(c.Query("excludedAttributes"), "members") {
+		var err error
+		members, err = model.GetUsersByGroup(group)
+		if err != nil {
+			scimError(c, http.StatusInternalServerError, "", err.Error())
+			return
+		}
+	}
+	c.Header("Location", scimLocation("Groups", group))
+	scimJSON(c, status, groupToScim(group, members))
+}
+
+func getScimGroup(c *gin.Context) (string, bool) {
+	group := c.Param("id")
+	if !ratio_setting.ContainsGroupRatio(group) {
+		scimError(c, http.StatusNotFound, dto.ScimErrorNoTarget, "group not found")
+		return "", false
+	}
+	return group, true
+}
+
+func ScimListGroups(c *gin.Context) {
+	conditions, err := model.ParseScimFilter(c.Query("filter"))
+	if err != nil {
+		scimError(c, http.StatusBadRequest, dto.ScimErrorInvalidFilter, err.Error())
+		return
+	}
+	for _, condition := range conditions {
+		if condition.Attribute != "displayname" && condition.Attribute != "id" {
+			scimError(c, http.StatusBadRequest, dto.ScimErrorInvalidFilter, "unsupported attribute "+condition.Attribute)
+			return
+		}
+	}
+	var names []string
+	for _, name := range scimGroupNames() {
+		matched := true
+		for _, condition := range conditions {
+			if !model.MatchScimFilterValue(condition, name) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			names = append(names, name)
+		}
+	}
+	startIndex, count := scimPagination(c)
+	resources := make([]*dto.ScimGroup, 0)
+	excludeMembers := strings.Contains(c.Query("excludedAttributes"), "members")
+	for i := startIndex - 1; i < len(names) && len(resources) < count; i++ {
+		var members []*model.User
+		if !excludeMembers {
+			members, err = model.GetUsersByGroup(names[i])
+			if err != nil {
+				scimError(c, http.StatusInternalServerError, "", err.Error())
+				return
+			}
+		}
+		resources = append(resources, groupToScim(names[i], members))
+	}
+	scimListResponse(c, resources, len(names), startIndex, len(resources))
+}
+
+func ScimGetGroup(c *gin.Context) {
+	group, ok := getScimGroup(c)
+	if !ok {
+		return
+	}
+	respondScimGroup(c, group, http.StatusOK)
+}
+
+// ScimCreateGroup 分组需要先在分组倍率中配置，SCIM 只负责同步成员
+func ScimCreateGroup(c *gin.Context) {
+	var resource dto.ScimGroup
+	if err := json.NewDecoder(c.Request.Body).Decode(&resource); err != nil {
+		scimError(c, http.StatusBadRequest, dto.ScimErrorInvalidSyntax, err.Error())
+		return
+	}
+	if !ratio_setting.ContainsGroupRatio(resource.DisplayName) {
+		scimError(c, http.StatusBadRequest, dto.ScimErrorInvalidValue, fmt.Sprintf("group %s is not configured in GroupRatio", resource.DisplayName))
+		return
+	}
+	ids, err := scimMemberIds(resource.Members)
+	if err != nil {
+		scimError(c, http.StatusBadRequest, dto.ScimErrorInvalidValue, err.Error())
+		return
+	}
+	if err = model.MoveUsersGroup(ids, "", resource.DisplayName); err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	respondScimGroup(c, resource.DisplayName, http.StatusCreated)
+}
+
+func ScimReplaceGroup(c *gin.Context) {
+	group, ok := getScimGroup(c)
+	if !ok {
+		return
+	}
+	var resource dto.ScimGroup
+	if err := json.NewDecoder(c.Request.Body).Decode(&resource); err != nil {
+		scimError(c, http.StatusBadRequest, dto.ScimErrorInvalidSyntax, err.Error())
+		return
+	}
+	if resource.DisplayName != "" && resource.DisplayName != group {
+		scimError(c, http.StatusBadRequest, dto.ScimErrorMutability, "groups cannot be renamed")
+		return
+	}
+	ids, err := scimMemberIds(resource.Members)
+	if err != nil {
+		scimError(c, http.StatusBadRequest, dto.ScimErrorInvalidValue, err.Error())
+		return
+	}
+	if err = replaceScimGroupMembers(group, ids); err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	respondScimGroup(c, group, http.StatusOK)
+}
+
+func ScimPatchGroup(c *gin.Context) {
+	group, ok := getScimGroup(c)
+	if !ok {
+		return
+	}
+	var req dto.ScimPatchRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		scimError(c, http.StatusBadRequest, dto.ScimErrorInvalidSyntax, err.Error())
+		return
+	}
+	for _, operation := range req.Operations {
+		if err := applyScimGroupPatch(group, operation); err != nil {
+			scimError(c, http.StatusBadRequest, dto.ScimErrorInvalidValue, err.Error())
+			return
+		}
+	}
+	if strings.Contains(c.Query("excludedAttributes"), "members") {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	respondScimGroup(c, group, http.StatusOK)
+}
+
+// ScimDeleteGroup 分组倍率配置保持不变，只将成员移回默认分组
+func ScimDeleteGroup(c *gin.Context) {
+	group, ok := getScimGroup(c)
+	if !ok {
+		return
+	}
+	if err := replaceScimGroupMembers(group, nil); err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GenerateScimToken 重新生成 SCIM bearer token，旧令牌立即失效，明文只返回这一次
+func GenerateScimToken(c *gin.Context) {
+	token, hash := service.GenerateScimToken()
+	if err := model.UpdateOption("scim.token_hash", hash); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordAudit(c, model.AuditResourceOption, "scim.token_hash", model.AuditActionReset, nil, nil)
+	common.ApiSuccess(c, gin.H{"token": token})
+}
@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"errors"
+	"one-api/common"
+	"one-api/common/envelope"
+	"one-api/model"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+// GetSecretEncryptionStatus 获取敏感信息加密状态与最近一次重新加密的结果
+func GetSecretEncryptionStatus(c *gin.Context) {
+	running, last := model.GetSecretRewrapStatus()
+	common.ApiSuccess(c, gin.H{
+		"enabled":        envelope.Enabled(),
+		"primary_key_id": envelope.PrimaryKeyId(),
+		"running":        running,
+		"last_result":    last,
+	})
+}
+
+// RotateSecretMasterKey 重新加载主密钥，并在后台使用新的主密钥重新加密全部敏感信息。
+// 轮换前需要将新密钥放在主密钥列表第一位并保留旧密钥，完成后再移除旧密钥
+func RotateSecretMasterKey(c *gin.Context) {
+	if !envelope.Enabled() {
+		common.ApiErrorMsg(c, "未配置加密主密钥，请先设置 SECRET_MASTER_KEY 或 SECRET_MASTER_KEY_FILE")
+		return
+	}
+	if running, _ := model.GetSecretRewrapStatus(); running {
+		common.ApiError(c, model.ErrSecretRewrapRunning)
+		return
+	}
+	previousKeyId := envelope.PrimaryKeyId()
+	keyId, err := envelope.Rotate()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	gopool.Go(func() {
+		if _, err := model.RewrapSecrets(); err != nil && !errors.Is(err, model.ErrSecretRewrapRunning) {
+			common.SysError("failed to rewrap secrets: " + err.Error())
+		}
+	})
+	model.RecordAudit(c, model.AuditResourceOption, "secret_master_key", model.AuditActionRotate,
+		map[string]any{"primary_key_id": previousKeyId}, map[string]any{"primary_key_id": keyId})
+	common.ApiSuccess(c, gin.H{
+		"primary_key_id": keyId,
+	})
+}
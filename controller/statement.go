@@ -0,0 +1,156 @@
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetAllStatements(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	statements, total, err := model.GetStatements(userId, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(statements)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func GetUserStatements(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	statements, total, err := model.GetStatements(c.GetInt("id"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(statements)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func GetStatement(c *gin.Context) {
+	statement, ok := getStatementFromParam(c, true)
+	if !ok {
+		return
+	}
+	common.ApiSuccess(c, statement)
+}
+
+func GetUserStatement(c *gin.Context) {
+	statement, ok := getStatementFromParam(c, false)
+	if !ok {
+		return
+	}
+	common.ApiSuccess(c, statement)
+}
+
+// CreateStatement 管理员为任意用户生成账单
+func CreateStatement(c *gin.Context) {
+	var req service.StatementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	statement, err := service.CreateStatement(req)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, statement)
+}
+
+// CreateUserStatement 用户为自己生成账单
+func CreateUserStatement(c *gin.Context) {
+	var req service.StatementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	req.UserId = c.GetInt("id")
+	statement, err := service.CreateSelfServiceStatement(req)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, statement)
+}
+
+func DeleteStatement(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.DeleteStatementById(id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func ExportStatement(c *gin.Context) {
+	statement, ok := getStatementFromParam(c, true)
+	if !ok {
+		return
+	}
+	exportStatement(c, statement)
+}
+
+func ExportUserStatement(c *gin.Context) {
+	statement, ok := getStatementFromParam(c, false)
+	if !ok {
+		return
+	}
+	exportStatement(c, statement)
+}
+
+func getStatementFromParam(c *gin.Context, isAdmin bool) (*model.Statement, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return nil, false
+	}
+	var statement *model.Statement
+	if isAdmin {
+		statement, err = model.GetStatementById(id)
+	} else {
+		statement, err = model.GetUserStatementById(id, c.GetInt("id"))
+	}
+	if err != nil {
+		common.ApiError(c, err)
+		return nil, false
+	}
+	return statement, true
+}
+
+func exportStatement(c *gin.Context, statement *model.Statement) {
+	if statement.Status != model.StatementStatusCompleted {
+		common.ApiErrorMsg(c, "账单尚未生成完成")
+		return
+	}
+	filename := fmt.Sprintf("statement-%d", statement.Id)
+	switch c.DefaultQuery("format", "csv") {
+	case "csv":
+		var buf bytes.Buffer
+		if err := service.WriteStatementCSV(&buf, statement); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.csv", filename))
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+	case "pdf":
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.pdf", filename))
+		c.Data(http.StatusOK, "application/pdf", service.RenderStatementPDF(statement))
+	default:
+		common.ApiErrorMsg(c, "不支持的导出格式")
+	}
+}
@@ -0,0 +1,257 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting"
+	"one-api/setting/operation_setting"
+	"one-api/setting/system_setting"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stripe/stripe-go/v81"
+	portalsession "github.com/stripe/stripe-go/v81/billingportal/session"
+	"github.com/stripe/stripe-go/v81/checkout/session"
+	"github.com/thanhpk/randstr"
+)
+
+type SubscriptionCheckoutRequest struct {
+	PlanId int `json:"plan_id"`
+}
+
+// GetSubscriptionPlans 用户套餐页：可购买的套餐与当前订阅
+func GetSubscriptionPlans(c *gin.Context) {
+	plans, err := model.GetSubscriptionPlans(true)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	current, err := model.GetActiveUserSubscription(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"enabled":      operation_setting.GetSubscriptionSetting().Enabled,
+		"plans":        plans,
+		"subscription": current,
+	})
+}
+
+func GetSelfSubscriptions(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	subs, total, err := model.GetUserSubscriptions(c.GetInt("id"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(subs)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func RequestSubscriptionCheckout(c *gin.Context) {
+	if !operation_setting.GetSubscriptionSetting().Enabled {
+		common.ApiErrorMsg(c, "订阅套餐未开放")
+		return
+	}
+	var req SubscriptionCheckoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	plan, err := model.GetSubscriptionPlanById(req.PlanId)
+	if err != nil || !plan.Enabled || plan.StripePriceId == "" {
+		common.ApiErrorMsg(c, "套餐不存在")
+		return
+	}
+	id := c.GetInt("id")
+	current, err := model.GetActiveUserSubscription(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if current != nil {
+		common.ApiErrorMsg(c, "已有生效中的订阅，请在订阅管理中更换套餐")
+		return
+	}
+	user, err := model.GetUserById(id, false)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	payLink, err := genStripeSubscriptionLink(user, plan)
+	if err != nil {
+		log.Println("获取Stripe订阅支付链接失败", err)
+		common.ApiErrorMsg(c, "拉起支付失败")
+		return
+	}
+	common.ApiSuccess(c, gin.H{"pay_link": payLink})
+}
+
+// RequestSubscriptionPortal 跳转 Stripe 客户门户，用于更换套餐、更新支付方式或取消订阅
+func RequestSubscriptionPortal(c *gin.Context) {
+	user, err := model.GetUserById(c.GetInt("id"), false)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if user.StripeCustomer == "" {
+		common.ApiErrorMsg(c, "尚未订阅任何套餐")
+		return
+	}
+	if err = setupStripeKey(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	result, err := portalsession.New(&stripe.BillingPortalSessionParams{
+		Customer:  stripe.String(user.StripeCustomer),
+		ReturnURL: stripe.String(system_setting.ServerAddress + "/subscription"),
+	})
+	if err != nil {
+		log.Println("获取Stripe客户门户链接失败", err)
+		common.ApiErrorMsg(c, "获取订阅管理链接失败")
+		return
+	}
+	common.ApiSuccess(c, gin.H{"url": result.URL})
+}
+
+func GetAllSubscriptionPlans(c *gin.Context) {
+	plans, err := model.GetSubscriptionPlans(false)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, plans)
+}
+
+func validateSubscriptionPlan(plan *model.SubscriptionPlan) error {
+	if plan.Name == "" {
+		return errors.New("套餐名称不能为空")
+	}
+	if !strings.HasPrefix(plan.StripePriceId, "price_") {
+		return errors.New("无效的Stripe价格ID")
+	}
+	if plan.MonthlyQuota < 0 {
+		return errors.New("每月额度不能为负数")
+	}
+	if plan.RateLimitTier != "" {
+		if _, _, found := operation_setting.GetRateLimitTier(plan.RateLimitTier); !found {
+			return fmt.Errorf("限流档位 %s 不存在", plan.RateLimitTier)
+		}
+	}
+	return nil
+}
+
+func AddSubscriptionPlan(c *gin.Context) {
+	plan := model.SubscriptionPlan{}
+	if err := c.ShouldBindJSON(&plan); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := validateSubscriptionPlan(&plan); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	plan.Id = 0
+	if err := plan.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, plan)
+}
+
+func UpdateSubscriptionPlan(c *gin.Context) {
+	plan := model.SubscriptionPlan{}
+	if err := c.ShouldBindJSON(&plan); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if _, err := model.GetSubscriptionPlanById(plan.Id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := validateSubscriptionPlan(&plan); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := plan.Update(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, plan)
+}
+
+func DeleteSubscriptionPlan(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := model.DeleteSubscriptionPlanById(id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func GetAllUserSubscriptions(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	subs, total, err := model.GetUserSubscriptions(userId, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(subs)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func genStripeSubscriptionLink(user *model.User, plan *model.SubscriptionPlan) (string, error) {
+	if err := setupStripeKey(); err != nil {
+		return "", err
+	}
+	reference := fmt.Sprintf("new-api-sub-%d-%d-%s", user.Id, time.Now().UnixMilli(), randstr.String(4))
+	userId := strconv.Itoa(user.Id)
+	params := &stripe.CheckoutSessionParams{
+		ClientReferenceID: stripe.String("sub_" + common.Sha1([]byte(reference))),
+		SuccessURL:        stripe.String(system_setting.ServerAddress + "/subscription"),
+		CancelURL:         stripe.String(system_setting.ServerAddress + "/subscription"),
+		LineItems: []*stripe.CheckoutSessionLineItemParams{
+			{
+				Price:    stripe.String(plan.StripePriceId),
+				Quantity: stripe.Int64(1),
+			},
+		},
+		Mode: stripe.String(string(stripe.CheckoutSessionModeSubscription)),
+		SubscriptionData: &stripe.CheckoutSessionSubscriptionDataParams{
+			// webhook 通过 metadata 找到对应用户
+			Metadata: map[string]string{
+				"user_id": userId,
+				"plan_id": strconv.Itoa(plan.Id),
+			},
+		},
+	}
+	if user.StripeCustomer == "" {
+		if user.Email != "" {
+			params.CustomerEmail = stripe.String(user.Email)
+		}
+	} else {
+		params.Customer = stripe.String(user.StripeCustomer)
+	}
+	result, err := session.New(params)
+	if err != nil {
+		return "", err
+	}
+	return result.URL, nil
+}
+
+func setupStripeKey() error {
+	if !strings.HasPrefix(setting.StripeApiSecret, "sk_") && !strings.HasPrefix(setting.StripeApiSecret, "rk_") {
+		return fmt.Errorf("无效的Stripe API密钥")
+	}
+	stripe.Key = setting.StripeApiSecret
+	return nil
+}
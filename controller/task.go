@@ -115,7 +115,12 @@ func updateSunoTaskAll(ctx context.Context, channelId int, taskIds []string, tas
 	if adaptor == nil {
 		return errors.New("adaptor not found")
 	}
-	resp, err := adaptor.FetchTask(*channel.BaseURL, channel.Key, map[string]any{
+	key, err := channel.ResolveKey()
+	if err != nil {
+		common.SysLog(fmt.Sprintf("ResolveKey: %v", err))
+		return err
+	}
+	resp, err := adaptor.FetchTask(*channel.BaseURL, key, map[string]any{
 		"ids": taskIds,
 	})
 	if err != nil {
@@ -163,7 +168,7 @@ func updateSunoTaskAll(ctx context.Context, channelId int, taskIds []string, tas
 			} else {
 				quota := task.Quota
 				if quota != 0 {
-					err = model.IncreaseUserQuota(task.UserId, quota, false)
+					err = model.RefundTaskQuota(task.UserId, task.OrgId, quota)
 					if err != nil {
 						logger.LogError(ctx, "fail to increase user quota: "+err.Error())
 					}
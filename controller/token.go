@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"one-api/common"
 	"one-api/model"
+	"one-api/setting/model_setting"
 	"strconv"
 	"strings"
 
@@ -148,6 +149,23 @@ func AddToken(c *gin.Context) {
 		})
 		return
 	}
+	if !model_setting.IsValidContextPolicy(token.ContextPolicy) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的上下文策略",
+		})
+		return
+	}
+	if token.OrgId != 0 {
+		// 组织令牌从组织额度池扣费，只有组织成员可以创建
+		if _, err := model.GetOrganizationMember(token.OrgId, c.GetInt("id")); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "不是该组织的成员",
+			})
+			return
+		}
+	}
 	key, err := common.GenerateKey()
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -170,6 +188,8 @@ func AddToken(c *gin.Context) {
 		ModelLimits:        token.ModelLimits,
 		AllowIps:           token.AllowIps,
 		Group:              token.Group,
+		OrgId:              token.OrgId,
+		ContextPolicy:      token.ContextPolicy,
 	}
 	err = cleanToken.Insert()
 	if err != nil {
@@ -214,6 +234,13 @@ func UpdateToken(c *gin.Context) {
 		})
 		return
 	}
+	if !model_setting.IsValidContextPolicy(token.ContextPolicy) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的上下文策略",
+		})
+		return
+	}
 	cleanToken, err := model.GetTokenByIds(token.Id, userId)
 	if err != nil {
 		common.ApiError(c, err)
@@ -247,6 +274,7 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.ModelLimits = token.ModelLimits
 		cleanToken.AllowIps = token.AllowIps
 		cleanToken.Group = token.Group
+		cleanToken.ContextPolicy = token.ContextPolicy
 	}
 	err = cleanToken.Update()
 	if err != nil {
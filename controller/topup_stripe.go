@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"one-api/common"
 	"one-api/model"
+	"one-api/service"
 	"one-api/setting"
 	"one-api/setting/operation_setting"
 	"one-api/setting/system_setting"
@@ -148,14 +149,30 @@ func StripeWebhook(c *gin.Context) {
 		sessionCompleted(event)
 	case stripe.EventTypeCheckoutSessionExpired:
 		sessionExpired(event)
+	case stripe.EventTypeInvoicePaid:
+		err = service.HandleStripeInvoicePaid(event.Data.Raw)
+	case stripe.EventTypeCustomerSubscriptionUpdated:
+		err = service.HandleStripeSubscriptionUpdated(event.Data.Raw)
+	case stripe.EventTypeCustomerSubscriptionDeleted:
+		err = service.HandleStripeSubscriptionDeleted(event.Data.Raw)
 	default:
 		log.Printf("不支持的Stripe Webhook事件类型: %s\n", event.Type)
 	}
+	if err != nil {
+		// 订阅事件处理是幂等的，返回错误让 Stripe 重试
+		log.Printf("处理Stripe Webhook事件 %s 失败: %v\n", event.Type, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
 
 	c.Status(http.StatusOK)
 }
 
 func sessionCompleted(event stripe.Event) {
+	if event.GetObjectValue("mode") == string(stripe.CheckoutSessionModeSubscription) {
+		// 订阅由 invoice.paid 处理
+		return
+	}
 	customerId := event.GetObjectValue("customer")
 	referenceId := event.GetObjectValue("client_reference_id")
 	status := event.GetObjectValue("status")
@@ -209,12 +226,10 @@ func sessionExpired(event stripe.Event) {
 }
 
 func genStripeLink(referenceId string, customerId string, email string, amount int64) (string, error) {
-	if !strings.HasPrefix(setting.StripeApiSecret, "sk_") && !strings.HasPrefix(setting.StripeApiSecret, "rk_") {
-		return "", fmt.Errorf("无效的Stripe API密钥")
+	if err := setupStripeKey(); err != nil {
+		return "", err
 	}
 
-	stripe.Key = setting.StripeApiSecret
-
 	params := &stripe.CheckoutSessionParams{
 		ClientReferenceID: stripe.String(referenceId),
 		SuccessURL:        stripe.String(system_setting.ServerAddress + "/console/log"),
@@ -447,6 +447,14 @@ func Verify2FALogin(c *gin.Context) {
 		return
 	}
 
+	if !common.StringsContains(twoFAMethods(user), "totp") {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "管理员账户需要使用通行密钥完成验证",
+		})
+		return
+	}
+
 	// 验证TOTP验证码或备用码
 	cleanCode, err := common.ValidateNumericCode(req.Code)
 	isValidTOTP := false
@@ -520,7 +528,7 @@ func AdminDisable2FA(c *gin.Context) {
 	}
 
 	myRole := c.GetInt("role")
-	if myRole <= targetUser.Role && myRole != common.RoleRootUser {
+	if (myRole <= targetUser.Role && myRole != common.RoleRootUser) || !canManageUser(c, targetUser) {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": "无权操作同级或更高级用户的2FA设置",
@@ -50,3 +50,16 @@ func GetUserQuotaDates(c *gin.Context) {
 	})
 	return
 }
+
+// GetPostpaidAccounts 数据看板中的后付费账户，按透支额度从高到低排列
+func GetPostpaidAccounts(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	accounts, total, err := model.GetPostpaidAccounts(pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(accounts)
+	common.ApiSuccess(c, pageInfo)
+}
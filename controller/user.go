@@ -9,7 +9,10 @@ import (
 	"one-api/dto"
 	"one-api/logger"
 	"one-api/model"
+	"one-api/service"
 	"one-api/setting"
+	"one-api/setting/operation_setting"
+	"one-api/setting/system_setting"
 	"strconv"
 	"strings"
 	"sync"
@@ -64,32 +67,47 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	// 检查是否启用2FA
-	if model.IsTwoFAEnabled(user.Id) {
-		// 设置pending session，等待2FA验证
-		session := sessions.Default(c)
-		session.Set("pending_username", user.Username)
-		session.Set("pending_user_id", user.Id)
-		err := session.Save()
-		if err != nil {
-			c.JSON(http.StatusOK, gin.H{
-				"message": "无法保存会话信息，请重试",
-				"success": false,
-			})
-			return
-		}
+	if requireTwoFA(&user, c) {
+		return
+	}
 
+	setupLogin(&user, c)
+}
+
+// twoFAMethods 返回用户登录时可用的两步验证方式；要求管理员使用通行密钥时不接受 TOTP
+func twoFAMethods(user *model.User) []string {
+	hasPasskey := system_setting.GetPasskeySettings().Enabled && model.HasPasskey(user.Id)
+	return service.TwoFAMethods(service.PasskeyRequiredForUser(user.Id, user.Role), hasPasskey, model.IsTwoFAEnabled(user.Id))
+}
+
+// requireTwoFA 用户启用了2FA或注册了通行密钥时设置 pending session 并返回 true，调用方此时不应继续登录
+func requireTwoFA(user *model.User, c *gin.Context) bool {
+	methods := twoFAMethods(user)
+	if len(methods) == 0 {
+		return false
+	}
+	// 设置pending session，等待2FA验证
+	session := sessions.Default(c)
+	session.Set("pending_username", user.Username)
+	session.Set("pending_user_id", user.Id)
+	err := session.Save()
+	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
-			"message": "请输入两步验证码",
-			"success": true,
-			"data": map[string]interface{}{
-				"require_2fa": true,
-			},
+			"message": "无法保存会话信息，请重试",
+			"success": false,
 		})
-		return
+		return true
 	}
 
-	setupLogin(&user, c)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "请输入两步验证码",
+		"success": true,
+		"data": map[string]interface{}{
+			"require_2fa": true,
+			"methods":     methods,
+		},
+	})
+	return true
 }
 
 // setup session & cookies and then return user info
@@ -100,6 +118,8 @@ func setupLogin(user *model.User, c *gin.Context) {
 	session.Set("role", user.Role)
 	session.Set("status", user.Status)
 	session.Set("group", user.Group)
+	// 只有通过通行密钥完成的登录才会标记，用于管理员强制使用通行密钥的策略
+	session.Set("passkey_verified", c.GetBool("passkey_verified"))
 	err := session.Save()
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -314,7 +334,7 @@ func GetUser(c *gin.Context) {
 		return
 	}
 	myRole := c.GetInt("role")
-	if myRole <= user.Role && myRole != common.RoleRootUser {
+	if (!model.CanManageUserRole(myRole, user.Role) && myRole != common.RoleRootUser) || !canManageUser(c, user) {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": "无权获取同级或更高等级用户的信息",
@@ -330,6 +350,16 @@ func GetUser(c *gin.Context) {
 }
 
 func GenerateAccessToken(c *gin.Context) {
+	// 令牌不能再派生新的令牌，否则可以绕过 scope、有效期与 IP 限制
+	if c.GetBool("use_access_token") {
+		common.ApiErrorMsg(c, "请登录后生成访问令牌")
+		return
+	}
+	// 访问令牌可以直接访问管理接口，要求管理员使用通行密钥时只能在通行密钥验证过的会话中生成
+	if !service.PasskeyVerifiedForUser(c.GetInt("id"), c.GetInt("role"), sessions.Default(c).Get("passkey_verified") == true) {
+		common.ApiErrorMsg(c, "管理员需要使用通行密钥登录后才能生成访问令牌")
+		return
+	}
 	id := c.GetInt("id")
 	user, err := model.GetUserById(id, true)
 	if err != nil {
@@ -436,8 +466,13 @@ func GetSelf(c *gin.Context) {
 	// Hide admin remarks: set to empty to trigger omitempty tag, ensuring the remark field is not included in JSON returned to regular users
 	user.Remark = ""
 
+	managementPermissions, err := model.GetRequestPermissions(c)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
 	// 计算用户权限信息
-	permissions := calculateUserPermissions(userRole)
+	permissions := calculateUserPermissions(userRole, managementPermissions)
 
 	// 获取用户设置并提取sidebar_modules
 	userSetting := user.GetSetting()
@@ -460,8 +495,9 @@ func GetSelf(c *gin.Context) {
 		"aff_history_quota": user.AffHistoryQuota,
 		"inviter_id":        user.InviterId,
 		"linux_do_id":       user.LinuxDOId,
-		"setting":           user.Setting,
+		"setting":           userSettingJSON(userSetting),
 		"stripe_customer":   user.StripeCustomer,
+		"credit_limit":      user.ToBaseUser().GetCreditLimit(),
 		"sidebar_modules":   userSetting.SidebarModules, // 正确提取sidebar_modules字段
 		"permissions":       permissions,                // 新增权限字段
 	}
@@ -474,22 +510,36 @@ func GetSelf(c *gin.Context) {
 	return
 }
 
+// userSettingJSON 返回给前端的用户设置，数据库中的设置可能包含加密的 Webhook 密钥
+func userSettingJSON(setting dto.UserSetting) string {
+	data, err := common.Marshal(setting)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
 // 计算用户权限的辅助函数
-func calculateUserPermissions(userRole int) map[string]interface{} {
+func calculateUserPermissions(userRole int, managementPermissions []string) map[string]interface{} {
 	permissions := map[string]interface{}{}
+	permissions["management"] = managementPermissions
 
 	// 根据用户角色计算权限
 	if userRole == common.RoleRootUser {
 		// 超级管理员不需要边栏设置功能
 		permissions["sidebar_settings"] = false
 		permissions["sidebar_modules"] = map[string]interface{}{}
-	} else if userRole == common.RoleAdminUser {
-		// 管理员可以设置边栏，但不包含系统设置功能
+	} else if adminConfig := model.GenerateSidebarAdminConfig(managementPermissions); adminConfig != nil {
+		// 拥有管理权限的用户可以设置边栏，但不包含无权访问的管理模块
+		disabledModules := map[string]interface{}{}
+		for module, enabled := range adminConfig {
+			if !enabled.(bool) {
+				disabledModules[module] = false
+			}
+		}
 		permissions["sidebar_settings"] = true
 		permissions["sidebar_modules"] = map[string]interface{}{
-			"admin": map[string]interface{}{
-				"setting": false, // 管理员不能访问系统设置
-			},
+			"admin": disabledModules,
 		}
 	} else {
 		// 普通用户只能设置个人功能，不包含管理员区域
@@ -502,8 +552,8 @@ func calculateUserPermissions(userRole int) map[string]interface{} {
 	return permissions
 }
 
-// 根据用户角色生成默认的边栏配置
-func generateDefaultSidebarConfig(userRole int) string {
+// 根据用户的管理权限生成默认的边栏配置
+func generateDefaultSidebarConfig(managementPermissions []string) string {
 	defaultConfig := map[string]interface{}{}
 
 	// 聊天区域 - 所有用户都可以访问
@@ -530,29 +580,10 @@ func generateDefaultSidebarConfig(userRole int) string {
 		"personal": true,
 	}
 
-	// 管理员区域 - 根据角色决定
-	if userRole == common.RoleAdminUser {
-		// 管理员可以访问管理员区域，但不能访问系统设置
-		defaultConfig["admin"] = map[string]interface{}{
-			"enabled":    true,
-			"channel":    true,
-			"models":     true,
-			"redemption": true,
-			"user":       true,
-			"setting":    false, // 管理员不能访问系统设置
-		}
-	} else if userRole == common.RoleRootUser {
-		// 超级管理员可以访问所有功能
-		defaultConfig["admin"] = map[string]interface{}{
-			"enabled":    true,
-			"channel":    true,
-			"models":     true,
-			"redemption": true,
-			"user":       true,
-			"setting":    true,
-		}
+	// 管理员区域 - 只展示拥有权限的模块，没有任何管理权限时不包含admin区域
+	if adminConfig := model.GenerateSidebarAdminConfig(managementPermissions); adminConfig != nil {
+		defaultConfig["admin"] = adminConfig
 	}
-	// 普通用户不包含admin区域
 
 	// 转换为JSON字符串
 	configBytes, err := json.Marshal(defaultConfig)
@@ -611,26 +642,33 @@ func UpdateUser(c *gin.Context) {
 		})
 		return
 	}
-	originUser, err := model.GetUserById(updatedUser.Id, false)
+	originUser, err := model.GetUserById(updatedUser.Id, true)
 	if err != nil {
 		common.ApiError(c, err)
 		return
 	}
 	myRole := c.GetInt("role")
-	if myRole <= originUser.Role && myRole != common.RoleRootUser {
+	if (!model.CanManageUserRole(myRole, originUser.Role) && myRole != common.RoleRootUser) || !canManageUser(c, originUser) {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": "无权更新同权限等级或更高权限等级的用户信息",
 		})
 		return
 	}
-	if myRole <= updatedUser.Role && myRole != common.RoleRootUser {
+	if !model.CanManageUserRole(myRole, updatedUser.Role) && myRole != common.RoleRootUser {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": "无权将其他用户权限等级提升到大于等于自己的权限等级",
 		})
 		return
 	}
+	if !operation_setting.IsValidBillingMode(updatedUser.BillingMode) || updatedUser.CreditLimit < 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的计费方式或信用额度",
+		})
+		return
+	}
 	if updatedUser.Password == "$I_LOVE_U" {
 		updatedUser.Password = "" // rollback to what it should be
 	}
@@ -642,6 +680,16 @@ func UpdateUser(c *gin.Context) {
 	if originUser.Quota != updatedUser.Quota {
 		model.RecordLog(originUser.Id, model.LogTypeManage, fmt.Sprintf("管理员将用户额度从 %s修改为 %s", logger.LogQuota(originUser.Quota), logger.LogQuota(updatedUser.Quota)))
 	}
+	if originUser.BillingMode != updatedUser.BillingMode || originUser.CreditLimit != updatedUser.CreditLimit {
+		billingMode := updatedUser.BillingMode
+		if billingMode == operation_setting.BillingModeDefault {
+			billingMode = "跟随分组"
+		}
+		model.RecordLog(originUser.Id, model.LogTypeManage, fmt.Sprintf("管理员将用户计费方式修改为 %s，信用额度 %s", billingMode, logger.LogQuota(updatedUser.CreditLimit)))
+	}
+	if after, err := model.GetUserById(originUser.Id, true); err == nil {
+		model.RecordAudit(c, model.AuditResourceUser, originUser.Id, model.AuditActionUpdate, originUser, after)
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -780,7 +828,7 @@ func DeleteUser(c *gin.Context) {
 		return
 	}
 	myRole := c.GetInt("role")
-	if myRole <= originUser.Role {
+	if !model.CanManageUserRole(myRole, originUser.Role) || !canManageUser(c, originUser) {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": "无权删除同权限等级或更高权限等级的用户",
@@ -788,6 +836,12 @@ func DeleteUser(c *gin.Context) {
 		return
 	}
 	err = model.HardDeleteUserById(id)
+	if err == nil {
+		err = model.RevokeUserPersonalAccessTokens(id)
+	}
+	if err == nil {
+		model.RecordAudit(c, model.AuditResourceUser, id, model.AuditActionDelete, originUser, nil)
+	}
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": true,
@@ -843,7 +897,7 @@ func CreateUser(c *gin.Context) {
 		user.DisplayName = user.Username
 	}
 	myRole := c.GetInt("role")
-	if user.Role >= myRole {
+	if !model.CanManageUserRole(myRole, user.Role) {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": "无法创建权限大于等于自己的用户",
@@ -861,6 +915,7 @@ func CreateUser(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	model.RecordAudit(c, model.AuditResourceUser, cleanUser.Id, model.AuditActionCreate, nil, cleanUser)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -899,13 +954,14 @@ func ManageUser(c *gin.Context) {
 		return
 	}
 	myRole := c.GetInt("role")
-	if myRole <= user.Role && myRole != common.RoleRootUser {
+	if (!model.CanManageUserRole(myRole, user.Role) && myRole != common.RoleRootUser) || !canManageUser(c, &user) {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": "无权更新同权限等级或更高权限等级的用户信息",
 		})
 		return
 	}
+	before := user
 	switch req.Action {
 	case "disable":
 		user.Status = common.UserStatusDisabled
@@ -971,6 +1027,17 @@ func ManageUser(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	if req.Action == "disable" || req.Action == "delete" {
+		if err := model.RevokeUserPersonalAccessTokens(user.Id); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+	}
+	if req.Action == "delete" {
+		model.RecordAudit(c, model.AuditResourceUser, user.Id, model.AuditActionDelete, before, nil)
+	} else {
+		model.RecordAudit(c, model.AuditResourceUser, user.Id, req.Action, before, user)
+	}
 	clearUser := model.User{
 		Role:   user.Role,
 		Status: user.Status,
@@ -7,6 +7,23 @@ type ChannelSettings struct {
 	PassThroughBodyEnabled bool   `json:"pass_through_body_enabled,omitempty"`
 	SystemPrompt           string `json:"system_prompt,omitempty"`
 	SystemPromptOverride   bool   `json:"system_prompt_override,omitempty"`
+	// Transport 渠道独立的连接设置，为空时使用全局 HTTP 客户端
+	Transport *ChannelTransportSettings `json:"transport,omitempty"`
+}
+
+// ChannelTransportSettings 渠道的 HTTP 连接设置，时间单位均为秒，0 表示使用默认值
+type ChannelTransportSettings struct {
+	ConnectTimeout      int    `json:"connect_timeout,omitempty"`     // 建立连接（含 TLS 握手）超时
+	FirstByteTimeout    int    `json:"first_byte_timeout,omitempty"`  // 请求发出后等待响应头的超时
+	IdleStreamTimeout   int    `json:"idle_stream_timeout,omitempty"` // 读取响应体时两次收到数据的最大间隔
+	IdleConnTimeout     int    `json:"idle_conn_timeout,omitempty"`   // 空闲连接的保留时间
+	DisableHTTP2        bool   `json:"disable_http2,omitempty"`
+	MaxConnsPerHost     int    `json:"max_conns_per_host,omitempty"`
+	MaxIdleConnsPerHost int    `json:"max_idle_conns_per_host,omitempty"`
+	CACert              string `json:"ca_cert,omitempty"`     // PEM 格式的自定义 CA 证书，追加到系统证书之后
+	ClientCert          string `json:"client_cert,omitempty"` // PEM 格式的客户端证书，用于 mTLS
+	ClientKey           string `json:"client_key,omitempty"`  // PEM 格式客户端私钥的外部密钥引用，例如 file:///run/secrets/client.key，不接受私钥明文
+	ServerName          string `json:"server_name,omitempty"` // 覆盖 TLS 校验使用的服务器名称
 }
 
 type VertexKeyType string
@@ -19,4 +36,6 @@ const (
 type ChannelOtherSettings struct {
 	AzureResponsesVersion string        `json:"azure_responses_version,omitempty"`
 	VertexKeyType         VertexKeyType `json:"vertex_key_type,omitempty"` // "json" or "api_key"
+	// DeclarativeSpec 声明式渠道的接口描述，仅声明式渠道使用
+	DeclarativeSpec *DeclarativeSpec `json:"declarative_spec,omitempty"`
 }
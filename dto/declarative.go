@@ -0,0 +1,69 @@
+package dto
+
+// DeclarativeSpec 声明式渠道的接口描述，用于在不编写适配器的情况下接入与 OpenAI 不兼容的 HTTP 接口。
+// 路径均为 gjson 路径，例如 output.choices.0.text
+type DeclarativeSpec struct {
+	// URL 请求地址模板，支持 {{base_url}}、{{model}}、{{request.路径}} 以及参数覆盖中的模板变量
+	URL string `json:"url"`
+	// StreamURL 流式请求使用的地址，为空时使用 URL
+	StreamURL string            `json:"stream_url,omitempty"`
+	Auth      DeclarativeAuth   `json:"auth"`
+	Headers   map[string]string `json:"headers,omitempty"` // 额外请求头，值支持模板
+	// Request 请求体模板。字符串 "$.路径" 取 OpenAI 请求中对应的值并保留原类型，路径不存在时省略该字段；
+	// 含 {{...}} 的字符串按模板渲染；{"$each": "$.messages", "$item": {...}} 将数组逐项映射，$item 中的 "$.路径" 相对于数组元素
+	Request  any                 `json:"request"`
+	Response DeclarativeResponse `json:"response"`
+	Stream   DeclarativeStream   `json:"stream"`
+	Usage    DeclarativeUsage    `json:"usage"`
+	Error    DeclarativeError    `json:"error"`
+}
+
+const (
+	DeclarativeAuthBearer = "bearer" // Authorization: Bearer <key>（默认）
+	DeclarativeAuthHeader = "header" // 自定义请求头 <Header>: <Prefix><key>
+	DeclarativeAuthQuery  = "query"  // 查询参数 ?<Query>=<key>
+	DeclarativeAuthNone   = "none"
+
+	DeclarativeStreamSSE    = "sse"    // data: 开头的 SSE（默认）
+	DeclarativeStreamNDJSON = "ndjson" // 每行一个 JSON 对象
+)
+
+type DeclarativeAuth struct {
+	Type   string `json:"type,omitempty"`
+	Header string `json:"header,omitempty"`
+	Prefix string `json:"prefix,omitempty"`
+	Query  string `json:"query,omitempty"`
+}
+
+// DeclarativeResponse 非流式响应的字段路径
+type DeclarativeResponse struct {
+	Id               string            `json:"id,omitempty"`
+	Content          string            `json:"content"`
+	ReasoningContent string            `json:"reasoning_content,omitempty"`
+	FinishReason     string            `json:"finish_reason,omitempty"`
+	FinishReasonMap  map[string]string `json:"finish_reason_map,omitempty"` // 上游结束原因到 OpenAI 结束原因的映射，流式同样适用
+}
+
+// DeclarativeStream 流式响应中每个数据块的字段路径
+type DeclarativeStream struct {
+	Format           string `json:"format,omitempty"`
+	Content          string `json:"content"`
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+	FinishReason     string `json:"finish_reason,omitempty"`
+	Done             string `json:"done,omitempty"`      // 表示结束的数据内容，默认 [DONE]
+	DonePath         string `json:"done_path,omitempty"` // 值为 true 时表示结束的字段路径
+}
+
+// DeclarativeUsage 用量字段路径，同时用于非流式响应与流式数据块，未提供时按文本估算
+type DeclarativeUsage struct {
+	PromptTokens     string `json:"prompt_tokens,omitempty"`
+	CompletionTokens string `json:"completion_tokens,omitempty"`
+	TotalTokens      string `json:"total_tokens,omitempty"`
+}
+
+// DeclarativeError 错误字段路径，Message 路径存在时视为上游返回了错误
+type DeclarativeError struct {
+	Message string `json:"message,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Code    string `json:"code,omitempty"`
+}
@@ -13,6 +13,8 @@ const (
 	NotifyTypeQuotaExceed   = "quota_exceed"
 	NotifyTypeChannelUpdate = "channel_update"
 	NotifyTypeChannelTest   = "channel_test"
+	NotifyTypeCreditLimit   = "credit_limit"
+	NotifyTypeUserSuspended = "user_suspended"
 )
 
 func NewNotify(t string, title string, content string, values []interface{}) Notify {
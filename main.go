@@ -1,11 +1,16 @@
 package main
 
 import (
+	"context"
 	"embed"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"one-api/common"
+	"one-api/common/envelope"
+	"one-api/common/logsink"
+	"one-api/common/secretref"
 	"one-api/constant"
 	"one-api/controller"
 	"one-api/logger"
@@ -15,7 +20,10 @@ import (
 	"one-api/service"
 	"one-api/setting/ratio_setting"
 	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/bytedance/gopkg/util/gopool"
 	"github.com/gin-contrib/sessions"
@@ -87,6 +95,27 @@ func main() {
 	// 数据看板
 	go model.UpdateQuotaData()
 
+	// 用量分析预聚合
+	go model.UpdateUsageRollup()
+
+	// 清理过期的请求体捕获
+	go model.CleanBodyCaptures()
+
+	// 将明文或使用旧主密钥加密的敏感信息使用当前主密钥重新加密
+	if envelope.Enabled() && common.IsMasterNode {
+		gopool.Go(func() {
+			if _, err := model.RewrapSecrets(); err != nil {
+				common.SysError("failed to rewrap secrets: " + err.Error())
+			}
+		})
+	}
+
+	// 刷新渠道的外部密钥引用
+	go secretref.SyncRefresh(secretref.CacheTTL / 2)
+
+	// 月度账单
+	go service.AutomaticallyGenerateStatements()
+
 	if os.Getenv("CHANNEL_UPDATE_FREQUENCY") != "" {
 		frequency, err := strconv.Atoi(os.Getenv("CHANNEL_UPDATE_FREQUENCY"))
 		if err != nil {
@@ -150,10 +179,24 @@ func main() {
 	if port == "" {
 		port = strconv.Itoa(*common.Port)
 	}
-	err = server.Run(":" + port)
-	if err != nil {
-		common.FatalLog("failed to start HTTP server: " + err.Error())
+	srv := &http.Server{Addr: ":" + port, Handler: server}
+	gopool.Go(func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			common.FatalLog("failed to start HTTP server: " + err.Error())
+		}
+	})
+
+	// 收到退出信号后停止接收新请求，并排空日志投递缓冲区，避免未写出的日志丢失
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	common.SysLog("shutting down server...")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		common.SysError("failed to shutdown HTTP server: " + err.Error())
 	}
+	logsink.Close()
 }
 
 func InitResources() error {
@@ -168,6 +211,11 @@ func InitResources() error {
 	// 加载环境变量
 	common.InitEnv()
 
+	// 初始化渠道密钥等敏感信息的加密，需要在读取数据库之前完成
+	if err = envelope.InitFromEnv(); err != nil {
+		return err
+	}
+
 	logger.SetupLogger()
 
 	// Initialize model settings
@@ -6,6 +6,7 @@ import (
 	"one-api/common"
 	"one-api/constant"
 	"one-api/model"
+	"one-api/service"
 	"one-api/setting"
 	"one-api/setting/ratio_setting"
 	"strconv"
@@ -26,7 +27,9 @@ func validUserInfo(username string, role int) bool {
 	return true
 }
 
-func authHelper(c *gin.Context, minRole int) {
+// authHelper 校验登录状态与最低角色，传入 permissions 时还需具备全部所列权限。
+// 个人访问令牌只能用于传入了 permissions 的接口
+func authHelper(c *gin.Context, minRole int, permissions ...string) {
 	session := sessions.Default(c)
 	username := session.Get("username")
 	role := session.Get("role")
@@ -44,7 +47,32 @@ func authHelper(c *gin.Context, minRole int) {
 			c.Abort()
 			return
 		}
-		user := model.ValidateAccessToken(accessToken)
+		var user *model.User
+		if model.IsPersonalAccessTokenKey(strings.TrimPrefix(accessToken, "Bearer ")) {
+			// 个人访问令牌，权限受令牌 scope 限制；未声明权限点的接口无法按 scope 校验，一律拒绝
+			if len(permissions) == 0 {
+				c.JSON(http.StatusOK, gin.H{
+					"success": false,
+					"message": "无权进行此操作，个人访问令牌只能访问声明了权限的管理接口",
+				})
+				c.Abort()
+				return
+			}
+			pat, patUser, err := model.ValidatePersonalAccessToken(accessToken, c.ClientIP())
+			if err != nil {
+				c.JSON(http.StatusOK, gin.H{
+					"success": false,
+					"message": "无权进行此操作，" + err.Error(),
+				})
+				c.Abort()
+				return
+			}
+			user = patUser
+			c.Set("personal_access_token_id", pat.Id)
+			c.Set("access_token_scopes", pat.GetScopes())
+		} else {
+			user = model.ValidateAccessToken(accessToken)
+		}
 		if user != nil && user.Username != "" {
 			if !validUserInfo(user.Username, user.Role) {
 				c.JSON(http.StatusOK, gin.H{
@@ -113,6 +141,17 @@ func authHelper(c *gin.Context, minRole int) {
 		c.Abort()
 		return
 	}
+	// 要求管理员使用通行密钥时，通过密码或 TOTP 建立的会话只能访问普通用户接口（用于注册通行密钥）。
+	// 访问令牌只能在通行密钥验证过的会话中生成，见 GenerateAccessToken 与 CreatePersonalAccessToken
+	if !useAccessToken && (minRole >= common.RoleAdminUser || len(permissions) > 0) &&
+		!service.PasskeyVerifiedForUser(apiUserId, role.(int), session.Get("passkey_verified") == true) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无权进行此操作，管理员需要使用通行密钥登录",
+		})
+		c.Abort()
+		return
+	}
 	if !validUserInfo(username.(string), role.(int)) {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -128,6 +167,29 @@ func authHelper(c *gin.Context, minRole int) {
 	c.Set("user_group", session.Get("group"))
 	c.Set("use_access_token", useAccessToken)
 
+	if len(permissions) > 0 {
+		granted, err := model.GetRequestPermissions(c)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无权进行此操作，获取权限失败",
+			})
+			c.Abort()
+			return
+		}
+		for _, permission := range permissions {
+			if !model.HasPermission(granted, permission) {
+				c.JSON(http.StatusOK, gin.H{
+					"success": false,
+					"message": "无权进行此操作，缺少权限 " + permission,
+				})
+				c.Abort()
+				return
+			}
+		}
+		c.Set("permissions", granted)
+	}
+
 	//userCache, err := model.GetUserCache(id.(int))
 	//if err != nil {
 	//	c.JSON(http.StatusOK, gin.H{
@@ -171,6 +233,13 @@ func RootAuth() func(c *gin.Context) {
 	}
 }
 
+// RequirePermission 按权限点校验管理接口，替代按固定角色划分的 AdminAuth/RootAuth
+func RequirePermission(permissions ...string) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		authHelper(c, common.RoleCommonUser, permissions...)
+	}
+}
+
 func WssAuth(c *gin.Context) {
 
 }
@@ -307,6 +376,8 @@ func SetupContextForToken(c *gin.Context, token *model.Token, parts ...string) e
 		c.Set("token_model_limit_enabled", false)
 	}
 	c.Set("token_group", token.Group)
+	common.SetContextKey(c, constant.ContextKeyTokenContextPolicy, token.ContextPolicy)
+	c.Set("token_org_id", token.OrgId)
 	if len(parts) > 1 {
 		if model.IsAdmin(token.UserId) {
 			c.Set("specific_channel_id", parts[1])
@@ -7,7 +7,9 @@ import (
 	"one-api/common"
 	"one-api/common/limiter"
 	"one-api/constant"
+	"one-api/dto"
 	"one-api/setting"
+	"one-api/setting/operation_setting"
 	"strconv"
 	"time"
 
@@ -189,6 +191,15 @@ func ModelRequestRateLimit() func(c *gin.Context) {
 			successMaxCount = groupSuccessCount
 		}
 
+		// 订阅套餐的限流档位优先于分组配置
+		if userSetting, ok := common.GetContextKeyType[dto.UserSetting](c, constant.ContextKeyUserSetting); ok && userSetting.RateLimitTier != "" {
+			tierTotalCount, tierSuccessCount, found := operation_setting.GetRateLimitTier(userSetting.RateLimitTier)
+			if found {
+				totalMaxCount = tierTotalCount
+				successMaxCount = tierSuccessCount
+			}
+		}
+
 		// 根据存储类型选择并执行限流处理器
 		if common.RedisEnabled {
 			redisRateLimitHandler(duration, totalMaxCount, successMaxCount)(c)
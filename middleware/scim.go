@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+	"one-api/dto"
+	"one-api/service"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ScimAuth 校验 SCIM 专用的 bearer token，与用户会话及访问令牌相互独立
+func ScimAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authorization := c.Request.Header.Get("Authorization")
+		token := strings.TrimSpace(strings.TrimPrefix(authorization, "Bearer "))
+		if !strings.HasPrefix(authorization, "Bearer ") || !service.ValidateScimToken(token) {
+			c.Header("Content-Type", dto.ScimContentType)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, dto.ScimError{
+				Schemas: []string{dto.ScimSchemaError},
+				Status:  strconv.Itoa(http.StatusUnauthorized),
+				Detail:  "invalid SCIM bearer token",
+			})
+			return
+		}
+		c.Set("username", "scim")
+		c.Next()
+	}
+}
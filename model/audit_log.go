@@ -0,0 +1,345 @@
+package model
+
+import (
+	"fmt"
+	"one-api/common"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 审计日志的资源类型
+const (
+	AuditResourceOption     = "option"
+	AuditResourceChannel    = "channel"
+	AuditResourceUser       = "user"
+	AuditResourceRedemption = "redemption"
+	AuditResourceModel      = "model"
+	AuditResourceRole       = "role"
+)
+
+// 审计日志的操作类型
+const (
+	AuditActionCreate  = "create"
+	AuditActionUpdate  = "update"
+	AuditActionDelete  = "delete"
+	AuditActionReset   = "reset"
+	AuditActionSync    = "sync"
+	AuditActionReadKey = "read_key" // 查看渠道密钥明文
+	AuditActionRotate  = "rotate"
+)
+
+const (
+	auditMaskedValue = "******"
+	// auditDiffMaxLength 变更内容的最大长度，超出时只保留字段名，兼容 MySQL text 类型的长度限制
+	auditDiffMaxLength = 60000
+	auditExportBatch   = 1000
+)
+
+// AuditLog 管理操作与配置变更的审计记录，只追加不修改
+type AuditLog struct {
+	Id           int    `json:"id"`
+	CreatedAt    int64  `json:"created_at" gorm:"bigint;index"`
+	UserId       int    `json:"user_id" gorm:"index"`
+	Username     string `json:"username" gorm:"type:varchar(64)"`
+	Ip           string `json:"ip" gorm:"type:varchar(64)"`
+	Via          string `json:"via" gorm:"type:varchar(64)"` // 认证方式：session、access_token 或 personal_access_token:<id>
+	ResourceType string `json:"resource_type" gorm:"type:varchar(32);index:idx_audit_resource,priority:1"`
+	ResourceId   string `json:"resource_id" gorm:"type:varchar(128);index:idx_audit_resource,priority:2"`
+	Action       string `json:"action" gorm:"type:varchar(32);index"`
+	Diff         string `json:"diff" gorm:"type:text"` // JSON：{"字段": {"before": 旧值, "after": 新值}}
+}
+
+// AuditChange 单个字段的变更
+type AuditChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+type AuditLogFilter struct {
+	UserId         int
+	ResourceType   string
+	ResourceId     string
+	Action         string
+	Keyword        string
+	StartTimestamp int64
+	EndTimestamp   int64
+}
+
+// IsSecretField 判断字段或配置项是否为密钥类信息，与 GetOptions 隐藏配置项的规则一致
+func IsSecretField(name string) bool {
+	if strings.HasSuffix(name, "Token") || strings.HasSuffix(name, "Secret") || strings.HasSuffix(name, "Key") {
+		return true
+	}
+	lower := strings.ToLower(name)
+	for _, suffix := range []string{"key", "secret", "token", "password"} {
+		if strings.HasSuffix(lower, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isAuditSecretKey 在 IsSecretField 的基础上识别请求头形式的凭据，例如渠道请求头覆盖中的 Authorization、X-Api-Key、Cookie
+func isAuditSecretKey(name string) bool {
+	if IsSecretField(name) {
+		return true
+	}
+	lower := strings.ToLower(name)
+	for _, suffix := range []string{"authorization", "cookie", "credential", "credentials"} {
+		if strings.HasSuffix(lower, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isAuditSecretValue 带认证方案前缀的值视为凭据，即使字段名看不出来，例如 {"X-Upstream-Auth": "Bearer sk-..."}
+func isAuditSecretValue(v any) bool {
+	s, ok := v.(string)
+	if !ok {
+		return false
+	}
+	lower := strings.ToLower(strings.TrimSpace(s))
+	return strings.HasPrefix(lower, "bearer ") || strings.HasPrefix(lower, "basic ")
+}
+
+// normalizeAuditValue 将结构体转换为 map，将 JSON 字符串解析为对象，便于逐字段比较。
+// 字段中嵌套的 JSON 字符串（例如渠道的 header_override、setting）同样解析，以便按字段名屏蔽密钥
+func normalizeAuditValue(v any) any {
+	if v == nil {
+		return nil
+	}
+	if s, ok := v.(string); ok {
+		return parseAuditJSONString(s)
+	}
+	data, err := common.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	var parsed any
+	if err = common.Unmarshal(data, &parsed); err != nil {
+		return string(data)
+	}
+	return normalizeAuditNested(parsed)
+}
+
+func parseAuditJSONString(s string) any {
+	trimmed := strings.TrimSpace(s)
+	if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		var parsed any
+		if err := common.Unmarshal([]byte(trimmed), &parsed); err == nil {
+			return normalizeAuditNested(parsed)
+		}
+	}
+	return s
+}
+
+func normalizeAuditNested(v any) any {
+	switch value := v.(type) {
+	case string:
+		return parseAuditJSONString(value)
+	case map[string]any:
+		for k, item := range value {
+			value[k] = normalizeAuditNested(item)
+		}
+		return value
+	case []any:
+		for i, item := range value {
+			value[i] = normalizeAuditNested(item)
+		}
+		return value
+	default:
+		return v
+	}
+}
+
+// maskAuditNested 屏蔽数组等整体记录的值中嵌套的密钥字段
+func maskAuditNested(v any) any {
+	switch value := v.(type) {
+	case map[string]any:
+		masked := make(map[string]any, len(value))
+		for k, item := range value {
+			if isAuditSecretKey(k) && item != nil && item != "" {
+				masked[k] = auditMaskedValue
+			} else {
+				masked[k] = maskAuditNested(item)
+			}
+		}
+		return masked
+	case []any:
+		masked := make([]any, len(value))
+		for i, item := range value {
+			masked[i] = maskAuditNested(item)
+		}
+		return masked
+	default:
+		if isAuditSecretValue(v) {
+			return auditMaskedValue
+		}
+		return v
+	}
+}
+
+func diffAuditValues(path string, before any, after any, masked bool, changes map[string]AuditChange) {
+	beforeMap, beforeIsMap := before.(map[string]any)
+	afterMap, afterIsMap := after.(map[string]any)
+	if beforeIsMap && afterIsMap || beforeIsMap && after == nil || before == nil && afterIsMap {
+		keys := make(map[string]struct{}, len(beforeMap)+len(afterMap))
+		for k := range beforeMap {
+			keys[k] = struct{}{}
+		}
+		for k := range afterMap {
+			keys[k] = struct{}{}
+		}
+		for k := range keys {
+			childPath := k
+			if path != "" {
+				childPath = path + "." + k
+			}
+			diffAuditValues(childPath, beforeMap[k], afterMap[k], masked || isAuditSecretKey(k), changes)
+		}
+		return
+	}
+	if reflect.DeepEqual(before, after) {
+		return
+	}
+	if path == "" {
+		path = "value"
+	}
+	if masked || isAuditSecretValue(before) || isAuditSecretValue(after) {
+		change := AuditChange{}
+		if before != nil && before != "" {
+			change.Before = auditMaskedValue
+		}
+		if after != nil && after != "" {
+			change.After = auditMaskedValue
+		}
+		changes[path] = change
+		return
+	}
+	changes[path] = AuditChange{Before: maskAuditNested(before), After: maskAuditNested(after)}
+}
+
+// ComputeAuditDiff 计算变更前后的字段差异，masked 为 true 或字段名为密钥类时只记录是否变更
+func ComputeAuditDiff(before any, after any, masked bool) map[string]AuditChange {
+	changes := make(map[string]AuditChange)
+	diffAuditValues("", normalizeAuditValue(before), normalizeAuditValue(after), masked, changes)
+	return changes
+}
+
+// encodeAuditDiff 序列化变更，超出长度限制时只保留变更的字段名
+func encodeAuditDiff(changes map[string]AuditChange) string {
+	data, err := common.Marshal(changes)
+	if err == nil && len(data) <= auditDiffMaxLength {
+		return string(data)
+	}
+	fields := make([]string, 0, len(changes))
+	for k := range changes {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	summary := map[string]any{"_truncated": true, "_changed_fields": fields}
+	for {
+		data, err = common.Marshal(summary)
+		if err != nil || len(data) <= auditDiffMaxLength || len(fields) == 0 {
+			break
+		}
+		fields = fields[:len(fields)/2]
+		summary["_changed_fields"] = fields
+	}
+	return string(data)
+}
+
+func auditVia(c *gin.Context) string {
+	if patId := c.GetInt("personal_access_token_id"); patId != 0 {
+		return fmt.Sprintf("personal_access_token:%d", patId)
+	}
+	if c.GetBool("use_access_token") {
+		return "access_token"
+	}
+	return "session"
+}
+
+// RecordAudit 记录一次管理操作，before/after 可以是结构体、map 或（JSON）字符串，创建时 before 传 nil，删除时 after 传 nil
+// 没有任何字段变化的更新不会被记录
+func RecordAudit(c *gin.Context, resourceType string, resourceId any, action string, before any, after any) {
+	changes := ComputeAuditDiff(before, after, resourceType == AuditResourceOption && IsSecretField(fmt.Sprintf("%v", resourceId)))
+	if len(changes) == 0 && action == AuditActionUpdate {
+		return
+	}
+	auditLog := &AuditLog{
+		CreatedAt:    common.GetTimestamp(),
+		UserId:       c.GetInt("id"),
+		Username:     c.GetString("username"),
+		Ip:           c.ClientIP(),
+		Via:          auditVia(c),
+		ResourceType: resourceType,
+		ResourceId:   fmt.Sprintf("%v", resourceId),
+		Action:       action,
+		Diff:         encodeAuditDiff(changes),
+	}
+	if err := LOG_DB.Create(auditLog).Error; err != nil {
+		common.SysError("failed to record audit log: " + err.Error())
+	}
+}
+
+func (filter *AuditLogFilter) apply() *gorm.DB {
+	tx := LOG_DB.Model(&AuditLog{})
+	if filter.UserId != 0 {
+		tx = tx.Where("user_id = ?", filter.UserId)
+	}
+	if filter.ResourceType != "" {
+		tx = tx.Where("resource_type = ?", filter.ResourceType)
+	}
+	if filter.ResourceId != "" {
+		tx = tx.Where("resource_id = ?", filter.ResourceId)
+	}
+	if filter.Action != "" {
+		tx = tx.Where("action = ?", filter.Action)
+	}
+	if filter.Keyword != "" {
+		tx = tx.Where("diff LIKE ?", "%"+filter.Keyword+"%")
+	}
+	if filter.StartTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", filter.StartTimestamp)
+	}
+	if filter.EndTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", filter.EndTimestamp)
+	}
+	return tx
+}
+
+func GetAuditLogs(filter *AuditLogFilter, startIdx int, num int) (logs []*AuditLog, total int64, err error) {
+	err = filter.apply().Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = filter.apply().Order("id desc").Limit(num).Offset(startIdx).Find(&logs).Error
+	return logs, total, err
+}
+
+// StreamAuditLogs 按 id 分批读取审计日志，用于导出
+func StreamAuditLogs(filter *AuditLogFilter, fn func(logs []*AuditLog) error) error {
+	lastId := 0
+	for {
+		var logs []*AuditLog
+		err := filter.apply().Where("id > ?", lastId).Order("id asc").Limit(auditExportBatch).Find(&logs).Error
+		if err != nil {
+			return err
+		}
+		if len(logs) == 0 {
+			return nil
+		}
+		if err = fn(logs); err != nil {
+			return err
+		}
+		if len(logs) < auditExportBatch {
+			return nil
+		}
+		lastId = logs[len(logs)-1].Id
+	}
+}
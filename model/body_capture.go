@@ -0,0 +1,98 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"one-api/common"
+	"one-api/setting/operation_setting"
+)
+
+// BodyCapture 调试用的完整请求/响应体，与日志存放在同一个数据库
+// 各 body 字段均为 gzip 压缩后的内容，敏感信息在写入前已脱敏
+type BodyCapture struct {
+	Id                int    `json:"id"`
+	RequestId         string `json:"request_id" gorm:"type:varchar(64);index"`
+	UserId            int    `json:"user_id" gorm:"index"`
+	TokenId           int    `json:"token_id"`
+	ChannelId         int    `json:"channel_id"`
+	ModelName         string `json:"model_name"`
+	UpstreamModelName string `json:"upstream_model_name"`
+	IsStream          bool   `json:"is_stream"`
+	StatusCode        int    `json:"status_code"`
+	UpstreamURL       string `json:"upstream_url" gorm:"type:text"`
+	ClientHeaders     string `json:"client_headers" gorm:"type:text"`
+	UpstreamHeaders   string `json:"upstream_headers" gorm:"type:text"`
+	ClientRequest     []byte `json:"-"`
+	UpstreamRequest   []byte `json:"-"`
+	UpstreamResponse  []byte `json:"-"`
+	AssembledResponse []byte `json:"-"`
+	Truncated         bool   `json:"truncated"`
+	CreatedAt         int64  `json:"created_at" gorm:"bigint;index"`
+	ExpiresAt         int64  `json:"expires_at" gorm:"bigint;index"`
+}
+
+func (capture *BodyCapture) Insert() error {
+	capture.CreatedAt = common.GetTimestamp()
+	hours := operation_setting.GetBodyCaptureSetting().RetentionHours
+	if hours <= 0 {
+		hours = 72
+	}
+	capture.ExpiresAt = capture.CreatedAt + int64(hours)*3600
+	return LOG_DB.Create(capture).Error
+}
+
+func GetBodyCaptureById(id int) (*BodyCapture, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	capture := BodyCapture{}
+	err := LOG_DB.First(&capture, "id = ?", id).Error
+	return &capture, err
+}
+
+// GetBodyCaptures 分页查询捕获记录，不加载 body 字段
+func GetBodyCaptures(requestId string, userId int, channelId int, modelName string, startIdx int, num int) (captures []*BodyCapture, total int64, err error) {
+	tx := LOG_DB.Model(&BodyCapture{})
+	if requestId != "" {
+		tx = tx.Where("request_id = ?", requestId)
+	}
+	if userId != 0 {
+		tx = tx.Where("user_id = ?", userId)
+	}
+	if channelId != 0 {
+		tx = tx.Where("channel_id = ?", channelId)
+	}
+	if modelName != "" {
+		tx = tx.Where("model_name = ?", modelName)
+	}
+	err = tx.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = tx.Omit("client_request", "upstream_request", "upstream_response", "assembled_response").
+		Order("id desc").Limit(num).Offset(startIdx).Find(&captures).Error
+	return captures, total, err
+}
+
+func DeleteExpiredBodyCaptures() (int64, error) {
+	result := LOG_DB.Where("expires_at < ?", common.GetTimestamp()).Delete(&BodyCapture{})
+	return result.RowsAffected, result.Error
+}
+
+// CleanBodyCaptures 定期清理过期的捕获记录
+func CleanBodyCaptures() {
+	if !common.IsMasterNode {
+		return
+	}
+	for {
+		count, err := DeleteExpiredBodyCaptures()
+		if err != nil {
+			common.SysError("failed to clean body captures: " + err.Error())
+		} else if count > 0 {
+			common.SysLog(fmt.Sprintf("cleaned %d expired body captures", count))
+		}
+		time.Sleep(time.Hour)
+	}
+}
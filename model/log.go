@@ -8,7 +8,9 @@ import (
 	"time"
 
 	"one-api/common"
+	"one-api/common/logsink"
 	"one-api/logger"
+	"one-api/setting/operation_setting"
 	"one-api/types"
 
 	"github.com/gin-gonic/gin"
@@ -37,6 +39,7 @@ type Log struct {
 	Group            string `json:"group" gorm:"index"`
 	Ip               string `json:"ip" gorm:"index;default:''"`
 	Other            string `json:"other"`
+	OrgId            int    `json:"org_id" gorm:"default:0;index"`
 }
 
 const (
@@ -76,6 +79,22 @@ func GetLogByKey(key string) (logs []*Log, err error) {
 	return logs, err
 }
 
+// insertLog 写入 logs 表并投递到外部日志目标
+// 存在启用的投递目标时可以关闭 logs 表的写入，以减轻数据库压力
+func insertLog(log *Log) error {
+	var err error
+	if operation_setting.GetLogSinkSetting().SQLEnabled || !logsink.Active() {
+		err = LOG_DB.Create(log).Error
+	}
+	logsink.Dispatch(log.Type, log)
+	return err
+}
+
+// ReloadLogSinks 按当前配置重建外部日志投递目标
+func ReloadLogSinks() {
+	logsink.Apply(operation_setting.GetLogSinkSetting().Sinks)
+}
+
 func RecordLog(userId int, logType int, content string) {
 	if logType == LogTypeConsume && !common.LogConsumeEnabled {
 		return
@@ -88,7 +107,7 @@ func RecordLog(userId int, logType int, content string) {
 		Type:      logType,
 		Content:   content,
 	}
-	err := LOG_DB.Create(log).Error
+	err := insertLog(log)
 	if err != nil {
 		common.SysLog("failed to record log: " + err.Error())
 	}
@@ -122,6 +141,7 @@ func RecordErrorLog(c *gin.Context, userId int, channelId int, modelName string,
 		UseTime:          useTimeSeconds,
 		IsStream:         isStream,
 		Group:            group,
+		OrgId:            c.GetInt("token_org_id"),
 		Ip: func() string {
 			if needRecordIp {
 				return c.ClientIP()
@@ -130,7 +150,7 @@ func RecordErrorLog(c *gin.Context, userId int, channelId int, modelName string,
 		}(),
 		Other: otherStr,
 	}
-	err := LOG_DB.Create(log).Error
+	err := insertLog(log)
 	if err != nil {
 		logger.LogError(c, "failed to record log: "+err.Error())
 	}
@@ -181,6 +201,7 @@ func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams)
 		UseTime:          params.UseTimeSeconds,
 		IsStream:         params.IsStream,
 		Group:            params.Group,
+		OrgId:            c.GetInt("token_org_id"),
 		Ip: func() string {
 			if needRecordIp {
 				return c.ClientIP()
@@ -189,7 +210,7 @@ func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams)
 		}(),
 		Other: otherStr,
 	}
-	err := LOG_DB.Create(log).Error
+	err := insertLog(log)
 	if err != nil {
 		logger.LogError(c, "failed to record log: "+err.Error())
 	}
@@ -265,11 +265,22 @@ func migrateDB() error {
 		&Setup{},
 		&TwoFA{},
 		&TwoFABackupCode{},
+		&Statement{},
+		&SubscriptionPlan{},
+		&UserSubscription{},
+		&SubscriptionInvoice{},
+		&Organization{},
+		&OrganizationMember{},
+		&BodyCapture{},
+		&Role{},
+		&PersonalAccessToken{},
+		&Passkey{},
+		&UserIdentity{},
 	)
 	if err != nil {
 		return err
 	}
-	return nil
+	return migrateLegacyIdentities()
 }
 
 func migrateDBFast() error {
@@ -297,6 +308,17 @@ func migrateDBFast() error {
 		{&Setup{}, "Setup"},
 		{&TwoFA{}, "TwoFA"},
 		{&TwoFABackupCode{}, "TwoFABackupCode"},
+		{&Statement{}, "Statement"},
+		{&SubscriptionPlan{}, "SubscriptionPlan"},
+		{&UserSubscription{}, "UserSubscription"},
+		{&SubscriptionInvoice{}, "SubscriptionInvoice"},
+		{&Organization{}, "Organization"},
+		{&OrganizationMember{}, "OrganizationMember"},
+		{&BodyCapture{}, "BodyCapture"},
+		{&Role{}, "Role"},
+		{&PersonalAccessToken{}, "PersonalAccessToken"},
+		{&Passkey{}, "Passkey"},
+		{&UserIdentity{}, "UserIdentity"},
 	}
 	// 动态计算migration数量，确保errChan缓冲区足够大
 	errChan := make(chan error, len(migrations))
@@ -321,13 +343,16 @@ func migrateDBFast() error {
 			return err
 		}
 	}
+	if err := migrateLegacyIdentities(); err != nil {
+		return err
+	}
 	common.SysLog("database migrated")
 	return nil
 }
 
 func migrateLOGDB() error {
 	var err error
-	if err = LOG_DB.AutoMigrate(&Log{}); err != nil {
+	if err = LOG_DB.AutoMigrate(&Log{}, &UsageRollup{}, &UsageRollupCursor{}, &BodyCapture{}, &AuditLog{}); err != nil {
 		return err
 	}
 	return nil
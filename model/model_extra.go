@@ -29,3 +29,13 @@ func GetModelQuotaTypes(modelName string) []int {
 	}
 	return []int{quota}
 }
+
+// GetModelContextLength 返回模型元数据中配置的上下文窗口大小（来自缓存），未配置时返回 0
+func GetModelContextLength(modelName string) int {
+	GetPricing()
+
+	modelEnableGroupsLock.RLock()
+	contextLength := modelContextLengthMap[modelName]
+	modelEnableGroupsLock.RUnlock()
+	return contextLength
+}
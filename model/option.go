@@ -2,6 +2,7 @@ package model
 
 import (
 	"one-api/common"
+	"one-api/common/envelope"
 	"one-api/setting"
 	"one-api/setting/config"
 	"one-api/setting/operation_setting"
@@ -149,6 +150,14 @@ func InitOptionMap() {
 func loadOptionsFromDatabase() {
 	options, _ := AllOption()
 	for _, option := range options {
+		if isEncryptedOption(option.Key) {
+			value, err := envelope.Decrypt(option.Value)
+			if err != nil {
+				common.SysError("failed to decrypt option " + option.Key + ": " + err.Error())
+				continue
+			}
+			option.Value = value
+		}
 		err := updateOptionMap(option.Key, option.Value)
 		if err != nil {
 			common.SysLog("failed to update option map: " + err.Error())
@@ -172,6 +181,13 @@ func UpdateOption(key string, value string) error {
 	// https://gorm.io/docs/update.html#Save-All-Fields
 	DB.FirstOrCreate(&option, Option{Key: key})
 	option.Value = value
+	if isEncryptedOption(key) {
+		encrypted, err := envelope.Encrypt(value)
+		if err != nil {
+			return err
+		}
+		option.Value = encrypted
+	}
 	// Save is a combination function.
 	// If save value does not contain primary key, it will execute Create,
 	// otherwise it will execute Update (with all fields).
@@ -441,5 +457,9 @@ func handleConfigUpdate(key, value string) bool {
 	}
 	config.UpdateConfigFromMap(cfg, configMap)
 
+	if configName == "log_sink_setting" {
+		ReloadLogSinks()
+	}
+
 	return true // 已处理
 }
@@ -0,0 +1,136 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"one-api/common"
+)
+
+// OptionSecretPlaceholder 返回给前端的密钥类配置项占位符，提交占位符表示保持原值不变
+const OptionSecretPlaceholder = "******"
+
+// maskedJSONOptions 值为 JSON 对象数组的配置项中需要隐藏的字段，还原时按 name 对应到已保存的元素
+var maskedJSONOptions = map[string][]string{
+	"oauth_providers.providers": {"client_secret"},
+	"log_sink_setting.sinks":    {"password", "headers"},
+}
+
+// MaskOptionValue 隐藏配置项中的密钥，用于 GetOptions 返回给前端
+func MaskOptionValue(key string, value string) string {
+	if fields, ok := maskedJSONOptions[key]; ok {
+		items, err := parseOptionItems(value)
+		if err != nil {
+			// 无法解析时不返回原值，避免泄露密钥
+			return ""
+		}
+		for _, item := range items {
+			for _, field := range fields {
+				if v, ok := item[field]; ok {
+					item[field] = maskOptionField(v)
+				}
+			}
+		}
+		data, _ := common.Marshal(items)
+		return string(data)
+	}
+	if IsSecretField(key) && value != "" {
+		return OptionSecretPlaceholder
+	}
+	return value
+}
+
+// RestoreMaskedOption 将提交值中的占位符还原为当前保存的值
+func RestoreMaskedOption(key string, value string) (string, error) {
+	fields, isJSON := maskedJSONOptions[key]
+	if !isJSON && (!IsSecretField(key) || value != OptionSecretPlaceholder) {
+		return value, nil
+	}
+	common.OptionMapRWMutex.RLock()
+	stored := common.OptionMap[key]
+	common.OptionMapRWMutex.RUnlock()
+	if !isJSON {
+		return stored, nil
+	}
+	if !bytes.Contains([]byte(value), []byte(OptionSecretPlaceholder)) {
+		return value, nil
+	}
+	items, err := parseOptionItems(value)
+	if err != nil {
+		// 格式错误交给后续校验处理
+		return value, nil
+	}
+	storedItems, _ := parseOptionItems(stored)
+	storedByName := make(map[string]map[string]any, len(storedItems))
+	for _, item := range storedItems {
+		if name, ok := item["name"].(string); ok {
+			storedByName[name] = item
+		}
+	}
+	for _, item := range items {
+		name, _ := item["name"].(string)
+		for _, field := range fields {
+			if _, ok := item[field]; !ok {
+				continue
+			}
+			restored, ok := restoreOptionField(item[field], storedByName[name][field])
+			if !ok {
+				return "", fmt.Errorf("%s 的 %s 未找到已保存的值，请重新填写", name, field)
+			}
+			item[field] = restored
+		}
+	}
+	data, err := common.Marshal(items)
+	return string(data), err
+}
+
+func parseOptionItems(value string) ([]map[string]any, error) {
+	var items []map[string]any
+	if value == "" {
+		return items, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(value)))
+	decoder.UseNumber()
+	err := decoder.Decode(&items)
+	return items, err
+}
+
+// maskOptionField 隐藏字符串字段，或对象字段（例如请求头）中的每一个值
+func maskOptionField(value any) any {
+	switch v := value.(type) {
+	case string:
+		if v != "" {
+			return OptionSecretPlaceholder
+		}
+	case map[string]any:
+		masked := make(map[string]any, len(v))
+		for k, item := range v {
+			masked[k] = maskOptionField(item)
+		}
+		return masked
+	}
+	return value
+}
+
+func restoreOptionField(value any, stored any) (any, bool) {
+	switch v := value.(type) {
+	case string:
+		if v != OptionSecretPlaceholder {
+			return v, true
+		}
+		s, ok := stored.(string)
+		return s, ok
+	case map[string]any:
+		storedMap, _ := stored.(map[string]any)
+		restored := make(map[string]any, len(v))
+		for k, item := range v {
+			r, ok := restoreOptionField(item, storedMap[k])
+			if !ok {
+				return nil, false
+			}
+			restored[k] = r
+		}
+		return restored, true
+	}
+	return value, true
+}
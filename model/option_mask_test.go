@@ -0,0 +1,97 @@
+package model
+
+import (
+	"one-api/common"
+	"strings"
+	"testing"
+)
+
+func setTestOptionMap(t *testing.T, options map[string]string) {
+	t.Helper()
+	common.OptionMapRWMutex.Lock()
+	old := common.OptionMap
+	common.OptionMap = options
+	common.OptionMapRWMutex.Unlock()
+	t.Cleanup(func() {
+		common.OptionMapRWMutex.Lock()
+		common.OptionMap = old
+		common.OptionMapRWMutex.Unlock()
+	})
+}
+
+func TestMaskOptionValue(t *testing.T) {
+	setTestOptionMap(t, map[string]string{"ldap.bind_password": "directory-secret"})
+
+	cases := []struct {
+		key, value, expected string
+	}{
+		{"ldap.bind_password", "directory-secret", OptionSecretPlaceholder},
+		{"GitHubClientSecret", "secret", OptionSecretPlaceholder},
+		{"SMTPToken", "", ""},
+		{"ldap.bind_dn", "cn=svc,dc=example,dc=com", "cn=svc,dc=example,dc=com"},
+	}
+	for _, tc := range cases {
+		if got := MaskOptionValue(tc.key, tc.value); got != tc.expected {
+			t.Errorf("MaskOptionValue(%s) = %q, want %q", tc.key, got, tc.expected)
+		}
+	}
+
+	if got, _ := RestoreMaskedOption("ldap.bind_password", OptionSecretPlaceholder); got != "directory-secret" {
+		t.Errorf("Expected placeholder to keep the stored password, got %q", got)
+	}
+	if got, _ := RestoreMaskedOption("ldap.bind_password", "new-secret"); got != "new-secret" {
+		t.Errorf("Expected new password to be saved, got %q", got)
+	}
+	if got, _ := RestoreMaskedOption("ldap.bind_dn", OptionSecretPlaceholder); got != OptionSecretPlaceholder {
+		t.Errorf("Expected non secret option to be saved as is, got %q", got)
+	}
+}
+
+func TestMaskOAuthProvidersOption(t *testing.T) {
+	stored := `[{"name":"gitea","client_id":"id","client_secret":"gitea-secret","scopes":["openid"]},{"name":"okta","client_secret":"okta-secret"}]`
+	setTestOptionMap(t, map[string]string{"oauth_providers.providers": stored})
+
+	masked := MaskOptionValue("oauth_providers.providers", stored)
+	if strings.Contains(masked, "gitea-secret") || strings.Contains(masked, "okta-secret") || !strings.Contains(masked, `"client_id":"id"`) {
+		t.Fatalf("Unexpected masked providers: %s", masked)
+	}
+
+	// 修改 client_id 并保留占位符时使用已保存的密钥，新填写的密钥直接保存
+	submitted := strings.Replace(masked, `"client_id":"id"`, `"client_id":"new-id"`, 1)
+	submitted = strings.Replace(submitted, `{"client_secret":"******","name":"okta"}`, `{"client_secret":"rotated","name":"okta"}`, 1)
+	restored, err := RestoreMaskedOption("oauth_providers.providers", submitted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, _ := parseOptionItems(restored)
+	if len(items) != 2 || items[0]["client_secret"] != "gitea-secret" || items[0]["client_id"] != "new-id" || items[1]["client_secret"] != "rotated" {
+		t.Errorf("Unexpected restored providers: %s", restored)
+	}
+
+	// 新增或改名的提供方没有可还原的密钥
+	if _, err = RestoreMaskedOption("oauth_providers.providers", `[{"name":"github","client_secret":"******"}]`); err == nil {
+		t.Error("Expected placeholder without stored secret to be rejected")
+	}
+	if !isEncryptedOption("oauth_providers.providers") {
+		t.Error("Expected providers option to be encrypted")
+	}
+}
+
+func TestMaskLogSinksOption(t *testing.T) {
+	stored := `[{"name":"ch","type":"clickhouse","username":"default","password":"ch-secret"},{"name":"hook","type":"http","headers":{"Authorization":"Bearer hook-secret"}},{"name":"local","type":"file","path":"consume.ndjson"}]`
+	setTestOptionMap(t, map[string]string{"log_sink_setting.sinks": stored})
+
+	masked := MaskOptionValue("log_sink_setting.sinks", stored)
+	if strings.Contains(masked, "ch-secret") || strings.Contains(masked, "hook-secret") || strings.Contains(masked, `"password":null`) {
+		t.Fatalf("Unexpected masked sinks: %s", masked)
+	}
+	restored, err := RestoreMaskedOption("log_sink_setting.sinks", masked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, _ := parseOptionItems(restored)
+	headers, _ := items[1]["headers"].(map[string]any)
+	if items[0]["password"] != "ch-secret" || headers["Authorization"] != "Bearer hook-secret" {
+		t.Errorf("Unexpected restored sinks: %s", restored)
+	}
+}
@@ -0,0 +1,393 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/logger"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"gorm.io/gorm"
+)
+
+// 组织成员角色
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+)
+
+const (
+	OrganizationStatusEnabled  = 1
+	OrganizationStatusDisabled = 2
+)
+
+// Organization 组织，成员的组织令牌从组织额度池扣费
+// 未加入任何组织的用户相当于只有自己一个成员的个人组织（令牌 OrgId 为 0），行为与之前一致
+type Organization struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name" gorm:"type:varchar(64);index"`
+	OwnerId     int    `json:"owner_id" gorm:"index"`
+	Quota       int    `json:"quota" gorm:"default:0"`
+	UsedQuota   int    `json:"used_quota" gorm:"default:0"`
+	Status      int    `json:"status" gorm:"default:1"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+}
+
+type OrganizationMember struct {
+	Id          int    `json:"id"`
+	OrgId       int    `json:"org_id" gorm:"uniqueIndex:idx_org_member,priority:1"`
+	UserId      int    `json:"user_id" gorm:"uniqueIndex:idx_org_member,priority:2;index"`
+	Username    string `json:"username" gorm:"-:migration;->"`
+	Role        string `json:"role" gorm:"type:varchar(16)"`
+	QuotaLimit  int    `json:"quota_limit" gorm:"default:0"` // 成员在组织内的消费上限，0 表示不限制
+	UsedQuota   int    `json:"used_quota" gorm:"default:0"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+}
+
+// UserOrganization 用户所在的组织及其在组织内的角色
+type UserOrganization struct {
+	Organization
+	Role            string `json:"role"`
+	QuotaLimit      int    `json:"quota_limit"`
+	MemberUsedQuota int    `json:"member_used_quota"`
+}
+
+func IsValidOrgRole(role string) bool {
+	return role == OrgRoleOwner || role == OrgRoleAdmin || role == OrgRoleMember
+}
+
+// CanManage 所有者与管理员可以管理成员、查看全部令牌和日志
+func (member *OrganizationMember) CanManage() bool {
+	return member.Role == OrgRoleOwner || member.Role == OrgRoleAdmin
+}
+
+// CreateOrganization 创建组织，创建者成为所有者
+func CreateOrganization(org *Organization) error {
+	org.CreatedTime = common.GetTimestamp()
+	org.Status = OrganizationStatusEnabled
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&OrganizationMember{
+			OrgId:       org.Id,
+			UserId:      org.OwnerId,
+			Role:        OrgRoleOwner,
+			CreatedTime: org.CreatedTime,
+		}).Error
+	})
+}
+
+func GetOrganizationById(id int) (*Organization, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	org := Organization{}
+	err := DB.First(&org, "id = ?", id).Error
+	return &org, err
+}
+
+func GetAllOrganizations(keyword string, startIdx int, num int) (orgs []*Organization, total int64, err error) {
+	tx := DB.Model(&Organization{})
+	if keyword != "" {
+		tx = tx.Where("name LIKE ?", "%"+keyword+"%")
+	}
+	err = tx.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&orgs).Error
+	return orgs, total, err
+}
+
+func GetUserOrganizations(userId int) (orgs []*UserOrganization, err error) {
+	err = DB.Table("organizations").
+		Select("organizations.*, organization_members.role, organization_members.quota_limit, organization_members.used_quota as member_used_quota").
+		Joins("join organization_members on organization_members.org_id = organizations.id").
+		Where("organization_members.user_id = ?", userId).
+		Order("organizations.id asc").Scan(&orgs).Error
+	return orgs, err
+}
+
+func (org *Organization) Update() error {
+	return DB.Model(org).Select("name").Updates(org).Error
+}
+
+// SetOrganizationQuota 管理员直接设置组织剩余额度
+func SetOrganizationQuota(orgId int, quota int) error {
+	err := DB.Model(&Organization{}).Where("id = ?", orgId).Update("quota", quota).Error
+	invalidateOrganizationCache(orgId)
+	return err
+}
+
+func UpdateOrganizationStatus(orgId int, status int) error {
+	err := DB.Model(&Organization{}).Where("id = ?", orgId).Update("status", status).Error
+	invalidateOrganizationCache(orgId)
+	return err
+}
+
+// TransferQuotaToOrganization 将用户自己的额度划转到组织额度池
+func TransferQuotaToOrganization(userId int, orgId int, quota int) error {
+	if quota <= 0 {
+		return errors.New("划转额度必须大于 0")
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&User{}).Where("id = ? and quota >= ?", userId, quota).
+			Update("quota", gorm.Expr("quota - ?", quota))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("用户额度不足")
+		}
+		return tx.Model(&Organization{}).Where("id = ?", orgId).Update("quota", gorm.Expr("quota + ?", quota)).Error
+	})
+	if err != nil {
+		return err
+	}
+	if err = invalidateUserCache(userId); err != nil {
+		common.SysError("failed to invalidate user cache: " + err.Error())
+	}
+	invalidateOrganizationCache(orgId)
+	RecordLog(userId, LogTypeManage, fmt.Sprintf("向组织 %d 划转额度 %s", orgId, logger.FormatQuota(quota)))
+	return nil
+}
+
+// DeleteOrganization 删除组织，剩余额度退回所有者，组织令牌全部禁用
+func DeleteOrganization(orgId int) error {
+	var org Organization
+	var keys []string
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		// 在事务内读取剩余额度，避免与并发扣费交错导致退回的额度不准确
+		err := tx.Set("gorm:query_option", "FOR UPDATE").First(&org, "id = ?", orgId).Error
+		if err != nil {
+			return err
+		}
+		if org.Quota > 0 {
+			err = tx.Model(&User{}).Where("id = ?", org.OwnerId).Update("quota", gorm.Expr("quota + ?", org.Quota)).Error
+			if err != nil {
+				return err
+			}
+		}
+		keys, err = disableOrganizationTokens(tx, orgId, 0)
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("org_id = ?", orgId).Delete(&OrganizationMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&Organization{}, "id = ?", orgId).Error
+	})
+	if err != nil {
+		return err
+	}
+	deleteTokenCaches(keys)
+	invalidateOrganizationCache(orgId)
+	if err = invalidateUserCache(org.OwnerId); err != nil {
+		common.SysError("failed to invalidate user cache: " + err.Error())
+	}
+	return nil
+}
+
+func GetOrganizationMember(orgId int, userId int) (*OrganizationMember, error) {
+	member := OrganizationMember{}
+	err := DB.First(&member, "org_id = ? and user_id = ?", orgId, userId).Error
+	return &member, err
+}
+
+func GetOrganizationMembers(orgId int) (members []*OrganizationMember, err error) {
+	err = DB.Table("organization_members").
+		Select("organization_members.*, users.username").
+		Joins("left join users on users.id = organization_members.user_id").
+		Where("organization_members.org_id = ?", orgId).
+		Order("organization_members.id asc").Scan(&members).Error
+	return members, err
+}
+
+func AddOrganizationMember(member *OrganizationMember) error {
+	member.CreatedTime = common.GetTimestamp()
+	var count int64
+	err := DB.Model(&OrganizationMember{}).Where("org_id = ? and user_id = ?", member.OrgId, member.UserId).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("该用户已是组织成员")
+	}
+	return DB.Create(member).Error
+}
+
+func (member *OrganizationMember) Update() error {
+	err := DB.Model(member).Select("role", "quota_limit").Updates(member).Error
+	invalidateOrganizationMemberCache(member.OrgId, member.UserId)
+	return err
+}
+
+// RemoveOrganizationMember 移除成员，并禁用其在该组织下创建的令牌
+func RemoveOrganizationMember(orgId int, userId int) error {
+	var keys []string
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		keys, err = disableOrganizationTokens(tx, orgId, userId)
+		if err != nil {
+			return err
+		}
+		return tx.Where("org_id = ? and user_id = ?", orgId, userId).Delete(&OrganizationMember{}).Error
+	})
+	if err != nil {
+		return err
+	}
+	deleteTokenCaches(keys)
+	invalidateOrganizationMemberCache(orgId, userId)
+	return nil
+}
+
+func disableOrganizationTokens(tx *gorm.DB, orgId int, userId int) (keys []string, err error) {
+	query := tx.Where("org_id = ?", orgId)
+	if userId != 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+	var tokens []*Token
+	if err = query.Select("id", "key").Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	ids := make([]int, 0, len(tokens))
+	for _, token := range tokens {
+		ids = append(ids, token.Id)
+		keys = append(keys, token.Key)
+	}
+	err = tx.Model(&Token{}).Where("id in ?", ids).Update("status", common.TokenStatusDisabled).Error
+	return keys, err
+}
+
+func deleteTokenCaches(keys []string) {
+	if !common.RedisEnabled || len(keys) == 0 {
+		return
+	}
+	gopool.Go(func() {
+		for _, key := range keys {
+			if err := cacheDeleteToken(key); err != nil {
+				common.SysLog("failed to delete token cache: " + err.Error())
+			}
+		}
+	})
+}
+
+// GetOrganizationTokens 查询组织令牌，userId 为 0 时返回全部成员的令牌
+func GetOrganizationTokens(orgId int, userId int, startIdx int, num int) (tokens []*Token, total int64, err error) {
+	tx := DB.Model(&Token{}).Where("org_id = ?", orgId)
+	if userId != 0 {
+		tx = tx.Where("user_id = ?", userId)
+	}
+	err = tx.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&tokens).Error
+	return tokens, total, err
+}
+
+// GetOrganizationLogs 查询组织日志，userId 为 0 时返回全部成员的日志
+func GetOrganizationLogs(orgId int, userId int, logType int, startTimestamp int64, endTimestamp int64, modelName string, tokenName string, startIdx int, num int) (logs []*Log, total int64, err error) {
+	tx := LOG_DB.Where("logs.org_id = ?", orgId)
+	if userId != 0 {
+		tx = tx.Where("logs.user_id = ?", userId)
+	}
+	if logType != LogTypeUnknown {
+		tx = tx.Where("logs.type = ?", logType)
+	}
+	if modelName != "" {
+		tx = tx.Where("logs.model_name like ?", modelName)
+	}
+	if tokenName != "" {
+		tx = tx.Where("logs.token_name = ?", tokenName)
+	}
+	if startTimestamp != 0 {
+		tx = tx.Where("logs.created_at >= ?", startTimestamp)
+	}
+	if endTimestamp != 0 {
+		tx = tx.Where("logs.created_at <= ?", endTimestamp)
+	}
+	err = tx.Model(&Log{}).Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("logs.id desc").Limit(num).Offset(startIdx).Find(&logs).Error
+	return logs, total, err
+}
+
+// GetOrganizationBillingQuota 返回成员使用组织令牌时可用的额度，受组织额度池与成员上限共同约束
+// 与用户额度一致，优先读取缓存
+func GetOrganizationBillingQuota(orgId int, userId int) (int, error) {
+	org, err := GetOrganizationCache(orgId)
+	if err != nil {
+		return 0, errors.New("组织不存在")
+	}
+	if org.Status != OrganizationStatusEnabled {
+		return 0, errors.New("组织已被禁用")
+	}
+	member, err := GetOrganizationMemberCache(orgId, userId)
+	if err != nil {
+		return 0, errors.New("用户不是该组织成员")
+	}
+	return organizationBillingQuota(org, member), nil
+}
+
+func organizationBillingQuota(org *OrganizationBase, member *OrganizationMemberBase) int {
+	quota := org.Quota
+	if member.QuotaLimit > 0 && member.QuotaLimit-member.UsedQuota < quota {
+		quota = member.QuotaLimit - member.UsedQuota
+	}
+	return quota
+}
+
+// DecreaseOrganizationQuota 从组织额度池扣费并累计到成员已用额度，quota 为负数时表示退还。
+// 与用户额度一致，先更新缓存，开启批量更新时合并写入数据库
+func DecreaseOrganizationQuota(orgId int, userId int, quota int) error {
+	if quota == 0 {
+		return nil
+	}
+	gopool.Go(func() {
+		cacheDecrOrganizationQuota(orgId, userId, quota)
+	})
+	if common.BatchUpdateEnabled {
+		member, err := GetOrganizationMemberCache(orgId, userId)
+		if err != nil {
+			return err
+		}
+		addNewRecord(BatchUpdateTypeOrganizationQuota, orgId, quota)
+		addNewRecord(BatchUpdateTypeOrganizationMemberUsedQuota, member.Id, quota)
+		return nil
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := decreaseOrganizationQuota(tx, orgId, quota); err != nil {
+			return err
+		}
+		return tx.Model(&OrganizationMember{}).Where("org_id = ? and user_id = ?", orgId, userId).
+			Update("used_quota", gorm.Expr("used_quota + ?", quota)).Error
+	})
+}
+
+func decreaseOrganizationQuota(tx *gorm.DB, orgId int, quota int) error {
+	return tx.Model(&Organization{}).Where("id = ?", orgId).Updates(map[string]interface{}{
+		"quota":      gorm.Expr("quota - ?", quota),
+		"used_quota": gorm.Expr("used_quota + ?", quota),
+	}).Error
+}
+
+func increaseOrganizationMemberUsedQuota(memberId int, quota int) error {
+	return DB.Model(&OrganizationMember{}).Where("id = ?", memberId).
+		Update("used_quota", gorm.Expr("used_quota + ?", quota)).Error
+}
+
+// RefundTaskQuota 退还异步任务预扣的额度，组织令牌发起的任务退回组织额度池
+func RefundTaskQuota(userId int, orgId int, quota int) error {
+	if orgId != 0 {
+		return DecreaseOrganizationQuota(orgId, userId, -quota)
+	}
+	return IncreaseUserQuota(userId, quota, false)
+}
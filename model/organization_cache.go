@@ -0,0 +1,110 @@
+package model
+
+import (
+	"fmt"
+	"one-api/common"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+// OrganizationBase 组织计费所需字段的缓存结构
+type OrganizationBase struct {
+	Id     int `json:"id"`
+	Quota  int `json:"quota"`
+	Status int `json:"status"`
+}
+
+// OrganizationMemberBase 成员计费所需字段的缓存结构
+type OrganizationMemberBase struct {
+	Id         int `json:"id"`
+	QuotaLimit int `json:"quota_limit"`
+	UsedQuota  int `json:"used_quota"`
+}
+
+func getOrganizationCacheKey(orgId int) string {
+	return fmt.Sprintf("org:%d", orgId)
+}
+
+func getOrganizationMemberCacheKey(orgId int, userId int) string {
+	return fmt.Sprintf("org_member:%d:%d", orgId, userId)
+}
+
+// invalidateOrganizationCache 组织额度或状态被直接修改后清除缓存
+func invalidateOrganizationCache(orgId int) {
+	if !common.RedisEnabled {
+		return
+	}
+	if err := common.RedisDelKey(getOrganizationCacheKey(orgId)); err != nil {
+		common.SysError("failed to invalidate organization cache: " + err.Error())
+	}
+}
+
+// invalidateOrganizationMemberCache 成员上限被修改或成员被移除后清除缓存
+func invalidateOrganizationMemberCache(orgId int, userId int) {
+	if !common.RedisEnabled {
+		return
+	}
+	if err := common.RedisDelKey(getOrganizationMemberCacheKey(orgId, userId)); err != nil {
+		common.SysError("failed to invalidate organization member cache: " + err.Error())
+	}
+}
+
+// GetOrganizationCache 优先从 Redis 读取组织额度与状态，未命中时读数据库并异步写入缓存
+func GetOrganizationCache(orgId int) (*OrganizationBase, error) {
+	if common.RedisEnabled {
+		var cache OrganizationBase
+		if err := common.RedisHGetObj(getOrganizationCacheKey(orgId), &cache); err == nil {
+			return &cache, nil
+		}
+	}
+	org, err := GetOrganizationById(orgId)
+	if err != nil {
+		return nil, err
+	}
+	cache := &OrganizationBase{Id: org.Id, Quota: org.Quota, Status: org.Status}
+	if common.RedisEnabled {
+		gopool.Go(func() {
+			if err := common.RedisHSetObj(getOrganizationCacheKey(orgId), cache, time.Duration(common.RedisKeyCacheSeconds())*time.Second); err != nil {
+				common.SysError("failed to update organization cache: " + err.Error())
+			}
+		})
+	}
+	return cache, nil
+}
+
+// GetOrganizationMemberCache 优先从 Redis 读取成员上限与已用额度，未命中时读数据库并异步写入缓存
+func GetOrganizationMemberCache(orgId int, userId int) (*OrganizationMemberBase, error) {
+	if common.RedisEnabled {
+		var cache OrganizationMemberBase
+		if err := common.RedisHGetObj(getOrganizationMemberCacheKey(orgId, userId), &cache); err == nil {
+			return &cache, nil
+		}
+	}
+	member, err := GetOrganizationMember(orgId, userId)
+	if err != nil {
+		return nil, err
+	}
+	cache := &OrganizationMemberBase{Id: member.Id, QuotaLimit: member.QuotaLimit, UsedQuota: member.UsedQuota}
+	if common.RedisEnabled {
+		gopool.Go(func() {
+			if err := common.RedisHSetObj(getOrganizationMemberCacheKey(orgId, userId), cache, time.Duration(common.RedisKeyCacheSeconds())*time.Second); err != nil {
+				common.SysError("failed to update organization member cache: " + err.Error())
+			}
+		})
+	}
+	return cache, nil
+}
+
+// cacheDecrOrganizationQuota 同步扣减缓存中的组织额度并累计成员已用额度，缓存不存在时不做处理
+func cacheDecrOrganizationQuota(orgId int, userId int, quota int) {
+	if !common.RedisEnabled {
+		return
+	}
+	if err := common.RedisHIncrBy(getOrganizationCacheKey(orgId), "Quota", int64(-quota)); err != nil {
+		common.SysError("failed to decrease organization quota cache: " + err.Error())
+	}
+	if err := common.RedisHIncrBy(getOrganizationMemberCacheKey(orgId, userId), "UsedQuota", int64(quota)); err != nil {
+		common.SysError("failed to increase organization member used quota cache: " + err.Error())
+	}
+}
@@ -0,0 +1,105 @@
+package model
+
+import (
+	"one-api/common"
+	"testing"
+)
+
+func setupOrganizationTest(t *testing.T) (*Organization, *User, *User) {
+	t.Helper()
+	setupTestDB(t, &User{}, &Token{}, &Organization{}, &OrganizationMember{})
+	owner := &User{Username: "owner", Password: "x", AffCode: "a1", Quota: 1000, Status: common.UserStatusEnabled}
+	member := &User{Username: "member", Password: "x", AffCode: "a2", Status: common.UserStatusEnabled}
+	for _, user := range []*User{owner, member} {
+		if err := DB.Create(user).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	org := &Organization{Name: "team", OwnerId: owner.Id}
+	if err := CreateOrganization(org); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddOrganizationMember(&OrganizationMember{OrgId: org.Id, UserId: member.Id, Role: OrgRoleMember, QuotaLimit: 300}); err != nil {
+		t.Fatal(err)
+	}
+	if err := TransferQuotaToOrganization(owner.Id, org.Id, 800); err != nil {
+		t.Fatal(err)
+	}
+	return org, owner, member
+}
+
+func TestOrganizationBillingQuota(t *testing.T) {
+	org, owner, member := setupOrganizationTest(t)
+
+	if quota, err := GetOrganizationBillingQuota(org.Id, owner.Id); err != nil || quota != 800 {
+		t.Errorf("Expected owner billing quota 800, got %d %v", quota, err)
+	}
+	if quota, err := GetOrganizationBillingQuota(org.Id, member.Id); err != nil || quota != 300 {
+		t.Errorf("Expected member billing quota limited to 300, got %d %v", quota, err)
+	}
+
+	if err := DecreaseOrganizationQuota(org.Id, member.Id, 120); err != nil {
+		t.Fatal(err)
+	}
+	if err := DecreaseOrganizationQuota(org.Id, member.Id, -20); err != nil {
+		t.Fatal(err)
+	}
+	if quota, _ := GetOrganizationBillingQuota(org.Id, member.Id); quota != 200 {
+		t.Errorf("Expected member billing quota 200 after consuming 100, got %d", quota)
+	}
+	stored, _ := GetOrganizationById(org.Id)
+	if stored.Quota != 700 || stored.UsedQuota != 100 {
+		t.Errorf("Expected organization quota 700 used 100, got %d %d", stored.Quota, stored.UsedQuota)
+	}
+
+	if err := UpdateOrganizationStatus(org.Id, OrganizationStatusDisabled); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetOrganizationBillingQuota(org.Id, owner.Id); err == nil {
+		t.Error("Expected disabled organization to be rejected")
+	}
+	if _, err := GetOrganizationBillingQuota(org.Id, 999); err == nil {
+		t.Error("Expected non-member to be rejected")
+	}
+}
+
+func TestOrganizationBatchUpdate(t *testing.T) {
+	org, _, member := setupOrganizationTest(t)
+	common.BatchUpdateEnabled = true
+
+	for _, quota := range []int{50, 30, -10} {
+		if err := DecreaseOrganizationQuota(org.Id, member.Id, quota); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stored, _ := GetOrganizationById(org.Id); stored.Quota != 800 {
+		t.Errorf("Expected quota to stay unchanged before batch flush, got %d", stored.Quota)
+	}
+	batchUpdate()
+	stored, _ := GetOrganizationById(org.Id)
+	storedMember, _ := GetOrganizationMember(org.Id, member.Id)
+	if stored.Quota != 730 || stored.UsedQuota != 70 || storedMember.UsedQuota != 70 {
+		t.Errorf("Unexpected quota after batch flush: org %d/%d member %d", stored.Quota, stored.UsedQuota, storedMember.UsedQuota)
+	}
+}
+
+func TestDeleteOrganizationRefundsOwner(t *testing.T) {
+	org, owner, member := setupOrganizationTest(t)
+	if err := DecreaseOrganizationQuota(org.Id, member.Id, 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteOrganization(org.Id); err != nil {
+		t.Fatal(err)
+	}
+	var quota int
+	DB.Model(&User{}).Where("id = ?", owner.Id).Select("quota").Find(&quota)
+	if quota != 900 {
+		t.Errorf("Expected owner quota 900 after refund, got %d", quota)
+	}
+	if _, err := GetOrganizationById(org.Id); err == nil {
+		t.Error("Expected organization to be deleted")
+	}
+	if _, err := GetOrganizationMember(org.Id, member.Id); err == nil {
+		t.Error("Expected members to be deleted")
+	}
+}
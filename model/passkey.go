@@ -0,0 +1,117 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+
+	"gorm.io/gorm"
+)
+
+// MaxPasskeysPerUser 每个用户最多可注册的通行密钥数量
+const MaxPasskeysPerUser = 10
+
+// Passkey 用户注册的 WebAuthn 凭据
+type Passkey struct {
+	Id           int    `json:"id"`
+	UserId       int    `json:"user_id" gorm:"index"`
+	Name         string `json:"name" gorm:"type:varchar(64)"`
+	CredentialId string `json:"credential_id" gorm:"type:varchar(255);uniqueIndex"` // base64url 编码的凭据 ID
+	Credential   string `json:"-" gorm:"type:text"`                                 // 序列化的凭据，包含公钥与签名计数
+	BackedUp     bool   `json:"backed_up"`                                          // 凭据是否已同步（例如 iCloud 钥匙串）
+	CreatedTime  int64  `json:"created_time" gorm:"bigint"`
+	LastUsedTime int64  `json:"last_used_time" gorm:"bigint"`
+}
+
+func GetUserPasskeys(userId int) (passkeys []*Passkey, err error) {
+	err = DB.Where("user_id = ?", userId).Order("id asc").Find(&passkeys).Error
+	return passkeys, err
+}
+
+func CountUserPasskeys(userId int) (count int64, err error) {
+	err = DB.Model(&Passkey{}).Where("user_id = ?", userId).Count(&count).Error
+	return count, err
+}
+
+// HasPasskey 检查用户是否注册了通行密钥
+func HasPasskey(userId int) bool {
+	count, err := CountUserPasskeys(userId)
+	return err == nil && count > 0
+}
+
+func (p *Passkey) Insert() error {
+	count, err := CountUserPasskeys(p.UserId)
+	if err != nil {
+		return err
+	}
+	if count >= MaxPasskeysPerUser {
+		return errors.New("通行密钥数量已达上限")
+	}
+	p.CreatedTime = common.GetTimestamp()
+	return DB.Create(p).Error
+}
+
+// UpdateUsage 保存登录后更新的签名计数与同步状态
+func (p *Passkey) UpdateUsage() error {
+	p.LastUsedTime = common.GetTimestamp()
+	return DB.Model(p).Select("credential", "backed_up", "last_used_time").Updates(p).Error
+}
+
+func RenamePasskey(id int, userId int, name string) error {
+	result := DB.Model(&Passkey{}).Where("id = ? AND user_id = ?", id, userId).Update("name", name)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func DeletePasskey(id int, userId int) error {
+	result := DB.Where("id = ? AND user_id = ?", id, userId).Delete(&Passkey{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func DeleteUserPasskeys(userId int) error {
+	return DB.Where("user_id = ?", userId).Delete(&Passkey{}).Error
+}
+
+// GetPasskeyStats 获取通行密钥统计信息（管理员使用），与 GetTwoFAStats 对应
+func GetPasskeyStats() (map[string]interface{}, error) {
+	var totalUsers, enabledUsers, totalPasskeys int64
+	if err := DB.Model(&User{}).Count(&totalUsers).Error; err != nil {
+		return nil, err
+	}
+	if err := DB.Model(&Passkey{}).Distinct("user_id").Count(&enabledUsers).Error; err != nil {
+		return nil, err
+	}
+	if err := DB.Model(&Passkey{}).Count(&totalPasskeys).Error; err != nil {
+		return nil, err
+	}
+	var adminsWithout int64
+	err := DB.Model(&User{}).
+		Where("role >= ?", common.RoleAdminUser).
+		Where("id NOT IN (?)", DB.Model(&Passkey{}).Select("user_id")).
+		Count(&adminsWithout).Error
+	if err != nil {
+		return nil, err
+	}
+	enabledRate := float64(0)
+	if totalUsers > 0 {
+		enabledRate = float64(enabledUsers) / float64(totalUsers) * 100
+	}
+	return map[string]interface{}{
+		"total_users":            totalUsers,
+		"enabled_users":          enabledUsers,
+		"enabled_rate":           fmt.Sprintf("%.1f%%", enabledRate),
+		"total_passkeys":         totalPasskeys,
+		"admins_without_passkey": adminsWithout,
+	}, nil
+}
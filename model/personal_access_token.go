@@ -0,0 +1,219 @@
+package model
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"one-api/common"
+	"strings"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	PersonalAccessTokenPrefix = "pat_"
+	// MaxPersonalAccessTokens 每个用户最多可创建的个人访问令牌数量
+	MaxPersonalAccessTokens = 20
+	// personalAccessTokenTouchInterval 最近使用时间的最小刷新间隔（秒），避免每次请求都写库
+	personalAccessTokenTouchInterval = 60
+)
+
+const (
+	PersonalAccessTokenStatusEnabled = 1
+	PersonalAccessTokenStatusRevoked = 2
+)
+
+// PersonalAccessToken 管理接口 /api/* 的个人访问令牌，数据库中只保存令牌的 SHA-256 摘要
+type PersonalAccessToken struct {
+	Id           int    `json:"id"`
+	UserId       int    `json:"user_id" gorm:"index"`
+	Name         string `json:"name" gorm:"type:varchar(64)"`
+	TokenHash    string `json:"-" gorm:"type:char(64);uniqueIndex"`
+	TokenPrefix  string `json:"token_prefix" gorm:"type:varchar(16)"` // 明文前缀，仅用于界面识别
+	Scopes       string `json:"scopes" gorm:"type:text"`              // JSON 数组，取值为 RBAC 权限点
+	AllowIps     string `json:"allow_ips" gorm:"type:text"`           // 每行一个 IP 或 CIDR，为空时不限制
+	Status       int    `json:"status" gorm:"type:int;default:1"`
+	ExpiredTime  int64  `json:"expired_time" gorm:"bigint;default:-1"` // -1 表示永不过期
+	CreatedTime  int64  `json:"created_time" gorm:"bigint"`
+	LastUsedTime int64  `json:"last_used_time" gorm:"bigint"`
+	LastUsedIp   string `json:"last_used_ip" gorm:"type:varchar(64)"`
+	RevokedTime  int64  `json:"revoked_time" gorm:"bigint"`
+}
+
+func hashPersonalAccessToken(key string) string {
+	return hex.EncodeToString(common.Sha256Raw([]byte(key)))
+}
+
+// GeneratePersonalAccessTokenKey 生成令牌明文，明文只在创建时返回一次
+func GeneratePersonalAccessTokenKey() (string, error) {
+	key, err := common.GenerateRandomCharsKey(40)
+	if err != nil {
+		return "", err
+	}
+	return PersonalAccessTokenPrefix + key, nil
+}
+
+func IsPersonalAccessTokenKey(key string) bool {
+	return strings.HasPrefix(key, PersonalAccessTokenPrefix)
+}
+
+func (pat *PersonalAccessToken) GetScopes() []string {
+	scopes := make([]string, 0)
+	if pat.Scopes == "" {
+		return scopes
+	}
+	if err := common.Unmarshal([]byte(pat.Scopes), &scopes); err != nil {
+		common.SysLog(fmt.Sprintf("failed to unmarshal scopes of personal access token %d: %s", pat.Id, err.Error()))
+		return []string{}
+	}
+	return scopes
+}
+
+// SetScopes 复用角色的权限校验与去重逻辑
+func (pat *PersonalAccessToken) SetScopes(scopes []string) error {
+	role := Role{}
+	if err := role.SetPermissions(scopes); err != nil {
+		return err
+	}
+	pat.Scopes = role.Permissions
+	return nil
+}
+
+func (pat *PersonalAccessToken) GetAllowIps() []string {
+	ips := make([]string, 0)
+	for _, line := range strings.Split(pat.AllowIps, "\n") {
+		line = strings.TrimSpace(strings.ReplaceAll(line, ",", ""))
+		if line != "" {
+			ips = append(ips, line)
+		}
+	}
+	return ips
+}
+
+func (pat *PersonalAccessToken) IsExpired() bool {
+	return pat.ExpiredTime != -1 && pat.ExpiredTime < common.GetTimestamp()
+}
+
+// Insert 保存令牌摘要，key 为令牌明文
+func (pat *PersonalAccessToken) Insert(key string) error {
+	var count int64
+	err := DB.Model(&PersonalAccessToken{}).Where("user_id = ? and status = ?", pat.UserId, PersonalAccessTokenStatusEnabled).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count >= MaxPersonalAccessTokens {
+		return fmt.Errorf("每个用户最多创建 %d 个访问令牌", MaxPersonalAccessTokens)
+	}
+	pat.TokenHash = hashPersonalAccessToken(key)
+	pat.TokenPrefix = key[:len(PersonalAccessTokenPrefix)+6]
+	pat.Status = PersonalAccessTokenStatusEnabled
+	pat.CreatedTime = common.GetTimestamp()
+	return DB.Create(pat).Error
+}
+
+func GetUserPersonalAccessTokens(userId int, startIdx int, num int) (pats []*PersonalAccessToken, total int64, err error) {
+	tx := DB.Model(&PersonalAccessToken{})
+	if userId != 0 {
+		tx = tx.Where("user_id = ?", userId)
+	}
+	err = tx.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&pats).Error
+	return pats, total, err
+}
+
+func GetPersonalAccessTokenById(id int) (*PersonalAccessToken, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	pat := PersonalAccessToken{}
+	err := DB.First(&pat, "id = ?", id).Error
+	return &pat, err
+}
+
+// RevokePersonalAccessToken 吊销令牌，userId 不为 0 时只能吊销该用户自己的令牌
+func RevokePersonalAccessToken(id int, userId int) (*PersonalAccessToken, error) {
+	pat, err := GetPersonalAccessTokenById(id)
+	if err != nil {
+		return nil, errors.New("访问令牌不存在")
+	}
+	if userId != 0 && pat.UserId != userId {
+		return nil, errors.New("访问令牌不存在")
+	}
+	if pat.Status == PersonalAccessTokenStatusRevoked {
+		return pat, nil
+	}
+	pat.Status = PersonalAccessTokenStatusRevoked
+	pat.RevokedTime = common.GetTimestamp()
+	err = DB.Model(pat).Select("status", "revoked_time").Updates(pat).Error
+	return pat, err
+}
+
+// RevokeUserPersonalAccessTokens 吊销用户的全部令牌并清除用户缓存，用于封禁或删除用户
+func RevokeUserPersonalAccessTokens(userId int) error {
+	err := DB.Model(&PersonalAccessToken{}).Where("user_id = ? and status = ?", userId, PersonalAccessTokenStatusEnabled).
+		Updates(map[string]interface{}{
+			"status":       PersonalAccessTokenStatusRevoked,
+			"revoked_time": common.GetTimestamp(),
+		}).Error
+	if err != nil {
+		return err
+	}
+	return invalidateUserCache(userId)
+}
+
+// ValidatePersonalAccessToken 校验令牌状态、有效期与 IP 白名单，并异步记录最近使用信息
+func ValidatePersonalAccessToken(key string, clientIp string) (*PersonalAccessToken, *User, error) {
+	key = strings.TrimPrefix(key, "Bearer ")
+	if !IsPersonalAccessTokenKey(key) {
+		return nil, nil, errors.New("access token 无效")
+	}
+	pat := PersonalAccessToken{}
+	err := DB.First(&pat, "token_hash = ?", hashPersonalAccessToken(key)).Error
+	if err != nil {
+		return nil, nil, errors.New("access token 无效")
+	}
+	if pat.Status != PersonalAccessTokenStatusEnabled {
+		return nil, nil, errors.New("access token 已被吊销")
+	}
+	if pat.IsExpired() {
+		return nil, nil, errors.New("access token 已过期")
+	}
+	if allowIps := pat.GetAllowIps(); len(allowIps) > 0 && !common.IsIPInList(clientIp, allowIps) {
+		return nil, nil, errors.New("您的 IP 不在访问令牌允许访问的列表中")
+	}
+	user := User{}
+	if err = DB.First(&user, "id = ?", pat.UserId).Error; err != nil {
+		return nil, nil, errors.New("access token 无效")
+	}
+	now := common.GetTimestamp()
+	if now-pat.LastUsedTime >= personalAccessTokenTouchInterval || pat.LastUsedIp != clientIp {
+		gopool.Go(func() {
+			err := DB.Model(&PersonalAccessToken{}).Where("id = ?", pat.Id).
+				Updates(map[string]interface{}{"last_used_time": now, "last_used_ip": clientIp}).Error
+			if err != nil {
+				common.SysLog("failed to update personal access token last used: " + err.Error())
+			}
+		})
+	}
+	return &pat, &user, nil
+}
+
+// GetRequestPermissions 计算当前请求的有效权限，通过个人访问令牌访问时再与令牌的 scope 取交集
+func GetRequestPermissions(c *gin.Context) ([]string, error) {
+	patId := c.GetInt("personal_access_token_id")
+	permissions, err := GetUserPermissions(c.GetInt("id"), c.GetInt("role"), c.GetBool("use_access_token") && patId == 0)
+	if err != nil {
+		return nil, err
+	}
+	if patId != 0 {
+		scopes, _ := c.Get("access_token_scopes")
+		granted, _ := scopes.([]string)
+		permissions = IntersectPermissions(permissions, granted)
+	}
+	return permissions, nil
+}
@@ -0,0 +1,337 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 管理接口的权限点
+const (
+	PermissionChannelsRead      = "channels.read"
+	PermissionChannelsWrite     = "channels.write"
+	PermissionChannelsKeyRead   = "channels.key.read"
+	PermissionUsersRead         = "users.read"
+	PermissionUsersManage       = "users.manage"
+	PermissionRedemptionsManage = "redemptions.manage"
+	PermissionLogsRead          = "logs.read"
+	PermissionLogsDelete        = "logs.delete"
+	PermissionLogsBodyRead      = "logs.body.read"
+	PermissionAnalyticsRead     = "analytics.read"
+	PermissionStatementsManage  = "statements.manage"
+	PermissionModelsManage      = "models.manage"
+	PermissionBillingManage     = "billing.manage"
+	PermissionTasksRead         = "tasks.read"
+	PermissionAuditRead         = "audit.read"
+	PermissionOptionsWrite      = "options.write"
+	PermissionRolesManage       = "roles.manage"
+)
+
+// AllPermissions 全部权限点，超级管理员始终拥有全部权限
+var AllPermissions = []string{
+	PermissionChannelsRead,
+	PermissionChannelsWrite,
+	PermissionChannelsKeyRead,
+	PermissionUsersRead,
+	PermissionUsersManage,
+	PermissionRedemptionsManage,
+	PermissionLogsRead,
+	PermissionLogsDelete,
+	PermissionLogsBodyRead,
+	PermissionAnalyticsRead,
+	PermissionStatementsManage,
+	PermissionModelsManage,
+	PermissionBillingManage,
+	PermissionTasksRead,
+	PermissionAuditRead,
+	PermissionOptionsWrite,
+	PermissionRolesManage,
+}
+
+// rootOnlyPermissions 内置管理员角色不具备的权限，与原先 RootAuth 保护的接口一致
+var rootOnlyPermissions = map[string]bool{
+	PermissionOptionsWrite: true,
+	PermissionRolesManage:  true,
+}
+
+// Role 自定义角色，绑定到用户后替代内置角色的默认权限
+type Role struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name" gorm:"type:varchar(64);uniqueIndex"`
+	Description string `json:"description" gorm:"type:varchar(255)"`
+	Permissions string `json:"permissions" gorm:"type:text"` // JSON 数组，例如 ["channels.read","logs.read"]
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime int64  `json:"updated_time" gorm:"bigint"`
+}
+
+func IsValidPermission(permission string) bool {
+	for _, p := range AllPermissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultRolePermissions 内置角色的默认权限：超级管理员拥有全部权限，管理员拥有除系统设置与角色管理外的权限
+func DefaultRolePermissions(role int) []string {
+	switch {
+	case role >= common.RoleRootUser:
+		return append([]string{}, AllPermissions...)
+	case role >= common.RoleAdminUser:
+		permissions := make([]string, 0, len(AllPermissions))
+		for _, p := range AllPermissions {
+			if !rootOnlyPermissions[p] {
+				permissions = append(permissions, p)
+			}
+		}
+		return permissions
+	}
+	return []string{}
+}
+
+func (role *Role) GetPermissions() []string {
+	permissions := make([]string, 0)
+	if role.Permissions == "" {
+		return permissions
+	}
+	if err := common.Unmarshal([]byte(role.Permissions), &permissions); err != nil {
+		common.SysLog(fmt.Sprintf("failed to unmarshal permissions of role %d: %s", role.Id, err.Error()))
+		return []string{}
+	}
+	return permissions
+}
+
+// SetPermissions 校验、去重并排序后保存权限列表
+func (role *Role) SetPermissions(permissions []string) error {
+	seen := make(map[string]bool, len(permissions))
+	cleaned := make([]string, 0, len(permissions))
+	for _, p := range permissions {
+		p = strings.TrimSpace(p)
+		if !IsValidPermission(p) {
+			return fmt.Errorf("未知的权限: %s", p)
+		}
+		if !seen[p] {
+			seen[p] = true
+			cleaned = append(cleaned, p)
+		}
+	}
+	sort.Strings(cleaned)
+	data, err := common.Marshal(cleaned)
+	if err != nil {
+		return err
+	}
+	role.Permissions = string(data)
+	return nil
+}
+
+func (role *Role) Insert() error {
+	role.CreatedTime = common.GetTimestamp()
+	role.UpdatedTime = role.CreatedTime
+	return DB.Create(role).Error
+}
+
+func (role *Role) Update() error {
+	role.UpdatedTime = common.GetTimestamp()
+	err := DB.Model(role).Select("name", "description", "permissions", "updated_time").Updates(role).Error
+	invalidateRolePermissionsCache(role.Id)
+	return err
+}
+
+func GetAllRoles() (roles []*Role, err error) {
+	err = DB.Order("id asc").Find(&roles).Error
+	return roles, err
+}
+
+func GetRoleById(id int) (*Role, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	role := Role{}
+	err := DB.First(&role, "id = ?", id).Error
+	return &role, err
+}
+
+// DeleteRoleById 删除角色并解除所有绑定，被解绑的用户回退到内置角色的默认权限
+func DeleteRoleById(id int) error {
+	if id == 0 {
+		return errors.New("id 为空！")
+	}
+	var userIds []int
+	if err := DB.Model(&User{}).Where("role_id = ? or access_token_role_id = ?", id, id).Pluck("id", &userIds).Error; err != nil {
+		return err
+	}
+	if err := DB.Model(&User{}).Where("role_id = ?", id).Update("role_id", 0).Error; err != nil {
+		return err
+	}
+	if err := DB.Model(&User{}).Where("access_token_role_id = ?", id).Update("access_token_role_id", 0).Error; err != nil {
+		return err
+	}
+	if err := DB.Delete(&Role{}, "id = ?", id).Error; err != nil {
+		return err
+	}
+	invalidateRolePermissionsCache(id)
+	for _, userId := range userIds {
+		if err := invalidateUserCache(userId); err != nil {
+			common.SysError("failed to invalidate user cache: " + err.Error())
+		}
+	}
+	return nil
+}
+
+// BindUserRole 为用户绑定自定义角色，roleId 为 0 时解除绑定
+func BindUserRole(userId int, roleId int) error {
+	if err := DB.Model(&User{}).Where("id = ?", userId).Update("role_id", roleId).Error; err != nil {
+		return err
+	}
+	return invalidateUserCache(userId)
+}
+
+// BindAccessTokenRole 为用户的系统访问令牌绑定角色，令牌的权限为用户权限与该角色权限的交集
+func BindAccessTokenRole(userId int, roleId int) error {
+	if err := DB.Model(&User{}).Where("id = ?", userId).Update("access_token_role_id", roleId).Error; err != nil {
+		return err
+	}
+	return invalidateUserCache(userId)
+}
+
+// rolePermissionsCacheTTL 角色权限的本地缓存时间，角色在其他节点被修改时最多延迟该时长生效
+const rolePermissionsCacheTTL = time.Minute
+
+type rolePermissionsCacheEntry struct {
+	permissions []string
+	expiresAt   time.Time
+}
+
+var (
+	rolePermissionsCacheLock sync.RWMutex
+	rolePermissionsCache     = make(map[int]rolePermissionsCacheEntry)
+)
+
+func invalidateRolePermissionsCache(roleId int) {
+	rolePermissionsCacheLock.Lock()
+	delete(rolePermissionsCache, roleId)
+	rolePermissionsCacheLock.Unlock()
+}
+
+// rolePermissions 读取绑定的角色权限，角色不存在时返回 nil，结果在本地缓存 rolePermissionsCacheTTL
+func rolePermissions(roleId int) []string {
+	if roleId == 0 {
+		return nil
+	}
+	rolePermissionsCacheLock.RLock()
+	entry, ok := rolePermissionsCache[roleId]
+	rolePermissionsCacheLock.RUnlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.permissions
+	}
+	role, err := GetRoleById(roleId)
+	if err != nil {
+		// 查询失败不缓存，避免数据库短暂不可用时长期按无权限处理
+		return nil
+	}
+	permissions := role.GetPermissions()
+	rolePermissionsCacheLock.Lock()
+	rolePermissionsCache[roleId] = rolePermissionsCacheEntry{permissions: permissions, expiresAt: time.Now().Add(rolePermissionsCacheTTL)}
+	rolePermissionsCacheLock.Unlock()
+	return permissions
+}
+
+// GetUserPermissions 计算用户的有效权限
+// 超级管理员始终拥有全部权限；其余用户绑定了自定义角色时使用该角色的权限，否则使用内置角色的默认权限；
+// 通过系统访问令牌访问且令牌绑定了角色时，再与令牌角色的权限取交集
+// 角色绑定读取用户缓存，角色权限读取本地缓存，避免每个管理请求都查询数据库
+func GetUserPermissions(userId int, role int, useAccessToken bool) ([]string, error) {
+	user, err := GetUserCache(userId)
+	if err != nil {
+		return nil, err
+	}
+	permissions := UserRolePermissions(role, user.RoleId)
+	if useAccessToken && user.AccessTokenRoleId != 0 {
+		permissions = IntersectPermissions(permissions, rolePermissions(user.AccessTokenRoleId))
+	}
+	return permissions, nil
+}
+
+// UserRolePermissions 由内置角色与绑定的自定义角色计算用户本身的权限，不含访问令牌的限制
+func UserRolePermissions(role int, roleId int) []string {
+	if role < common.RoleRootUser {
+		if custom := rolePermissions(roleId); custom != nil {
+			return custom
+		}
+	}
+	return DefaultRolePermissions(role)
+}
+
+// CanManageUserPermissions 操作者是否具备目标用户的全部权限。
+// 仅比较内置角色时，持有用户管理权限的操作者可以重置绑定了更高权限自定义角色的普通用户的密码并接管其账户
+func CanManageUserPermissions(operatorPermissions []string, target *User) bool {
+	return ContainsAllPermissions(operatorPermissions, UserRolePermissions(target.Role, target.RoleId))
+}
+
+// CanManageUserRole 操作者能否管理具有 targetRole 内置角色的用户，或将用户设为该角色。
+// 内置角色高于目标即可；目标为普通用户时，持有用户管理权限（由路由校验）的自定义角色也可以管理，
+// 管理员及以上的用户仍然只能由更高的内置角色管理。管理已有用户时还需满足 CanManageUserPermissions
+func CanManageUserRole(myRole int, targetRole int) bool {
+	return myRole > targetRole || targetRole < common.RoleAdminUser
+}
+
+func IntersectPermissions(a []string, b []string) []string {
+	allowed := make(map[string]bool, len(b))
+	for _, p := range b {
+		allowed[p] = true
+	}
+	result := make([]string, 0, len(a))
+	for _, p := range a {
+		if allowed[p] {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+func HasPermission(permissions []string, permission string) bool {
+	for _, p := range permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainsAllPermissions 判断 granted 是否包含 required 中的全部权限，用于防止越权授予
+func ContainsAllPermissions(granted []string, required []string) bool {
+	for _, p := range required {
+		if !HasPermission(granted, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// GenerateSidebarAdminConfig 根据权限生成边栏管理员区域的配置，没有任何管理权限时返回 nil
+func GenerateSidebarAdminConfig(permissions []string) map[string]interface{} {
+	config := map[string]interface{}{
+		"channel":    HasPermission(permissions, PermissionChannelsRead),
+		"models":     HasPermission(permissions, PermissionModelsManage),
+		"redemption": HasPermission(permissions, PermissionRedemptionsManage),
+		"user":       HasPermission(permissions, PermissionUsersRead),
+		"setting":    HasPermission(permissions, PermissionOptionsWrite),
+	}
+	enabled := false
+	for _, v := range config {
+		if v.(bool) {
+			enabled = true
+			break
+		}
+	}
+	if !enabled {
+		return nil
+	}
+	config["enabled"] = true
+	return config
+}
@@ -0,0 +1,156 @@
+package model
+
+import (
+	"one-api/common"
+	"testing"
+)
+
+func TestDefaultRolePermissions(t *testing.T) {
+	root := DefaultRolePermissions(common.RoleRootUser)
+	if len(root) != len(AllPermissions) {
+		t.Errorf("Expected root to have all %d permissions, got %d", len(AllPermissions), len(root))
+	}
+
+	admin := DefaultRolePermissions(common.RoleAdminUser)
+	if HasPermission(admin, PermissionOptionsWrite) || HasPermission(admin, PermissionRolesManage) {
+		t.Errorf("Expected admin to lack root only permissions, got %v", admin)
+	}
+	if !HasPermission(admin, PermissionChannelsKeyRead) || !HasPermission(admin, PermissionLogsRead) {
+		t.Errorf("Expected admin to keep management permissions, got %v", admin)
+	}
+
+	if len(DefaultRolePermissions(common.RoleCommonUser)) != 0 {
+		t.Errorf("Expected common user to have no management permissions")
+	}
+}
+
+func TestRoleSetPermissions(t *testing.T) {
+	role := Role{}
+	if err := role.SetPermissions([]string{PermissionLogsRead, PermissionChannelsRead, PermissionLogsRead}); err != nil {
+		t.Fatalf("SetPermissions failed: %v", err)
+	}
+	if role.Permissions != `["channels.read","logs.read"]` {
+		t.Errorf("Expected sorted unique permissions, got %s", role.Permissions)
+	}
+	if err := role.SetPermissions([]string{"channels.delete_everything"}); err == nil {
+		t.Errorf("Expected unknown permission to be rejected")
+	}
+}
+
+func TestIntersectPermissions(t *testing.T) {
+	result := IntersectPermissions(
+		[]string{PermissionChannelsRead, PermissionChannelsWrite, PermissionLogsRead},
+		[]string{PermissionLogsRead, PermissionChannelsRead, PermissionOptionsWrite},
+	)
+	if len(result) != 2 || !ContainsAllPermissions(result, []string{PermissionChannelsRead, PermissionLogsRead}) {
+		t.Errorf("Unexpected intersection: %v", result)
+	}
+	if len(IntersectPermissions(result, nil)) != 0 {
+		t.Errorf("Expected intersection with a missing role to be empty")
+	}
+}
+
+func TestGenerateSidebarAdminConfig(t *testing.T) {
+	if GenerateSidebarAdminConfig(nil) != nil {
+		t.Errorf("Expected no admin section without management permissions")
+	}
+	config := GenerateSidebarAdminConfig([]string{PermissionChannelsRead})
+	if config == nil || config["channel"] != true || config["setting"] != false || config["enabled"] != true {
+		t.Errorf("Unexpected admin sidebar config: %v", config)
+	}
+}
+
+func TestCanManageUserRole(t *testing.T) {
+	cases := []struct {
+		myRole, targetRole int
+		expected           bool
+	}{
+		{common.RoleRootUser, common.RoleAdminUser, true},
+		{common.RoleAdminUser, common.RoleCommonUser, true},
+		{common.RoleAdminUser, common.RoleAdminUser, false},
+		{common.RoleAdminUser, common.RoleRootUser, false},
+		// 绑定了 users.manage 自定义角色的普通用户可以管理普通用户，但不能管理管理员
+		{common.RoleCommonUser, common.RoleCommonUser, true},
+		{common.RoleCommonUser, common.RoleAdminUser, false},
+		{common.RoleRootUser, common.RoleRootUser, false},
+	}
+	for _, tc := range cases {
+		if CanManageUserRole(tc.myRole, tc.targetRole) != tc.expected {
+			t.Errorf("CanManageUserRole(%d, %d) expected %v", tc.myRole, tc.targetRole, tc.expected)
+		}
+	}
+}
+
+func TestGetUserPermissionsCachesRoles(t *testing.T) {
+	setupTestDB(t, &User{}, &Role{})
+	role := &Role{Name: "auditor"}
+	_ = role.SetPermissions([]string{PermissionLogsRead, PermissionUsersManage})
+	if err := role.Insert(); err != nil {
+		t.Fatal(err)
+	}
+	tokenRole := &Role{Name: "readonly"}
+	_ = tokenRole.SetPermissions([]string{PermissionLogsRead})
+	if err := tokenRole.Insert(); err != nil {
+		t.Fatal(err)
+	}
+	user := &User{Username: "u", Password: "x", AffCode: "rbac", Role: common.RoleCommonUser, RoleId: role.Id, AccessTokenRoleId: tokenRole.Id}
+	if err := DB.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	permissions, err := GetUserPermissions(user.Id, user.Role, false)
+	if err != nil || !ContainsAllPermissions(permissions, []string{PermissionLogsRead, PermissionUsersManage}) {
+		t.Fatalf("Unexpected permissions: %v %v", permissions, err)
+	}
+	permissions, _ = GetUserPermissions(user.Id, user.Role, true)
+	if len(permissions) != 1 || permissions[0] != PermissionLogsRead {
+		t.Errorf("Expected access token permissions to be narrowed, got %v", permissions)
+	}
+
+	// 绕过 Update 直接修改数据库时命中缓存，通过 Update 修改后立即生效
+	DB.Model(&Role{}).Where("id = ?", role.Id).Update("permissions", `["audit.read"]`)
+	if permissions, _ = GetUserPermissions(user.Id, user.Role, false); !HasPermission(permissions, PermissionUsersManage) {
+		t.Errorf("Expected cached role permissions, got %v", permissions)
+	}
+	_ = role.SetPermissions([]string{PermissionAuditRead})
+	if err = role.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if permissions, _ = GetUserPermissions(user.Id, user.Role, false); len(permissions) != 1 || permissions[0] != PermissionAuditRead {
+		t.Errorf("Expected updated role permissions, got %v", permissions)
+	}
+
+	if err = DeleteRoleById(role.Id); err != nil {
+		t.Fatal(err)
+	}
+	if permissions, _ = GetUserPermissions(user.Id, user.Role, false); len(permissions) != 0 {
+		t.Errorf("Expected common user to fall back to default permissions, got %v", permissions)
+	}
+}
+
+func TestCanManageUserPermissions(t *testing.T) {
+	setupTestDB(t, &User{}, &Role{})
+	role := &Role{Name: "operator"}
+	_ = role.SetPermissions([]string{PermissionUsersManage, PermissionChannelsKeyRead})
+	if err := role.Insert(); err != nil {
+		t.Fatal(err)
+	}
+	manager := []string{PermissionUsersRead, PermissionUsersManage}
+	plain := &User{Role: common.RoleCommonUser}
+	privileged := &User{Role: common.RoleCommonUser, RoleId: role.Id}
+	admin := &User{Role: common.RoleAdminUser}
+
+	if !CanManageUserPermissions(manager, plain) {
+		t.Error("Expected user manager to manage a plain common user")
+	}
+	// 内置角色同为普通用户，但自定义角色包含操作者不具备的 channels.key.read
+	if CanManageUserPermissions(manager, privileged) {
+		t.Error("Expected user manager not to manage a user with broader custom permissions")
+	}
+	if !CanManageUserPermissions(DefaultRolePermissions(common.RoleAdminUser), privileged) {
+		t.Error("Expected admin to manage a user whose permissions it covers")
+	}
+	if !CanManageUserPermissions(DefaultRolePermissions(common.RoleRootUser), admin) || CanManageUserPermissions(manager, admin) {
+		t.Error("Unexpected result for admin target")
+	}
+}
@@ -0,0 +1,232 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ScimFilterCondition SCIM 过滤表达式中的一个比较条件，例如 userName eq "alice"
+type ScimFilterCondition struct {
+	Attribute string
+	Operator  string
+	Value     string
+}
+
+// ErrInvalidScimFilter 过滤表达式无法解析或使用了不支持的属性
+var ErrInvalidScimFilter = errors.New("invalid filter")
+
+// scimUserColumns SCIM 用户属性（小写）到数据库列的映射
+var scimUserColumns = map[string]string{
+	"id":           "id",
+	"username":     "username",
+	"externalid":   "external_id",
+	"displayname":  "display_name",
+	"emails":       "email",
+	"emails.value": "email",
+	"active":       "status",
+}
+
+func tokenizeScimFilter(filter string) ([]string, error) {
+	var tokens []string
+	var current strings.Builder
+	inQuotes := false
+	for i := 0; i < len(filter); i++ {
+		ch := filter[i]
+		switch {
+		case inQuotes && ch == '\\' && i+1 < len(filter):
+			i++
+			current.WriteByte(filter[i])
+		case ch == '"':
+			if inQuotes {
+				tokens = append(tokens, "\""+current.String())
+				current.Reset()
+			} else if current.Len() > 0 {
+				return nil, ErrInvalidScimFilter
+			}
+			inQuotes = !inQuotes
+		case !inQuotes && (ch == ' ' || ch == '\t'):
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		case !inQuotes && (ch == '(' || ch == ')' || ch == '['):
+			// 不支持分组与复杂属性过滤
+			return nil, ErrInvalidScimFilter
+		default:
+			current.WriteByte(ch)
+		}
+	}
+	if inQuotes {
+		return nil, ErrInvalidScimFilter
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens, nil
+}
+
+// ParseScimFilter 解析 SCIM 过滤表达式，支持 eq、ne、co、sw、ew、pr 以及 and 连接，不支持 or 与括号
+func ParseScimFilter(filter string) ([]ScimFilterCondition, error) {
+	tokens, err := tokenizeScimFilter(strings.TrimSpace(filter))
+	if err != nil {
+		return nil, err
+	}
+	var conditions []ScimFilterCondition
+	for i := 0; i < len(tokens); {
+		if len(conditions) > 0 {
+			if !strings.EqualFold(tokens[i], "and") {
+				return nil, ErrInvalidScimFilter
+			}
+			i++
+		}
+		if i+1 >= len(tokens) {
+			return nil, ErrInvalidScimFilter
+		}
+		condition := ScimFilterCondition{
+			Attribute: strings.ToLower(tokens[i]),
+			Operator:  strings.ToLower(tokens[i+1]),
+		}
+		i += 2
+		switch condition.Operator {
+		case "pr":
+		case "eq", "ne", "co", "sw", "ew":
+			if i >= len(tokens) {
+				return nil, ErrInvalidScimFilter
+			}
+			condition.Value = strings.TrimPrefix(tokens[i], "\"")
+			i++
+		default:
+			return nil, ErrInvalidScimFilter
+		}
+		conditions = append(conditions, condition)
+	}
+	return conditions, nil
+}
+
+// MatchScimFilterValue 在内存中判断单个值是否满足条件，用于分组等不在数据库中的资源
+func MatchScimFilterValue(condition ScimFilterCondition, value string) bool {
+	lowerValue, lowerTarget := strings.ToLower(value), strings.ToLower(condition.Value)
+	switch condition.Operator {
+	case "pr":
+		return value != ""
+	case "eq":
+		return lowerValue == lowerTarget
+	case "ne":
+		return lowerValue != lowerTarget
+	case "co":
+		return strings.Contains(lowerValue, lowerTarget)
+	case "sw":
+		return strings.HasPrefix(lowerValue, lowerTarget)
+	case "ew":
+		return strings.HasSuffix(lowerValue, lowerTarget)
+	}
+	return false
+}
+
+func applyScimUserCondition(tx *gorm.DB, condition ScimFilterCondition) (*gorm.DB, error) {
+	column, ok := scimUserColumns[condition.Attribute]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported attribute %s", ErrInvalidScimFilter, condition.Attribute)
+	}
+	if column == "status" {
+		active := strings.EqualFold(condition.Value, "true")
+		switch condition.Operator {
+		case "eq":
+		case "ne":
+			active = !active
+		case "pr":
+			return tx, nil
+		default:
+			return nil, ErrInvalidScimFilter
+		}
+		if active {
+			return tx.Where("status = ?", common.UserStatusEnabled), nil
+		}
+		return tx.Where("status <> ?", common.UserStatusEnabled), nil
+	}
+	switch condition.Operator {
+	case "pr":
+		if column == "id" {
+			return tx, nil
+		}
+		return tx.Where(column + " IS NOT NULL AND " + column + " <> ''"), nil
+	case "eq":
+		return tx.Where(column+" = ?", condition.Value), nil
+	case "ne":
+		return tx.Where(column+" <> ?", condition.Value), nil
+	case "co":
+		return tx.Where(column+" LIKE ?", "%"+condition.Value+"%"), nil
+	case "sw":
+		return tx.Where(column+" LIKE ?", condition.Value+"%"), nil
+	case "ew":
+		return tx.Where(column+" LIKE ?", "%"+condition.Value), nil
+	}
+	return nil, ErrInvalidScimFilter
+}
+
+// GetScimUsers 按 SCIM 过滤条件分页查询用户
+func GetScimUsers(conditions []ScimFilterCondition, startIdx int, num int) (users []*User, total int64, err error) {
+	tx := DB.Model(&User{})
+	for _, condition := range conditions {
+		if tx, err = applyScimUserCondition(tx, condition); err != nil {
+			return nil, 0, err
+		}
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id asc").Limit(num).Offset(startIdx).Omit("password").Find(&users).Error
+	return users, total, err
+}
+
+// GetUsersByGroup 返回指定分组的全部用户
+func GetUsersByGroup(group string) (users []*User, err error) {
+	err = DB.Select("id", "username", "display_name").Where(commonGroupCol+" = ?", group).Order("id asc").Find(&users).Error
+	return users, err
+}
+
+// MoveUsersGroup 将用户移动到 toGroup，fromGroup 不为空时只移动当前属于 fromGroup 的用户
+func MoveUsersGroup(userIds []int, fromGroup string, toGroup string) error {
+	if len(userIds) == 0 {
+		return nil
+	}
+	tx := DB.Model(&User{}).Where("id IN ?", userIds)
+	if fromGroup != "" {
+		tx = tx.Where(commonGroupCol+" = ?", fromGroup)
+	}
+	if err := tx.Update("group", toGroup).Error; err != nil {
+		return err
+	}
+	for _, id := range userIds {
+		if err := invalidateUserCache(id); err != nil {
+			common.SysError(fmt.Sprintf("failed to invalidate user cache %d: %s", id, err.Error()))
+		}
+	}
+	return nil
+}
+
+// UpdateUserFields 更新用户的部分字段并清除用户缓存
+func UpdateUserFields(userId int, updates map[string]interface{}) error {
+	if len(updates) == 0 {
+		return nil
+	}
+	if err := DB.Model(&User{}).Where("id = ?", userId).Updates(updates).Error; err != nil {
+		return err
+	}
+	return invalidateUserCache(userId)
+}
+
+// DeactivateUser 禁用用户并立即禁用其全部令牌与访问令牌，返回被禁用的令牌数量
+func DeactivateUser(userId int) (int, error) {
+	if err := UpdateUserFields(userId, map[string]interface{}{"status": common.UserStatusDisabled}); err != nil {
+		return 0, err
+	}
+	if err := RevokeUserPersonalAccessTokens(userId); err != nil {
+		return 0, err
+	}
+	return DisableUserTokens(userId)
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+	"testing"
+)
+
+func TestParseScimFilter(t *testing.T) {
+	conditions, err := ParseScimFilter(`userName eq "alice \"a\"@corp.com" and active pr`)
+	if err != nil {
+		t.Fatalf("ParseScimFilter failed: %v", err)
+	}
+	if len(conditions) != 2 {
+		t.Fatalf("Expected 2 conditions, got %v", conditions)
+	}
+	if conditions[0] != (ScimFilterCondition{Attribute: "username", Operator: "eq", Value: `alice "a"@corp.com`}) {
+		t.Errorf("Unexpected first condition: %+v", conditions[0])
+	}
+	if conditions[1] != (ScimFilterCondition{Attribute: "active", Operator: "pr"}) {
+		t.Errorf("Unexpected second condition: %+v", conditions[1])
+	}
+
+	if conditions, err = ParseScimFilter(""); err != nil || len(conditions) != 0 {
+		t.Errorf("Expected empty filter to match everything, got %v %v", conditions, err)
+	}
+
+	for _, filter := range []string{
+		`userName eq`,
+		`userName gt "a"`,
+		`userName eq "a" or userName eq "b"`,
+		`(userName eq "a")`,
+		`emails[type eq "work"].value eq "a"`,
+		`userName eq "a`,
+	} {
+		if _, err = ParseScimFilter(filter); !errors.Is(err, ErrInvalidScimFilter) {
+			t.Errorf("Expected %q to be rejected, got %v", filter, err)
+		}
+	}
+}
+
+func TestMatchScimFilterValue(t *testing.T) {
+	if !MatchScimFilterValue(ScimFilterCondition{Operator: "eq", Value: "VIP"}, "vip") {
+		t.Error("Expected eq to be case-insensitive")
+	}
+	if !MatchScimFilterValue(ScimFilterCondition{Operator: "sw", Value: "v"}, "vip") {
+		t.Error("Expected sw to match prefix")
+	}
+	if MatchScimFilterValue(ScimFilterCondition{Operator: "co", Value: "x"}, "vip") {
+		t.Error("Expected co not to match")
+	}
+}
+
+func TestDeactivateUserRevokesPersonalAccessTokens(t *testing.T) {
+	setupTestDB(t, &User{}, &Token{}, &PersonalAccessToken{})
+	user := &User{Username: "alice", AffCode: "aff1", Status: common.UserStatusEnabled}
+	if err := DB.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	pat := &PersonalAccessToken{UserId: user.Id, TokenHash: "h1", Status: PersonalAccessTokenStatusEnabled}
+	if err := DB.Create(pat).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DeactivateUser(user.Id); err != nil {
+		t.Fatalf("DeactivateUser failed: %v", err)
+	}
+	if err := DB.First(pat, pat.Id).Error; err != nil {
+		t.Fatal(err)
+	}
+	if pat.Status != PersonalAccessTokenStatusRevoked || pat.RevokedTime == 0 {
+		t.Errorf("Expected access token to be revoked, got %+v", pat)
+	}
+}
@@ -0,0 +1,228 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/common/envelope"
+	"reflect"
+	"sync"
+
+	"gorm.io/gorm/schema"
+)
+
+func init() {
+	schema.RegisterSerializer("secret", SecretSerializer{})
+}
+
+// SecretSerializer 写入数据库时使用信封加密，读取时解密，对业务代码透明。
+// 注意 Update("column", value) 与 map 形式的更新不会经过序列化器，需要调用方自行加密
+type SecretSerializer struct{}
+
+func (SecretSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var value string
+	switch v := dbValue.(type) {
+	case nil:
+	case []byte:
+		value = string(v)
+	case string:
+		value = v
+	default:
+		return fmt.Errorf("unsupported secret value type %T", dbValue)
+	}
+	plain, err := envelope.Decrypt(value)
+	if err != nil {
+		// 解密失败时保留密文，避免整批查询失败；密文再次写入时会原样保存
+		common.SysError(fmt.Sprintf("failed to decrypt %s.%s: %s", field.Schema.Table, field.DBName, err.Error()))
+		plain = value
+	}
+	field.ReflectValueOf(ctx, dst).SetString(plain)
+	return nil
+}
+
+func (SecretSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	value, _ := fieldValue.(string)
+	return envelope.Encrypt(value)
+}
+
+// isEncryptedOption 需要加密存储的配置项，与 GetOptions 隐藏的密钥类配置项一致，见 MaskOptionValue
+func isEncryptedOption(key string) bool {
+	_, ok := maskedJSONOptions[key]
+	return IsSecretField(key) || ok
+}
+
+// decryptWebhookSecret 解密用户设置中的 Webhook 密钥，失败时保留原值
+func decryptWebhookSecret(value string) string {
+	plain, err := envelope.Decrypt(value)
+	if err != nil {
+		common.SysError("failed to decrypt webhook secret: " + err.Error())
+		return value
+	}
+	return plain
+}
+
+// SecretRewrapResult 一次重新加密的统计
+type SecretRewrapResult struct {
+	PrimaryKeyId string `json:"primary_key_id"`
+	Channels     int    `json:"channels"`
+	Options      int    `json:"options"`
+	Users        int    `json:"users"`
+	Failed       int    `json:"failed"`
+	StartedAt    int64  `json:"started_at"`
+	FinishedAt   int64  `json:"finished_at"`
+}
+
+var (
+	secretRewrapMu      sync.Mutex
+	secretRewrapRunning bool
+	lastSecretRewrap    *SecretRewrapResult
+)
+
+var ErrSecretRewrapRunning = errors.New("重新加密任务正在进行中")
+
+// GetSecretRewrapStatus 返回是否正在重新加密以及上一次的结果
+func GetSecretRewrapStatus() (bool, *SecretRewrapResult) {
+	secretRewrapMu.Lock()
+	defer secretRewrapMu.Unlock()
+	return secretRewrapRunning, lastSecretRewrap
+}
+
+// RewrapSecrets 将明文或使用旧主密钥加密的渠道密钥、密钥类配置项与用户 Webhook 密钥使用当前主密钥重新加密。
+// 既用于启用加密后迁移已有数据，也用于主密钥轮换，可以重复执行
+func RewrapSecrets() (*SecretRewrapResult, error) {
+	if !envelope.Enabled() {
+		return nil, envelope.ErrNotConfigured
+	}
+	secretRewrapMu.Lock()
+	if secretRewrapRunning {
+		secretRewrapMu.Unlock()
+		return nil, ErrSecretRewrapRunning
+	}
+	secretRewrapRunning = true
+	secretRewrapMu.Unlock()
+
+	result := &SecretRewrapResult{PrimaryKeyId: envelope.PrimaryKeyId(), StartedAt: common.GetTimestamp()}
+	defer func() {
+		result.FinishedAt = common.GetTimestamp()
+		secretRewrapMu.Lock()
+		secretRewrapRunning = false
+		lastSecretRewrap = result
+		secretRewrapMu.Unlock()
+	}()
+
+	if err := rewrapChannelKeys(result); err != nil {
+		return result, err
+	}
+	if err := rewrapOptions(result); err != nil {
+		return result, err
+	}
+	if err := rewrapUserWebhookSecrets(result); err != nil {
+		return result, err
+	}
+	common.SysLog(fmt.Sprintf("secrets rewrapped with key %s: %d channels, %d options, %d users, %d failed",
+		result.PrimaryKeyId, result.Channels, result.Options, result.Users, result.Failed))
+	return result, nil
+}
+
+const secretRewrapBatchSize = 200
+
+func rewrapChannelKeys(result *SecretRewrapResult) error {
+	lastId := 0
+	for {
+		// 读取原始列值，不经过序列化器，以便判断是否需要重新加密
+		var rows []struct {
+			Id  int
+			Key string
+		}
+		err := DB.Table("channels").Select("id, "+commonKeyCol).Where("id > ?", lastId).
+			Order("id asc").Limit(secretRewrapBatchSize).Scan(&rows).Error
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		for _, row := range rows {
+			lastId = row.Id
+			if !envelope.NeedsRewrap(row.Key) {
+				continue
+			}
+			encrypted, err := envelope.Rewrap(row.Key)
+			if err != nil {
+				result.Failed++
+				common.SysError(fmt.Sprintf("failed to rewrap key of channel %d: %s", row.Id, err.Error()))
+				continue
+			}
+			// 以原值为条件，避免覆盖期间被修改的密钥
+			err = DB.Table("channels").Where("id = ? AND "+commonKeyCol+" = ?", row.Id, row.Key).
+				Update("key", encrypted).Error
+			if err != nil {
+				return err
+			}
+			result.Channels++
+		}
+	}
+}
+
+func rewrapOptions(result *SecretRewrapResult) error {
+	options, err := AllOption()
+	if err != nil {
+		return err
+	}
+	for _, option := range options {
+		if !isEncryptedOption(option.Key) || !envelope.NeedsRewrap(option.Value) {
+			continue
+		}
+		encrypted, err := envelope.Rewrap(option.Value)
+		if err != nil {
+			result.Failed++
+			common.SysError(fmt.Sprintf("failed to rewrap option %s: %s", option.Key, err.Error()))
+			continue
+		}
+		err = DB.Model(&Option{}).Where(commonKeyCol+" = ? AND value = ?", option.Key, option.Value).
+			Update("value", encrypted).Error
+		if err != nil {
+			return err
+		}
+		result.Options++
+	}
+	return nil
+}
+
+func rewrapUserWebhookSecrets(result *SecretRewrapResult) error {
+	lastId := 0
+	for {
+		var users []*User
+		err := DB.Unscoped().Select("id", "setting").Where("id > ? AND setting LIKE ?", lastId, "%webhook_secret%").
+			Order("id asc").Limit(secretRewrapBatchSize).Find(&users).Error
+		if err != nil {
+			return err
+		}
+		if len(users) == 0 {
+			return nil
+		}
+		for _, user := range users {
+			lastId = user.Id
+			var raw struct {
+				WebhookSecret string `json:"webhook_secret"`
+			}
+			if err = common.UnmarshalJsonStr(user.Setting, &raw); err != nil || !envelope.NeedsRewrap(raw.WebhookSecret) {
+				continue
+			}
+			setting := user.GetSetting()
+			if setting.WebhookSecret == raw.WebhookSecret && envelope.IsEncrypted(raw.WebhookSecret) {
+				// 解密失败，保留原值
+				result.Failed++
+				continue
+			}
+			original := user.Setting
+			user.SetSetting(setting)
+			err = DB.Model(&User{}).Where("id = ? AND setting = ?", user.Id, original).Update("setting", user.Setting).Error
+			if err != nil {
+				return err
+			}
+			result.Users++
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"one-api/common"
+
+	"gorm.io/gorm"
+)
+
+const (
+	StatementStatusPending   = "pending"
+	StatementStatusCompleted = "completed"
+	StatementStatusFailed    = "failed"
+)
+
+// Statement 账单（对账单）归档
+// 生成后的数据独立保存，不会因为 DeleteOldLog 清理日志而发生变化。
+// TokenId / Group 为空时表示整个用户的账单，否则仅统计对应令牌/分组的消费；
+// 期初、期末余额始终是用户账户的余额。
+type Statement struct {
+	Id               int     `json:"id"`
+	UserId           int     `json:"user_id" gorm:"index"`
+	Username         string  `json:"username" gorm:"type:varchar(64);default:''"`
+	TokenId          int     `json:"token_id" gorm:"default:0"`
+	TokenName        string  `json:"token_name" gorm:"default:''"`
+	Group            string  `json:"group" gorm:"type:varchar(64);default:''"`
+	PeriodStart      int64   `json:"period_start" gorm:"bigint;index"`
+	PeriodEnd        int64   `json:"period_end" gorm:"bigint;index"`
+	Status           string  `json:"status" gorm:"type:varchar(32);index"`
+	Message          string  `json:"message" gorm:"type:text"`
+	QuotaPerUnit     float64 `json:"quota_per_unit"`
+	OpeningQuota     int64   `json:"opening_quota"`
+	ClosingQuota     int64   `json:"closing_quota"`
+	TopUpCount       int64   `json:"top_up_count"`
+	TopUpQuota       int64   `json:"top_up_quota"`
+	TopUpMoney       float64 `json:"top_up_money"`
+	RedemptionCount  int64   `json:"redemption_count"`
+	RedemptionQuota  int64   `json:"redemption_quota"`
+	RequestCount     int64   `json:"request_count"`
+	ConsumedQuota    int64   `json:"consumed_quota"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	BillingMode      string  `json:"billing_mode" gorm:"type:varchar(16);default:''"` // 出账时的计费方式
+	CreditLimit      int64   `json:"credit_limit"`                                    // 出账时后付费用户生效的信用额度
+	Items            string  `json:"items" gorm:"type:text"`
+	CreatedTime      int64   `json:"created_time" gorm:"bigint"`
+	CompletedTime    int64   `json:"completed_time" gorm:"bigint"`
+}
+
+// StatementItem 账单中按模型统计的消费明细
+type StatementItem struct {
+	ModelName        string `json:"model_name"`
+	RequestCount     int64  `json:"request_count"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	Quota            int64  `json:"quota"`
+}
+
+// StatementFlow 某时间段内的资金流入/流出汇总
+type StatementFlow struct {
+	TopUpCount       int64
+	TopUpQuota       int64
+	TopUpMoney       float64
+	RedemptionCount  int64
+	RedemptionQuota  int64
+	RequestCount     int64
+	ConsumedQuota    int64
+	PromptTokens     int64
+	CompletionTokens int64
+}
+
+func (s *Statement) Insert() error {
+	s.CreatedTime = common.GetTimestamp()
+	return DB.Create(s).Error
+}
+
+func (s *Statement) Update() error {
+	return DB.Save(s).Error
+}
+
+func (s *Statement) GetItems() []StatementItem {
+	var items []StatementItem
+	if s.Items == "" {
+		return items
+	}
+	_ = json.Unmarshal([]byte(s.Items), &items)
+	return items
+}
+
+func (s *Statement) SetItems(items []StatementItem) {
+	data, err := json.Marshal(items)
+	if err != nil {
+		return
+	}
+	s.Items = string(data)
+}
+
+func GetStatementById(id int) (*Statement, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	statement := Statement{}
+	err := DB.First(&statement, "id = ?", id).Error
+	return &statement, err
+}
+
+func GetUserStatementById(id int, userId int) (*Statement, error) {
+	if id == 0 || userId == 0 {
+		return nil, errors.New("id 或 userId 为空！")
+	}
+	statement := Statement{}
+	err := DB.First(&statement, "id = ? and user_id = ?", id, userId).Error
+	return &statement, err
+}
+
+func GetStatements(userId int, startIdx int, num int) (statements []*Statement, total int64, err error) {
+	tx := DB.Model(&Statement{})
+	if userId != 0 {
+		tx = tx.Where("user_id = ?", userId)
+	}
+	err = tx.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&statements).Error
+	return statements, total, err
+}
+
+// StatementExists 判断同一范围、同一周期的账单是否已生成过
+func StatementExists(userId int, tokenId int, group string, periodStart int64, periodEnd int64) bool {
+	var count int64
+	DB.Model(&Statement{}).Where("user_id = ? and token_id = ? and "+commonGroupCol+" = ? and period_start = ? and period_end = ? and status <> ?",
+		userId, tokenId, group, periodStart, periodEnd, StatementStatusFailed).Count(&count)
+	return count > 0
+}
+
+func DeleteStatementById(id int) error {
+	return DB.Delete(&Statement{}, "id = ?", id).Error
+}
+
+// topUpCreditedQuota 计算充值订单实际到账的额度
+// Stripe 订单按 Money 入账（见 Recharge），其余按 Amount 入账
+func topUpCreditedQuota(topUp *TopUp) int64 {
+	if strings.HasPrefix(topUp.TradeNo, "ref_") {
+		return int64(topUp.Money * common.QuotaPerUnit)
+	}
+	return int64(float64(topUp.Amount) * common.QuotaPerUnit)
+}
+
+// GetStatementFlow 汇总 [startTimestamp, endTimestamp) 内用户的充值、兑换与消费
+// tokenId、group 仅作用于消费统计
+func GetStatementFlow(userId int, tokenId int, group string, startTimestamp int64, endTimestamp int64) (flow StatementFlow, err error) {
+	var topUps []*TopUp
+	err = DB.Where("user_id = ? and status = ? and complete_time >= ? and complete_time < ?",
+		userId, common.TopUpStatusSuccess, startTimestamp, endTimestamp).Find(&topUps).Error
+	if err != nil {
+		return flow, err
+	}
+	for _, topUp := range topUps {
+		flow.TopUpCount++
+		flow.TopUpMoney += topUp.Money
+		flow.TopUpQuota += topUpCreditedQuota(topUp)
+	}
+
+	// 订阅套餐发放的额度计入充值
+	var invoices []*SubscriptionInvoice
+	err = DB.Where("user_id = ? and created_time >= ? and created_time < ?", userId, startTimestamp, endTimestamp).
+		Find(&invoices).Error
+	if err != nil {
+		return flow, err
+	}
+	for _, invoice := range invoices {
+		flow.TopUpCount++
+		flow.TopUpMoney += float64(invoice.AmountPaid) / 100
+		flow.TopUpQuota += int64(invoice.Quota)
+	}
+
+	var redemption struct {
+		Count int64
+		Quota int64
+	}
+	err = DB.Model(&Redemption{}).Unscoped().Select("count(*) as count, coalesce(sum(quota),0) as quota").
+		Where("used_user_id = ? and redeemed_time >= ? and redeemed_time < ?", userId, startTimestamp, endTimestamp).
+		Scan(&redemption).Error
+	if err != nil {
+		return flow, err
+	}
+	flow.RedemptionCount = redemption.Count
+	flow.RedemptionQuota = redemption.Quota
+
+	var consume struct {
+		Count            int64
+		Quota            int64
+		PromptTokens     int64
+		CompletionTokens int64
+	}
+	err = statementLogQuery(userId, tokenId, group, startTimestamp, endTimestamp).
+		Select("count(*) as count, coalesce(sum(quota),0) as quota, coalesce(sum(prompt_tokens),0) as prompt_tokens, coalesce(sum(completion_tokens),0) as completion_tokens").
+		Scan(&consume).Error
+	if err != nil {
+		return flow, err
+	}
+	flow.RequestCount = consume.Count
+	flow.ConsumedQuota = consume.Quota
+	flow.PromptTokens = consume.PromptTokens
+	flow.CompletionTokens = consume.CompletionTokens
+	return flow, nil
+}
+
+// GetStatementItems 按模型汇总 [startTimestamp, endTimestamp) 内的消费日志
+func GetStatementItems(userId int, tokenId int, group string, startTimestamp int64, endTimestamp int64) (items []StatementItem, err error) {
+	err = statementLogQuery(userId, tokenId, group, startTimestamp, endTimestamp).
+		Select("model_name, count(*) as request_count, coalesce(sum(prompt_tokens),0) as prompt_tokens, coalesce(sum(completion_tokens),0) as completion_tokens, coalesce(sum(quota),0) as quota").
+		Group("model_name").Order("quota desc").Scan(&items).Error
+	return items, err
+}
+
+func statementLogQuery(userId int, tokenId int, group string, startTimestamp int64, endTimestamp int64) *gorm.DB {
+	tx := LOG_DB.Table("logs").Where("user_id = ? and type = ? and created_at >= ? and created_at < ?",
+		userId, LogTypeConsume, startTimestamp, endTimestamp)
+	if tokenId != 0 {
+		tx = tx.Where("token_id = ?", tokenId)
+	}
+	if group != "" {
+		tx = tx.Where(logGroupCol+" = ?", group)
+	}
+	return tx
+}
+
+// GetStatementUserIds 获取周期内有充值、兑换或消费记录的用户
+func GetStatementUserIds(startTimestamp int64, endTimestamp int64) ([]int, error) {
+	userIds := make(map[int]struct{})
+	var ids []int
+	if err := LOG_DB.Table("logs").Distinct("user_id").
+		Where("type = ? and created_at >= ? and created_at < ?", LogTypeConsume, startTimestamp, endTimestamp).
+		Pluck("user_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		userIds[id] = struct{}{}
+	}
+	ids = nil
+	if err := DB.Model(&TopUp{}).Distinct("user_id").
+		Where("status = ? and complete_time >= ? and complete_time < ?", common.TopUpStatusSuccess, startTimestamp, endTimestamp).
+		Pluck("user_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		userIds[id] = struct{}{}
+	}
+	ids = nil
+	if err := DB.Model(&Redemption{}).Unscoped().Distinct("used_user_id").
+		Where("redeemed_time >= ? and redeemed_time < ?", startTimestamp, endTimestamp).
+		Pluck("used_user_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		userIds[id] = struct{}{}
+	}
+	// 后付费用户即使本期没有账务变动，只要仍有欠款也需要出账
+	ids, err := GetOverduePostpaidUserIds()
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		userIds[id] = struct{}{}
+	}
+	result := make([]int, 0, len(userIds))
+	for id := range userIds {
+		if id != 0 {
+			result = append(result, id)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,290 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/logger"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Stripe 订阅状态
+const (
+	SubscriptionStatusActive            = "active"
+	SubscriptionStatusTrialing          = "trialing"
+	SubscriptionStatusPastDue           = "past_due"
+	SubscriptionStatusCanceled          = "canceled"
+	SubscriptionStatusUnpaid            = "unpaid"
+	SubscriptionStatusIncomplete        = "incomplete"
+	SubscriptionStatusIncompleteExpired = "incomplete_expired"
+)
+
+// SubscriptionPlan 订阅套餐，每个套餐对应 Stripe 上的一个周期价格
+type SubscriptionPlan struct {
+	Id            int     `json:"id"`
+	Name          string  `json:"name" gorm:"type:varchar(64)"`
+	Description   string  `json:"description" gorm:"type:text"`
+	StripePriceId string  `json:"stripe_price_id" gorm:"type:varchar(64);index"`
+	Price         float64 `json:"price"` // 仅用于展示，实际扣款以 Stripe 价格为准
+	Currency      string  `json:"currency" gorm:"type:varchar(8)"`
+	Group         string  `json:"group" gorm:"type:varchar(64)"`
+	MonthlyQuota  int     `json:"monthly_quota"`
+	RateLimitTier string  `json:"rate_limit_tier" gorm:"type:varchar(64)"`
+	Enabled       bool    `json:"enabled"`
+	SortOrder     int     `json:"sort_order"`
+	CreatedTime   int64   `json:"created_time" gorm:"bigint"`
+}
+
+// UserSubscription 用户的 Stripe 订阅
+type UserSubscription struct {
+	Id                   int    `json:"id"`
+	UserId               int    `json:"user_id" gorm:"index"`
+	PlanId               int    `json:"plan_id"`
+	StripeSubscriptionId string `json:"stripe_subscription_id" gorm:"type:varchar(64);uniqueIndex"`
+	StripeCustomerId     string `json:"stripe_customer_id" gorm:"type:varchar(64)"`
+	Status               string `json:"status" gorm:"type:varchar(32);index"`
+	PreviousGroup        string `json:"previous_group" gorm:"type:varchar(64)"` // 订阅前的分组，订阅结束后恢复
+	CurrentPeriodStart   int64  `json:"current_period_start" gorm:"bigint"`
+	CurrentPeriodEnd     int64  `json:"current_period_end" gorm:"bigint"`
+	CancelAtPeriodEnd    bool   `json:"cancel_at_period_end"`
+	CreatedTime          int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime          int64  `json:"updated_time" gorm:"bigint"`
+}
+
+// SubscriptionInvoice 已发放额度的订阅账单，InvoiceId 唯一以保证 webhook 重放时不会重复发放
+type SubscriptionInvoice struct {
+	Id                   int    `json:"id"`
+	InvoiceId            string `json:"invoice_id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId               int    `json:"user_id" gorm:"index"`
+	PlanId               int    `json:"plan_id"`
+	StripeSubscriptionId string `json:"stripe_subscription_id" gorm:"type:varchar(64);index"`
+	Quota                int    `json:"quota"`
+	AmountPaid           int64  `json:"amount_paid"` // 最小货币单位，例如美分
+	Currency             string `json:"currency" gorm:"type:varchar(8)"`
+	CreatedTime          int64  `json:"created_time" gorm:"bigint;index"`
+}
+
+func (sub *UserSubscription) IsActive() bool {
+	switch sub.Status {
+	case SubscriptionStatusActive, SubscriptionStatusTrialing, SubscriptionStatusPastDue:
+		return true
+	}
+	return false
+}
+
+func (plan *SubscriptionPlan) Insert() error {
+	plan.CreatedTime = common.GetTimestamp()
+	return DB.Create(plan).Error
+}
+
+func (plan *SubscriptionPlan) Update() error {
+	return DB.Model(plan).Select("name", "description", "stripe_price_id", "price", "currency", "group",
+		"monthly_quota", "rate_limit_tier", "enabled", "sort_order").Updates(plan).Error
+}
+
+func DeleteSubscriptionPlanById(id int) error {
+	if id == 0 {
+		return errors.New("id 为空！")
+	}
+	var count int64
+	err := DB.Model(&UserSubscription{}).Where("plan_id = ? and status in ?", id, activeSubscriptionStatuses()).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("该套餐仍有生效中的订阅，无法删除")
+	}
+	return DB.Delete(&SubscriptionPlan{}, "id = ?", id).Error
+}
+
+func GetSubscriptionPlans(enabledOnly bool) (plans []*SubscriptionPlan, err error) {
+	tx := DB.Model(&SubscriptionPlan{})
+	if enabledOnly {
+		tx = tx.Where("enabled = ?", true)
+	}
+	err = tx.Order("sort_order asc, id asc").Find(&plans).Error
+	return plans, err
+}
+
+func GetSubscriptionPlanById(id int) (*SubscriptionPlan, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	plan := SubscriptionPlan{}
+	err := DB.First(&plan, "id = ?", id).Error
+	return &plan, err
+}
+
+func GetSubscriptionPlanByPriceId(priceId string) (*SubscriptionPlan, error) {
+	if priceId == "" {
+		return nil, errors.New("price id 为空！")
+	}
+	plan := SubscriptionPlan{}
+	err := DB.First(&plan, "stripe_price_id = ?", priceId).Error
+	return &plan, err
+}
+
+func GetUserSubscriptionByStripeId(stripeSubscriptionId string) (*UserSubscription, error) {
+	sub := UserSubscription{}
+	err := DB.First(&sub, "stripe_subscription_id = ?", stripeSubscriptionId).Error
+	return &sub, err
+}
+
+// GetActiveUserSubscription 返回用户当前生效的订阅，没有时返回 nil
+func GetActiveUserSubscription(userId int) (*UserSubscription, error) {
+	var subs []*UserSubscription
+	err := DB.Where("user_id = ? and status in ?", userId, activeSubscriptionStatuses()).
+		Order("id desc").Limit(1).Find(&subs).Error
+	if err != nil || len(subs) == 0 {
+		return nil, err
+	}
+	return subs[0], nil
+}
+
+func GetUserSubscriptions(userId int, startIdx int, num int) (subs []*UserSubscription, total int64, err error) {
+	tx := DB.Model(&UserSubscription{})
+	if userId != 0 {
+		tx = tx.Where("user_id = ?", userId)
+	}
+	err = tx.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&subs).Error
+	return subs, total, err
+}
+
+func GetUserIdByStripeCustomer(customerId string) (int, error) {
+	if customerId == "" {
+		return 0, errors.New("customer 为空！")
+	}
+	user := User{}
+	err := DB.Select("id").First(&user, "stripe_customer = ?", customerId).Error
+	return user.Id, err
+}
+
+func activeSubscriptionStatuses() []string {
+	return []string{SubscriptionStatusActive, SubscriptionStatusTrialing, SubscriptionStatusPastDue}
+}
+
+// setUserSubscriptionPlan 更新用户分组与限流档位
+func setUserSubscriptionPlan(tx *gorm.DB, userId int, group string, rateLimitTier string) error {
+	user := User{}
+	err := tx.Select("id", "setting").First(&user, "id = ?", userId).Error
+	if err != nil {
+		return err
+	}
+	setting := user.GetSetting()
+	setting.RateLimitTier = rateLimitTier
+	user.SetSetting(setting)
+	updates := map[string]interface{}{"setting": user.Setting}
+	if group != "" {
+		updates["group"] = group
+	}
+	return tx.Model(&User{}).Where("id = ?", userId).Updates(updates).Error
+}
+
+// SyncUserSubscription 根据 Stripe 的订阅状态创建或更新本地订阅，并同步用户分组与限流档位
+// 订阅生效时切换到套餐分组，结束时恢复订阅前的分组
+func SyncUserSubscription(sub *UserSubscription, plan *SubscriptionPlan) error {
+	now := common.GetTimestamp()
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		existing := UserSubscription{}
+		err := tx.Set("gorm:query_option", "FOR UPDATE").
+			Where("stripe_subscription_id = ?", sub.StripeSubscriptionId).Limit(1).Find(&existing).Error
+		if err != nil {
+			return err
+		}
+		if existing.Id == 0 {
+			var group string
+			err = tx.Model(&User{}).Where("id = ?", sub.UserId).Select(commonGroupCol).Find(&group).Error
+			if err != nil {
+				return err
+			}
+			sub.PreviousGroup = group
+			// 用户已有生效中的订阅（例如更换套餐）时，沿用其记录的原始分组
+			previous := UserSubscription{}
+			err = tx.Where("user_id = ? and stripe_subscription_id <> ?", sub.UserId, sub.StripeSubscriptionId).
+				Order("id desc").Limit(1).Find(&previous).Error
+			if err != nil {
+				return err
+			}
+			if previous.Id != 0 && previous.IsActive() {
+				sub.PreviousGroup = previous.PreviousGroup
+			}
+			sub.CreatedTime = now
+		} else {
+			sub.Id = existing.Id
+			sub.UserId = existing.UserId
+			sub.PreviousGroup = existing.PreviousGroup
+			sub.CreatedTime = existing.CreatedTime
+			// Stripe 的订阅取消后无法恢复，忽略乱序或重放的旧事件
+			if existing.Status == SubscriptionStatusCanceled || existing.Status == SubscriptionStatusIncompleteExpired {
+				sub.Status = existing.Status
+			}
+			if sub.StripeCustomerId == "" {
+				sub.StripeCustomerId = existing.StripeCustomerId
+			}
+			if sub.CurrentPeriodEnd == 0 {
+				sub.CurrentPeriodStart = existing.CurrentPeriodStart
+				sub.CurrentPeriodEnd = existing.CurrentPeriodEnd
+			}
+		}
+		sub.UpdatedTime = now
+		if err = tx.Save(sub).Error; err != nil {
+			return err
+		}
+		if sub.StripeCustomerId != "" {
+			err = tx.Model(&User{}).Where("id = ? and (stripe_customer = '' or stripe_customer is null)", sub.UserId).
+				Update("stripe_customer", sub.StripeCustomerId).Error
+			if err != nil {
+				return err
+			}
+		}
+		if sub.IsActive() {
+			return setUserSubscriptionPlan(tx, sub.UserId, plan.Group, plan.RateLimitTier)
+		}
+		// 用户仍有其他生效中的订阅时不恢复分组
+		var others int64
+		err = tx.Model(&UserSubscription{}).Where("user_id = ? and id <> ? and status in ?",
+			sub.UserId, sub.Id, activeSubscriptionStatuses()).Count(&others).Error
+		if err != nil || others > 0 {
+			return err
+		}
+		return setUserSubscriptionPlan(tx, sub.UserId, sub.PreviousGroup, "")
+	})
+	if err != nil {
+		return err
+	}
+	return invalidateUserCache(sub.UserId)
+}
+
+// GrantSubscriptionQuota 为已支付的订阅账单发放额度，重复的账单会被忽略
+func GrantSubscriptionQuota(invoice *SubscriptionInvoice) (granted bool, err error) {
+	invoice.CreatedTime = common.GetTimestamp()
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(invoice)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		granted = true
+		if invoice.Quota <= 0 {
+			return nil
+		}
+		return tx.Model(&User{}).Where("id = ?", invoice.UserId).Update("quota", gorm.Expr("quota + ?", invoice.Quota)).Error
+	})
+	if err != nil || !granted {
+		return false, err
+	}
+	if err = invalidateUserCache(invoice.UserId); err != nil {
+		common.SysError("failed to invalidate user cache: " + err.Error())
+	}
+	RecordLog(invoice.UserId, LogTypeTopup, fmt.Sprintf("订阅套餐续费成功，发放额度: %v，支付金额：%.2f %s",
+		logger.FormatQuota(invoice.Quota), float64(invoice.AmountPaid)/100, invoice.Currency))
+	return true, nil
+}
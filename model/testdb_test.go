@@ -0,0 +1,42 @@
+package model
+
+import (
+	"one-api/common"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// setupTestDB 使用内存 SQLite 作为主数据库与日志数据库，测试结束后恢复
+func setupTestDB(t *testing.T, models ...interface{}) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: gormlogger.Default.LogMode(gormlogger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 内存数据库每个连接相互独立，只保留一个连接
+	sqlDB.SetMaxOpenConns(1)
+	if err = db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	oldDB, oldLogDB := DB, LOG_DB
+	oldSQLite, oldMySQL, oldPostgreSQL := common.UsingSQLite, common.UsingMySQL, common.UsingPostgreSQL
+	oldRedis, oldBatch := common.RedisEnabled, common.BatchUpdateEnabled
+	DB, LOG_DB = db, db
+	common.UsingSQLite, common.UsingMySQL, common.UsingPostgreSQL = true, false, false
+	common.RedisEnabled, common.BatchUpdateEnabled = false, false
+	initCol()
+	t.Cleanup(func() {
+		_ = sqlDB.Close()
+		DB, LOG_DB = oldDB, oldLogDB
+		common.UsingSQLite, common.UsingMySQL, common.UsingPostgreSQL = oldSQLite, oldMySQL, oldPostgreSQL
+		common.RedisEnabled, common.BatchUpdateEnabled = oldRedis, oldBatch
+		initCol()
+	})
+}
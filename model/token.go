@@ -26,6 +26,8 @@ type Token struct {
 	AllowIps           *string        `json:"allow_ips" gorm:"default:''"`
 	UsedQuota          int            `json:"used_quota" gorm:"default:0"` // used quota
 	Group              string         `json:"group" gorm:"default:''"`
+	OrgId              int            `json:"org_id" gorm:"default:0;index"`                     // 所属组织，0 表示个人令牌
+	ContextPolicy      string         `json:"context_policy" gorm:"type:varchar(32);default:''"` // 超出上下文窗口时的处理策略，空表示沿用分组配置
 	DeletedAt          gorm.DeletedAt `gorm:"index"`
 }
 
@@ -184,7 +186,7 @@ func (token *Token) Update() (err error) {
 		}
 	}()
 	err = DB.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota",
-		"model_limits_enabled", "model_limits", "allow_ips", "group").Updates(token).Error
+		"model_limits_enabled", "model_limits", "allow_ips", "group", "context_policy").Updates(token).Error
 	return err
 }
 
@@ -361,3 +363,29 @@ func BatchDeleteTokens(ids []int, userId int) (int, error) {
 
 	return len(tokens), nil
 }
+
+// DisableUserTokens 禁用指定用户的全部可用令牌并立即清除令牌缓存，返回禁用数量
+func DisableUserTokens(userId int) (int, error) {
+	var tokens []Token
+	if err := DB.Where("user_id = ? AND status = ?", userId, common.TokenStatusEnabled).Find(&tokens).Error; err != nil {
+		return 0, err
+	}
+	if len(tokens) == 0 {
+		return 0, nil
+	}
+	ids := make([]int, 0, len(tokens))
+	for _, t := range tokens {
+		ids = append(ids, t.Id)
+	}
+	if err := DB.Model(&Token{}).Where("id IN ?", ids).Update("status", common.TokenStatusDisabled).Error; err != nil {
+		return 0, err
+	}
+	if common.RedisEnabled {
+		for _, t := range tokens {
+			if err := cacheDeleteToken(t.Key); err != nil {
+				common.SysError(fmt.Sprintf("failed to delete token cache %d: %s", t.Id, err.Error()))
+			}
+		}
+	}
+	return len(tokens), nil
+}
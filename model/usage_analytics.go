@@ -0,0 +1,334 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"one-api/setting/operation_setting"
+
+	"gorm.io/gorm"
+)
+
+const (
+	UsageBucketNone  = ""
+	UsageBucketHour  = "hour"
+	UsageBucketDay   = "day"
+	UsageBucketMonth = "month"
+)
+
+// UsageAnalyticsQuery 用量分析查询条件，时间范围为 [StartTimestamp, EndTimestamp)
+type UsageAnalyticsQuery struct {
+	StartTimestamp int64
+	EndTimestamp   int64
+	GroupBy        []string
+	Bucket         string
+	Location       *time.Location
+	UserId         int
+	TokenId        int
+	ChannelId      int
+	ModelName      string
+	Group          string
+}
+
+type UsageAnalyticsRow struct {
+	Bucket           int64  `json:"bucket"`
+	UserId           int    `json:"user_id,omitempty"`
+	Username         string `json:"username,omitempty"`
+	TokenId          int    `json:"token_id,omitempty"`
+	TokenName        string `json:"token_name,omitempty"`
+	ModelName        string `json:"model_name,omitempty"`
+	ChannelId        int    `json:"channel_id,omitempty"`
+	Group            string `json:"group,omitempty"`
+	Ip               string `json:"ip,omitempty"`
+	RequestCount     int64  `json:"request_count"`
+	Quota            int64  `json:"quota"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	UseTime          int64  `json:"use_time"`
+}
+
+// usageAnalyticsDimensions 可分组的维度及其对应的列
+var usageAnalyticsDimensions = map[string][]string{
+	"user":    {"user_id", "username"},
+	"token":   {"token_id", "token_name"},
+	"model":   {"model_name"},
+	"channel": {"channel_id"},
+	"group":   {"group"},
+	"ip":      {"ip"},
+}
+
+// UsageAnalyticsDimensions 返回支持的分组维度
+func UsageAnalyticsDimensions() []string {
+	return []string{"user", "token", "model", "channel", "group", "ip"}
+}
+
+func (q *UsageAnalyticsQuery) Validate() error {
+	if q.StartTimestamp <= 0 || q.EndTimestamp <= q.StartTimestamp {
+		return errors.New("时间范围无效")
+	}
+	maxDays := operation_setting.GetAnalyticsSetting().MaxRangeDays
+	if maxDays > 0 && q.EndTimestamp-q.StartTimestamp > int64(maxDays)*86400 {
+		return fmt.Errorf("时间跨度不能超过 %d 天", maxDays)
+	}
+	switch q.Bucket {
+	case UsageBucketNone, UsageBucketHour, UsageBucketDay, UsageBucketMonth:
+	default:
+		return errors.New("不支持的时间粒度: " + q.Bucket)
+	}
+	seen := make(map[string]bool)
+	for _, dim := range q.GroupBy {
+		if _, ok := usageAnalyticsDimensions[dim]; !ok {
+			return errors.New("不支持的分组维度: " + dim)
+		}
+		if seen[dim] {
+			return errors.New("重复的分组维度: " + dim)
+		}
+		seen[dim] = true
+	}
+	if q.Location == nil {
+		q.Location = time.Local
+	}
+	return nil
+}
+
+func (q *UsageAnalyticsQuery) hasDimension(dim string) bool {
+	for _, d := range q.GroupBy {
+		if d == dim {
+			return true
+		}
+	}
+	return false
+}
+
+// truncate 将时间戳对齐到所在桶的起点
+func (q *UsageAnalyticsQuery) truncate(ts int64) int64 {
+	t := time.Unix(ts, 0).In(q.Location)
+	switch q.Bucket {
+	case UsageBucketHour:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, q.Location).Unix()
+	case UsageBucketDay:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, q.Location).Unix()
+	case UsageBucketMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, q.Location).Unix()
+	}
+	return 0
+}
+
+// canUseRollup 判断是否可以使用小时级预聚合数据
+func (q *UsageAnalyticsQuery) canUseRollup() bool {
+	if !operation_setting.GetAnalyticsSetting().RollupEnabled || q.hasDimension("ip") {
+		return false
+	}
+	// 非整小时时区无法由 UTC 小时桶拼出本地的小时与自然日
+	return q.Bucket == UsageBucketNone || q.wholeHourOffset()
+}
+
+// wholeHourOffset 判断查询时区相对 UTC 的偏移是否为整小时
+func (q *UsageAnalyticsQuery) wholeHourOffset() bool {
+	_, offset := time.Unix(q.StartTimestamp, 0).In(q.Location).Zone()
+	return offset%3600 == 0
+}
+
+// logBucketSeconds 直接查询 logs 时的预分组粒度，需保证每个分组不会跨越本地整点边界
+// 非整小时时区（如 +5:30、+5:45）按 15 分钟分组
+func (q *UsageAnalyticsQuery) logBucketSeconds() int64 {
+	if q.Bucket != UsageBucketNone && !q.wholeHourOffset() {
+		return 900
+	}
+	return 3600
+}
+
+func (q *UsageAnalyticsQuery) columns() []string {
+	var cols []string
+	for _, dim := range q.GroupBy {
+		for _, col := range usageAnalyticsDimensions[dim] {
+			if col == "group" {
+				col = logGroupCol
+			}
+			cols = append(cols, col)
+		}
+	}
+	return cols
+}
+
+func (q *UsageAnalyticsQuery) applyFilters(tx *gorm.DB) *gorm.DB {
+	if q.UserId != 0 {
+		tx = tx.Where("user_id = ?", q.UserId)
+	}
+	if q.TokenId != 0 {
+		tx = tx.Where("token_id = ?", q.TokenId)
+	}
+	if q.ChannelId != 0 {
+		tx = tx.Where("channel_id = ?", q.ChannelId)
+	}
+	if q.ModelName != "" {
+		tx = tx.Where("model_name = ?", q.ModelName)
+	}
+	if q.Group != "" {
+		tx = tx.Where(logGroupCol+" = ?", q.Group)
+	}
+	return tx
+}
+
+func (q *UsageAnalyticsQuery) queryLogs(start int64, end int64) ([]*UsageAnalyticsRow, error) {
+	cols := q.columns()
+	selects := append([]string{}, cols...)
+	groups := append([]string{}, cols...)
+	if q.Bucket != UsageBucketNone {
+		bucketExpr := fmt.Sprintf("(created_at - created_at %% %d)", q.logBucketSeconds())
+		selects = append(selects, bucketExpr+" as bucket")
+		groups = append(groups, bucketExpr)
+	}
+	selects = append(selects, "count(*) as request_count", "coalesce(sum(quota),0) as quota",
+		"coalesce(sum(prompt_tokens),0) as prompt_tokens", "coalesce(sum(completion_tokens),0) as completion_tokens",
+		"coalesce(sum(use_time),0) as use_time")
+	tx := LOG_DB.Table("logs").Select(strings.Join(selects, ", ")).
+		Where("type = ? and created_at >= ? and created_at < ?", LogTypeConsume, start, end)
+	tx = q.applyFilters(tx)
+	if len(groups) > 0 {
+		tx = tx.Group(strings.Join(groups, ", "))
+	}
+	var rows []*UsageAnalyticsRow
+	err := tx.Scan(&rows).Error
+	return rows, err
+}
+
+func (q *UsageAnalyticsQuery) queryRollup(start int64, end int64) ([]*UsageAnalyticsRow, error) {
+	cols := q.columns()
+	selects := append([]string{}, cols...)
+	groups := append([]string{}, cols...)
+	if q.Bucket != UsageBucketNone {
+		selects = append(selects, "bucket_start as bucket")
+		groups = append(groups, "bucket_start")
+	}
+	selects = append(selects, "coalesce(sum(request_count),0) as request_count", "coalesce(sum(quota),0) as quota",
+		"coalesce(sum(prompt_tokens),0) as prompt_tokens", "coalesce(sum(completion_tokens),0) as completion_tokens",
+		"coalesce(sum(use_time),0) as use_time")
+	tx := LOG_DB.Model(&UsageRollup{}).Select(strings.Join(selects, ", ")).
+		Where("bucket_start >= ? and bucket_start < ?", start, end)
+	tx = q.applyFilters(tx)
+	if len(groups) > 0 {
+		tx = tx.Group(strings.Join(groups, ", "))
+	}
+	var rows []*UsageAnalyticsRow
+	err := tx.Scan(&rows).Error
+	return rows, err
+}
+
+// QueryUsageAnalytics 按维度和时间粒度聚合消费日志
+// 已完成预聚合的整小时区间读取 usage_rollups，其余部分读取 logs
+func QueryUsageAnalytics(q *UsageAnalyticsQuery) ([]*UsageAnalyticsRow, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	type segment struct {
+		start, end int64
+		rollup     bool
+	}
+	var segments []segment
+	rollupStart, rollupEnd := int64(0), int64(0)
+	if q.canUseRollup() {
+		rollupStart = (q.StartTimestamp + 3599) / 3600 * 3600
+		rollupEnd = q.EndTimestamp - q.EndTimestamp%3600
+		if watermark := GetUsageRollupWatermark(); watermark < rollupEnd {
+			rollupEnd = watermark
+		}
+	}
+	if rollupEnd > rollupStart {
+		if q.StartTimestamp < rollupStart {
+			segments = append(segments, segment{q.StartTimestamp, rollupStart, false})
+		}
+		segments = append(segments, segment{rollupStart, rollupEnd, true})
+		if rollupEnd < q.EndTimestamp {
+			segments = append(segments, segment{rollupEnd, q.EndTimestamp, false})
+		}
+	} else {
+		segments = append(segments, segment{q.StartTimestamp, q.EndTimestamp, false})
+	}
+
+	merged := make(map[string]*UsageAnalyticsRow)
+	var keys []string
+	for _, seg := range segments {
+		var rows []*UsageAnalyticsRow
+		var err error
+		if seg.rollup {
+			rows, err = q.queryRollup(seg.start, seg.end)
+		} else {
+			rows, err = q.queryLogs(seg.start, seg.end)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			row.Bucket = q.truncate(row.Bucket)
+			key := fmt.Sprintf("%d|%d|%s|%d|%s|%s|%d|%s|%s", row.Bucket, row.UserId, row.Username, row.TokenId,
+				row.TokenName, row.ModelName, row.ChannelId, row.Group, row.Ip)
+			if existing, ok := merged[key]; ok {
+				existing.RequestCount += row.RequestCount
+				existing.Quota += row.Quota
+				existing.PromptTokens += row.PromptTokens
+				existing.CompletionTokens += row.CompletionTokens
+				existing.UseTime += row.UseTime
+				continue
+			}
+			merged[key] = row
+			keys = append(keys, key)
+		}
+	}
+	result := make([]*UsageAnalyticsRow, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, merged[key])
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Bucket != result[j].Bucket {
+			return result[i].Bucket < result[j].Bucket
+		}
+		return result[i].Quota > result[j].Quota
+	})
+	return result, nil
+}
+
+// StreamUsageAnalytics 将查询拆分为多个时间窗口依次执行，适用于大范围导出
+// 窗口边界与时间桶对齐，保证同一个桶的数据只会出现在一个窗口内
+func StreamUsageAnalytics(q *UsageAnalyticsQuery, fn func(rows []*UsageAnalyticsRow) error) error {
+	if err := q.Validate(); err != nil {
+		return err
+	}
+	if q.Bucket == UsageBucketNone {
+		rows, err := QueryUsageAnalytics(q)
+		if err != nil {
+			return err
+		}
+		return fn(rows)
+	}
+	for start := q.StartTimestamp; start < q.EndTimestamp; {
+		t := time.Unix(start, 0).In(q.Location)
+		var next time.Time
+		if q.Bucket == UsageBucketMonth {
+			next = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, q.Location)
+		} else {
+			next = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, q.Location)
+		}
+		end := next.Unix()
+		if end > q.EndTimestamp {
+			end = q.EndTimestamp
+		}
+		window := *q
+		window.StartTimestamp = start
+		window.EndTimestamp = end
+		rows, err := QueryUsageAnalytics(&window)
+		if err != nil {
+			return err
+		}
+		if len(rows) > 0 {
+			if err := fn(rows); err != nil {
+				return err
+			}
+		}
+		start = end
+	}
+	return nil
+}
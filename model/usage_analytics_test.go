@@ -0,0 +1,174 @@
+package model
+
+import (
+	"one-api/setting/operation_setting"
+	"testing"
+	"time"
+)
+
+// usageTestDay 2023-11-14 00:00:00 UTC
+const usageTestDay = int64(1699920000)
+
+func setupUsageAnalyticsTest(t *testing.T, rollupEnabled bool) {
+	t.Helper()
+	setupTestDB(t, &Log{}, &UsageRollup{}, &UsageRollupCursor{})
+	setting := operation_setting.GetAnalyticsSetting()
+	old := *setting
+	setting.RollupEnabled = rollupEnabled
+	t.Cleanup(func() { *setting = old })
+
+	logs := []*Log{
+		{CreatedAt: usageTestDay - 10, ModelName: "gpt-4o", Quota: 1000},
+		{CreatedAt: usageTestDay + 100, ModelName: "gpt-4o", Quota: 1},
+		{CreatedAt: usageTestDay + 3600 + 10, ModelName: "gpt-4o", Quota: 10},
+		{CreatedAt: usageTestDay + 2*3600 + 5, ModelName: "gpt-4o", Quota: 100},
+		{CreatedAt: usageTestDay + 2*3600 + 50, ModelName: "claude", Quota: 200},
+		{CreatedAt: usageTestDay + 3*3600 + 1, ModelName: "gpt-4o", Quota: 1000},
+		{CreatedAt: usageTestDay + 17*3600, ModelName: "gpt-4o", Quota: 10000},
+		{CreatedAt: usageTestDay + 18*3600 + 2400, ModelName: "gpt-4o", Quota: 100000},
+	}
+	for _, log := range logs {
+		log.Type = LogTypeConsume
+		log.UserId = 1
+		log.PromptTokens = 1
+		if err := LOG_DB.Create(log).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 与后台任务一样从第一条日志所在小时开始，到第 2 小时完成预聚合，之后写入的日志只能从 logs 表读到
+	for hour := int64(-1); hour < 3; hour++ {
+		if err := rollupUsageBucket(usageTestDay + hour*3600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if watermark := GetUsageRollupWatermark(); watermark != usageTestDay+3*3600 {
+		t.Fatalf("Unexpected rollup watermark %d", watermark)
+	}
+}
+
+func sumUsageQuota(rows []*UsageAnalyticsRow) (quota int64, requests int64) {
+	for _, row := range rows {
+		quota += row.Quota
+		requests += row.RequestCount
+	}
+	return quota, requests
+}
+
+func TestQueryUsageAnalyticsMergesRollupAndLogs(t *testing.T) {
+	setupUsageAnalyticsTest(t, true)
+	// 预聚合之后修改原始日志，用于确认整小时区间读取的是 usage_rollups
+	LOG_DB.Model(&Log{}).Where("created_at = ?", usageTestDay+3600+10).Update("quota", 99999)
+
+	q := &UsageAnalyticsQuery{
+		StartTimestamp: usageTestDay + 50,
+		EndTimestamp:   usageTestDay + 4*3600,
+		GroupBy:        []string{"model"},
+		Bucket:         UsageBucketHour,
+		Location:       time.UTC,
+	}
+	rows, err := QueryUsageAnalytics(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[int64]map[string]int64{
+		usageTestDay:          {"gpt-4o": 1},
+		usageTestDay + 3600:   {"gpt-4o": 10},
+		usageTestDay + 2*3600: {"gpt-4o": 100, "claude": 200},
+		usageTestDay + 3*3600: {"gpt-4o": 1000},
+	}
+	count := 0
+	for _, row := range rows {
+		if expected[row.Bucket][row.ModelName] != row.Quota {
+			t.Errorf("Unexpected row %+v", row)
+		}
+		count++
+	}
+	if count != 5 {
+		t.Errorf("Expected 5 rows, got %d", count)
+	}
+	if rows[0].Bucket != usageTestDay || rows[len(rows)-1].Bucket != usageTestDay+3*3600 {
+		t.Errorf("Expected rows sorted by bucket, got %+v", rows)
+	}
+
+	q.Bucket = UsageBucketNone
+	q.GroupBy = nil
+	rows, err = QueryUsageAnalytics(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quota, requests := sumUsageQuota(rows); len(rows) != 1 || quota != 1311 || requests != 5 {
+		t.Errorf("Expected merged total 1311 over 5 requests, got %d %d in %d rows", quota, requests, len(rows))
+	}
+}
+
+func TestQueryUsageAnalyticsDayBucketTimezone(t *testing.T) {
+	for _, rollupEnabled := range []bool{true, false} {
+		setupUsageAnalyticsTest(t, rollupEnabled)
+		q := &UsageAnalyticsQuery{
+			StartTimestamp: usageTestDay - 3600,
+			EndTimestamp:   usageTestDay + 24*3600,
+			Bucket:         UsageBucketDay,
+			Location:       time.FixedZone("UTC+8", 8*3600),
+		}
+		rows, err := QueryUsageAnalytics(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// UTC+8 的自然日在 UTC 16:00 切换，17:00 的日志属于下一天
+		firstDay := usageTestDay - 8*3600
+		if len(rows) != 2 || rows[0].Bucket != firstDay || rows[0].Quota != 2311 ||
+			rows[1].Bucket != firstDay+86400 || rows[1].Quota != 110000 {
+			t.Errorf("Unexpected UTC+8 day buckets (rollup %v): %+v", rollupEnabled, rows)
+		}
+
+		// 非整小时时区不能由小时桶拼出自然日，直接读取 logs
+		// UTC+5:30 的自然日在 UTC 18:30 切换，18:40 的日志不能随 18:00 的小时桶归入前一天
+		q.Location = time.FixedZone("UTC+5:30", 5*3600+1800)
+		if q.canUseRollup() {
+			t.Error("Expected half-hour timezone not to use rollup")
+		}
+		rows, err = QueryUsageAnalytics(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		firstDay = usageTestDay - 5*3600 - 1800
+		if len(rows) != 2 || rows[0].Bucket != firstDay || rows[0].Quota != 12311 ||
+			rows[1].Bucket != firstDay+86400 || rows[1].Quota != 100000 {
+			t.Errorf("Unexpected UTC+5:30 day buckets (rollup %v): %+v", rollupEnabled, rows)
+		}
+	}
+}
+
+func TestQueryUsageAnalyticsHourBucketTimezone(t *testing.T) {
+	for _, rollupEnabled := range []bool{true, false} {
+		setupUsageAnalyticsTest(t, rollupEnabled)
+		// UTC+5:30 的整点对应 UTC 的半点，小时桶从 UTC xx:30 开始
+		q := &UsageAnalyticsQuery{
+			StartTimestamp: usageTestDay + 50,
+			EndTimestamp:   usageTestDay + 4*3600,
+			Bucket:         UsageBucketHour,
+			Location:       time.FixedZone("UTC+5:30", 5*3600+1800),
+		}
+		if rollupEnabled && q.canUseRollup() {
+			t.Error("Expected half-hour timezone not to use rollup for hour buckets")
+		}
+		rows, err := QueryUsageAnalytics(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := map[int64]int64{
+			usageTestDay - 1800:   1,
+			usageTestDay + 1800:   10,
+			usageTestDay + 3*1800: 300,
+			usageTestDay + 5*1800: 1000,
+		}
+		if len(rows) != len(expected) {
+			t.Fatalf("Unexpected UTC+5:30 hour buckets (rollup %v): %+v", rollupEnabled, rows)
+		}
+		for _, row := range rows {
+			if expected[row.Bucket] != row.Quota {
+				t.Errorf("Unexpected UTC+5:30 hour bucket (rollup %v): %+v", rollupEnabled, row)
+			}
+		}
+	}
+}
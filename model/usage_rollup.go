@@ -0,0 +1,131 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"one-api/common"
+	"one-api/setting/operation_setting"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UsageRollup 消费日志按小时预聚合的结果，与 logs 表存放在同一个数据库
+// IP 维度基数过高，不参与预聚合，按 IP 分组时直接查询 logs 表
+type UsageRollup struct {
+	Id               int    `json:"id"`
+	BucketStart      int64  `json:"bucket_start" gorm:"bigint;index:idx_ur_bucket_user,priority:1"`
+	UserId           int    `json:"user_id" gorm:"index:idx_ur_bucket_user,priority:2"`
+	Username         string `json:"username" gorm:"default:''"`
+	TokenId          int    `json:"token_id" gorm:"default:0"`
+	TokenName        string `json:"token_name" gorm:"default:''"`
+	ModelName        string `json:"model_name" gorm:"default:''"`
+	ChannelId        int    `json:"channel_id" gorm:"default:0"`
+	Group            string `json:"group" gorm:"default:''"`
+	RequestCount     int64  `json:"request_count"`
+	Quota            int64  `json:"quota"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	UseTime          int64  `json:"use_time"`
+}
+
+// UsageRollupCursor 记录预聚合已经完成到的时间点（不含）
+type UsageRollupCursor struct {
+	Id        int   `json:"id"`
+	Watermark int64 `json:"watermark" gorm:"bigint"`
+}
+
+const (
+	usageRollupBucketSeconds = 3600
+	// 每轮最多处理的小时数，避免首次回填时长时间占用数据库
+	usageRollupMaxHoursPerRound = 24 * 7
+	// 日志写入存在延迟，小时结束后等待一段时间再聚合
+	usageRollupDelaySeconds = 300
+)
+
+// GetUsageRollupWatermark 获取预聚合的进度，0 表示尚未开始
+func GetUsageRollupWatermark() int64 {
+	var cursor UsageRollupCursor
+	if err := LOG_DB.Where("id = ?", 1).Limit(1).Find(&cursor).Error; err != nil {
+		return 0
+	}
+	return cursor.Watermark
+}
+
+func setUsageRollupWatermark(tx *gorm.DB, watermark int64) error {
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"watermark"}),
+	}).Create(&UsageRollupCursor{Id: 1, Watermark: watermark}).Error
+}
+
+// UpdateUsageRollup 后台维护 usage_rollups 预聚合表
+func UpdateUsageRollup() {
+	if !common.IsMasterNode {
+		return
+	}
+	for {
+		if operation_setting.GetAnalyticsSetting().RollupEnabled {
+			if err := RollupUsage(); err != nil {
+				common.SysError("failed to rollup usage: " + err.Error())
+			}
+		}
+		time.Sleep(time.Minute)
+	}
+}
+
+// RollupUsage 将已经结束的小时内的消费日志聚合进 usage_rollups
+func RollupUsage() error {
+	watermark := GetUsageRollupWatermark()
+	if watermark == 0 {
+		var first Log
+		err := LOG_DB.Where("type = ?", LogTypeConsume).Order("created_at asc").Select("created_at").First(&first).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		watermark = first.CreatedAt - first.CreatedAt%usageRollupBucketSeconds
+	}
+	limit := time.Now().Unix() - usageRollupDelaySeconds
+	limit -= limit % usageRollupBucketSeconds
+	processed := 0
+	for ; watermark+usageRollupBucketSeconds <= limit && processed < usageRollupMaxHoursPerRound; processed++ {
+		if err := rollupUsageBucket(watermark); err != nil {
+			return fmt.Errorf("bucket %d: %w", watermark, err)
+		}
+		watermark += usageRollupBucketSeconds
+	}
+	return nil
+}
+
+func rollupUsageBucket(bucketStart int64) error {
+	var rows []*UsageRollup
+	err := LOG_DB.Table("logs").
+		Select("user_id, username, token_id, token_name, model_name, channel_id, "+logGroupCol+" as "+logGroupCol+", "+
+			"count(*) as request_count, coalesce(sum(quota),0) as quota, coalesce(sum(prompt_tokens),0) as prompt_tokens, "+
+			"coalesce(sum(completion_tokens),0) as completion_tokens, coalesce(sum(use_time),0) as use_time").
+		Where("type = ? and created_at >= ? and created_at < ?", LogTypeConsume, bucketStart, bucketStart+usageRollupBucketSeconds).
+		Group("user_id, username, token_id, token_name, model_name, channel_id, " + logGroupCol).
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		row.BucketStart = bucketStart
+	}
+	return LOG_DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("bucket_start = ?", bucketStart).Delete(&UsageRollup{}).Error; err != nil {
+			return err
+		}
+		if len(rows) > 0 {
+			if err := tx.CreateInBatches(rows, 200).Error; err != nil {
+				return err
+			}
+		}
+		return setUsageRollupWatermark(tx, bucketStart+usageRollupBucketSeconds)
+	})
+}
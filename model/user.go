@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"one-api/common"
+	"one-api/common/envelope"
 	"one-api/dto"
 	"one-api/logger"
 	"strconv"
@@ -17,34 +18,39 @@ import (
 // User if you add sensitive fields, don't forget to clean them in setupLogin function.
 // Otherwise, the sensitive information will be saved on local storage in plain text!
 type User struct {
-	Id               int            `json:"id"`
-	Username         string         `json:"username" gorm:"unique;index" validate:"max=12"`
-	Password         string         `json:"password" gorm:"not null;" validate:"min=8,max=20"`
-	OriginalPassword string         `json:"original_password" gorm:"-:all"` // this field is only for Password change verification, don't save it to database!
-	DisplayName      string         `json:"display_name" gorm:"index" validate:"max=20"`
-	Role             int            `json:"role" gorm:"type:int;default:1"`   // admin, common
-	Status           int            `json:"status" gorm:"type:int;default:1"` // enabled, disabled
-	Email            string         `json:"email" gorm:"index" validate:"max=50"`
-	GitHubId         string         `json:"github_id" gorm:"column:github_id;index"`
-	OidcId           string         `json:"oidc_id" gorm:"column:oidc_id;index"`
-	WeChatId         string         `json:"wechat_id" gorm:"column:wechat_id;index"`
-	TelegramId       string         `json:"telegram_id" gorm:"column:telegram_id;index"`
-	VerificationCode string         `json:"verification_code" gorm:"-:all"`                                    // this field is only for Email verification, don't save it to database!
-	AccessToken      *string        `json:"access_token" gorm:"type:char(32);column:access_token;uniqueIndex"` // this token is for system management
-	Quota            int            `json:"quota" gorm:"type:int;default:0"`
-	UsedQuota        int            `json:"used_quota" gorm:"type:int;default:0;column:used_quota"` // used quota
-	RequestCount     int            `json:"request_count" gorm:"type:int;default:0;"`               // request number
-	Group            string         `json:"group" gorm:"type:varchar(64);default:'default'"`
-	AffCode          string         `json:"aff_code" gorm:"type:varchar(32);column:aff_code;uniqueIndex"`
-	AffCount         int            `json:"aff_count" gorm:"type:int;default:0;column:aff_count"`
-	AffQuota         int            `json:"aff_quota" gorm:"type:int;default:0;column:aff_quota"`           // 邀请剩余额度
-	AffHistoryQuota  int            `json:"aff_history_quota" gorm:"type:int;default:0;column:aff_history"` // 邀请历史额度
-	InviterId        int            `json:"inviter_id" gorm:"type:int;column:inviter_id;index"`
-	DeletedAt        gorm.DeletedAt `gorm:"index"`
-	LinuxDOId        string         `json:"linux_do_id" gorm:"column:linux_do_id;index"`
-	Setting          string         `json:"setting" gorm:"type:text;column:setting"`
-	Remark           string         `json:"remark,omitempty" gorm:"type:varchar(255)" validate:"max=255"`
-	StripeCustomer   string         `json:"stripe_customer" gorm:"type:varchar(64);column:stripe_customer;index"`
+	Id                int            `json:"id"`
+	Username          string         `json:"username" gorm:"unique;index" validate:"max=12"`
+	Password          string         `json:"password" gorm:"not null;" validate:"min=8,max=20"`
+	OriginalPassword  string         `json:"original_password" gorm:"-:all"` // this field is only for Password change verification, don't save it to database!
+	DisplayName       string         `json:"display_name" gorm:"index" validate:"max=20"`
+	Role              int            `json:"role" gorm:"type:int;default:1"`   // admin, common
+	Status            int            `json:"status" gorm:"type:int;default:1"` // enabled, disabled
+	Email             string         `json:"email" gorm:"index" validate:"max=50"`
+	GitHubId          string         `json:"github_id" gorm:"column:github_id;index"`
+	OidcId            string         `json:"oidc_id" gorm:"column:oidc_id;index"`
+	ExternalId        string         `json:"external_id" gorm:"type:varchar(255);column:external_id;index"` // SCIM 身份提供方中的用户标识
+	WeChatId          string         `json:"wechat_id" gorm:"column:wechat_id;index"`
+	TelegramId        string         `json:"telegram_id" gorm:"column:telegram_id;index"`
+	VerificationCode  string         `json:"verification_code" gorm:"-:all"`                                    // this field is only for Email verification, don't save it to database!
+	AccessToken       *string        `json:"access_token" gorm:"type:char(32);column:access_token;uniqueIndex"` // this token is for system management
+	Quota             int            `json:"quota" gorm:"type:int;default:0"`
+	UsedQuota         int            `json:"used_quota" gorm:"type:int;default:0;column:used_quota"` // used quota
+	RequestCount      int            `json:"request_count" gorm:"type:int;default:0;"`               // request number
+	Group             string         `json:"group" gorm:"type:varchar(64);default:'default'"`
+	AffCode           string         `json:"aff_code" gorm:"type:varchar(32);column:aff_code;uniqueIndex"`
+	AffCount          int            `json:"aff_count" gorm:"type:int;default:0;column:aff_count"`
+	AffQuota          int            `json:"aff_quota" gorm:"type:int;default:0;column:aff_quota"`           // 邀请剩余额度
+	AffHistoryQuota   int            `json:"aff_history_quota" gorm:"type:int;default:0;column:aff_history"` // 邀请历史额度
+	InviterId         int            `json:"inviter_id" gorm:"type:int;column:inviter_id;index"`
+	DeletedAt         gorm.DeletedAt `gorm:"index"`
+	LinuxDOId         string         `json:"linux_do_id" gorm:"column:linux_do_id;index"`
+	Setting           string         `json:"setting" gorm:"type:text;column:setting"`
+	Remark            string         `json:"remark,omitempty" gorm:"type:varchar(255)" validate:"max=255"`
+	StripeCustomer    string         `json:"stripe_customer" gorm:"type:varchar(64);column:stripe_customer;index"`
+	RoleId            int            `json:"role_id" gorm:"type:int;default:0;index"`         // 自定义角色，为 0 时使用内置角色的默认权限
+	AccessTokenRoleId int            `json:"access_token_role_id" gorm:"type:int;default:0"`  // 系统访问令牌绑定的角色，用于收窄令牌权限
+	BillingMode       string         `json:"billing_mode" gorm:"type:varchar(16);default:''"` // 计费方式，为空时跟随分组配置
+	CreditLimit       int            `json:"credit_limit" gorm:"type:int;default:0"`          // 后付费信用额度，为 0 时使用分组的默认值
 }
 
 func (user *User) ToBaseUser() *UserBase {
@@ -56,6 +62,12 @@ func (user *User) ToBaseUser() *UserBase {
 		Username: user.Username,
 		Setting:  user.Setting,
 		Email:    user.Email,
+
+		BillingMode: user.BillingMode,
+		CreditLimit: user.CreditLimit,
+
+		RoleId:            user.RoleId,
+		AccessTokenRoleId: user.AccessTokenRoleId,
 	}
 	return cache
 }
@@ -78,11 +90,18 @@ func (user *User) GetSetting() dto.UserSetting {
 		if err != nil {
 			common.SysLog("failed to unmarshal setting: " + err.Error())
 		}
+		setting.WebhookSecret = decryptWebhookSecret(setting.WebhookSecret)
 	}
 	return setting
 }
 
 func (user *User) SetSetting(setting dto.UserSetting) {
+	webhookSecret, err := envelope.Encrypt(setting.WebhookSecret)
+	if err != nil {
+		common.SysError("failed to encrypt webhook secret: " + err.Error())
+		return
+	}
+	setting.WebhookSecret = webhookSecret
 	settingBytes, err := json.Marshal(setting)
 	if err != nil {
 		common.SysLog("failed to marshal setting: " + err.Error())
@@ -119,29 +138,10 @@ func generateDefaultSidebarConfigForRole(userRole int) string {
 		"personal": true,
 	}
 
-	// 管理员区域 - 根据角色决定
-	if userRole == common.RoleAdminUser {
-		// 管理员可以访问管理员区域，但不能访问系统设置
-		defaultConfig["admin"] = map[string]interface{}{
-			"enabled":    true,
-			"channel":    true,
-			"models":     true,
-			"redemption": true,
-			"user":       true,
-			"setting":    false, // 管理员不能访问系统设置
-		}
-	} else if userRole == common.RoleRootUser {
-		// 超级管理员可以访问所有功能
-		defaultConfig["admin"] = map[string]interface{}{
-			"enabled":    true,
-			"channel":    true,
-			"models":     true,
-			"redemption": true,
-			"user":       true,
-			"setting":    true,
-		}
+	// 管理员区域 - 根据内置角色的默认权限决定，普通用户不包含admin区域
+	if adminConfig := GenerateSidebarAdminConfig(DefaultRolePermissions(userRole)); adminConfig != nil {
+		defaultConfig["admin"] = adminConfig
 	}
-	// 普通用户不包含admin区域
 
 	// 转换为JSON字符串
 	configBytes, err := json.Marshal(defaultConfig)
@@ -307,6 +307,15 @@ func GetUserIdByAffCode(affCode string) (int, error) {
 	return user.Id, err
 }
 
+func GetUserIdByUsername(username string) (int, error) {
+	if username == "" {
+		return 0, errors.New("username 为空！")
+	}
+	var user User
+	err := DB.Select("id").First(&user, "username = ?", username).Error
+	return user.Id, err
+}
+
 func DeleteUserById(id int) (err error) {
 	if id == 0 {
 		return errors.New("id 为空！")
@@ -320,7 +329,10 @@ func HardDeleteUserById(id int) error {
 		return errors.New("id 为空！")
 	}
 	err := DB.Unscoped().Delete(&User{}, "id = ?", id).Error
-	return err
+	if err != nil {
+		return err
+	}
+	return DB.Where("user_id = ?", id).Delete(&UserIdentity{}).Error
 }
 
 func inviteUser(inviterId int) (err error) {
@@ -394,6 +406,7 @@ func (user *User) Insert(inviterId int) error {
 	if result.Error != nil {
 		return result.Error
 	}
+	syncLegacyIdentities(user)
 
 	// 用户创建成功后，根据角色初始化边栏配置
 	// 需要重新获取用户以确保有正确的ID和Role
@@ -440,6 +453,7 @@ func (user *User) Update(updatePassword bool) error {
 	if err = DB.Model(user).Updates(newUser).Error; err != nil {
 		return err
 	}
+	syncLegacyIdentities(user)
 
 	// Update cache
 	return updateUserCache(*user)
@@ -461,6 +475,8 @@ func (user *User) Edit(updatePassword bool) error {
 		"group":        newUser.Group,
 		"quota":        newUser.Quota,
 		"remark":       newUser.Remark,
+		"billing_mode": newUser.BillingMode,
+		"credit_limit": newUser.CreditLimit,
 	}
 	if updatePassword {
 		updates["password"] = newUser.Password
@@ -492,7 +508,10 @@ func (user *User) HardDelete() error {
 		return errors.New("id 为空！")
 	}
 	err := DB.Unscoped().Delete(user).Error
-	return err
+	if err != nil {
+		return err
+	}
+	return DB.Where("user_id = ?", user.Id).Delete(&UserIdentity{}).Error
 }
 
 // ValidateAndFill check password & user status
@@ -534,35 +553,32 @@ func (user *User) FillUserByGitHubId() error {
 	if user.GitHubId == "" {
 		return errors.New("GitHub id 为空！")
 	}
-	DB.Where(User{GitHubId: user.GitHubId}).First(user)
-	return nil
+	return user.FillUserByIdentity(IdentityProviderGitHub, user.GitHubId)
 }
 
 func (user *User) FillUserByOidcId() error {
 	if user.OidcId == "" {
 		return errors.New("oidc id 为空！")
 	}
-	DB.Where(User{OidcId: user.OidcId}).First(user)
-	return nil
+	return user.FillUserByIdentity(IdentityProviderOidc, user.OidcId)
 }
 
 func (user *User) FillUserByWeChatId() error {
 	if user.WeChatId == "" {
 		return errors.New("WeChat id 为空！")
 	}
-	DB.Where(User{WeChatId: user.WeChatId}).First(user)
-	return nil
+	return user.FillUserByIdentity(IdentityProviderWeChat, user.WeChatId)
 }
 
 func (user *User) FillUserByTelegramId() error {
 	if user.TelegramId == "" {
 		return errors.New("Telegram id 为空！")
 	}
-	err := DB.Where(User{TelegramId: user.TelegramId}).First(user).Error
-	if errors.Is(err, gorm.ErrRecordNotFound) {
+	err := user.FillUserByIdentity(IdentityProviderTelegram, user.TelegramId)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && user.Id == 0) {
 		return errors.New("该 Telegram 账户未绑定")
 	}
-	return nil
+	return err
 }
 
 func IsEmailAlreadyTaken(email string) bool {
@@ -570,19 +586,43 @@ func IsEmailAlreadyTaken(email string) bool {
 }
 
 func IsWeChatIdAlreadyTaken(wechatId string) bool {
-	return DB.Unscoped().Where("wechat_id = ?", wechatId).Find(&User{}).RowsAffected == 1
+	return IsIdentityTaken(IdentityProviderWeChat, wechatId)
 }
 
 func IsGitHubIdAlreadyTaken(githubId string) bool {
-	return DB.Unscoped().Where("github_id = ?", githubId).Find(&User{}).RowsAffected == 1
+	return IsIdentityTaken(IdentityProviderGitHub, githubId)
 }
 
 func IsOidcIdAlreadyTaken(oidcId string) bool {
-	return DB.Where("oidc_id = ?", oidcId).Find(&User{}).RowsAffected == 1
+	return IsIdentityTakenByActiveUser(IdentityProviderOidc, oidcId)
+}
+
+// UpdateUserRoleAndGroup 按目录组映射同步用户的角色与分组，role 为 0 或 group 为空时不修改对应字段
+func UpdateUserRoleAndGroup(user *User, role int, group string) error {
+	updates := make(map[string]interface{})
+	if role != 0 && role != user.Role {
+		updates["role"] = role
+	}
+	if group != "" && group != user.Group {
+		updates["group"] = group
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	if err := DB.Model(&User{}).Where("id = ?", user.Id).Updates(updates).Error; err != nil {
+		return err
+	}
+	if role != 0 {
+		user.Role = role
+	}
+	if group != "" {
+		user.Group = group
+	}
+	return invalidateUserCache(user.Id)
 }
 
 func IsTelegramIdAlreadyTaken(telegramId string) bool {
-	return DB.Unscoped().Where("telegram_id = ?", telegramId).Find(&User{}).RowsAffected == 1
+	return IsIdentityTaken(IdentityProviderTelegram, telegramId)
 }
 
 func ResetUserPasswordByEmail(email string, password string) error {
@@ -894,16 +934,17 @@ func GetUsernameById(id int, fromDB bool) (username string, err error) {
 }
 
 func IsLinuxDOIdAlreadyTaken(linuxDOId string) bool {
-	var user User
-	err := DB.Unscoped().Where("linux_do_id = ?", linuxDOId).First(&user).Error
-	return !errors.Is(err, gorm.ErrRecordNotFound)
+	return IsIdentityTaken(IdentityProviderLinuxDO, linuxDOId)
 }
 
 func (user *User) FillUserByLinuxDOId() error {
 	if user.LinuxDOId == "" {
 		return errors.New("linux do id is empty")
 	}
-	err := DB.Where("linux_do_id = ?", user.LinuxDOId).First(user).Error
+	err := user.FillUserByIdentity(IdentityProviderLinuxDO, user.LinuxDOId)
+	if err == nil && user.Id == 0 {
+		return gorm.ErrRecordNotFound
+	}
 	return err
 }
 
@@ -0,0 +1,87 @@
+package model
+
+import (
+	"one-api/common"
+	"one-api/setting/operation_setting"
+
+	"gorm.io/gorm"
+)
+
+// PostpaidAccount 数据看板中的后付费账户概况
+type PostpaidAccount struct {
+	Id          int    `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	Group       string `json:"group"`
+	Status      int    `json:"status"`
+	Quota       int    `json:"quota"`
+	UsedQuota   int    `json:"used_quota"`
+	BillingMode string `json:"billing_mode"`
+	CreditLimit int    `json:"credit_limit"` // 生效的信用额度
+	UsedCredit  int    `json:"used_credit"`  // 已透支的额度
+}
+
+// GetCreditLimit 返回用户生效的信用额度，预付费用户为 0
+func (user *UserBase) GetCreditLimit() int {
+	return operation_setting.ResolveCreditLimit(user.BillingMode, user.CreditLimit, user.Group)
+}
+
+// postpaidUserQuery 筛选单独设置为后付费或所在分组开启了后付费的用户，
+// 分组生效的信用额度可能为 0，调用方需要再用 GetCreditLimit 判断
+func postpaidUserQuery() *gorm.DB {
+	tx := DB.Model(&User{})
+	groups := operation_setting.PostpaidGroups()
+	if len(groups) == 0 {
+		return tx.Where("billing_mode = ?", operation_setting.BillingModePostpaid)
+	}
+	return tx.Where("(billing_mode = ? or (billing_mode = ? and "+commonGroupCol+" in ?))",
+		operation_setting.BillingModePostpaid, operation_setting.BillingModeDefault, groups)
+}
+
+// GetPostpaidAccounts 按透支额度从高到低分页返回后付费账户
+func GetPostpaidAccounts(startIdx int, num int) (accounts []*PostpaidAccount, total int64, err error) {
+	var users []*User
+	if err = postpaidUserQuery().Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = postpaidUserQuery().Order("quota asc, id asc").Limit(num).Offset(startIdx).Omit("password").Find(&users).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	accounts = make([]*PostpaidAccount, 0, len(users))
+	for _, user := range users {
+		creditLimit := user.ToBaseUser().GetCreditLimit()
+		accounts = append(accounts, &PostpaidAccount{
+			Id:          user.Id,
+			Username:    user.Username,
+			DisplayName: user.DisplayName,
+			Group:       user.Group,
+			Status:      user.Status,
+			Quota:       user.Quota,
+			UsedQuota:   user.UsedQuota,
+			BillingMode: user.BillingMode,
+			CreditLimit: creditLimit,
+			UsedCredit:  max(-user.Quota, 0),
+		})
+	}
+	return accounts, total, nil
+}
+
+// GetOverduePostpaidUserIds 返回当前余额为负的后付费用户，月度账单需要为其出账
+func GetOverduePostpaidUserIds() (userIds []int, err error) {
+	err = postpaidUserQuery().Where("quota < 0").Pluck("id", &userIds).Error
+	return userIds, err
+}
+
+// SuspendPostpaidUser 后付费用户透支达到信用额度时禁用账户，用户已被禁用时返回 false
+func SuspendPostpaidUser(userId int) (bool, error) {
+	result := DB.Model(&User{}).Where("id = ? and status = ?", userId, common.UserStatusEnabled).
+		Update("status", common.UserStatusDisabled)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	return true, invalidateUserCache(userId)
+}
@@ -21,6 +21,12 @@ type UserBase struct {
 	Status   int    `json:"status"`
 	Username string `json:"username"`
 	Setting  string `json:"setting"`
+
+	BillingMode string `json:"billing_mode"`
+	CreditLimit int    `json:"credit_limit"`
+
+	RoleId            int `json:"role_id"`
+	AccessTokenRoleId int `json:"access_token_role_id"`
 }
 
 func (user *UserBase) WriteContext(c *gin.Context) {
@@ -30,6 +36,7 @@ func (user *UserBase) WriteContext(c *gin.Context) {
 	common.SetContextKey(c, constant.ContextKeyUserEmail, user.Email)
 	common.SetContextKey(c, constant.ContextKeyUserName, user.Username)
 	common.SetContextKey(c, constant.ContextKeyUserSetting, user.GetSetting())
+	common.SetContextKey(c, constant.ContextKeyUserCreditLimit, user.GetCreditLimit())
 }
 
 func (user *UserBase) GetSetting() dto.UserSetting {
@@ -39,6 +46,7 @@ func (user *UserBase) GetSetting() dto.UserSetting {
 		if err != nil {
 			common.SysLog("failed to unmarshal setting: " + err.Error())
 		}
+		setting.WebhookSecret = decryptWebhookSecret(setting.WebhookSecret)
 	}
 	return setting
 }
@@ -98,15 +106,7 @@ func GetUserCache(userId int) (userCache *UserBase, err error) {
 	}
 
 	// Create cache object from user data
-	userCache = &UserBase{
-		Id:       user.Id,
-		Group:    user.Group,
-		Quota:    user.Quota,
-		Status:   user.Status,
-		Username: user.Username,
-		Setting:  user.Setting,
-		Email:    user.Email,
-	}
+	userCache = user.ToBaseUser()
 
 	return userCache, nil
 }
@@ -0,0 +1,229 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+
+	"gorm.io/gorm"
+)
+
+// UserIdentity 外部身份与本地用户的关联，同一提供方的同一外部账户只能绑定一个用户
+type UserIdentity struct {
+	Id            int    `json:"id"`
+	UserId        int    `json:"user_id" gorm:"index"`
+	Provider      string `json:"provider" gorm:"type:varchar(64);uniqueIndex:idx_identity_provider_external"`
+	ExternalId    string `json:"external_id" gorm:"type:varchar(255);uniqueIndex:idx_identity_provider_external"`
+	Username      string `json:"username" gorm:"type:varchar(255)"`
+	Email         string `json:"email" gorm:"type:varchar(255)"`
+	CreatedTime   int64  `json:"created_time" gorm:"bigint"`
+	LastLoginTime int64  `json:"last_login_time" gorm:"bigint"`
+}
+
+// 内置登录方式的提供方名称，除 LDAP 外同时记录在 users 表的对应列上以兼容旧版本
+const (
+	IdentityProviderGitHub   = "github"
+	IdentityProviderOidc     = "oidc"
+	IdentityProviderLinuxDO  = "linuxdo"
+	IdentityProviderWeChat   = "wechat"
+	IdentityProviderTelegram = "telegram"
+	IdentityProviderLdap     = "ldap"
+)
+
+// legacyIdentityColumns 内置提供方到 users 表旧列的映射
+var legacyIdentityColumns = map[string]string{
+	IdentityProviderGitHub:   "github_id",
+	IdentityProviderOidc:     "oidc_id",
+	IdentityProviderLinuxDO:  "linux_do_id",
+	IdentityProviderWeChat:   "wechat_id",
+	IdentityProviderTelegram: "telegram_id",
+}
+
+var ErrIdentityAlreadyBound = errors.New("该外部账户已被其他用户绑定")
+
+// IsBuiltinIdentityProvider 是否为内置登录方式，自定义提供方不能使用这些名称
+func IsBuiltinIdentityProvider(provider string) bool {
+	_, ok := legacyIdentityColumns[provider]
+	return ok || provider == IdentityProviderLdap
+}
+
+func legacyIdentities(user *User) map[string]string {
+	return map[string]string{
+		IdentityProviderGitHub:   user.GitHubId,
+		IdentityProviderOidc:     user.OidcId,
+		IdentityProviderLinuxDO:  user.LinuxDOId,
+		IdentityProviderWeChat:   user.WeChatId,
+		IdentityProviderTelegram: user.TelegramId,
+	}
+}
+
+func GetUserIdentity(provider string, externalId string) (*UserIdentity, error) {
+	if provider == "" || externalId == "" {
+		return nil, gorm.ErrRecordNotFound
+	}
+	var identity UserIdentity
+	result := DB.Where("provider = ? AND external_id = ?", provider, externalId).Limit(1).Find(&identity)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &identity, nil
+}
+
+func GetUserIdentities(userId int) ([]*UserIdentity, error) {
+	var identities []*UserIdentity
+	err := DB.Where("user_id = ?", userId).Order("id asc").Find(&identities).Error
+	return identities, err
+}
+
+// IsIdentityTaken 外部账户是否已绑定过用户，包括已注销的用户
+func IsIdentityTaken(provider string, externalId string) bool {
+	_, err := GetUserIdentity(provider, externalId)
+	return err == nil
+}
+
+// IsIdentityTakenByActiveUser 外部账户是否绑定了未注销的用户
+func IsIdentityTakenByActiveUser(provider string, externalId string) bool {
+	identity, err := GetUserIdentity(provider, externalId)
+	if err != nil {
+		return false
+	}
+	var count int64
+	DB.Model(&User{}).Where("id = ?", identity.UserId).Count(&count)
+	return count > 0
+}
+
+// FillUserByIdentity 按外部身份填充用户，用户已注销时 user.Id 为 0
+func (user *User) FillUserByIdentity(provider string, externalId string) error {
+	identity, err := GetUserIdentity(provider, externalId)
+	if err != nil {
+		return err
+	}
+	return DB.Where("id = ?", identity.UserId).Limit(1).Find(user).Error
+}
+
+// LinkUserIdentity 为用户绑定外部身份；外部账户原先绑定的用户已注销时转移给当前用户
+func LinkUserIdentity(userId int, provider string, externalId string, username string, email string) error {
+	if userId == 0 || provider == "" || externalId == "" {
+		return errors.New("无效的外部身份")
+	}
+	identity, err := GetUserIdentity(provider, externalId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		identity = &UserIdentity{
+			UserId:      userId,
+			Provider:    provider,
+			ExternalId:  externalId,
+			Username:    username,
+			Email:       email,
+			CreatedTime: common.GetTimestamp(),
+		}
+		return DB.Create(identity).Error
+	}
+	if err != nil {
+		return err
+	}
+	if identity.UserId != userId && IsIdentityTakenByActiveUser(provider, externalId) {
+		return ErrIdentityAlreadyBound
+	}
+	updates := map[string]interface{}{"user_id": userId}
+	if username != "" {
+		updates["username"] = username
+	}
+	if email != "" {
+		updates["email"] = email
+	}
+	return DB.Model(identity).Updates(updates).Error
+}
+
+// UnlinkUserIdentity 解除用户与外部身份的绑定，内置提供方同时清空 users 表上的旧列
+func UnlinkUserIdentity(userId int, provider string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("user_id = ? AND provider = ?", userId, provider).Delete(&UserIdentity{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if column, ok := legacyIdentityColumns[provider]; ok {
+			if err := tx.Model(&User{}).Where("id = ?", userId).Update(column, "").Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// TouchUserIdentity 记录外部身份的最近登录时间
+func TouchUserIdentity(provider string, externalId string) {
+	err := DB.Model(&UserIdentity{}).Where("provider = ? AND external_id = ?", provider, externalId).
+		Update("last_login_time", common.GetTimestamp()).Error
+	if err != nil {
+		common.SysError("failed to update identity login time: " + err.Error())
+	}
+}
+
+// syncLegacyIdentities 将 users 表旧列上的内置身份同步到 user_identities
+func syncLegacyIdentities(user *User) {
+	if user.Id == 0 {
+		return
+	}
+	for provider, externalId := range legacyIdentities(user) {
+		if externalId == "" {
+			continue
+		}
+		identity, err := GetUserIdentity(provider, externalId)
+		if err == nil && identity.UserId == user.Id {
+			continue
+		}
+		if err = LinkUserIdentity(user.Id, provider, externalId, "", ""); err != nil {
+			common.SysError(fmt.Sprintf("failed to sync %s identity for user %d: %s", provider, user.Id, err.Error()))
+			continue
+		}
+		// 内置提供方每个用户只保留一个身份，换绑后移除旧的记录
+		err = DB.Where("user_id = ? AND provider = ? AND external_id <> ?", user.Id, provider, externalId).Delete(&UserIdentity{}).Error
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to remove stale %s identity for user %d: %s", provider, user.Id, err.Error()))
+		}
+	}
+}
+
+// migrateLegacyIdentities 将旧版本记录在 users 表上的内置身份迁移到 user_identities，已迁移的记录会被跳过
+func migrateLegacyIdentities() error {
+	for provider, column := range legacyIdentityColumns {
+		var rows []struct {
+			Id         int
+			ExternalId string
+		}
+		err := DB.Unscoped().Model(&User{}).Select("id, "+column+" AS external_id").
+			Where(column+" IS NOT NULL AND "+column+" <> ''").
+			Where("NOT EXISTS (SELECT 1 FROM user_identities WHERE user_identities.provider = ? AND user_identities.external_id = users."+column+")", provider).
+			Order("id desc").Find(&rows).Error
+		if err != nil {
+			return err
+		}
+		migrated := 0
+		for _, row := range rows {
+			// 旧数据中同一外部账户可能对应多个用户，保留最新的一个
+			if IsIdentityTaken(provider, row.ExternalId) {
+				continue
+			}
+			identity := &UserIdentity{
+				UserId:      row.Id,
+				Provider:    provider,
+				ExternalId:  row.ExternalId,
+				CreatedTime: common.GetTimestamp(),
+			}
+			if err = DB.Create(identity).Error; err != nil {
+				return err
+			}
+			migrated++
+		}
+		if migrated > 0 {
+			common.SysLog(fmt.Sprintf("migrated %d %s identities", migrated, provider))
+		}
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package channel
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -44,18 +45,24 @@ func DoApiRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBody
 	if common2.DebugEnabled {
 		println("fullRequestURL:", fullRequestURL)
 	}
+	// 命中捕获规则或请求头模板引用了请求体时先读出请求体，以便记录或签名最终发往上游的内容
+	var captureBody []byte
+	shouldCapture := requestBody != nil && service.ShouldCaptureBody(c, info)
+	if shouldCapture || (requestBody != nil && common.HeaderOverrideUsesBody(info.HeadersOverride)) {
+		captureBody, err = io.ReadAll(requestBody)
+		if err != nil {
+			return nil, fmt.Errorf("read request body failed: %w", err)
+		}
+		requestBody = bytes.NewReader(captureBody)
+	}
 	req, err := http.NewRequest(c.Request.Method, fullRequestURL, requestBody)
 	if err != nil {
 		return nil, fmt.Errorf("new request failed: %w", err)
 	}
 	headers := req.Header
-	headerOverride := make(map[string]string)
-	for k, v := range info.HeadersOverride {
-		if str, ok := v.(string); ok {
-			headerOverride[k] = str
-		} else {
-			return nil, types.NewError(err, types.ErrorCodeChannelHeaderOverrideInvalid)
-		}
+	headerOverride, err := common.RenderHeaderOverride(c, info, captureBody)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeChannelHeaderOverrideInvalid)
 	}
 	for key, value := range headerOverride {
 		headers.Set(key, value)
@@ -64,10 +71,20 @@ func DoApiRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBody
 	if err != nil {
 		return nil, fmt.Errorf("setup request header failed: %w", err)
 	}
+	var capture *service.BodyCapture
+	if shouldCapture {
+		capture = service.StartBodyCapture(c, info, req, captureBody)
+	}
 	resp, err := doRequest(c, req, info)
+	if capture != nil {
+		capture.WrapResponse(resp)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("do request failed: %w", err)
 	}
+	if err = common.ApplyResponseOverrideBeforeUsage(resp, info); err != nil {
+		return nil, fmt.Errorf("apply response override failed: %w", err)
+	}
 	return resp, nil
 }
 
@@ -86,13 +103,9 @@ func DoFormRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBod
 	// set form data
 	req.Header.Set("Content-Type", c.Request.Header.Get("Content-Type"))
 	headers := req.Header
-	headerOverride := make(map[string]string)
-	for k, v := range info.HeadersOverride {
-		if str, ok := v.(string); ok {
-			headerOverride[k] = str
-		} else {
-			return nil, types.NewError(err, types.ErrorCodeChannelHeaderOverrideInvalid)
-		}
+	headerOverride, err := common.RenderHeaderOverride(c, info, nil)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeChannelHeaderOverrideInvalid)
 	}
 	for key, value := range headerOverride {
 		headers.Set(key, value)
@@ -105,6 +118,9 @@ func DoFormRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBod
 	if err != nil {
 		return nil, fmt.Errorf("do request failed: %w", err)
 	}
+	if err = common.ApplyResponseOverrideBeforeUsage(resp, info); err != nil {
+		return nil, fmt.Errorf("apply response override failed: %w", err)
+	}
 	return resp, nil
 }
 
@@ -232,15 +248,9 @@ func DoRequest(c *gin.Context, req *http.Request, info *common.RelayInfo) (*http
 	return doRequest(c, req, info)
 }
 func doRequest(c *gin.Context, req *http.Request, info *common.RelayInfo) (*http.Response, error) {
-	var client *http.Client
-	var err error
-	if info.ChannelSetting.Proxy != "" {
-		client, err = service.NewProxyHttpClient(info.ChannelSetting.Proxy)
-		if err != nil {
-			return nil, fmt.Errorf("new proxy http client failed: %w", err)
-		}
-	} else {
-		client = service.GetHttpClient()
+	client, err := service.GetChannelHttpClient(info.ChannelId, info.ChannelSetting)
+	if err != nil {
+		return nil, fmt.Errorf("new channel http client failed: %w", err)
 	}
 
 	var stopPinger context.CancelFunc
@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"one-api/common"
+	"one-api/constant"
 	"one-api/dto"
 	"one-api/logger"
 	"one-api/relay/channel/openrouter"
@@ -17,6 +18,7 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
 )
 
 const (
@@ -703,7 +705,20 @@ func ClaudeStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.
 		Usage:        &dto.Usage{},
 	}
 	var err *types.NewAPIError
+	openBlockIndex := -1
 	helper.StreamScannerHandler(c, resp, info, func(data string) bool {
+		if info.StreamBudget != nil {
+			// 额度预算耗尽时丢弃该事件并结束读取，记录未关闭的内容块以便补发结束事件
+			if !service.ConsumeStreamBudget(info, claudeStreamDeltaText(data, requestMode)) {
+				return false
+			}
+			switch gjson.Get(data, "type").String() {
+			case "content_block_start":
+				openBlockIndex = int(gjson.Get(data, "index").Int())
+			case "content_block_stop":
+				openBlockIndex = -1
+			}
+		}
 		err = HandleStreamResponseData(c, info, claudeInfo, data, requestMode)
 		if err != nil {
 			return false
@@ -714,10 +729,60 @@ func ClaudeStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.
 		return nil, err
 	}
 
+	if info.StreamBudget.Exhausted() {
+		handleStreamBudgetExhausted(c, info, claudeInfo, requestMode, openBlockIndex)
+	}
 	HandleStreamFinalResponse(c, info, claudeInfo, requestMode)
 	return claudeInfo.Usage, nil
 }
 
+// claudeStreamDeltaText 返回单个流式事件中计入补全 token 的文本
+func claudeStreamDeltaText(data string, requestMode int) string {
+	if requestMode == RequestModeCompletion {
+		return gjson.Get(data, "completion").String()
+	}
+	switch gjson.Get(data, "type").String() {
+	case "content_block_start":
+		block := gjson.Get(data, "content_block")
+		return block.Get("text").String() + block.Get("name").String()
+	case "content_block_delta":
+		delta := gjson.Get(data, "delta")
+		return delta.Get("text").String() + delta.Get("thinking").String() + delta.Get("partial_json").String()
+	}
+	return ""
+}
+
+// handleStreamBudgetExhausted 额度预算耗尽时按已转发内容计算用量，并以 max_tokens 正常结束响应
+func handleStreamBudgetExhausted(c *gin.Context, info *relaycommon.RelayInfo, claudeInfo *ClaudeResponseInfo, requestMode int, openBlockIndex int) {
+	logger.LogWarn(c, fmt.Sprintf("stream budget exhausted after %d completion tokens, stopping stream", info.StreamBudget.CompletionTokens))
+	service.ApplyStreamBudgetUsage(info, claudeInfo.Usage)
+	claudeInfo.Done = true
+
+	switch info.RelayFormat {
+	case types.RelayFormatOpenAI:
+		_ = helper.ObjectData(c, helper.GenerateStopResponse(claudeInfo.ResponseId, claudeInfo.Created, info.UpstreamModelName, constant.FinishReasonLength))
+	case types.RelayFormatClaude:
+		if requestMode == RequestModeCompletion {
+			_ = helper.ClaudeData(c, dto.ClaudeResponse{Type: "completion", StopReason: "max_tokens", Model: claudeInfo.Model})
+			return
+		}
+		if openBlockIndex >= 0 {
+			_ = helper.ClaudeData(c, dto.ClaudeResponse{Type: "content_block_stop", Index: common.GetPointer(openBlockIndex)})
+		}
+		_ = helper.ClaudeData(c, dto.ClaudeResponse{
+			Type:  "message_delta",
+			Delta: &dto.ClaudeMediaMessage{StopReason: common.GetPointer("max_tokens")},
+			Usage: &dto.ClaudeUsage{
+				InputTokens:              claudeInfo.Usage.PromptTokens,
+				OutputTokens:             claudeInfo.Usage.CompletionTokens,
+				CacheCreationInputTokens: claudeInfo.Usage.PromptTokensDetails.CachedCreationTokens,
+				CacheReadInputTokens:     claudeInfo.Usage.PromptTokensDetails.CachedTokens,
+			},
+		})
+		_ = helper.ClaudeData(c, dto.ClaudeResponse{Type: "message_stop"})
+	}
+}
+
 func HandleClaudeResponseData(c *gin.Context, info *relaycommon.RelayInfo, claudeInfo *ClaudeResponseInfo, httpResp *http.Response, data []byte, requestMode int) *types.NewAPIError {
 	var claudeResponse dto.ClaudeResponse
 	err := common.Unmarshal(data, &claudeResponse)
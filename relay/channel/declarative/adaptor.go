@@ -0,0 +1,172 @@
+package declarative
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/relay/channel"
+	relaycommon "one-api/relay/common"
+	"one-api/relay/constant"
+	"one-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Adaptor 按渠道上保存的 dto.DeclarativeSpec 转换请求与响应，目前仅支持对话补全
+type Adaptor struct {
+	spec *dto.DeclarativeSpec
+}
+
+func (a *Adaptor) Init(info *relaycommon.RelayInfo) {
+	if info.ChannelMeta != nil {
+		a.spec = info.ChannelOtherSettings.DeclarativeSpec
+	}
+}
+
+func (a *Adaptor) getSpec() (*dto.DeclarativeSpec, error) {
+	if a.spec == nil {
+		return nil, errors.New("declarative spec is not configured")
+	}
+	return a.spec, nil
+}
+
+func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
+	spec, err := a.getSpec()
+	if err != nil {
+		return "", err
+	}
+	if info.RelayMode != constant.RelayModeChatCompletions {
+		return "", fmt.Errorf("declarative channel does not support relay mode %d", info.RelayMode)
+	}
+	urlTemplate := spec.URL
+	if info.IsStream && spec.StreamURL != "" {
+		urlTemplate = spec.StreamURL
+	}
+	fullRequestURL, err := newTemplateContext(nil, info, nil).Render(urlTemplate)
+	if err != nil {
+		return "", fmt.Errorf("render url failed: %w", err)
+	}
+	if spec.Auth.Type == dto.DeclarativeAuthQuery {
+		u, err := url.Parse(fullRequestURL)
+		if err != nil {
+			return "", err
+		}
+		query := u.Query()
+		query.Set(spec.Auth.Query, info.ApiKey)
+		u.RawQuery = query.Encode()
+		fullRequestURL = u.String()
+	}
+	return fullRequestURL, nil
+}
+
+func (a *Adaptor) SetupRequestHeader(c *gin.Context, req *http.Header, info *relaycommon.RelayInfo) error {
+	spec, err := a.getSpec()
+	if err != nil {
+		return err
+	}
+	channel.SetupApiRequestHeader(info, c, req)
+	req.Set("Content-Type", "application/json")
+	switch spec.Auth.Type {
+	case "", dto.DeclarativeAuthBearer:
+		req.Set("Authorization", "Bearer "+info.ApiKey)
+	case dto.DeclarativeAuthHeader:
+		req.Set(spec.Auth.Header, spec.Auth.Prefix+info.ApiKey)
+	}
+	tc := newTemplateContext(c, info, nil)
+	for key, value := range spec.Headers {
+		rendered, err := tc.Render(value)
+		if err != nil {
+			return fmt.Errorf("render header %s failed: %w", key, err)
+		}
+		req.Set(key, rendered)
+	}
+	return nil
+}
+
+func (a *Adaptor) ConvertOpenAIRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) (any, error) {
+	if request == nil {
+		return nil, errors.New("request is nil")
+	}
+	spec, err := a.getSpec()
+	if err != nil {
+		return nil, err
+	}
+	return convertRequest(c, info, spec, request)
+}
+
+func (a *Adaptor) ConvertRerankRequest(c *gin.Context, relayMode int, request dto.RerankRequest) (any, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *Adaptor) ConvertEmbeddingRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.EmbeddingRequest) (any, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.AudioRequest) (io.Reader, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (any, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *Adaptor) ConvertOpenAIResponsesRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.OpenAIResponsesRequest) (any, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *Adaptor) ConvertClaudeRequest(*gin.Context, *relaycommon.RelayInfo, *dto.ClaudeRequest) (any, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *Adaptor) ConvertGeminiRequest(*gin.Context, *relaycommon.RelayInfo, *dto.GeminiChatRequest) (any, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *Adaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, requestBody io.Reader) (any, error) {
+	spec, err := a.getSpec()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := channel.DoApiRequest(a, c, info, requestBody)
+	if err != nil || resp.StatusCode == http.StatusOK {
+		return resp, err
+	}
+	// 非 200 响应按错误映射转换为 OpenAI 格式，交给通用错误处理
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+	if openaiError := mapError(spec, body); openaiError != nil {
+		if converted, err := common.Marshal(gin.H{"error": openaiError}); err == nil {
+			body = converted
+		}
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	return resp, nil
+}
+
+func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (usage any, err *types.NewAPIError) {
+	spec, specErr := a.getSpec()
+	if specErr != nil {
+		return nil, types.NewError(specErr, types.ErrorCodeBadResponse)
+	}
+	if info.IsStream {
+		return declarativeStreamHandler(c, info, resp, spec)
+	}
+	return declarativeHandler(c, info, resp, spec)
+}
+
+func (a *Adaptor) GetModelList() []string {
+	return ModelList
+}
+
+func (a *Adaptor) GetChannelName() string {
+	return ChannelName
+}
@@ -0,0 +1,246 @@
+package declarative
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 每个 testdata 子目录是一组录制的上游交互：spec.json 为接口描述，request.json 为客户端的 OpenAI 请求，
+// upstream_*.json/txt 为录制的上游响应，*.golden.* 为期望输出。使用 go test -update 重新生成期望输出
+var updateGolden = flag.Bool("update", false, "update golden files")
+
+func newGoldenContext(t *testing.T, dir string, stream bool) (*gin.Context, *httptest.ResponseRecorder, *relaycommon.RelayInfo, *Adaptor) {
+	t.Helper()
+	spec := &dto.DeclarativeSpec{}
+	if err := common.Unmarshal(readFixture(t, dir, "spec.json"), spec); err != nil {
+		t.Fatalf("invalid spec: %v", err)
+	}
+	if err := ValidateSpec(spec); err != nil {
+		t.Fatalf("spec validation failed: %v", err)
+	}
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set(common.RequestIdKey, "req-1")
+	info := &relaycommon.RelayInfo{
+		RelayMode:          relayconstant.RelayModeChatCompletions,
+		OriginModelName:    "alias",
+		IsStream:           stream,
+		ShouldIncludeUsage: stream,
+		PromptTokens:       10,
+		StartTime:          time.Unix(1700000000, 0),
+		ChannelMeta: &relaycommon.ChannelMeta{
+			ChannelBaseUrl:       "https://llm.internal",
+			ApiKey:               "sk-test",
+			UpstreamModelName:    "house-7b",
+			ChannelOtherSettings: dto.ChannelOtherSettings{DeclarativeSpec: spec},
+		},
+	}
+	adaptor := &Adaptor{}
+	adaptor.Init(info)
+	return c, recorder, info, adaptor
+}
+
+func readFixture(t *testing.T, dir string, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func assertGolden(t *testing.T, dir string, name string, actual []byte) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if *updateGolden {
+		if err := os.WriteFile(path, actual, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("%s mismatch\nexpected:\n%s\nactual:\n%s", path, expected, actual)
+	}
+}
+
+func newUpstreamResponse(statusCode int, body []byte) *http.Response {
+	return &http.Response{
+		StatusCode: statusCode,
+		Header:     http.Header{},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+	}
+}
+
+func TestDeclarativeGolden(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	constant.StreamingTimeout = 30
+	dirs, err := filepath.Glob(filepath.Join("testdata", "*"))
+	if err != nil || len(dirs) == 0 {
+		t.Fatalf("no fixtures found: %v", err)
+	}
+	for _, dir := range dirs {
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+			testRequestMapping(t, dir)
+			testResponseMapping(t, dir)
+			testStreamMapping(t, dir)
+			testErrorMapping(t, dir)
+		})
+	}
+}
+
+func testRequestMapping(t *testing.T, dir string) {
+	var out bytes.Buffer
+	for _, stream := range []bool{false, true} {
+		c, _, info, adaptor := newGoldenContext(t, dir, stream)
+		request := &dto.GeneralOpenAIRequest{}
+		if err := common.Unmarshal(readFixture(t, dir, "request.json"), request); err != nil {
+			t.Fatal(err)
+		}
+		request.Stream = stream
+		fullRequestURL, err := adaptor.GetRequestURL(info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		headers := http.Header{}
+		if err = adaptor.SetupRequestHeader(c, &headers, info); err != nil {
+			t.Fatal(err)
+		}
+		converted, err := adaptor.ConvertOpenAIRequest(c, info, request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := common.Marshal(converted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&out, "POST %s\n", fullRequestURL)
+		keys := make([]string, 0, len(headers))
+		for key := range headers {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(&out, "%s: %s\n", key, headers.Get(key))
+		}
+		fmt.Fprintf(&out, "\n%s\n\n", body)
+	}
+	assertGolden(t, dir, "request.golden.txt", out.Bytes())
+}
+
+func testResponseMapping(t *testing.T, dir string) {
+	c, recorder, info, adaptor := newGoldenContext(t, dir, false)
+	resp := newUpstreamResponse(http.StatusOK, readFixture(t, dir, "upstream_response.json"))
+	if _, err := adaptor.DoResponse(c, resp, info); err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, dir, "response.golden.json", recorder.Body.Bytes())
+}
+
+func testStreamMapping(t *testing.T, dir string) {
+	c, recorder, info, adaptor := newGoldenContext(t, dir, true)
+	resp := newUpstreamResponse(http.StatusOK, readFixture(t, dir, "upstream_stream.txt"))
+	if _, err := adaptor.DoResponse(c, resp, info); err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, dir, "stream.golden.txt", recorder.Body.Bytes())
+}
+
+func testErrorMapping(t *testing.T, dir string) {
+	_, _, _, adaptor := newGoldenContext(t, dir, false)
+	openaiError := mapError(adaptor.spec, readFixture(t, dir, "upstream_error.json"))
+	if openaiError == nil {
+		t.Fatal("expected upstream error to be mapped")
+	}
+	data, _ := common.Marshal(openaiError)
+	assertGolden(t, dir, "error.golden.json", append(data, '\n'))
+}
+
+func TestValidateSpecInvalid(t *testing.T) {
+	base := func() *dto.DeclarativeSpec {
+		return &dto.DeclarativeSpec{
+			URL:      "{{base_url}}/chat",
+			Request:  map[string]any{"prompt": "{{last_user_message}}"},
+			Response: dto.DeclarativeResponse{Content: "text"},
+			Stream:   dto.DeclarativeStream{Content: "text"},
+		}
+	}
+	cases := map[string]func(spec *dto.DeclarativeSpec){
+		"missing url":    func(spec *dto.DeclarativeSpec) { spec.URL = "" },
+		"unknown auth":   func(spec *dto.DeclarativeSpec) { spec.Auth.Type = "digest" },
+		"header auth":    func(spec *dto.DeclarativeSpec) { spec.Auth.Type = dto.DeclarativeAuthHeader },
+		"unknown format": func(spec *dto.DeclarativeSpec) { spec.Stream.Format = "websocket" },
+	}
+	for name, mutate := range cases {
+		spec := base()
+		mutate(spec)
+		if err := ValidateSpec(spec); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+	if err := ValidateSpec(base()); err != nil {
+		t.Errorf("expected base spec to be valid: %v", err)
+	}
+	if !strings.Contains(fmt.Sprint(ValidateSpec(nil)), "required") {
+		t.Error("expected nil spec to be rejected")
+	}
+}
+
+func TestDeclarativeUsagePromptFallback(t *testing.T) {
+	dir := filepath.Join("testdata", "inhouse_sse")
+	c, _, info, adaptor := newGoldenContext(t, dir, false)
+	resp := newUpstreamResponse(http.StatusOK, []byte(`{"result":{"text":"Hello!","stop":"eos"},"meta":{"out":3}}`))
+	usage, err := adaptor.DoResponse(c, resp, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := usage.(*dto.Usage)
+	if got.PromptTokens != info.PromptTokens || got.CompletionTokens != 3 || got.TotalTokens != info.PromptTokens+3 {
+		t.Errorf("unexpected usage: %+v", got)
+	}
+}
+
+func TestDeclarativeStreamMidStreamError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	constant.StreamingTimeout = 30
+	dir := filepath.Join("testdata", "inhouse_sse")
+	c, recorder, info, adaptor := newGoldenContext(t, dir, true)
+	stream := "data: {\"token\":{\"text\":\"Hel\"}}\n\ndata: " + string(bytes.TrimSpace(readFixture(t, dir, "upstream_error.json"))) + "\n\n"
+	resp := newUpstreamResponse(http.StatusOK, []byte(stream))
+	_, err := adaptor.DoResponse(c, resp, info)
+	if err == nil {
+		t.Fatal("expected upstream error to be returned")
+	}
+	if err.StatusCode != http.StatusInternalServerError || !strings.Contains(err.Error(), "model overloaded") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	body := recorder.Body.String()
+	if !strings.Contains(body, "Hel") || !strings.Contains(body, `"error":`) {
+		t.Errorf("expected content and error event, got:\n%s", body)
+	}
+	if strings.Contains(body, `"finish_reason":"stop"`) || strings.Contains(body, "[DONE]") {
+		t.Errorf("stream must not finish normally after an error:\n%s", body)
+	}
+}
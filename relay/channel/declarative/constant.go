@@ -0,0 +1,6 @@
+package declarative
+
+// ModelList 声明式渠道没有固定模型，模型由渠道配置决定
+var ModelList = []string{}
+
+var ChannelName = "declarative"
@@ -0,0 +1,464 @@
+package declarative
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/types"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+// requestBuilder 根据请求体模板从 OpenAI 请求生成上游请求体
+type requestBuilder struct {
+	tc   *relaycommon.OverrideTemplateContext
+	root gjson.Result
+	item gjson.Result
+}
+
+// newTemplateContext 在参数覆盖模板的基础上增加 base_url、model、request.路径、item.路径、
+// last_user_message 与 system_message 变量
+func newTemplateContext(c *gin.Context, info *relaycommon.RelayInfo, builder *requestBuilder) *relaycommon.OverrideTemplateContext {
+	tc := relaycommon.NewOverrideTemplateContext(c, info, nil)
+	tc.Lookup = func(name string) (string, bool) {
+		switch name {
+		case "base_url":
+			return info.ChannelBaseUrl, true
+		case "model":
+			return info.UpstreamModelName, true
+		}
+		if builder == nil {
+			return "", false
+		}
+		if path, ok := strings.CutPrefix(name, "request."); ok {
+			return builder.root.Get(path).String(), true
+		}
+		if path, ok := strings.CutPrefix(name, "item."); ok {
+			return builder.item.Get(path).String(), true
+		}
+		switch name {
+		case "last_user_message":
+			return lastMessageText(builder.root, "user"), true
+		case "system_message":
+			return lastMessageText(builder.root, "system"), true
+		}
+		return "", false
+	}
+	if builder != nil {
+		builder.tc = tc
+	}
+	return tc
+}
+
+// lastMessageText 返回指定角色最后一条消息的文本，多模态内容只拼接其中的文本
+func lastMessageText(root gjson.Result, role string) string {
+	messages := root.Get("messages").Array()
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Get("role").String() != role {
+			continue
+		}
+		content := messages[i].Get("content")
+		if !content.IsArray() {
+			return content.String()
+		}
+		var sb strings.Builder
+		for _, part := range content.Array() {
+			if part.Get("type").String() == dto.ContentTypeText {
+				sb.WriteString(part.Get("text").String())
+			}
+		}
+		return sb.String()
+	}
+	return ""
+}
+
+func convertRequest(c *gin.Context, info *relaycommon.RelayInfo, spec *dto.DeclarativeSpec, request *dto.GeneralOpenAIRequest) (any, error) {
+	data, err := common.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	builder := &requestBuilder{root: gjson.ParseBytes(data)}
+	newTemplateContext(c, info, builder)
+	body, ok, err := builder.build(spec.Request, builder.root)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("declarative request template produced an empty body")
+	}
+	return body, nil
+}
+
+// build 渲染模板中的一个值，current 为 "$." 路径的查找对象，返回 false 表示该值应被省略
+func (b *requestBuilder) build(template any, current gjson.Result) (any, bool, error) {
+	switch v := template.(type) {
+	case string:
+		if path, ok := jsonPathOf(v); ok {
+			result := current
+			if path != "" {
+				result = current.Get(path)
+			}
+			if !result.Exists() {
+				return nil, false, nil
+			}
+			return json.RawMessage(result.Raw), true, nil
+		}
+		rendered, err := b.tc.Render(v)
+		return rendered, true, err
+	case map[string]any:
+		if source, ok := v["$each"]; ok {
+			return b.buildEach(source, v["$item"], current)
+		}
+		result := make(map[string]any, len(v))
+		for key, item := range v {
+			value, ok, err := b.build(item, current)
+			if err != nil {
+				return nil, false, fmt.Errorf("%s: %w", key, err)
+			}
+			if ok {
+				result[key] = value
+			}
+		}
+		return result, true, nil
+	case []any:
+		result := make([]any, 0, len(v))
+		for _, item := range v {
+			value, ok, err := b.build(item, current)
+			if err != nil {
+				return nil, false, err
+			}
+			if ok {
+				result = append(result, value)
+			}
+		}
+		return result, true, nil
+	default:
+		return template, true, nil
+	}
+}
+
+func (b *requestBuilder) buildEach(source any, itemTemplate any, current gjson.Result) (any, bool, error) {
+	sourcePath, _ := source.(string)
+	path, ok := jsonPathOf(sourcePath)
+	if !ok {
+		return nil, false, fmt.Errorf("$each must be a $. path, got %v", source)
+	}
+	list := current
+	if path != "" {
+		list = current.Get(path)
+	}
+	if !list.Exists() {
+		return nil, false, nil
+	}
+	if !list.IsArray() {
+		return nil, false, fmt.Errorf("$each path %s is not an array", sourcePath)
+	}
+	parent := b.item
+	defer func() { b.item = parent }()
+	result := make([]any, 0, len(list.Array()))
+	for _, element := range list.Array() {
+		b.item = element
+		value, ok, err := b.build(itemTemplate, element)
+		if err != nil {
+			return nil, false, err
+		}
+		if ok {
+			result = append(result, value)
+		}
+	}
+	return result, true, nil
+}
+
+// jsonPathOf 解析 "$" 与 "$.路径" 形式的字符串，返回去掉前缀的 gjson 路径
+func jsonPathOf(value string) (string, bool) {
+	if value == "$" {
+		return "", true
+	}
+	return strings.CutPrefix(value, "$.")
+}
+
+// mapError 按错误映射提取上游错误，未配置或响应中不存在错误信息时返回 nil
+func mapError(spec *dto.DeclarativeSpec, body []byte) *types.OpenAIError {
+	if spec.Error.Message == "" || !gjson.ValidBytes(body) {
+		return nil
+	}
+	message := gjson.GetBytes(body, spec.Error.Message)
+	if !message.Exists() || message.String() == "" {
+		return nil
+	}
+	openaiError := &types.OpenAIError{Message: message.String(), Type: "upstream_error"}
+	if spec.Error.Type != "" {
+		if errorType := gjson.GetBytes(body, spec.Error.Type).String(); errorType != "" {
+			openaiError.Type = errorType
+		}
+	}
+	if spec.Error.Code != "" {
+		if code := gjson.GetBytes(body, spec.Error.Code); code.Exists() {
+			openaiError.Code = code.Value()
+		}
+	}
+	return openaiError
+}
+
+func mapFinishReason(spec *dto.DeclarativeSpec, reason string) string {
+	if mapped, ok := spec.Response.FinishReasonMap[reason]; ok {
+		return mapped
+	}
+	return reason
+}
+
+// extractUsage 按用量路径读取用量，未配置或不存在时返回 false；上游未返回输入 token 时使用本地计算的 promptTokens
+func extractUsage(spec *dto.DeclarativeSpec, data gjson.Result, promptTokens int) (dto.Usage, bool) {
+	var usage dto.Usage
+	found := false
+	for path, field := range map[string]*int{
+		spec.Usage.PromptTokens:     &usage.PromptTokens,
+		spec.Usage.CompletionTokens: &usage.CompletionTokens,
+		spec.Usage.TotalTokens:      &usage.TotalTokens,
+	} {
+		if path == "" {
+			continue
+		}
+		if value := data.Get(path); value.Exists() {
+			*field = int(value.Int())
+			found = true
+		}
+	}
+	if found && usage.PromptTokens == 0 {
+		usage.PromptTokens = promptTokens
+		usage.TotalTokens = 0
+	}
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	return usage, found
+}
+
+func declarativeHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response, spec *dto.DeclarativeSpec) (*dto.Usage, *types.NewAPIError) {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeReadResponseBodyFailed, http.StatusInternalServerError)
+	}
+	service.CloseResponseBodyGracefully(resp)
+	if !gjson.ValidBytes(body) {
+		return nil, types.NewOpenAIError(errors.New("invalid upstream response"), types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	if openaiError := mapError(spec, body); openaiError != nil {
+		return nil, types.WithOpenAIError(*openaiError, resp.StatusCode)
+	}
+	data := gjson.ParseBytes(body)
+
+	id := helper.GetResponseID(c)
+	if spec.Response.Id != "" {
+		if value := data.Get(spec.Response.Id).String(); value != "" {
+			id = value
+		}
+	}
+	content := data.Get(spec.Response.Content).String()
+	finishReason := "stop"
+	if spec.Response.FinishReason != "" {
+		if value := data.Get(spec.Response.FinishReason).String(); value != "" {
+			finishReason = mapFinishReason(spec, value)
+		}
+	}
+	message := dto.Message{Role: "assistant", Content: content}
+	if spec.Response.ReasoningContent != "" {
+		message.ReasoningContent = data.Get(spec.Response.ReasoningContent).String()
+	}
+
+	usage, ok := extractUsage(spec, data, info.PromptTokens)
+	if !ok {
+		usage = *service.ResponseText2Usage(content+message.ReasoningContent, info.UpstreamModelName, info.PromptTokens)
+	}
+	openaiResp := dto.OpenAITextResponse{
+		Id:      id,
+		Model:   info.UpstreamModelName,
+		Object:  "chat.completion",
+		Created: info.StartTime.Unix(),
+		Choices: []dto.OpenAITextResponseChoice{
+			{
+				Index:        0,
+				Message:      message,
+				FinishReason: finishReason,
+			},
+		},
+		Usage: usage,
+	}
+	jsonResponse, err := common.Marshal(openaiResp)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	c.Writer.Header().Set("Content-Type", "application/json")
+	c.Writer.WriteHeader(resp.StatusCode)
+	_, _ = c.Writer.Write(jsonResponse)
+	return &usage, nil
+}
+
+// ndjsonBody 把每行一个 JSON 对象的流转换为 SSE 的 data: 行，以便复用通用的流式读取
+type ndjsonBody struct {
+	reader  *bufio.Reader
+	closer  io.Closer
+	pending []byte
+	err     error
+}
+
+func (b *ndjsonBody) Read(p []byte) (int, error) {
+	for len(b.pending) == 0 {
+		if b.err != nil {
+			return 0, b.err
+		}
+		line, err := b.reader.ReadBytes('\n')
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			b.pending = append(append([]byte("data: "), trimmed...), '\n')
+		}
+		b.err = err
+	}
+	n := copy(p, b.pending)
+	b.pending = b.pending[n:]
+	return n, nil
+}
+
+func (b *ndjsonBody) Close() error {
+	return b.closer.Close()
+}
+
+func declarativeStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response, spec *dto.DeclarativeSpec) (*dto.Usage, *types.NewAPIError) {
+	if spec.Stream.Format == dto.DeclarativeStreamNDJSON {
+		resp.Body = &ndjsonBody{reader: bufio.NewReader(resp.Body), closer: resp.Body}
+	}
+	id := helper.GetResponseID(c)
+	createdTime := info.StartTime.Unix()
+	var (
+		usage        dto.Usage
+		usageFound   bool
+		responseText strings.Builder
+		finishSent   bool
+		isFirst      = true
+		streamErr    *types.NewAPIError
+	)
+	helper.StreamScannerHandler(c, resp, info, func(data string) bool {
+		if spec.Stream.Done != "" && data == spec.Stream.Done {
+			return false
+		}
+		if !gjson.Valid(data) {
+			common.SysLog("declarative stream: invalid chunk: " + data)
+			return true
+		}
+		if openaiError := mapError(spec, []byte(data)); openaiError != nil {
+			common.SysLog("declarative stream: upstream error: " + openaiError.Message)
+			streamErr = types.WithOpenAIError(*openaiError, http.StatusInternalServerError)
+			return false
+		}
+		chunk := gjson.Parse(data)
+		if chunkUsage, ok := extractUsage(spec, chunk, info.PromptTokens); ok {
+			usage, usageFound = chunkUsage, true
+		}
+
+		var delta dto.ChatCompletionsStreamResponseChoiceDelta
+		if content := chunk.Get(spec.Stream.Content).String(); content != "" {
+			delta.SetContentString(content)
+			responseText.WriteString(content)
+		}
+		if spec.Stream.ReasoningContent != "" {
+			if reasoning := chunk.Get(spec.Stream.ReasoningContent).String(); reasoning != "" {
+				delta.ReasoningContent = &reasoning
+				responseText.WriteString(reasoning)
+			}
+		}
+		var finishReason *string
+		if spec.Stream.FinishReason != "" {
+			if reason := chunk.Get(spec.Stream.FinishReason).String(); reason != "" {
+				finishReason = common.GetPointer(mapFinishReason(spec, reason))
+			}
+		}
+		if delta.Content != nil || delta.ReasoningContent != nil || finishReason != nil {
+			if isFirst {
+				delta.Role = "assistant"
+				isFirst = false
+			}
+			err := helper.ObjectData(c, dto.ChatCompletionsStreamResponse{
+				Id:      id,
+				Object:  "chat.completion.chunk",
+				Created: createdTime,
+				Model:   info.UpstreamModelName,
+				Choices: []dto.ChatCompletionsStreamResponseChoice{
+					{Index: 0, Delta: delta, FinishReason: finishReason},
+				},
+			})
+			if err != nil {
+				common.SysLog("declarative stream: write chunk failed: " + err.Error())
+				return false
+			}
+			finishSent = finishSent || finishReason != nil
+		}
+		if spec.Stream.DonePath != "" && chunk.Get(spec.Stream.DonePath).Bool() {
+			return false
+		}
+		return true
+	})
+
+	if streamErr != nil {
+		// 已转发过内容时补发错误事件，不再发送结束块，由上层按错误处理重试与退款
+		if !isFirst {
+			_ = helper.ObjectData(c, gin.H{"error": streamErr.ToOpenAIError()})
+		}
+		return nil, streamErr
+	}
+	if !finishSent {
+		_ = helper.ObjectData(c, helper.GenerateStopResponse(id, createdTime, info.UpstreamModelName, "stop"))
+	}
+	if !usageFound {
+		usage = *service.ResponseText2Usage(responseText.String(), info.UpstreamModelName, info.PromptTokens)
+	}
+	if info.ShouldIncludeUsage {
+		_ = helper.ObjectData(c, helper.GenerateFinalUsageResponse(id, createdTime, info.UpstreamModelName, usage))
+	}
+	helper.Done(c)
+	return &usage, nil
+}
+
+// ValidateSpec 校验声明式渠道的接口描述
+func ValidateSpec(spec *dto.DeclarativeSpec) error {
+	if spec == nil {
+		return errors.New("declarative_spec is required")
+	}
+	if spec.URL == "" {
+		return errors.New("url is required")
+	}
+	if spec.Request == nil {
+		return errors.New("request template is required")
+	}
+	if spec.Response.Content == "" || spec.Stream.Content == "" {
+		return errors.New("response.content and stream.content are required")
+	}
+	switch spec.Auth.Type {
+	case "", dto.DeclarativeAuthBearer, dto.DeclarativeAuthNone:
+	case dto.DeclarativeAuthHeader:
+		if spec.Auth.Header == "" {
+			return errors.New("auth.header is required for header auth")
+		}
+	case dto.DeclarativeAuthQuery:
+		if spec.Auth.Query == "" {
+			return errors.New("auth.query is required for query auth")
+		}
+	default:
+		return fmt.Errorf("unknown auth type: %s", spec.Auth.Type)
+	}
+	switch spec.Stream.Format {
+	case "", dto.DeclarativeStreamSSE, dto.DeclarativeStreamNDJSON:
+	default:
+		return fmt.Errorf("unknown stream format: %s", spec.Stream.Format)
+	}
+	return nil
+}
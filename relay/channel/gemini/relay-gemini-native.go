@@ -1,6 +1,7 @@
 package gemini
 
 import (
+	"fmt"
 	"io"
 	"net/http"
 	"one-api/common"
@@ -110,6 +111,10 @@ func GeminiTextGenerationStreamHandler(c *gin.Context, info *relaycommon.RelayIn
 			logger.LogError(c, "error unmarshalling stream response: "+err.Error())
 			return false
 		}
+		// 额度预算耗尽时丢弃该分片并结束读取
+		if info.StreamBudget != nil && !service.ConsumeStreamBudget(info, geminiStreamDeltaText(&geminiResponse)) {
+			return false
+		}
 
 		// 统计图片数量
 		for _, candidate := range geminiResponse.Candidates {
@@ -147,6 +152,30 @@ func GeminiTextGenerationStreamHandler(c *gin.Context, info *relaycommon.RelayIn
 		return true
 	})
 
+	if info.StreamBudget.Exhausted() {
+		// 额度预算耗尽，按已转发内容计费并以 MAX_TOKENS 结束响应
+		logger.LogWarn(c, fmt.Sprintf("stream budget exhausted after %d completion tokens, stopping stream", info.StreamBudget.CompletionTokens))
+		service.ApplyStreamBudgetUsage(info, usage)
+		finishReason := "MAX_TOKENS"
+		err := helper.ObjectData(c, dto.GeminiChatResponse{
+			Candidates: []dto.GeminiChatCandidate{{
+				Content:       dto.GeminiChatContent{Role: "model", Parts: []dto.GeminiPart{}},
+				FinishReason:  &finishReason,
+				SafetyRatings: []dto.GeminiChatSafetyRating{},
+			}},
+			UsageMetadata: dto.GeminiUsageMetadata{
+				PromptTokenCount:     usage.PromptTokens,
+				CandidatesTokenCount: usage.CompletionTokens,
+				TotalTokenCount:      usage.TotalTokens,
+			},
+		})
+		if err != nil {
+			logger.LogError(c, err.Error())
+		}
+		info.SendResponseCount++
+		return usage, nil
+	}
+
 	if info.SendResponseCount == 0 {
 		return nil, types.NewOpenAIError(errors.New("no response received from Gemini API"), types.ErrorCodeEmptyResponse, http.StatusInternalServerError)
 	}
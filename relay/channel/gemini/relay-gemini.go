@@ -889,6 +889,23 @@ func handleStream(c *gin.Context, info *relaycommon.RelayInfo, resp *dto.ChatCom
 	return nil
 }
 
+// geminiStreamDeltaText 返回单个流式分片中计入补全 token 的文本
+func geminiStreamDeltaText(resp *dto.GeminiChatResponse) string {
+	var sb strings.Builder
+	for _, candidate := range resp.Candidates {
+		for _, part := range candidate.Content.Parts {
+			sb.WriteString(part.Text)
+			if part.FunctionCall != nil {
+				sb.WriteString(part.FunctionCall.FunctionName)
+				if args, err := common.Marshal(part.FunctionCall.Arguments); err == nil {
+					sb.Write(args)
+				}
+			}
+		}
+	}
+	return sb.String()
+}
+
 func handleFinalStream(c *gin.Context, info *relaycommon.RelayInfo, resp *dto.ChatCompletionsStreamResponse) error {
 	streamData, err := common.Marshal(resp)
 	if err != nil {
@@ -914,6 +931,10 @@ func GeminiChatStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *
 			logger.LogError(c, "error unmarshalling stream response: "+err.Error())
 			return false
 		}
+		// 额度预算耗尽时丢弃该分片并结束读取
+		if info.StreamBudget != nil && !service.ConsumeStreamBudget(info, geminiStreamDeltaText(&geminiResponse)) {
+			return false
+		}
 
 		for _, candidate := range geminiResponse.Candidates {
 			for _, part := range candidate.Content.Parts {
@@ -980,6 +1001,11 @@ func GeminiChatStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *
 		return true
 	})
 
+	if info.StreamBudget.Exhausted() {
+		logger.LogWarn(c, fmt.Sprintf("stream budget exhausted after %d completion tokens, stopping stream", info.StreamBudget.CompletionTokens))
+		_ = handleStream(c, info, helper.GenerateStopResponse(id, createAt, info.UpstreamModelName, constant.FinishReasonLength))
+	}
+
 	if info.SendResponseCount == 0 {
 		// 空补全，报错不计费
 		// empty response, throw an error
@@ -1005,6 +1031,10 @@ func GeminiChatStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *
 		}
 	}
 
+	if info.StreamBudget.Exhausted() {
+		service.ApplyStreamBudgetUsage(info, usage)
+	}
+
 	response := helper.GenerateFinalUsageResponse(id, createAt, info.UpstreamModelName, *usage)
 	err := handleFinalStream(c, info, response)
 	if err != nil {
@@ -3,6 +3,7 @@ package openai
 import (
 	"encoding/json"
 	"one-api/common"
+	"one-api/constant"
 	"one-api/dto"
 	"one-api/logger"
 	relaycommon "one-api/relay/common"
@@ -91,6 +92,31 @@ func ProcessStreamResponse(streamResponse dto.ChatCompletionsStreamResponse, res
 	return nil
 }
 
+// streamDeltaText 返回单个流式分片中计入补全 token 的文本
+func streamDeltaText(relayMode int, data string) string {
+	var sb strings.Builder
+	var toolCount int
+	_ = processTokens(relayMode, []string{data}, &sb, &toolCount)
+	return sb.String()
+}
+
+// budgetStopStreamData 生成额度预算耗尽时的结束分片（finish_reason 为 length），沿用上一个分片的 id 与模型
+func budgetStopStreamData(c *gin.Context, info *relaycommon.RelayInfo, lastStreamData string) string {
+	var lastStreamResponse dto.ChatCompletionsStreamResponse
+	if lastStreamData == "" || common.UnmarshalJsonStr(lastStreamData, &lastStreamResponse) != nil {
+		lastStreamResponse.Id = helper.GetResponseID(c)
+		lastStreamResponse.Created = common.GetTimestamp()
+		lastStreamResponse.Model = info.UpstreamModelName
+	}
+	response := helper.GenerateStopResponse(lastStreamResponse.Id, lastStreamResponse.Created, lastStreamResponse.Model, constant.FinishReasonLength)
+	response.SystemFingerprint = lastStreamResponse.SystemFingerprint
+	data, err := common.Marshal(response)
+	if err != nil {
+		return lastStreamData
+	}
+	return string(data)
+}
+
 func processTokens(relayMode int, streamItems []string, responseTextBuilder *strings.Builder, toolCount *int) error {
 	streamResp := "[" + strings.Join(streamItems, ",") + "]"
 
@@ -138,12 +138,21 @@ func OaiStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Re
 			}
 		}
 		if len(data) > 0 {
+			// 额度预算耗尽时丢弃该分片并结束读取，之前的分片均已发送
+			if info.StreamBudget != nil && !service.ConsumeStreamBudget(info, streamDeltaText(info.RelayMode, data)) {
+				return false
+			}
 			lastStreamData = data
 			streamItems = append(streamItems, data)
 		}
 		return true
 	})
 
+	if info.StreamBudget.Exhausted() {
+		logger.LogWarn(c, fmt.Sprintf("stream budget exhausted after %d completion tokens, stopping stream", info.StreamBudget.CompletionTokens))
+		lastStreamData = budgetStopStreamData(c, info, lastStreamData)
+	}
+
 	// 处理最后的响应
 	shouldSendLastResp := true
 	if err := handleLastResponse(lastStreamData, &responseId, &createAt, &systemFingerprint, &model, &usage,
@@ -172,6 +181,9 @@ func OaiStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Re
 			}
 		}
 	}
+	if info.StreamBudget.Exhausted() {
+		service.ApplyStreamBudgetUsage(info, usage)
+	}
 	HandleFinalResponse(c, info, lastStreamData, responseId, createAt, model, systemFingerprint, usage, containStreamUsage)
 
 	return usage, nil
@@ -0,0 +1,391 @@
+package common
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/common/secretref"
+	"one-api/constant"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 请求头与参数覆盖支持 {{...}} 模板，在每次请求时解析。表达式可以是：
+//   - 变量：user_id、username、token_id、token_name、group、user_group、request_id、channel_id、
+//     original_model、upstream_model、timestamp、timestamp_ms、iso_time、uuid、body（仅请求头可用，为最终请求体）
+//   - 客户端请求头：client_header.X-Trace
+//   - 函数：hmac_sha256(key, ...)、hmac_sha256_base64(key, ...)、sha256(...)、base64(...)、concat(...)、
+//     lower(...)、upper(...)、secret("env://NEWAPI_SECRET_NAME")，参数为表达式或双引号字符串，多个参数按顺序拼接
+//   - secret 只接受一个字符串字面量，引用范围与渠道密钥引用相同（SECRET_REF_ENV_PREFIX、SECRET_REF_FILE_DIRS）
+//
+// 例如 {"user": "{{user_id}}"}，或请求头 {"X-Signature": "{{hmac_sha256(secret(\"env://NEWAPI_SECRET_GW_KEY\"), timestamp, \".\", body)}}"}
+
+// OverrideTemplateContext 模板解析所需的请求上下文
+type OverrideTemplateContext struct {
+	c    *gin.Context
+	info *RelayInfo
+	// Body 最终发往上游的请求体，为 nil 时模板中不能使用 body
+	Body []byte
+	// Lookup 额外的变量，优先于内置变量，返回 false 时继续查找内置变量
+	Lookup func(name string) (string, bool)
+}
+
+func NewOverrideTemplateContext(c *gin.Context, info *RelayInfo, body []byte) *OverrideTemplateContext {
+	return &OverrideTemplateContext{c: c, info: info, Body: body}
+}
+
+func (t *OverrideTemplateContext) now() time.Time {
+	// 同一请求内的时间戳保持一致，便于签名与时间戳请求头对应
+	if t.info != nil && !t.info.StartTime.IsZero() {
+		return t.info.StartTime
+	}
+	return time.Now()
+}
+
+func (t *OverrideTemplateContext) variable(name string) (string, error) {
+	if t.Lookup != nil {
+		if value, ok := t.Lookup(name); ok {
+			return value, nil
+		}
+	}
+	if header, ok := strings.CutPrefix(name, "client_header."); ok {
+		if t.c == nil || t.c.Request == nil {
+			return "", nil
+		}
+		return t.c.Request.Header.Get(header), nil
+	}
+	switch name {
+	case "timestamp":
+		return strconv.FormatInt(t.now().Unix(), 10), nil
+	case "timestamp_ms":
+		return strconv.FormatInt(t.now().UnixMilli(), 10), nil
+	case "iso_time":
+		return t.now().UTC().Format(time.RFC3339), nil
+	case "uuid":
+		return common.GetUUID(), nil
+	case "body":
+		if t.Body == nil {
+			return "", errors.New("body is only available in header override")
+		}
+		return string(t.Body), nil
+	}
+	if t.c == nil {
+		return "", nil
+	}
+	switch name {
+	case "user_id":
+		return strconv.Itoa(common.GetContextKeyInt(t.c, constant.ContextKeyUserId)), nil
+	case "username":
+		return common.GetContextKeyString(t.c, constant.ContextKeyUserName), nil
+	case "token_id":
+		return strconv.Itoa(common.GetContextKeyInt(t.c, constant.ContextKeyTokenId)), nil
+	case "token_name":
+		return t.c.GetString("token_name"), nil
+	case "group":
+		return common.GetContextKeyString(t.c, constant.ContextKeyUsingGroup), nil
+	case "user_group":
+		return common.GetContextKeyString(t.c, constant.ContextKeyUserGroup), nil
+	case "request_id":
+		return t.c.GetString(common.RequestIdKey), nil
+	case "channel_id":
+		return strconv.Itoa(common.GetContextKeyInt(t.c, constant.ContextKeyChannelId)), nil
+	case "original_model":
+		if t.info != nil && t.info.OriginModelName != "" {
+			return t.info.OriginModelName, nil
+		}
+		return common.GetContextKeyString(t.c, constant.ContextKeyOriginalModel), nil
+	case "upstream_model":
+		if t.info != nil && t.info.ChannelMeta != nil {
+			return t.info.UpstreamModelName, nil
+		}
+		return "", nil
+	}
+	return "", fmt.Errorf("unknown template variable: %s", name)
+}
+
+func (t *OverrideTemplateContext) call(name string, args []string) (string, error) {
+	joined := strings.Join(args, "")
+	switch name {
+	case "concat":
+		return joined, nil
+	case "lower":
+		return strings.ToLower(joined), nil
+	case "upper":
+		return strings.ToUpper(joined), nil
+	case "base64":
+		return base64.StdEncoding.EncodeToString([]byte(joined)), nil
+	case "sha256":
+		sum := sha256.Sum256([]byte(joined))
+		return hex.EncodeToString(sum[:]), nil
+	case "hmac_sha256", "hmac_sha256_base64":
+		if len(args) < 1 {
+			return "", fmt.Errorf("%s requires a key", name)
+		}
+		mac := hmac.New(sha256.New, []byte(args[0]))
+		mac.Write([]byte(strings.Join(args[1:], "")))
+		if name == "hmac_sha256_base64" {
+			return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+		}
+		return hex.EncodeToString(mac.Sum(nil)), nil
+	case "secret":
+		if !secretref.IsReference(joined) {
+			return "", fmt.Errorf("invalid secret reference: %s", joined)
+		}
+		if err := secretref.CheckAllowed(joined); err != nil {
+			return "", err
+		}
+		return secretref.Resolve(joined)
+	}
+	return "", fmt.Errorf("unknown template function: %s", name)
+}
+
+// HasOverrideTemplate 值中是否包含模板
+func HasOverrideTemplate(value string) bool {
+	return strings.Contains(value, "{{")
+}
+
+// Render 替换字符串中的全部 {{...}} 模板
+func (t *OverrideTemplateContext) Render(value string) (string, error) {
+	if !HasOverrideTemplate(value) {
+		return value, nil
+	}
+	var sb strings.Builder
+	rest := value
+	for {
+		start := strings.Index(rest, "{{")
+		if start < 0 {
+			sb.WriteString(rest)
+			return sb.String(), nil
+		}
+		sb.WriteString(rest[:start])
+		p := &templateParser{src: rest[start+2:], ctx: t}
+		result, err := p.parseExpr()
+		if err != nil {
+			return "", err
+		}
+		p.skipSpaces()
+		if !strings.HasPrefix(p.src[p.pos:], "}}") {
+			return "", fmt.Errorf("unterminated template in %q", value)
+		}
+		sb.WriteString(result)
+		rest = p.src[p.pos+2:]
+	}
+}
+
+var overrideSecretPattern = regexp.MustCompile(`secret\(\s*"((?:[^"\\]|\\.)*)"`)
+
+// ValidateOverrideSecrets 检查覆盖规则中 secret(...) 引用的密钥是否在允许范围内，保存渠道时调用
+func ValidateOverrideSecrets(value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		for _, match := range overrideSecretPattern.FindAllStringSubmatch(v, -1) {
+			ref := strings.ReplaceAll(match[1], `\"`, `"`)
+			if err := secretref.CheckAllowed(ref); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if err := ValidateOverrideSecrets(item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		for _, item := range v {
+			if err := ValidateOverrideSecrets(item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RenderValue 递归替换 map、数组与字符串中的模板
+func (t *OverrideTemplateContext) RenderValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return t.Render(v)
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			rendered, err := t.RenderValue(item)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = rendered
+		}
+		return result, nil
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			rendered, err := t.RenderValue(item)
+			if err != nil {
+				return nil, err
+			}
+			result[k] = rendered
+		}
+		return result, nil
+	default:
+		return value, nil
+	}
+}
+
+type templateParser struct {
+	src string
+	pos int
+	ctx *OverrideTemplateContext
+}
+
+func (p *templateParser) skipSpaces() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func isTemplateIdentChar(ch byte, header bool) bool {
+	return ch == '_' || ch == '.' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9') ||
+		(header && ch == '-')
+}
+
+func (p *templateParser) parseExpr() (string, error) {
+	p.skipSpaces()
+	if p.pos >= len(p.src) {
+		return "", errors.New("unexpected end of template")
+	}
+	if p.src[p.pos] == '"' {
+		return p.parseString()
+	}
+	start := p.pos
+	for p.pos < len(p.src) && isTemplateIdentChar(p.src[p.pos], strings.HasPrefix(p.src[start:p.pos], "client_header.")) {
+		p.pos++
+	}
+	name := p.src[start:p.pos]
+	if name == "" {
+		return "", fmt.Errorf("unexpected character %q in template", p.src[p.pos])
+	}
+	p.skipSpaces()
+	if name == "secret" {
+		return p.parseSecret()
+	}
+	if p.pos < len(p.src) && p.src[p.pos] == '(' {
+		p.pos++
+		var args []string
+		for {
+			p.skipSpaces()
+			if p.pos < len(p.src) && p.src[p.pos] == ')' {
+				p.pos++
+				break
+			}
+			arg, err := p.parseExpr()
+			if err != nil {
+				return "", err
+			}
+			args = append(args, arg)
+			p.skipSpaces()
+			if p.pos < len(p.src) && p.src[p.pos] == ',' {
+				p.pos++
+				continue
+			}
+			if p.pos < len(p.src) && p.src[p.pos] == ')' {
+				p.pos++
+				break
+			}
+			return "", fmt.Errorf("expected ',' or ')' in call to %s", name)
+		}
+		return p.ctx.call(name, args)
+	}
+	return p.ctx.variable(name)
+}
+
+// parseSecret 解析 secret("...")，引用必须是字面量，避免请求头等变量参与拼接出任意引用
+func (p *templateParser) parseSecret() (string, error) {
+	if p.pos >= len(p.src) || p.src[p.pos] != '(' {
+		return "", errors.New("secret requires a string literal argument")
+	}
+	p.pos++
+	p.skipSpaces()
+	if p.pos >= len(p.src) || p.src[p.pos] != '"' {
+		return "", errors.New("secret requires a string literal argument")
+	}
+	ref, err := p.parseString()
+	if err != nil {
+		return "", err
+	}
+	p.skipSpaces()
+	if p.pos >= len(p.src) || p.src[p.pos] != ')' {
+		return "", errors.New("secret accepts exactly one string literal argument")
+	}
+	p.pos++
+	return p.ctx.call("secret", []string{ref})
+}
+
+func (p *templateParser) parseString() (string, error) {
+	var sb strings.Builder
+	p.pos++ // 跳过起始引号
+	for p.pos < len(p.src) {
+		ch := p.src[p.pos]
+		switch ch {
+		case '\\':
+			if p.pos+1 < len(p.src) {
+				sb.WriteByte(p.src[p.pos+1])
+				p.pos += 2
+				continue
+			}
+		case '"':
+			p.pos++
+			return sb.String(), nil
+		}
+		sb.WriteByte(ch)
+		p.pos++
+	}
+	return "", errors.New("unterminated string in template")
+}
+
+// ApplyParamOverrideWithTemplates 解析参数覆盖中的模板后再应用到请求体
+func ApplyParamOverrideWithTemplates(c *gin.Context, info *RelayInfo, jsonData []byte) ([]byte, error) {
+	rendered, err := NewOverrideTemplateContext(c, info, nil).RenderValue(info.ParamOverride)
+	if err != nil {
+		return nil, err
+	}
+	paramOverride, _ := rendered.(map[string]interface{})
+	return ApplyParamOverride(jsonData, paramOverride)
+}
+
+// HeaderOverrideUsesBody 请求头覆盖的模板中是否引用了请求体
+func HeaderOverrideUsesBody(headerOverride map[string]interface{}) bool {
+	for _, v := range headerOverride {
+		if str, ok := v.(string); ok && HasOverrideTemplate(str) && strings.Contains(str, "body") {
+			return true
+		}
+	}
+	return false
+}
+
+// RenderHeaderOverride 解析请求头覆盖中的模板，body 为最终请求体，未引用请求体时可以为 nil
+func RenderHeaderOverride(c *gin.Context, info *RelayInfo, body []byte) (map[string]string, error) {
+	if body == nil {
+		body = []byte{}
+	}
+	ctx := NewOverrideTemplateContext(c, info, body)
+	headers := make(map[string]string, len(info.HeadersOverride))
+	for k, v := range info.HeadersOverride {
+		str, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("header override value of %s must be a string", k)
+		}
+		rendered, err := ctx.Render(str)
+		if err != nil {
+			return nil, fmt.Errorf("header override %s: %w", k, err)
+		}
+		headers[k] = rendered
+	}
+	return headers, nil
+}
@@ -0,0 +1,121 @@
+package common
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTemplateTestContext(t *testing.T) (*gin.Context, *RelayInfo) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+	c.Request.Header.Set("X-Trace", "trace-1")
+	common.SetContextKey(c, constant.ContextKeyUserId, 42)
+	common.SetContextKey(c, constant.ContextKeyUsingGroup, "vip")
+	c.Set("token_name", "ci")
+	c.Set(common.RequestIdKey, "req-1")
+	info := &RelayInfo{
+		OriginModelName: "gpt-4o",
+		StartTime:       time.Unix(1700000000, 0),
+		ChannelMeta: &ChannelMeta{
+			UpstreamModelName: "gpt-4o-2024-08-06",
+			ParamOverride: map[string]interface{}{
+				"user":     "{{user_id}}",
+				"metadata": map[string]interface{}{"user_id": "{{group}}-{{token_name}}"},
+			},
+			HeadersOverride: map[string]interface{}{
+				"X-Timestamp": "{{timestamp}}",
+				"X-Signature": `{{hmac_sha256("k", timestamp, ".", body)}}`,
+				"X-Trace":     "{{client_header.X-Trace}}/{{request_id}}/{{upstream_model}}",
+			},
+		},
+	}
+	return c, info
+}
+
+func TestRenderOverrideTemplates(t *testing.T) {
+	c, info := newTemplateTestContext(t)
+
+	body, err := ApplyParamOverrideWithTemplates(c, info, []byte(`{"model":"gpt-4o"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result map[string]interface{}
+	_ = common.Unmarshal(body, &result)
+	if result["user"] != "42" || result["metadata"].(map[string]interface{})["user_id"] != "vip-ci" {
+		t.Errorf("Unexpected param override result: %s", body)
+	}
+
+	if !HeaderOverrideUsesBody(info.HeadersOverride) {
+		t.Error("Expected header override to use body")
+	}
+	headers, err := RenderHeaderOverride(c, info, []byte(`{"a":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mac := hmac.New(sha256.New, []byte("k"))
+	mac.Write([]byte(`1700000000.{"a":1}`))
+	if headers["X-Timestamp"] != "1700000000" || headers["X-Signature"] != hex.EncodeToString(mac.Sum(nil)) {
+		t.Errorf("Unexpected signature headers: %v", headers)
+	}
+	if headers["X-Trace"] != "trace-1/req-1/gpt-4o-2024-08-06" {
+		t.Errorf("Unexpected trace header: %s", headers["X-Trace"])
+	}
+}
+
+func TestRenderOverrideTemplateErrors(t *testing.T) {
+	c, info := newTemplateTestContext(t)
+	ctx := NewOverrideTemplateContext(c, info, nil)
+	for _, tpl := range []string{"{{unknown}}", "{{user_id", `{{concat("a"}}`, "{{body}}", `{{nope("a")}}`} {
+		if _, err := ctx.Render(tpl); err == nil {
+			t.Errorf("Expected %q to fail", tpl)
+		}
+	}
+	if value, _ := ctx.Render(`plain {{upper(original_model, "-x")}}`); value != "plain GPT-4O-X" {
+		t.Errorf("Unexpected render result: %q", value)
+	}
+}
+
+func TestOverrideTemplateSecretAllowlist(t *testing.T) {
+	t.Setenv("SQL_DSN", "root:password@tcp(db)/oneapi")
+	t.Setenv("NEWAPI_SECRET_GW_KEY", "gw-key")
+	c, info := newTemplateTestContext(t)
+	c.Request.Header.Set("X-Ref", "env://SQL_DSN")
+	ctx := NewOverrideTemplateContext(c, info, nil)
+	for _, tpl := range []string{
+		`{{secret("env://SQL_DSN")}}`,
+		`{{secret("file:///proc/self/environ")}}`,
+		`{{secret(client_header.X-Ref)}}`,
+		`{{secret(concat("env://", "NEWAPI_SECRET_GW_KEY"))}}`,
+		`{{secret("vault://secret/data/database#password")}}`,
+		`{{secret("vault://secret/data/newapi/../database#password")}}`,
+	} {
+		if value, err := ctx.Render(tpl); err == nil {
+			t.Errorf("Expected %q to fail, got %q", tpl, value)
+		}
+	}
+	if value, err := ctx.Render(`{{secret("env://NEWAPI_SECRET_GW_KEY")}}`); err != nil || value != "gw-key" {
+		t.Errorf("Unexpected secret result: %q %v", value, err)
+	}
+
+	overrides := map[string]interface{}{
+		"X-Sign": `{{hmac_sha256(secret("env://NEWAPI_SECRET_GW_KEY"), body)}}`,
+		"nested": []interface{}{map[string]interface{}{"X-Leak": `{{secret( "env://SQL_DSN" )}}`}},
+	}
+	if err := ValidateOverrideSecrets(overrides); err == nil {
+		t.Error("Expected ValidateOverrideSecrets to reject env://SQL_DSN")
+	}
+	delete(overrides, "nested")
+	if err := ValidateOverrideSecrets(overrides); err != nil {
+		t.Errorf("Unexpected ValidateOverrideSecrets error: %v", err)
+	}
+}
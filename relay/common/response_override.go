@@ -0,0 +1,328 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// ResponseOverrideStageBeforeUsage 在解析用量之前改写上游原始响应
+	ResponseOverrideStageBeforeUsage = "before_usage"
+	// ResponseOverrideStageAfterUsage 在解析用量之后改写返回给客户端的响应（默认）
+	ResponseOverrideStageAfterUsage = "after_usage"
+)
+
+// ResponseOverrideRule 响应改写规则，操作与条件的写法与 ParamOverride 相同。
+// 值中的 {{origin_model}} 与 {{upstream_model}} 会被替换为客户端请求的模型与实际请求上游的模型
+type ResponseOverrideRule struct {
+	Models     []string         `json:"models,omitempty"` // 适用的模型，支持以 * 结尾的前缀匹配，为空表示全部模型
+	Stage      string           `json:"stage,omitempty"`
+	Operations []ParamOperation `json:"operations"`
+}
+
+// ResponseOverride 渠道的响应改写配置，可以直接写 operations 作为一条适用于全部模型的规则
+type ResponseOverride struct {
+	Stage      string                 `json:"stage,omitempty"`
+	Operations []ParamOperation       `json:"operations,omitempty"`
+	Rules      []ResponseOverrideRule `json:"rules,omitempty"`
+}
+
+// ParseResponseOverride 解析渠道的响应改写配置
+func ParseResponseOverride(responseOverride map[string]interface{}) (*ResponseOverride, error) {
+	override := &ResponseOverride{}
+	if len(responseOverride) == 0 {
+		return override, nil
+	}
+	data, err := common.Marshal(responseOverride)
+	if err != nil {
+		return nil, err
+	}
+	if err = common.Unmarshal(data, override); err != nil {
+		return nil, err
+	}
+	if len(override.Operations) > 0 {
+		override.Rules = append([]ResponseOverrideRule{{Stage: override.Stage, Operations: override.Operations}}, override.Rules...)
+		override.Operations = nil
+	}
+	for i, rule := range override.Rules {
+		switch rule.Stage {
+		case "":
+			override.Rules[i].Stage = ResponseOverrideStageAfterUsage
+		case ResponseOverrideStageBeforeUsage, ResponseOverrideStageAfterUsage:
+		default:
+			return nil, fmt.Errorf("unknown response override stage: %s", rule.Stage)
+		}
+		for _, op := range rule.Operations {
+			switch op.Mode {
+			case "delete", "set", "move", "prepend", "append":
+			default:
+				return nil, fmt.Errorf("unknown operation: %s", op.Mode)
+			}
+		}
+	}
+	return override, nil
+}
+
+func matchResponseOverrideModel(patterns []string, models ...string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		for _, model := range models {
+			if model == "" {
+				continue
+			}
+			if strings.HasSuffix(pattern, "*") && strings.HasPrefix(model, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+			if pattern == model {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// responseOperations 返回当前渠道与模型在指定阶段需要执行的操作，模板变量已替换
+func (info *RelayInfo) responseOperations(stage string) []ParamOperation {
+	if info == nil || info.ChannelMeta == nil || len(info.ResponseOverride) == 0 {
+		return nil
+	}
+	override, err := ParseResponseOverride(info.ResponseOverride)
+	if err != nil {
+		common.SysError(fmt.Sprintf("invalid response override of channel %d: %s", info.ChannelId, err.Error()))
+		return nil
+	}
+	vars := strings.NewReplacer("{{origin_model}}", info.OriginModelName, "{{upstream_model}}", info.UpstreamModelName)
+	var operations []ParamOperation
+	for _, rule := range override.Rules {
+		if rule.Stage != stage || !matchResponseOverrideModel(rule.Models, info.OriginModelName, info.UpstreamModelName) {
+			continue
+		}
+		for _, op := range rule.Operations {
+			op.Value = replaceTemplateVars(op.Value, vars)
+			operations = append(operations, op)
+		}
+	}
+	return operations
+}
+
+func replaceTemplateVars(value interface{}, vars *strings.Replacer) interface{} {
+	switch v := value.(type) {
+	case string:
+		return vars.Replace(v)
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = replaceTemplateVars(item, vars)
+		}
+		return result
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			result[k] = replaceTemplateVars(item, vars)
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+// applyResponseOperations 改写一个 JSON 对象，非 JSON 内容或改写失败时原样返回
+func applyResponseOperations(data []byte, operations []ParamOperation) []byte {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || trimmed[0] != '{' || !json.Valid(trimmed) {
+		return data
+	}
+	result, err := applyOperations(string(trimmed), operations)
+	if err != nil {
+		common.SysError("failed to apply response override: " + err.Error())
+		return data
+	}
+	return []byte(result)
+}
+
+// applyResponseOperationsToLine 改写 SSE 中的一行，仅处理 data: 行
+func applyResponseOperationsToLine(line []byte, operations []ParamOperation) []byte {
+	content := bytes.TrimRight(line, "\r\n")
+	if !bytes.HasPrefix(content, []byte("data:")) {
+		return line
+	}
+	payload := bytes.TrimPrefix(bytes.TrimPrefix(content, []byte("data:")), []byte(" "))
+	rewritten := applyResponseOperations(payload, operations)
+	if bytes.Equal(rewritten, payload) {
+		return line
+	}
+	out := make([]byte, 0, len(rewritten)+len(line)-len(content)+6)
+	out = append(out, "data: "...)
+	out = append(out, rewritten...)
+	return append(out, line[len(content):]...)
+}
+
+// ApplyResponseOverrideBeforeUsage 在解析用量之前改写上游响应，非流式改写整个 JSON，流式逐条改写 SSE 数据
+func ApplyResponseOverrideBeforeUsage(resp *http.Response, info *RelayInfo) error {
+	if resp == nil || resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil
+	}
+	operations := info.responseOperations(ResponseOverrideStageBeforeUsage)
+	if len(operations) == 0 {
+		return nil
+	}
+	if strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream") {
+		resp.Body = &sseOverrideBody{
+			reader:     bufio.NewReader(resp.Body),
+			closer:     resp.Body,
+			operations: operations,
+		}
+		return nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	body = applyResponseOperations(body, operations)
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
+
+type sseOverrideBody struct {
+	reader     *bufio.Reader
+	closer     io.Closer
+	operations []ParamOperation
+	pending    []byte
+	err        error
+}
+
+func (b *sseOverrideBody) Read(p []byte) (int, error) {
+	for len(b.pending) == 0 {
+		if b.err != nil {
+			return 0, b.err
+		}
+		line, err := b.reader.ReadBytes('\n')
+		if len(line) > 0 {
+			b.pending = applyResponseOperationsToLine(line, b.operations)
+		}
+		b.err = err
+	}
+	n := copy(p, b.pending)
+	b.pending = b.pending[n:]
+	return n, nil
+}
+
+func (b *sseOverrideBody) Close() error {
+	return b.closer.Close()
+}
+
+// ResponseOverrideWriter 在解析用量之后改写写给客户端的内容。SSE 按行改写，JSON 响应缓存到 Finish 时一次改写
+type ResponseOverrideWriter struct {
+	gin.ResponseWriter
+	info *RelayInfo
+
+	mu         sync.Mutex
+	decided    bool
+	operations []ParamOperation
+	stream     bool
+	buffer     bytes.Buffer
+}
+
+// NewResponseOverrideWriter 替换 c.Writer，需要在请求结束时调用 Finish 写出缓存并恢复原 Writer。
+// 规则在第一次写入时按当前渠道读取，因此可以在选择渠道之前安装
+func NewResponseOverrideWriter(c *gin.Context, info *RelayInfo) *ResponseOverrideWriter {
+	w := &ResponseOverrideWriter{ResponseWriter: c.Writer, info: info}
+	c.Writer = w
+	return w
+}
+
+func (w *ResponseOverrideWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	status := w.ResponseWriter.Status()
+	if status < 200 || status >= 300 {
+		return
+	}
+	contentType := w.ResponseWriter.Header().Get("Content-Type")
+	switch {
+	case strings.Contains(contentType, "text/event-stream"):
+		w.stream = true
+	case strings.Contains(contentType, "json"):
+	default:
+		return
+	}
+	w.operations = w.info.responseOperations(ResponseOverrideStageAfterUsage)
+}
+
+func (w *ResponseOverrideWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.decide()
+	if len(w.operations) == 0 {
+		return w.ResponseWriter.Write(data)
+	}
+	w.buffer.Write(data)
+	if !w.stream {
+		return len(data), nil
+	}
+	// SSE 只写出完整的行，不完整的部分等待后续数据
+	for {
+		idx := bytes.IndexByte(w.buffer.Bytes(), '\n')
+		if idx < 0 {
+			break
+		}
+		line := applyResponseOperationsToLine(w.buffer.Next(idx+1), w.operations)
+		if _, err := w.ResponseWriter.Write(line); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *ResponseOverrideWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *ResponseOverrideWriter) Flush() {
+	w.mu.Lock()
+	buffering := len(w.operations) > 0 && !w.stream
+	w.mu.Unlock()
+	if !buffering {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// Finish 写出缓存的内容并恢复原 Writer
+func (w *ResponseOverrideWriter) Finish(c *gin.Context) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	c.Writer = w.ResponseWriter
+	if len(w.operations) == 0 || w.buffer.Len() == 0 {
+		return
+	}
+	data := w.buffer.Bytes()
+	if w.stream {
+		data = applyResponseOperationsToLine(data, w.operations)
+	} else {
+		data = applyResponseOperations(data, w.operations)
+		if !w.ResponseWriter.Written() {
+			w.ResponseWriter.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		}
+	}
+	if _, err := w.ResponseWriter.Write(data); err != nil {
+		common.SysError("failed to write overridden response: " + err.Error())
+	}
+	w.buffer.Reset()
+}
@@ -0,0 +1,104 @@
+package common
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newResponseOverrideInfo(t *testing.T, config string) *RelayInfo {
+	t.Helper()
+	override := make(map[string]interface{})
+	if err := common.Unmarshal([]byte(config), &override); err != nil {
+		t.Fatalf("invalid config: %v", err)
+	}
+	return &RelayInfo{
+		OriginModelName: "my-alias",
+		ChannelMeta: &ChannelMeta{
+			UpstreamModelName: "gpt-4o-2024-08-06",
+			ResponseOverride:  override,
+		},
+	}
+}
+
+func TestResponseOverrideBeforeUsage(t *testing.T) {
+	info := newResponseOverrideInfo(t, `{"rules":[
+		{"stage":"before_usage","models":["gpt-4o*"],"operations":[{"path":"provider_extra","mode":"delete"}]},
+		{"stage":"before_usage","models":["claude-*"],"operations":[{"path":"unused","mode":"set","value":1}]}
+	]}`)
+
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"id":"1","provider_extra":{"a":1}}`)),
+	}
+	if err := ApplyResponseOverrideBeforeUsage(resp, info); err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != `{"id":"1"}` {
+		t.Errorf("Unexpected body: %s", body)
+	}
+
+	resp = &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+		Body:       io.NopCloser(strings.NewReader("data: {\"provider_extra\":1,\"n\":1}\n\n: ping\ndata: [DONE]\n")),
+	}
+	if err := ApplyResponseOverrideBeforeUsage(resp, info); err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	if string(body) != "data: {\"n\":1}\n\n: ping\ndata: [DONE]\n" {
+		t.Errorf("Unexpected stream body: %q", body)
+	}
+}
+
+func TestResponseOverrideWriter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config := `{"operations":[
+		{"path":"model","mode":"set","value":"{{origin_model}}"},
+		{"path":"choices.0.finish_reason","mode":"set","value":"stop","conditions":[{"path":"choices.0.finish_reason","mode":"full","value":"end_turn"}]}
+	]}`
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	writer := NewResponseOverrideWriter(c, newResponseOverrideInfo(t, config))
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	_, _ = c.Writer.Write([]byte("data: {\"model\":\"gpt-4o\",\"choices\":[{\"finish_reason\":\"end_"))
+	_, _ = c.Writer.Write([]byte("turn\"}]}\n\ndata: [DONE]"))
+	writer.Finish(c)
+	expected := "data: {\"model\":\"my-alias\",\"choices\":[{\"finish_reason\":\"stop\"}]}\n\ndata: [DONE]"
+	if recorder.Body.String() != expected {
+		t.Errorf("Unexpected stream output: %q", recorder.Body.String())
+	}
+
+	recorder = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(recorder)
+	writer = NewResponseOverrideWriter(c, newResponseOverrideInfo(t, config))
+	c.Writer.Header().Set("Content-Type", "application/json")
+	c.Writer.Header().Set("Content-Length", "17")
+	c.Writer.WriteHeader(http.StatusOK)
+	_, _ = c.Writer.Write([]byte(`{"model":"gpt-4o"}`))
+	writer.Finish(c)
+	if recorder.Body.String() != `{"model":"my-alias"}` || recorder.Header().Get("Content-Length") != "20" {
+		t.Errorf("Unexpected json output: %q %s", recorder.Body.String(), recorder.Header().Get("Content-Length"))
+	}
+	if c.Writer == writer {
+		t.Error("Expected original writer to be restored")
+	}
+}
+
+func TestParseResponseOverrideInvalid(t *testing.T) {
+	if _, err := ParseResponseOverride(map[string]interface{}{"stage": "later", "operations": []interface{}{map[string]interface{}{"path": "a", "mode": "set"}}}); err == nil {
+		t.Error("Expected unknown stage to fail")
+	}
+	if _, err := ParseResponseOverride(map[string]interface{}{"operations": []interface{}{map[string]interface{}{"path": "a", "mode": "rename"}}}); err == nil {
+		t.Error("Expected unknown operation to fail")
+	}
+}
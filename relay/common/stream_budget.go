@@ -0,0 +1,34 @@
+package common
+
+// StreamBudget 流式响应的补全 token 预算，由剩余额度（令牌、用户或组织额度池中较小者）换算得到。
+// 各渠道的流式处理在转发每个分片前累计其 token 数，预算耗尽时停止转发并正常结束响应
+type StreamBudget struct {
+	MaxCompletionTokens int `json:"max_completion_tokens"`
+	CompletionTokens    int `json:"completion_tokens"`
+	exhausted           bool
+}
+
+func NewStreamBudget(maxCompletionTokens int) *StreamBudget {
+	return &StreamBudget{MaxCompletionTokens: max(maxCompletionTokens, 0)}
+}
+
+// Consume 累计即将转发的 token 数，超出预算时返回 false 且不计入，此后的调用均返回 false
+func (b *StreamBudget) Consume(tokens int) bool {
+	if b == nil {
+		return true
+	}
+	if b.exhausted {
+		return false
+	}
+	if b.CompletionTokens+tokens > b.MaxCompletionTokens {
+		b.exhausted = true
+		return false
+	}
+	b.CompletionTokens += tokens
+	return true
+}
+
+// Exhausted 预算是否已耗尽，即响应是否被提前截断
+func (b *StreamBudget) Exhausted() bool {
+	return b != nil && b.exhausted
+}
@@ -46,6 +46,19 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 		return types.NewError(err, types.ErrorCodeChannelModelMappedError, types.ErrOptionWithSkipRetry())
 	}
 
+	passThrough := model_setting.GetGlobalSettings().PassThroughRequestEnabled || info.ChannelSetting.PassThroughBodyEnabled
+
+	// 请求了结构化输出或工具时按设置校验最终内容，校验失败可以修复或换渠道重试
+	validator := service.NewStructuredOutputValidator(c, info, textReq)
+	defer validator.Release()
+
+	// 模型不支持工具调用或结构化输出时改写请求并转换响应，需要在校验之前完成，因此 Writer 装在校验之外
+	var emulator *service.CapabilityEmulator
+	if !passThrough {
+		emulator = service.NewCapabilityEmulator(c, info, request)
+		defer emulator.Release()
+	}
+
 	includeUsage := true
 	// 判断用户是否需要返回使用情况
 	if request.StreamOptions != nil {
@@ -73,7 +86,7 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 	adaptor.Init(info)
 	var requestBody io.Reader
 
-	if model_setting.GetGlobalSettings().PassThroughRequestEnabled || info.ChannelSetting.PassThroughBodyEnabled {
+	if passThrough {
 		body, err := common.GetRequestBody(c)
 		if err != nil {
 			return types.NewErrorWithStatusCode(err, types.ErrorCodeReadRequestBodyFailed, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
@@ -135,7 +148,7 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 
 		// apply param override
 		if len(info.ParamOverride) > 0 {
-			jsonData, err = relaycommon.ApplyParamOverride(jsonData, info.ParamOverride)
+			jsonData, err = relaycommon.ApplyParamOverrideWithTemplates(c, info, jsonData)
 			if err != nil {
 				return types.NewError(err, types.ErrorCodeChannelParamOverrideInvalid, types.ErrOptionWithSkipRetry())
 			}
@@ -171,6 +184,14 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 		service.ResetStatusCode(newApiErr, statusCodeMappingStr)
 		return newApiErr
 	}
+	emulator.Finish()
+	if newApiErr = validator.Finish(); newApiErr != nil {
+		// 校验失败需要重试时，上游已经产生了用量，先按本次用量结算并记录日志。
+		// 预扣费已在结算中抵扣，重试时不再重复抵扣，失败后也不再返还
+		postConsumeQuota(c, info, usage.(*dto.Usage), "结构化输出校验失败，已重试")
+		info.FinalPreConsumedQuota = 0
+		return newApiErr
+	}
 
 	if strings.HasPrefix(info.OriginModelName, "gpt-4o-audio") {
 		service.PostAudioConsumeQuota(c, info, usage.(*dto.Usage), "")
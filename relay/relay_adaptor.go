@@ -13,6 +13,7 @@ import (
 	"one-api/relay/channel/cloudflare"
 	"one-api/relay/channel/cohere"
 	"one-api/relay/channel/coze"
+	"one-api/relay/channel/declarative"
 	"one-api/relay/channel/deepseek"
 	"one-api/relay/channel/dify"
 	"one-api/relay/channel/gemini"
@@ -112,6 +113,8 @@ func GetAdaptor(apiType int) channel.Adaptor {
 		return &wavespeed.Adaptor{}
 	case constant.APITypeAi302:
 		return &openai.Adaptor{} // 302.ai uses OpenAI-compatible API
+	case constant.APITypeDeclarative:
+		return &declarative.Adaptor{}
 	case constant.APITypeMoonshot:
 		return &moonshot.Adaptor{} // Moonshot uses Claude API
 	}
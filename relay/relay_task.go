@@ -88,7 +88,7 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (taskErr *dto.
 	} else {
 		ratio = modelPrice * groupRatio
 	}
-	userQuota, err := model.GetUserQuota(info.UserId, false)
+	userQuota, err := service.GetBillingQuota(info)
 	if err != nil {
 		taskErr = service.TaskErrorWrapper(err, "get_user_quota_failed", http.StatusInternalServerError)
 		return
@@ -118,9 +118,13 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (taskErr *dto.
 			if channel.Status != common.ChannelStatusEnabled {
 				return service.TaskErrorWrapperLocal(errors.New("该任务所属渠道已被禁用"), "task_channel_disable", http.StatusBadRequest)
 			}
+			key, err := channel.ResolveKey()
+			if err != nil {
+				return service.TaskErrorWrapperLocal(err, "channel_key_resolve_failed", http.StatusInternalServerError)
+			}
 			c.Set("base_url", channel.GetBaseURL())
 			c.Set("channel_id", originTask.ChannelId)
-			c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", channel.Key))
+			c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", key))
 
 			info.ChannelBaseUrl = channel.GetBaseURL()
 			info.ChannelId = originTask.ChannelId
@@ -325,7 +329,11 @@ func videoFetchByIDRespBodyBuilder(c *gin.Context) (respBody []byte, taskResp *d
 		if adaptor == nil {
 			return
 		}
-		resp, err2 := adaptor.FetchTask(baseURL, channelModel.Key, map[string]any{
+		key, err2 := channelModel.ResolveKey()
+		if err2 != nil {
+			return
+		}
+		resp, err2 := adaptor.FetchTask(baseURL, key, map[string]any{
 			"task_id": originTask.TaskID,
 			"action":  originTask.Action,
 		})
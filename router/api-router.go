@@ -3,6 +3,7 @@ package router
 import (
 	"one-api/controller"
 	"one-api/middleware"
+	"one-api/model"
 
 	"github.com/gin-contrib/gzip"
 	"github.com/gin-gonic/gin"
@@ -18,7 +19,7 @@ func SetApiRouter(router *gin.Engine) {
 		apiRouter.GET("/status", controller.GetStatus)
 		apiRouter.GET("/uptime/status", controller.GetUptimeKumaStatus)
 		apiRouter.GET("/models", middleware.UserAuth(), controller.DashboardListModels)
-		apiRouter.GET("/status/test", middleware.AdminAuth(), controller.TestStatus)
+		apiRouter.GET("/status/test", middleware.RequirePermission(model.PermissionChannelsRead), controller.TestStatus)
 		apiRouter.GET("/notice", controller.GetNotice)
 		apiRouter.GET("/about", controller.GetAbout)
 		//apiRouter.GET("/midjourney", controller.GetMidjourney)
@@ -36,6 +37,7 @@ func SetApiRouter(router *gin.Engine) {
 		apiRouter.GET("/oauth/email/bind", middleware.CriticalRateLimit(), controller.EmailBind)
 		apiRouter.GET("/oauth/telegram/login", middleware.CriticalRateLimit(), controller.TelegramLogin)
 		apiRouter.GET("/oauth/telegram/bind", middleware.CriticalRateLimit(), controller.TelegramBind)
+		apiRouter.GET("/oauth/provider/:provider", middleware.CriticalRateLimit(), controller.OAuthProviderAuth)
 		apiRouter.GET("/ratio_config", middleware.CriticalRateLimit(), controller.GetRatioConfig)
 
 		apiRouter.POST("/stripe/webhook", controller.StripeWebhook)
@@ -44,7 +46,12 @@ func SetApiRouter(router *gin.Engine) {
 		{
 			userRoute.POST("/register", middleware.CriticalRateLimit(), middleware.TurnstileCheck(), controller.Register)
 			userRoute.POST("/login", middleware.CriticalRateLimit(), middleware.TurnstileCheck(), controller.Login)
+			userRoute.POST("/login/ldap", middleware.CriticalRateLimit(), middleware.TurnstileCheck(), controller.LdapLogin)
 			userRoute.POST("/login/2fa", middleware.CriticalRateLimit(), controller.Verify2FALogin)
+			userRoute.POST("/login/2fa/passkey/begin", middleware.CriticalRateLimit(), controller.Verify2FAPasskeyBegin)
+			userRoute.POST("/login/2fa/passkey/finish", middleware.CriticalRateLimit(), controller.Verify2FAPasskeyFinish)
+			userRoute.POST("/login/passkey/begin", middleware.CriticalRateLimit(), controller.PasskeyLoginBegin)
+			userRoute.POST("/login/passkey/finish", middleware.CriticalRateLimit(), controller.PasskeyLoginFinish)
 			//userRoute.POST("/tokenlog", middleware.CriticalRateLimit(), controller.TokenLog)
 			userRoute.GET("/logout", controller.Logout)
 			userRoute.GET("/epay/notify", controller.EpayNotify)
@@ -59,6 +66,13 @@ func SetApiRouter(router *gin.Engine) {
 				selfRoute.PUT("/self", controller.UpdateSelf)
 				selfRoute.DELETE("/self", controller.DeleteSelf)
 				selfRoute.GET("/token", controller.GenerateAccessToken)
+				selfRoute.PUT("/token/role", controller.BindSelfAccessTokenRole)
+				selfRoute.GET("/permissions", controller.GetSelfPermissions)
+				selfRoute.GET("/self/access_tokens", controller.GetSelfPersonalAccessTokens)
+				selfRoute.POST("/self/access_tokens", middleware.CriticalRateLimit(), controller.CreatePersonalAccessToken)
+				selfRoute.DELETE("/self/access_tokens/:id", controller.RevokeSelfPersonalAccessToken)
+				selfRoute.GET("/self/identities", controller.GetSelfIdentities)
+				selfRoute.DELETE("/self/identities/:provider", controller.UnbindSelfIdentity)
 				selfRoute.GET("/aff", controller.GetAffCode)
 				selfRoute.GET("/topup/info", controller.GetTopUpInfo)
 				selfRoute.POST("/topup", middleware.CriticalRateLimit(), controller.TopUp)
@@ -75,66 +89,88 @@ func SetApiRouter(router *gin.Engine) {
 				selfRoute.POST("/2fa/enable", controller.Enable2FA)
 				selfRoute.POST("/2fa/disable", controller.Disable2FA)
 				selfRoute.POST("/2fa/backup_codes", controller.RegenerateBackupCodes)
+
+				// Passkey routes
+				selfRoute.GET("/passkeys", controller.GetSelfPasskeys)
+				selfRoute.POST("/passkeys/register/begin", controller.PasskeyRegisterBegin)
+				selfRoute.POST("/passkeys/register/finish", controller.PasskeyRegisterFinish)
+				selfRoute.PUT("/passkeys/:id", controller.RenameSelfPasskey)
+				selfRoute.DELETE("/passkeys/:id", controller.DeleteSelfPasskey)
 			}
 
 			adminRoute := userRoute.Group("/")
-			adminRoute.Use(middleware.AdminAuth())
 			{
-				adminRoute.GET("/", controller.GetAllUsers)
-				adminRoute.GET("/search", controller.SearchUsers)
-				adminRoute.GET("/:id", controller.GetUser)
-				adminRoute.POST("/", controller.CreateUser)
-				adminRoute.POST("/manage", controller.ManageUser)
-				adminRoute.PUT("/", controller.UpdateUser)
-				adminRoute.DELETE("/:id", controller.DeleteUser)
+				adminRoute.GET("/", middleware.RequirePermission(model.PermissionUsersRead), controller.GetAllUsers)
+				adminRoute.GET("/search", middleware.RequirePermission(model.PermissionUsersRead), controller.SearchUsers)
+				adminRoute.GET("/:id", middleware.RequirePermission(model.PermissionUsersRead), controller.GetUser)
+				adminRoute.POST("/", middleware.RequirePermission(model.PermissionUsersManage), controller.CreateUser)
+				adminRoute.POST("/manage", middleware.RequirePermission(model.PermissionUsersManage), controller.ManageUser)
+				adminRoute.PUT("/", middleware.RequirePermission(model.PermissionUsersManage), controller.UpdateUser)
+				adminRoute.DELETE("/:id", middleware.RequirePermission(model.PermissionUsersManage), controller.DeleteUser)
+				adminRoute.GET("/access_tokens", middleware.RequirePermission(model.PermissionUsersRead), controller.GetAllPersonalAccessTokens)
+				adminRoute.DELETE("/access_tokens/:id", middleware.RequirePermission(model.PermissionUsersManage), controller.RevokePersonalAccessToken)
 
 				// Admin 2FA routes
-				adminRoute.GET("/2fa/stats", controller.Admin2FAStats)
-				adminRoute.DELETE("/:id/2fa", controller.AdminDisable2FA)
+				adminRoute.GET("/2fa/stats", middleware.RequirePermission(model.PermissionUsersRead), controller.Admin2FAStats)
+				adminRoute.DELETE("/:id/2fa", middleware.RequirePermission(model.PermissionUsersManage), controller.AdminDisable2FA)
+				adminRoute.GET("/passkey/stats", middleware.RequirePermission(model.PermissionUsersRead), controller.AdminPasskeyStats)
+				adminRoute.DELETE("/:id/passkeys", middleware.RequirePermission(model.PermissionUsersManage), controller.AdminDeletePasskeys)
 			}
 		}
 		optionRoute := apiRouter.Group("/option")
-		optionRoute.Use(middleware.RootAuth())
+		optionRoute.Use(middleware.RequirePermission(model.PermissionOptionsWrite))
 		{
 			optionRoute.GET("/", controller.GetOptions)
 			optionRoute.PUT("/", controller.UpdateOption)
 			optionRoute.POST("/rest_model_ratio", controller.ResetModelRatio)
+			optionRoute.POST("/scim_token", controller.GenerateScimToken)
+			optionRoute.GET("/secret_encryption", controller.GetSecretEncryptionStatus)
+			optionRoute.POST("/secret_encryption/rotate", middleware.CriticalRateLimit(), controller.RotateSecretMasterKey)
 			optionRoute.POST("/migrate_console_setting", controller.MigrateConsoleSetting) // 用于迁移检测的旧键，下个版本会删除
 		}
 		ratioSyncRoute := apiRouter.Group("/ratio_sync")
-		ratioSyncRoute.Use(middleware.RootAuth())
+		ratioSyncRoute.Use(middleware.RequirePermission(model.PermissionOptionsWrite))
 		{
 			ratioSyncRoute.GET("/channels", controller.GetSyncableChannels)
 			ratioSyncRoute.POST("/fetch", controller.FetchUpstreamRatios)
 		}
+		roleRoute := apiRouter.Group("/role")
+		roleRoute.Use(middleware.RequirePermission(model.PermissionRolesManage))
+		{
+			roleRoute.GET("/", controller.GetAllRoles)
+			roleRoute.GET("/permissions", controller.GetAllPermissions)
+			roleRoute.POST("/", controller.AddRole)
+			roleRoute.PUT("/", controller.UpdateRole)
+			roleRoute.DELETE("/:id", controller.DeleteRole)
+			roleRoute.POST("/bind", controller.BindUserRole)
+		}
 		channelRoute := apiRouter.Group("/channel")
-		channelRoute.Use(middleware.AdminAuth())
-		{
-			channelRoute.GET("/", controller.GetAllChannels)
-			channelRoute.GET("/search", controller.SearchChannels)
-			channelRoute.GET("/models", controller.ChannelListModels)
-			channelRoute.GET("/models_enabled", controller.EnabledListModels)
-			channelRoute.GET("/:id", controller.GetChannel)
-			channelRoute.POST("/:id/key", middleware.CriticalRateLimit(), middleware.DisableCache(), controller.GetChannelKey)
-			channelRoute.GET("/test", controller.TestAllChannels)
-			channelRoute.GET("/test/:id", controller.TestChannel)
-			channelRoute.GET("/update_balance", controller.UpdateAllChannelsBalance)
-			channelRoute.GET("/update_balance/:id", controller.UpdateChannelBalance)
-			channelRoute.POST("/", controller.AddChannel)
-			channelRoute.PUT("/", controller.UpdateChannel)
-			channelRoute.DELETE("/disabled", controller.DeleteDisabledChannel)
-			channelRoute.POST("/tag/disabled", controller.DisableTagChannels)
-			channelRoute.POST("/tag/enabled", controller.EnableTagChannels)
-			channelRoute.PUT("/tag", controller.EditTagChannels)
-			channelRoute.DELETE("/:id", controller.DeleteChannel)
-			channelRoute.POST("/batch", controller.DeleteChannelBatch)
-			channelRoute.POST("/fix", controller.FixChannelsAbilities)
-			channelRoute.GET("/fetch_models/:id", controller.FetchUpstreamModels)
-			channelRoute.POST("/fetch_models", controller.FetchModels)
-			channelRoute.POST("/batch/tag", controller.BatchSetChannelTag)
-			channelRoute.GET("/tag/models", controller.GetTagModels)
-			channelRoute.POST("/copy/:id", controller.CopyChannel)
-			channelRoute.POST("/multi_key/manage", controller.ManageMultiKeys)
+		{
+			channelRoute.GET("/", middleware.RequirePermission(model.PermissionChannelsRead), controller.GetAllChannels)
+			channelRoute.GET("/search", middleware.RequirePermission(model.PermissionChannelsRead), controller.SearchChannels)
+			channelRoute.GET("/models", middleware.RequirePermission(model.PermissionChannelsRead), controller.ChannelListModels)
+			channelRoute.GET("/models_enabled", middleware.RequirePermission(model.PermissionChannelsRead), controller.EnabledListModels)
+			channelRoute.GET("/:id", middleware.RequirePermission(model.PermissionChannelsRead), controller.GetChannel)
+			channelRoute.POST("/:id/key", middleware.RequirePermission(model.PermissionChannelsKeyRead), middleware.CriticalRateLimit(), middleware.DisableCache(), controller.GetChannelKey)
+			channelRoute.GET("/test", middleware.RequirePermission(model.PermissionChannelsWrite), controller.TestAllChannels)
+			channelRoute.GET("/test/:id", middleware.RequirePermission(model.PermissionChannelsWrite), controller.TestChannel)
+			channelRoute.GET("/update_balance", middleware.RequirePermission(model.PermissionChannelsWrite), controller.UpdateAllChannelsBalance)
+			channelRoute.GET("/update_balance/:id", middleware.RequirePermission(model.PermissionChannelsWrite), controller.UpdateChannelBalance)
+			channelRoute.POST("/", middleware.RequirePermission(model.PermissionChannelsWrite), controller.AddChannel)
+			channelRoute.PUT("/", middleware.RequirePermission(model.PermissionChannelsWrite), controller.UpdateChannel)
+			channelRoute.DELETE("/disabled", middleware.RequirePermission(model.PermissionChannelsWrite), controller.DeleteDisabledChannel)
+			channelRoute.POST("/tag/disabled", middleware.RequirePermission(model.PermissionChannelsWrite), controller.DisableTagChannels)
+			channelRoute.POST("/tag/enabled", middleware.RequirePermission(model.PermissionChannelsWrite), controller.EnableTagChannels)
+			channelRoute.PUT("/tag", middleware.RequirePermission(model.PermissionChannelsWrite), controller.EditTagChannels)
+			channelRoute.DELETE("/:id", middleware.RequirePermission(model.PermissionChannelsWrite), controller.DeleteChannel)
+			channelRoute.POST("/batch", middleware.RequirePermission(model.PermissionChannelsWrite), controller.DeleteChannelBatch)
+			channelRoute.POST("/fix", middleware.RequirePermission(model.PermissionChannelsWrite), controller.FixChannelsAbilities)
+			channelRoute.GET("/fetch_models/:id", middleware.RequirePermission(model.PermissionChannelsRead), controller.FetchUpstreamModels)
+			channelRoute.POST("/fetch_models", middleware.RequirePermission(model.PermissionChannelsWrite), controller.FetchModels)
+			channelRoute.POST("/batch/tag", middleware.RequirePermission(model.PermissionChannelsWrite), controller.BatchSetChannelTag)
+			channelRoute.GET("/tag/models", middleware.RequirePermission(model.PermissionChannelsRead), controller.GetTagModels)
+			channelRoute.POST("/copy/:id", middleware.RequirePermission(model.PermissionChannelsWrite), controller.CopyChannel)
+			channelRoute.POST("/multi_key/manage", middleware.RequirePermission(model.PermissionChannelsWrite), controller.ManageMultiKeys)
 		}
 		tokenRoute := apiRouter.Group("/token")
 		tokenRoute.Use(middleware.UserAuth())
@@ -159,7 +195,7 @@ func SetApiRouter(router *gin.Engine) {
 		}
 
 		redemptionRoute := apiRouter.Group("/redemption")
-		redemptionRoute.Use(middleware.AdminAuth())
+		redemptionRoute.Use(middleware.RequirePermission(model.PermissionRedemptionsManage))
 		{
 			redemptionRoute.GET("/", controller.GetAllRedemptions)
 			redemptionRoute.GET("/search", controller.SearchRedemptions)
@@ -170,30 +206,93 @@ func SetApiRouter(router *gin.Engine) {
 			redemptionRoute.DELETE("/:id", controller.DeleteRedemption)
 		}
 		logRoute := apiRouter.Group("/log")
-		logRoute.GET("/", middleware.AdminAuth(), controller.GetAllLogs)
-		logRoute.DELETE("/", middleware.AdminAuth(), controller.DeleteHistoryLogs)
-		logRoute.GET("/stat", middleware.AdminAuth(), controller.GetLogsStat)
+		logRoute.GET("/", middleware.RequirePermission(model.PermissionLogsRead), controller.GetAllLogs)
+		logRoute.DELETE("/", middleware.RequirePermission(model.PermissionLogsDelete), controller.DeleteHistoryLogs)
+		logRoute.GET("/stat", middleware.RequirePermission(model.PermissionLogsRead), controller.GetLogsStat)
+		logRoute.GET("/sinks", middleware.RequirePermission(model.PermissionLogsRead), controller.GetLogSinkStats)
+		logRoute.GET("/capture", middleware.RequirePermission(model.PermissionLogsBodyRead), controller.GetBodyCaptures)
+		logRoute.GET("/capture/:id", middleware.RequirePermission(model.PermissionLogsBodyRead), controller.GetBodyCapture)
 		logRoute.GET("/self/stat", middleware.UserAuth(), controller.GetLogsSelfStat)
-		logRoute.GET("/search", middleware.AdminAuth(), controller.SearchAllLogs)
+		logRoute.GET("/search", middleware.RequirePermission(model.PermissionLogsRead), controller.SearchAllLogs)
 		logRoute.GET("/self", middleware.UserAuth(), controller.GetUserLogs)
 		logRoute.GET("/self/search", middleware.UserAuth(), controller.SearchUserLogs)
 
+		auditRoute := apiRouter.Group("/audit")
+		auditRoute.Use(middleware.RequirePermission(model.PermissionAuditRead))
+		{
+			auditRoute.GET("/", controller.GetAuditLogs)
+			auditRoute.GET("/export", controller.ExportAuditLogs)
+		}
+
+		analyticsRoute := apiRouter.Group("/analytics")
+		{
+			analyticsRoute.GET("/self", middleware.UserAuth(), controller.GetUserUsageAnalytics)
+			analyticsRoute.GET("/self/export", middleware.UserAuth(), middleware.CriticalRateLimit(), controller.ExportUserUsageAnalytics)
+			analyticsRoute.GET("/", middleware.RequirePermission(model.PermissionAnalyticsRead), controller.GetUsageAnalytics)
+			analyticsRoute.GET("/export", middleware.RequirePermission(model.PermissionAnalyticsRead), controller.ExportUsageAnalytics)
+		}
+
+		statementRoute := apiRouter.Group("/statement")
+		{
+			statementRoute.GET("/self", middleware.UserAuth(), controller.GetUserStatements)
+			statementRoute.POST("/self", middleware.UserAuth(), middleware.CriticalRateLimit(), controller.CreateUserStatement)
+			statementRoute.GET("/self/:id", middleware.UserAuth(), controller.GetUserStatement)
+			statementRoute.GET("/self/:id/export", middleware.UserAuth(), controller.ExportUserStatement)
+			statementRoute.GET("/", middleware.RequirePermission(model.PermissionStatementsManage), controller.GetAllStatements)
+			statementRoute.POST("/", middleware.RequirePermission(model.PermissionStatementsManage), controller.CreateStatement)
+			statementRoute.GET("/:id", middleware.RequirePermission(model.PermissionStatementsManage), controller.GetStatement)
+			statementRoute.GET("/:id/export", middleware.RequirePermission(model.PermissionStatementsManage), controller.ExportStatement)
+			statementRoute.DELETE("/:id", middleware.RequirePermission(model.PermissionStatementsManage), controller.DeleteStatement)
+		}
+
+		orgRoute := apiRouter.Group("/org")
+		{
+			orgRoute.GET("/self", middleware.UserAuth(), controller.GetSelfOrganizations)
+			orgRoute.POST("/", middleware.UserAuth(), controller.CreateOrganization)
+			orgRoute.GET("/", middleware.RequirePermission(model.PermissionBillingManage), controller.GetAllOrganizations)
+			orgRoute.PUT("/manage", middleware.RequirePermission(model.PermissionBillingManage), controller.ManageOrganization)
+			orgRoute.GET("/:id", middleware.UserAuth(), controller.GetOrganization)
+			orgRoute.PUT("/:id", middleware.UserAuth(), controller.UpdateOrganization)
+			orgRoute.DELETE("/:id", middleware.UserAuth(), controller.DeleteOrganization)
+			orgRoute.GET("/:id/member", middleware.UserAuth(), controller.GetOrganizationMembers)
+			orgRoute.POST("/:id/member", middleware.UserAuth(), controller.AddOrganizationMember)
+			orgRoute.PUT("/:id/member", middleware.UserAuth(), controller.UpdateOrganizationMember)
+			orgRoute.DELETE("/:id/member/:user_id", middleware.UserAuth(), controller.RemoveOrganizationMember)
+			orgRoute.POST("/:id/quota", middleware.UserAuth(), middleware.CriticalRateLimit(), controller.TransferOrganizationQuota)
+			orgRoute.GET("/:id/token", middleware.UserAuth(), controller.GetOrganizationTokens)
+			orgRoute.GET("/:id/log", middleware.UserAuth(), controller.GetOrganizationLogs)
+		}
+
+		subscriptionRoute := apiRouter.Group("/subscription")
+		{
+			subscriptionRoute.GET("/plans", middleware.UserAuth(), controller.GetSubscriptionPlans)
+			subscriptionRoute.GET("/self", middleware.UserAuth(), controller.GetSelfSubscriptions)
+			subscriptionRoute.POST("/checkout", middleware.UserAuth(), middleware.CriticalRateLimit(), controller.RequestSubscriptionCheckout)
+			subscriptionRoute.POST("/portal", middleware.UserAuth(), middleware.CriticalRateLimit(), controller.RequestSubscriptionPortal)
+			subscriptionRoute.GET("/", middleware.RequirePermission(model.PermissionBillingManage), controller.GetAllUserSubscriptions)
+			subscriptionRoute.GET("/plan", middleware.RequirePermission(model.PermissionBillingManage), controller.GetAllSubscriptionPlans)
+			subscriptionRoute.POST("/plan", middleware.RequirePermission(model.PermissionBillingManage), controller.AddSubscriptionPlan)
+			subscriptionRoute.PUT("/plan", middleware.RequirePermission(model.PermissionBillingManage), controller.UpdateSubscriptionPlan)
+			subscriptionRoute.DELETE("/plan/:id", middleware.RequirePermission(model.PermissionBillingManage), controller.DeleteSubscriptionPlan)
+		}
+
 		dataRoute := apiRouter.Group("/data")
-		dataRoute.GET("/", middleware.AdminAuth(), controller.GetAllQuotaDates)
+		dataRoute.GET("/", middleware.RequirePermission(model.PermissionAnalyticsRead), controller.GetAllQuotaDates)
 		dataRoute.GET("/self", middleware.UserAuth(), controller.GetUserQuotaDates)
+		dataRoute.GET("/postpaid", middleware.RequirePermission(model.PermissionAnalyticsRead), controller.GetPostpaidAccounts)
 
 		logRoute.Use(middleware.CORS())
 		{
 			logRoute.GET("/token", controller.GetLogByKey)
 		}
 		groupRoute := apiRouter.Group("/group")
-		groupRoute.Use(middleware.AdminAuth())
+		groupRoute.Use(middleware.RequirePermission(model.PermissionChannelsRead))
 		{
 			groupRoute.GET("/", controller.GetGroups)
 		}
 
 		prefillGroupRoute := apiRouter.Group("/prefill_group")
-		prefillGroupRoute.Use(middleware.AdminAuth())
+		prefillGroupRoute.Use(middleware.RequirePermission(model.PermissionModelsManage))
 		{
 			prefillGroupRoute.GET("/", controller.GetPrefillGroups)
 			prefillGroupRoute.POST("/", controller.CreatePrefillGroup)
@@ -203,16 +302,16 @@ func SetApiRouter(router *gin.Engine) {
 
 		mjRoute := apiRouter.Group("/mj")
 		mjRoute.GET("/self", middleware.UserAuth(), controller.GetUserMidjourney)
-		mjRoute.GET("/", middleware.AdminAuth(), controller.GetAllMidjourney)
+		mjRoute.GET("/", middleware.RequirePermission(model.PermissionTasksRead), controller.GetAllMidjourney)
 
 		taskRoute := apiRouter.Group("/task")
 		{
 			taskRoute.GET("/self", middleware.UserAuth(), controller.GetUserTask)
-			taskRoute.GET("/", middleware.AdminAuth(), controller.GetAllTask)
+			taskRoute.GET("/", middleware.RequirePermission(model.PermissionTasksRead), controller.GetAllTask)
 		}
 
 		vendorRoute := apiRouter.Group("/vendors")
-		vendorRoute.Use(middleware.AdminAuth())
+		vendorRoute.Use(middleware.RequirePermission(model.PermissionModelsManage))
 		{
 			vendorRoute.GET("/", controller.GetAllVendors)
 			vendorRoute.GET("/search", controller.SearchVendors)
@@ -223,7 +322,7 @@ func SetApiRouter(router *gin.Engine) {
 		}
 
 		modelsRoute := apiRouter.Group("/models")
-		modelsRoute.Use(middleware.AdminAuth())
+		modelsRoute.Use(middleware.RequirePermission(model.PermissionModelsManage))
 		{
 			modelsRoute.GET("/sync_upstream/preview", controller.SyncUpstreamPreview)
 			modelsRoute.POST("/sync_upstream", controller.SyncUpstreamModels)
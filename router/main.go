@@ -19,6 +19,7 @@ func SetRouter(router *gin.Engine, buildFS embed.FS, indexPage []byte) {
 	SetRelayRouter(router)
 	SetVideoRouter(router)
 	SetAsyncImageRouter(router)
+	SetScimRouter(router)
 
 	// 添加重定向路由处理编码的链接
 	router.GET("/redirect/:encoded", controller.RedirectHandler)
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+)
+
+// GetBillingQuota 返回本次请求可用的额度：组织令牌使用组织额度池（受成员上限约束），个人令牌使用用户额度，
+// 后付费用户的可用额度为余额加信用额度
+func GetBillingQuota(relayInfo *relaycommon.RelayInfo) (int, error) {
+	if relayInfo.OrgId != 0 {
+		return model.GetOrganizationBillingQuota(relayInfo.OrgId, relayInfo.UserId)
+	}
+	quota, err := model.GetUserQuota(relayInfo.UserId, false)
+	if err != nil {
+		return 0, err
+	}
+	return quota + relayInfo.UserCreditLimit, nil
+}
+
+func decreaseBillingQuota(relayInfo *relaycommon.RelayInfo, quota int) error {
+	if relayInfo.OrgId != 0 {
+		return model.DecreaseOrganizationQuota(relayInfo.OrgId, relayInfo.UserId, quota)
+	}
+	return model.DecreaseUserQuota(relayInfo.UserId, quota)
+}
+
+func increaseBillingQuota(relayInfo *relaycommon.RelayInfo, quota int) error {
+	if relayInfo.OrgId != 0 {
+		return model.DecreaseOrganizationQuota(relayInfo.OrgId, relayInfo.UserId, -quota)
+	}
+	return model.IncreaseUserQuota(relayInfo.UserId, quota, false)
+}
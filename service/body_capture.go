@@ -0,0 +1,307 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
+	"strings"
+	"sync"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+const redactedValue = "***"
+
+// 需要脱敏的请求头（小写）
+var sensitiveHeaders = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"x-api-key":           true,
+	"api-key":             true,
+	"x-goog-api-key":      true,
+	"cookie":              true,
+	"set-cookie":          true,
+}
+
+// 需要脱敏的 URL 查询参数
+var sensitiveQueryParams = []string{"key", "api_key", "apikey", "token", "access_token", "signature"}
+
+// ShouldCaptureBody 判断本次请求是否需要捕获请求/响应体
+// 结果在首次判断后缓存到上下文中，重试时保持一致
+func ShouldCaptureBody(c *gin.Context, info *relaycommon.RelayInfo) bool {
+	setting := operation_setting.GetBodyCaptureSetting()
+	if !setting.Enabled || len(setting.Rules) == 0 {
+		return false
+	}
+	// 已被采样的请求在重试时继续捕获
+	if common.GetContextKeyBool(c, constant.ContextKeyBodyCapture) {
+		return true
+	}
+	for _, rule := range setting.Rules {
+		if rule.UserId != 0 && rule.UserId != info.UserId {
+			continue
+		}
+		if rule.TokenId != 0 && rule.TokenId != info.TokenId {
+			continue
+		}
+		if rule.ChannelId != 0 && (info.ChannelMeta == nil || rule.ChannelId != info.ChannelId) {
+			continue
+		}
+		if rule.ModelName != "" && rule.ModelName != info.OriginModelName {
+			continue
+		}
+		if rule.SampleRate >= 1 || rand.Float64() < rule.SampleRate {
+			common.SetContextKey(c, constant.ContextKeyBodyCapture, true)
+			return true
+		}
+	}
+	return false
+}
+
+// BodyCapture 一次上游请求的捕获状态
+type BodyCapture struct {
+	record   *model.BodyCapture
+	secrets  []string
+	maxBytes int
+	client   []byte
+	upstream []byte
+
+	mu        sync.Mutex
+	response  bytes.Buffer
+	truncated bool
+	saved     bool
+}
+
+// StartBodyCapture 在上游请求发出前记录客户端请求与实际发往上游的请求
+func StartBodyCapture(c *gin.Context, info *relaycommon.RelayInfo, req *http.Request, upstreamBody []byte) *BodyCapture {
+	setting := operation_setting.GetBodyCaptureSetting()
+	maxBytes := setting.MaxBodyBytes
+	if maxBytes <= 0 {
+		maxBytes = 1024 * 1024
+	}
+	clientBody, _ := common.GetRequestBody(c)
+	capture := &BodyCapture{
+		maxBytes: maxBytes,
+		record: &model.BodyCapture{
+			RequestId:       c.GetString(common.RequestIdKey),
+			UserId:          info.UserId,
+			TokenId:         info.TokenId,
+			ModelName:       info.OriginModelName,
+			IsStream:        info.IsStream,
+			UpstreamURL:     redactURL(req.URL),
+			ClientHeaders:   redactHeaders(c.Request.Header),
+			UpstreamHeaders: redactHeaders(req.Header),
+		},
+	}
+	if info.ChannelMeta != nil {
+		capture.record.ChannelId = info.ChannelId
+		capture.record.UpstreamModelName = info.UpstreamModelName
+		if info.ApiKey != "" {
+			capture.secrets = append(capture.secrets, info.ApiKey)
+		}
+	}
+	capture.client = capture.limit(clientBody)
+	capture.upstream = capture.limit(upstreamBody)
+	return capture
+}
+
+func (capture *BodyCapture) limit(data []byte) []byte {
+	if len(data) > capture.maxBytes {
+		capture.truncated = true
+		return data[:capture.maxBytes]
+	}
+	return data
+}
+
+// WrapResponse 包装上游响应体，读取时同步记录，关闭时保存
+func (capture *BodyCapture) WrapResponse(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		capture.save()
+		return
+	}
+	capture.record.StatusCode = resp.StatusCode
+	capture.record.IsStream = capture.record.IsStream || strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream")
+	resp.Body = &captureReadCloser{ReadCloser: resp.Body, capture: capture}
+}
+
+func (capture *BodyCapture) write(p []byte) {
+	capture.mu.Lock()
+	defer capture.mu.Unlock()
+	remain := capture.maxBytes - capture.response.Len()
+	if remain <= 0 {
+		capture.truncated = true
+		return
+	}
+	if len(p) > remain {
+		p = p[:remain]
+		capture.truncated = true
+	}
+	capture.response.Write(p)
+}
+
+func (capture *BodyCapture) save() {
+	capture.mu.Lock()
+	if capture.saved {
+		capture.mu.Unlock()
+		return
+	}
+	capture.saved = true
+	response := capture.response.Bytes()
+	record := capture.record
+	record.Truncated = capture.truncated
+	capture.mu.Unlock()
+
+	gopool.Go(func() {
+		record.ClientRequest = compressBody(capture.redact(capture.client))
+		record.UpstreamRequest = compressBody(capture.redact(capture.upstream))
+		record.UpstreamResponse = compressBody(capture.redact(response))
+		if record.IsStream {
+			record.AssembledResponse = compressBody([]byte(AssembleStreamResponse(response)))
+		}
+		if err := record.Insert(); err != nil {
+			common.SysError("failed to save body capture: " + err.Error())
+		}
+	})
+}
+
+// redact 将渠道密钥等敏感值替换为占位符
+func (capture *BodyCapture) redact(data []byte) []byte {
+	for _, secret := range capture.secrets {
+		for _, part := range strings.Split(secret, "\n") {
+			if part = strings.TrimSpace(part); len(part) >= 8 {
+				data = bytes.ReplaceAll(data, []byte(part), []byte(redactedValue))
+			}
+		}
+	}
+	return data
+}
+
+type captureReadCloser struct {
+	io.ReadCloser
+	capture *BodyCapture
+}
+
+func (r *captureReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.capture.write(p[:n])
+	}
+	if err == io.EOF {
+		r.capture.save()
+	}
+	return n, err
+}
+
+func (r *captureReadCloser) Close() error {
+	r.capture.save()
+	return r.ReadCloser.Close()
+}
+
+func redactHeaders(header http.Header) string {
+	redacted := make(map[string]string, len(header))
+	for key, values := range header {
+		if sensitiveHeaders[strings.ToLower(key)] {
+			redacted[key] = redactedValue
+			continue
+		}
+		redacted[key] = strings.Join(values, ", ")
+	}
+	data, _ := common.Marshal(redacted)
+	return string(data)
+}
+
+func redactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	copied := *u
+	query := copied.Query()
+	for _, param := range sensitiveQueryParams {
+		if query.Has(param) {
+			query.Set(param, redactedValue)
+		}
+	}
+	copied.RawQuery = query.Encode()
+	copied.User = nil
+	return copied.String()
+}
+
+func compressBody(data []byte) []byte {
+	if len(data) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	_, _ = writer.Write(data)
+	_ = writer.Close()
+	return buf.Bytes()
+}
+
+// DecompressBody 解压捕获的 body
+func DecompressBody(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return ""
+	}
+	defer reader.Close()
+	result, _ := io.ReadAll(reader)
+	return string(result)
+}
+
+// AssembleStreamResponse 将 SSE 流拼接为完整的回复文本
+// 支持 OpenAI、Claude 与 Gemini 的流式格式
+func AssembleStreamResponse(data []byte) string {
+	var content strings.Builder
+	var reasoning strings.Builder
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if payload == "" || payload == "[DONE]" || !gjson.Valid(payload) {
+			continue
+		}
+		chunk := gjson.Parse(payload)
+		// OpenAI
+		chunk.Get("choices").ForEach(func(_, choice gjson.Result) bool {
+			content.WriteString(choice.Get("delta.content").String())
+			reasoning.WriteString(choice.Get("delta.reasoning_content").String())
+			return true
+		})
+		// Claude
+		if chunk.Get("type").String() == "content_block_delta" {
+			content.WriteString(chunk.Get("delta.text").String())
+			reasoning.WriteString(chunk.Get("delta.thinking").String())
+		}
+		// Gemini
+		chunk.Get("candidates.0.content.parts").ForEach(func(_, part gjson.Result) bool {
+			if part.Get("thought").Bool() {
+				reasoning.WriteString(part.Get("text").String())
+			} else {
+				content.WriteString(part.Get("text").String())
+			}
+			return true
+		})
+	}
+	if reasoning.Len() == 0 {
+		return content.String()
+	}
+	return "<reasoning>\n" + reasoning.String() + "\n</reasoning>\n" + content.String()
+}
@@ -0,0 +1,526 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/common/jsonschema"
+	"one-api/dto"
+	"one-api/logger"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/model_setting"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	emulatedToolCallStart = "<tool_call>"
+	emulatedToolCallEnd   = "</tool_call>"
+)
+
+// CapabilityEmulator 为不支持工具调用或结构化输出的模型模拟这些能力：
+// 请求时把工具与 JSON Schema 写入系统提示词，并把历史中的工具调用与结果改写为文本；
+// 响应时把模型输出的 <tool_call> 块解析为 tool_calls，或把输出整理为纯 JSON。
+// 流式响应在确定输出不是工具调用之前会被缓存，模拟结构化输出时缓存完整响应
+type CapabilityEmulator struct {
+	c      *gin.Context
+	tools  bool
+	format bool
+	writer *emulationWriter
+}
+
+// NewCapabilityEmulator 模型未开启模拟或请求不需要模拟时返回 nil，否则改写请求并替换 c.Writer，
+// 需要在请求结束前调用 Finish 或 Release 恢复原 Writer
+func NewCapabilityEmulator(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) *CapabilityEmulator {
+	if request == nil {
+		return nil
+	}
+	e := &CapabilityEmulator{c: c}
+	if model_setting.ShouldEmulateTools(info.UpstreamModelName) {
+		e.tools = len(request.Tools) > 0 || hasToolHistory(request.Messages)
+	}
+	if model_setting.ShouldEmulateResponseFormat(info.UpstreamModelName) && request.ResponseFormat != nil {
+		e.format = request.ResponseFormat.Type == "json_object" || request.ResponseFormat.Type == "json_schema"
+	}
+	if !e.tools && !e.format {
+		return nil
+	}
+	e.rewriteRequest(request)
+	e.writer = &emulationWriter{
+		ResponseWriter: c.Writer,
+		emulator:       e,
+		header:         c.Writer.Header().Clone(),
+	}
+	c.Writer = e.writer
+	return e
+}
+
+func hasToolHistory(messages []dto.Message) bool {
+	for _, message := range messages {
+		if message.Role == "tool" || len(message.ToolCalls) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// toolChoiceOf 返回 tool_choice 的模式与指定的工具名
+func toolChoiceOf(toolChoice any) (string, string) {
+	switch v := toolChoice.(type) {
+	case string:
+		return v, ""
+	case map[string]any:
+		if function, ok := v["function"].(map[string]any); ok {
+			name, _ := function["name"].(string)
+			return "function", name
+		}
+	}
+	return "auto", ""
+}
+
+func (e *CapabilityEmulator) rewriteRequest(request *dto.GeneralOpenAIRequest) {
+	var instructions []string
+	if e.tools {
+		mode, name := toolChoiceOf(request.ToolChoice)
+		if len(request.Tools) > 0 && mode != "none" {
+			instructions = append(instructions, toolInstruction(request.Tools, mode, name))
+		}
+		request.Messages = convertToolHistory(request.Messages)
+		request.Tools = nil
+		request.ToolChoice = nil
+		request.ParallelTooCalls = nil
+	}
+	if e.format {
+		instructions = append(instructions, responseFormatInstruction(request.ResponseFormat))
+		request.ResponseFormat = nil
+	}
+	if len(instructions) == 0 {
+		return
+	}
+	instruction := strings.Join(instructions, "\n\n")
+	systemRole := request.GetSystemRoleName()
+	if len(request.Messages) > 0 && request.Messages[0].Role == systemRole && request.Messages[0].IsStringContent() {
+		request.Messages[0].SetStringContent(request.Messages[0].StringContent() + "\n\n" + instruction)
+		return
+	}
+	request.Messages = append([]dto.Message{{Role: systemRole, Content: instruction}}, request.Messages...)
+}
+
+func toolInstruction(tools []dto.ToolCallRequest, mode string, name string) string {
+	definitions := make([]map[string]any, 0, len(tools))
+	for _, tool := range tools {
+		definition := map[string]any{"name": tool.Function.Name}
+		if tool.Function.Description != "" {
+			definition["description"] = tool.Function.Description
+		}
+		if tool.Function.Parameters != nil {
+			definition["parameters"] = tool.Function.Parameters
+		}
+		definitions = append(definitions, definition)
+	}
+	toolsJson, _ := common.Marshal(definitions)
+	var sb strings.Builder
+	sb.WriteString("You can call the following tools, described as JSON Schema:\n")
+	sb.Write(toolsJson)
+	sb.WriteString("\n\nTo call tools, reply with only one or more blocks in exactly this format and nothing else:\n")
+	sb.WriteString(emulatedToolCallStart + `{"name": "<tool name>", "arguments": {<arguments as a JSON object>}}` + emulatedToolCallEnd)
+	sb.WriteString("\nTool results will be sent back to you inside <tool_result> blocks.")
+	switch mode {
+	case "required":
+		sb.WriteString("\nYou must call at least one tool.")
+	case "function":
+		sb.WriteString(fmt.Sprintf("\nYou must call the tool %q.", name))
+	default:
+		sb.WriteString("\nIf no tool is needed, answer the user directly without any " + emulatedToolCallStart + " block.")
+	}
+	return sb.String()
+}
+
+func responseFormatInstruction(format *dto.ResponseFormat) string {
+	instruction := "Respond with a single valid JSON object only, without any explanation or Markdown code fences."
+	if format.Type == "json_schema" {
+		var schema dto.FormatJsonSchema
+		if err := common.Unmarshal(format.JsonSchema, &schema); err == nil && schema.Schema != nil {
+			schemaJson, _ := common.Marshal(schema.Schema)
+			instruction += "\nThe JSON object must conform to this JSON Schema:\n" + string(schemaJson)
+		}
+	}
+	return instruction
+}
+
+// convertToolHistory 把历史中的工具调用改写为 <tool_call> 文本，把工具结果改写为用户消息，并合并相邻的用户消息
+func convertToolHistory(messages []dto.Message) []dto.Message {
+	toolNames := make(map[string]string)
+	converted := make([]dto.Message, 0, len(messages))
+	for _, message := range messages {
+		switch {
+		case message.Role == "assistant" && len(message.ToolCalls) > 0:
+			var sb strings.Builder
+			sb.WriteString(message.StringContent())
+			for _, call := range message.ParseToolCalls() {
+				toolNames[call.ID] = call.Function.Name
+				var arguments any = call.Function.Arguments
+				if gjson.Valid(call.Function.Arguments) {
+					arguments = gjson.Parse(call.Function.Arguments).Value()
+				}
+				callJson, _ := common.Marshal(map[string]any{"name": call.Function.Name, "arguments": arguments})
+				sb.WriteString("\n" + emulatedToolCallStart + string(callJson) + emulatedToolCallEnd)
+			}
+			message.ToolCalls = nil
+			message.SetStringContent(strings.TrimSpace(sb.String()))
+		case message.Role == "tool":
+			message = dto.Message{
+				Role:    "user",
+				Content: fmt.Sprintf("<tool_result name=%q id=%q>\n%s\n</tool_result>", toolNames[message.ToolCallId], message.ToolCallId, message.StringContent()),
+			}
+		}
+		if last := len(converted) - 1; last >= 0 && message.Role == "user" && converted[last].Role == "user" &&
+			message.IsStringContent() && converted[last].IsStringContent() {
+			converted[last].SetStringContent(converted[last].StringContent() + "\n\n" + message.StringContent())
+			continue
+		}
+		converted = append(converted, message)
+	}
+	return converted
+}
+
+// parseEmulatedToolCalls 从模型输出中解析 <tool_call> 块，返回块之外的文本与工具调用，
+// 任何一个块无法解析时视为普通文本
+func parseEmulatedToolCalls(text string) (string, []dto.ToolCallResponse) {
+	if !strings.Contains(text, emulatedToolCallStart) {
+		return text, nil
+	}
+	var rest strings.Builder
+	var calls []dto.ToolCallResponse
+	remaining := text
+	for {
+		start := strings.Index(remaining, emulatedToolCallStart)
+		if start < 0 {
+			rest.WriteString(remaining)
+			break
+		}
+		rest.WriteString(remaining[:start])
+		body := remaining[start+len(emulatedToolCallStart):]
+		end := strings.Index(body, emulatedToolCallEnd)
+		if end < 0 {
+			end = len(body)
+			remaining = ""
+		} else {
+			remaining = body[end+len(emulatedToolCallEnd):]
+		}
+		repaired, ok := jsonschema.Repair(body[:end])
+		if !ok {
+			return text, nil
+		}
+		call := gjson.Parse(repaired)
+		name := call.Get("name").String()
+		if name == "" {
+			return text, nil
+		}
+		arguments := call.Get("arguments")
+		argumentsJson := arguments.Raw
+		if arguments.Type == gjson.String {
+			argumentsJson = arguments.String()
+		} else if !arguments.Exists() {
+			argumentsJson = "{}"
+		}
+		toolCall := dto.ToolCallResponse{
+			ID:       "call_" + common.GetUUID()[:24],
+			Type:     "function",
+			Function: dto.FunctionResponse{Name: name, Arguments: argumentsJson},
+		}
+		calls = append(calls, toolCall)
+	}
+	return strings.TrimSpace(rest.String()), calls
+}
+
+// transform 把模型的完整输出转换为最终内容与工具调用
+func (e *CapabilityEmulator) transform(content string) (string, []dto.ToolCallResponse) {
+	if e.tools {
+		if rest, calls := parseEmulatedToolCalls(content); len(calls) > 0 {
+			return rest, calls
+		}
+	}
+	if e.format {
+		if repaired, ok := jsonschema.Repair(content); ok {
+			return repaired, nil
+		}
+	}
+	return content, nil
+}
+
+// Release 丢弃缓存并恢复原 Writer，用于请求出错的情况，可以重复调用
+func (e *CapabilityEmulator) Release() {
+	if e == nil || e.writer.released {
+		return
+	}
+	e.writer.released = true
+	e.c.Writer = e.writer.ResponseWriter
+	if !e.writer.passthrough {
+		e.writer.resetHeader()
+	}
+}
+
+// Finish 转换缓存的响应并写出，恢复原 Writer
+func (e *CapabilityEmulator) Finish() {
+	if e == nil || e.writer.released {
+		return
+	}
+	w := e.writer
+	w.released = true
+	e.c.Writer = w.ResponseWriter
+	if w.passthrough || !w.decided {
+		return
+	}
+	var data []byte
+	if w.stream {
+		if w.pending.Len() > 0 {
+			w.lines = append(w.lines, bytes.Clone(w.pending.Bytes()))
+		}
+		data = e.rewriteStream(w.lines)
+	} else {
+		data = e.rewriteJson(w.body.Bytes())
+	}
+	header := w.ResponseWriter.Header()
+	if header.Get("Content-Length") != "" {
+		header.Set("Content-Length", strconv.Itoa(len(data)))
+	}
+	if _, err := w.ResponseWriter.Write(data); err != nil {
+		logger.LogError(e.c, "failed to write emulated response: "+err.Error())
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (e *CapabilityEmulator) rewriteJson(data []byte) []byte {
+	message := gjson.GetBytes(data, "choices.0.message")
+	if !message.Exists() {
+		return data
+	}
+	original := message.Get("content").String()
+	content, calls := e.transform(original)
+	var err error
+	if len(calls) > 0 {
+		callsJson, _ := common.Marshal(calls)
+		if content == "" {
+			data, err = sjson.SetRawBytes(data, "choices.0.message.content", []byte("null"))
+		} else {
+			data, err = sjson.SetBytes(data, "choices.0.message.content", content)
+		}
+		if err == nil {
+			data, err = sjson.SetRawBytes(data, "choices.0.message.tool_calls", callsJson)
+		}
+		if err == nil {
+			data, err = sjson.SetBytes(data, "choices.0.finish_reason", "tool_calls")
+		}
+	} else if content != original {
+		data, err = sjson.SetBytes(data, "choices.0.message.content", content)
+	}
+	if err != nil {
+		logger.LogError(e.c, "failed to rewrite emulated response: "+err.Error())
+	}
+	return data
+}
+
+// rewriteStream 根据缓存的流式数据重新生成 OpenAI 格式的流：内容（或工具调用）、结束原因、用量与 [DONE]
+func (e *CapabilityEmulator) rewriteStream(lines [][]byte) []byte {
+	var (
+		first        gjson.Result
+		usageChunk   string
+		finishReason string
+		done         bool
+		content      strings.Builder
+		reasoning    strings.Builder
+	)
+	for _, line := range lines {
+		payload, ok := bytes.CutPrefix(bytes.TrimRight(line, "\r"), []byte("data:"))
+		if !ok {
+			continue
+		}
+		payload = bytes.TrimSpace(payload)
+		if string(payload) == "[DONE]" {
+			done = true
+			continue
+		}
+		if !gjson.ValidBytes(payload) {
+			continue
+		}
+		chunk := gjson.ParseBytes(payload)
+		if !first.Exists() {
+			first = chunk
+		}
+		delta := chunk.Get("choices.0.delta")
+		content.WriteString(delta.Get("content").String())
+		reasoning.WriteString(delta.Get("reasoning_content").String())
+		if reason := chunk.Get("choices.0.finish_reason").String(); reason != "" {
+			finishReason = reason
+		}
+		if usage := chunk.Get("usage"); usage.Exists() && usage.Type != gjson.Null {
+			usageChunk, _ = sjson.SetRaw(string(payload), "choices", "[]")
+		}
+	}
+
+	text, calls := e.transform(content.String())
+	if finishReason == "" {
+		finishReason = "stop"
+	}
+	if len(calls) > 0 {
+		finishReason = "tool_calls"
+		for i := range calls {
+			calls[i].SetIndex(i)
+		}
+	}
+	newChunk := func(delta dto.ChatCompletionsStreamResponseChoiceDelta, finish *string) []byte {
+		chunk := dto.ChatCompletionsStreamResponse{
+			Id:      first.Get("id").String(),
+			Object:  "chat.completion.chunk",
+			Created: first.Get("created").Int(),
+			Model:   first.Get("model").String(),
+			Choices: []dto.ChatCompletionsStreamResponseChoice{{Index: 0, Delta: delta, FinishReason: finish}},
+		}
+		data, _ := common.Marshal(chunk)
+		return data
+	}
+
+	var out bytes.Buffer
+	writeData := func(data []byte) {
+		out.WriteString("data: ")
+		out.Write(data)
+		out.WriteString("\n\n")
+	}
+	delta := dto.ChatCompletionsStreamResponseChoiceDelta{Role: "assistant", ToolCalls: calls}
+	if text != "" {
+		delta.SetContentString(text)
+	}
+	if reasoning.Len() > 0 {
+		reasoningContent := reasoning.String()
+		delta.ReasoningContent = &reasoningContent
+	}
+	writeData(newChunk(delta, nil))
+	writeData(newChunk(dto.ChatCompletionsStreamResponseChoiceDelta{}, &finishReason))
+	if usageChunk != "" {
+		writeData([]byte(usageChunk))
+	}
+	if done {
+		writeData([]byte("[DONE]"))
+	}
+	return out.Bytes()
+}
+
+// emulationWriter 缓存写给客户端的内容。流式响应在内容确定不是工具调用且无需整理 JSON 时切换为直接写出
+type emulationWriter struct {
+	gin.ResponseWriter
+	emulator    *CapabilityEmulator
+	header      http.Header
+	released    bool
+	decided     bool
+	stream      bool
+	passthrough bool
+	body        bytes.Buffer
+	pending     bytes.Buffer
+	lines       [][]byte
+	content     strings.Builder
+}
+
+func (w *emulationWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	status := w.ResponseWriter.Status()
+	if status < 200 || status >= 300 {
+		w.passthrough = true
+		return
+	}
+	w.stream = strings.Contains(w.ResponseWriter.Header().Get("Content-Type"), "text/event-stream")
+}
+
+func (w *emulationWriter) Write(data []byte) (int, error) {
+	w.decide()
+	if w.passthrough {
+		return w.ResponseWriter.Write(data)
+	}
+	if !w.stream {
+		return w.body.Write(data)
+	}
+	w.pending.Write(data)
+	for {
+		idx := bytes.IndexByte(w.pending.Bytes(), '\n')
+		if idx < 0 {
+			break
+		}
+		line := bytes.Clone(w.pending.Next(idx + 1))
+		w.lines = append(w.lines, line)
+		if payload, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data:")); ok {
+			w.content.WriteString(gjson.GetBytes(bytes.TrimSpace(payload), "choices.0.delta.content").String())
+		}
+	}
+	if w.shouldPassthrough() {
+		w.passthrough = true
+		for _, line := range w.lines {
+			if _, err := w.ResponseWriter.Write(line); err != nil {
+				return 0, err
+			}
+		}
+		w.lines = nil
+		if w.pending.Len() > 0 {
+			if _, err := w.ResponseWriter.Write(w.pending.Bytes()); err != nil {
+				return 0, err
+			}
+			w.pending.Reset()
+		}
+	}
+	return len(data), nil
+}
+
+// shouldPassthrough 只模拟工具调用时，输出开头不是 <tool_call> 即可直接写出
+func (w *emulationWriter) shouldPassthrough() bool {
+	if w.emulator.format {
+		return false
+	}
+	text := strings.TrimLeft(w.content.String(), " \t\r\n")
+	if text == "" {
+		return false
+	}
+	if len(text) >= len(emulatedToolCallStart) {
+		return !strings.HasPrefix(text, emulatedToolCallStart)
+	}
+	return !strings.HasPrefix(emulatedToolCallStart, text)
+}
+
+func (w *emulationWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *emulationWriter) WriteHeaderNow() {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *emulationWriter) Written() bool {
+	if w.passthrough {
+		return w.ResponseWriter.Written()
+	}
+	return w.decided
+}
+
+func (w *emulationWriter) Flush() {
+	if w.passthrough {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *emulationWriter) resetHeader() {
+	header := w.ResponseWriter.Header()
+	for key := range header {
+		delete(header, key)
+	}
+	for key, values := range w.header {
+		header[key] = values
+	}
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"encoding/json"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/model_setting"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func withEmulationSettings(t *testing.T, settings model_setting.EmulationSettings) {
+	t.Helper()
+	current := model_setting.GetEmulationSettings()
+	previous := *current
+	*current = settings
+	t.Cleanup(func() { *current = previous })
+}
+
+func newEmulationInfo(model string) *relaycommon.RelayInfo {
+	return &relaycommon.RelayInfo{ChannelMeta: &relaycommon.ChannelMeta{UpstreamModelName: model}}
+}
+
+func newEmulationRequest() *dto.GeneralOpenAIRequest {
+	return &dto.GeneralOpenAIRequest{
+		Messages: []dto.Message{
+			{Role: "user", Content: "weather in Paris?"},
+			{Role: "assistant", ToolCalls: json.RawMessage(`[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]`)},
+			{Role: "tool", ToolCallId: "call_1", Content: "sunny"},
+			{Role: "user", Content: "and in Rome?"},
+		},
+		Tools: []dto.ToolCallRequest{{
+			Type:     "function",
+			Function: dto.FunctionRequest{Name: "get_weather", Parameters: map[string]any{"type": "object"}},
+		}},
+		ToolChoice: "required",
+	}
+}
+
+func TestCapabilityEmulatorRewriteRequest(t *testing.T) {
+	withEmulationSettings(t, model_setting.EmulationSettings{ToolsModels: []string{"local-*"}})
+	c, _ := newStructuredOutputContext()
+	request := newEmulationRequest()
+	if NewCapabilityEmulator(c, newEmulationInfo("gpt-4o"), request) != nil {
+		t.Fatal("expected no emulation for model without flag")
+	}
+
+	emulator := NewCapabilityEmulator(c, newEmulationInfo("local-llama"), request)
+	if emulator == nil {
+		t.Fatal("expected emulation for flagged model")
+	}
+	defer emulator.Release()
+	if request.Tools != nil || request.ToolChoice != nil {
+		t.Error("expected tools to be removed from request")
+	}
+	roles := make([]string, 0, len(request.Messages))
+	for _, message := range request.Messages {
+		roles = append(roles, message.Role)
+	}
+	if strings.Join(roles, ",") != "system,user,assistant,user" {
+		t.Fatalf("unexpected roles %v", roles)
+	}
+	if system := request.Messages[0].StringContent(); !strings.Contains(system, `"get_weather"`) || !strings.Contains(system, "must call at least one tool") {
+		t.Errorf("unexpected system instruction %q", system)
+	}
+	if assistant := request.Messages[2].StringContent(); assistant != `<tool_call>{"arguments":{"city":"Paris"},"name":"get_weather"}</tool_call>` {
+		t.Errorf("unexpected assistant history %q", assistant)
+	}
+	if user := request.Messages[3].StringContent(); !strings.HasPrefix(user, `<tool_result name="get_weather" id="call_1">`) || !strings.HasSuffix(user, "and in Rome?") {
+		t.Errorf("unexpected merged tool result %q", user)
+	}
+}
+
+func TestParseEmulatedToolCalls(t *testing.T) {
+	text := "Let me check.\n<tool_call>{\"name\": \"get_weather\", \"arguments\": {\"city\": \"Rome\"}}</tool_call>\n<tool_call>{\"name\": \"get_time\", \"arguments\": {\"tz\": \"CET\""
+	rest, calls := parseEmulatedToolCalls(text)
+	if rest != "Let me check." || len(calls) != 2 {
+		t.Fatalf("unexpected parse result %q, %+v", rest, calls)
+	}
+	if calls[0].Function.Name != "get_weather" || calls[0].Function.Arguments != `{"city": "Rome"}` {
+		t.Errorf("unexpected first call %+v", calls[0])
+	}
+	if calls[1].Function.Name != "get_time" || calls[1].Function.Arguments != `{"tz": "CET"}` {
+		t.Errorf("expected truncated call to be repaired, got %+v", calls[1])
+	}
+	if _, calls = parseEmulatedToolCalls("<tool_call>not json</tool_call>"); calls != nil {
+		t.Errorf("expected unparsable block to be kept as text, got %+v", calls)
+	}
+}
+
+func TestCapabilityEmulatorResponses(t *testing.T) {
+	withEmulationSettings(t, model_setting.EmulationSettings{ToolsModels: []string{"local-llama"}})
+
+	c, recorder := newStructuredOutputContext()
+	emulator := NewCapabilityEmulator(c, newEmulationInfo("local-llama"), newEmulationRequest())
+	c.Writer.Header().Set("Content-Type", "application/json")
+	_, _ = c.Writer.Write([]byte(`{"choices":[{"index":0,"message":{"role":"assistant","content":"<tool_call>{\"name\":\"get_weather\",\"arguments\":{\"city\":\"Rome\"}}</tool_call>"},"finish_reason":"stop"}]}`))
+	emulator.Finish()
+	body := gjson.Parse(recorder.Body.String())
+	if body.Get("choices.0.finish_reason").String() != "tool_calls" || body.Get("choices.0.message.content").Type != gjson.Null ||
+		body.Get("choices.0.message.tool_calls.0.function.name").String() != "get_weather" {
+		t.Errorf("unexpected emulated response %s", recorder.Body.String())
+	}
+
+	c, recorder = newStructuredOutputContext()
+	emulator = NewCapabilityEmulator(c, newEmulationInfo("local-llama"), newEmulationRequest())
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	for _, content := range []string{"<tool_", `call>{"name":"get_weather",`, `"arguments":{"city":"Rome"}}</tool_call>`} {
+		_, _ = c.Writer.Write([]byte(`data: {"id":"chatcmpl-1","created":1,"model":"local-llama","choices":[{"index":0,"delta":{"content":` + jsonString(content) + `}}]}` + "\n\n"))
+	}
+	_, _ = c.Writer.Write([]byte(`data: {"id":"chatcmpl-1","created":1,"model":"local-llama","choices":[],"usage":{"prompt_tokens":3,"completion_tokens":5,"total_tokens":8}}` + "\n\ndata: [DONE]\n\n"))
+	if recorder.Body.Len() != 0 {
+		t.Fatalf("expected tool call stream to be buffered, got %q", recorder.Body.String())
+	}
+	emulator.Finish()
+	stream := recorder.Body.String()
+	output := extractStructuredOutput(recorder.Body.Bytes(), true)
+	if len(output.toolCalls) != 1 || output.toolCalls[0].name != "get_weather" || output.toolCalls[0].arguments != `{"city":"Rome"}` {
+		t.Errorf("unexpected emulated stream %q", stream)
+	}
+	if !strings.Contains(stream, `"finish_reason":"tool_calls"`) || !strings.Contains(stream, `"total_tokens":8`) || !strings.HasSuffix(stream, "data: [DONE]\n\n") {
+		t.Errorf("expected finish reason, usage and terminator in %q", stream)
+	}
+
+	// 输出不是工具调用时直接透传
+	c, recorder = newStructuredOutputContext()
+	emulator = NewCapabilityEmulator(c, newEmulationInfo("local-llama"), newEmulationRequest())
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	chunk := `data: {"choices":[{"index":0,"delta":{"content":"Rome is sunny"}}]}` + "\n\n"
+	_, _ = c.Writer.Write([]byte(chunk))
+	if recorder.Body.String() != chunk {
+		t.Errorf("expected plain content to pass through, got %q", recorder.Body.String())
+	}
+	emulator.Finish()
+}
+
+func jsonString(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
@@ -0,0 +1,290 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/common/secretref"
+	"one-api/dto"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+var ErrStreamIdleTimeout = errors.New("upstream stream idle timeout")
+
+type channelHttpClient struct {
+	fingerprint string
+	client      *http.Client
+}
+
+var (
+	channelHttpClientsLock sync.Mutex
+	channelHttpClients     = make(map[int]*channelHttpClient)
+)
+
+// GetChannelHttpClient 返回渠道使用的 HTTP 客户端。配置了连接设置的渠道各自复用一个 Transport，
+// 代理或连接设置变化时自动重建；未配置时使用全局客户端或按代理地址复用的客户端
+func GetChannelHttpClient(channelId int, setting dto.ChannelSettings) (*http.Client, error) {
+	if setting.Transport == nil {
+		if setting.Proxy == "" {
+			return GetHttpClient(), nil
+		}
+		return NewProxyHttpClient(setting.Proxy)
+	}
+	clientKey, err := resolveClientKey(setting.Transport)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint := channelTransportFingerprint(setting, clientKey)
+
+	channelHttpClientsLock.Lock()
+	defer channelHttpClientsLock.Unlock()
+	if cached, ok := channelHttpClients[channelId]; ok {
+		if cached.fingerprint == fingerprint {
+			return cached.client, nil
+		}
+		cached.client.CloseIdleConnections()
+	}
+	client, err := newChannelHttpClient(setting.Proxy, setting.Transport, clientKey)
+	if err != nil {
+		return nil, err
+	}
+	channelHttpClients[channelId] = &channelHttpClient{fingerprint: fingerprint, client: client}
+	return client, nil
+}
+
+// InvalidateChannelHttpClient 渠道更新或删除时关闭并移除其 HTTP 客户端
+func InvalidateChannelHttpClient(channelId int) {
+	channelHttpClientsLock.Lock()
+	defer channelHttpClientsLock.Unlock()
+	if cached, ok := channelHttpClients[channelId]; ok {
+		cached.client.CloseIdleConnections()
+		delete(channelHttpClients, channelId)
+	}
+}
+
+// ValidateChannelTransport 校验渠道的代理与连接设置，证书或私钥无效时返回错误
+func ValidateChannelTransport(setting dto.ChannelSettings) error {
+	if setting.Transport == nil {
+		if setting.Proxy == "" {
+			return nil
+		}
+		_, err := newProxyTransport(setting.Proxy, nil)
+		return err
+	}
+	if setting.Transport.ClientKey != "" && !secretref.IsReference(setting.Transport.ClientKey) {
+		return errors.New("客户端私钥必须使用外部密钥引用（env://、file://、vault://），不能直接保存私钥内容")
+	}
+	clientKey, err := resolveClientKey(setting.Transport)
+	if err != nil {
+		return err
+	}
+	_, err = newChannelHttpClient(setting.Proxy, setting.Transport, clientKey)
+	return err
+}
+
+// resolveClientKey 客户端私钥只能是外部密钥引用，避免私钥明文保存在渠道设置中并随渠道列表与审计日志返回
+func resolveClientKey(settings *dto.ChannelTransportSettings) (string, error) {
+	if settings.ClientKey == "" {
+		return "", nil
+	}
+	if !secretref.IsReference(settings.ClientKey) {
+		return "", errors.New("client key must be a secret reference")
+	}
+	return secretref.Resolve(settings.ClientKey)
+}
+
+// channelTransportFingerprint 代理、连接设置与解析后的私钥共同决定是否需要重建 Transport
+func channelTransportFingerprint(setting dto.ChannelSettings, clientKey string) string {
+	data, _ := common.Marshal(setting.Transport)
+	sum := sha256.New()
+	sum.Write([]byte(setting.Proxy))
+	sum.Write([]byte{0})
+	sum.Write(data)
+	sum.Write([]byte{0})
+	sum.Write([]byte(clientKey))
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+func seconds(value int) time.Duration {
+	return time.Duration(value) * time.Second
+}
+
+func newChannelHttpClient(proxyURL string, settings *dto.ChannelTransportSettings, clientKey string) (*http.Client, error) {
+	transport, err := newProxyTransport(proxyURL, settings)
+	if err != nil {
+		return nil, err
+	}
+	if settings.FirstByteTimeout > 0 {
+		transport.ResponseHeaderTimeout = seconds(settings.FirstByteTimeout)
+	}
+	if settings.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = seconds(settings.IdleConnTimeout)
+	}
+	if settings.MaxConnsPerHost > 0 {
+		transport.MaxConnsPerHost = settings.MaxConnsPerHost
+	}
+	if settings.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = settings.MaxIdleConnsPerHost
+	}
+	if settings.DisableHTTP2 {
+		transport.ForceAttemptHTTP2 = false
+		// 非 nil 的空映射会关闭 HTTP/2 协商
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+	if settings.CACert != "" || settings.ClientCert != "" || settings.ServerName != "" {
+		tlsConfig, err := newChannelTLSConfig(settings, clientKey)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	client := &http.Client{Transport: transport}
+	if common.RelayTimeout != 0 {
+		client.Timeout = seconds(common.RelayTimeout)
+	}
+	if settings.IdleStreamTimeout > 0 {
+		client.Transport = &idleTimeoutTransport{base: transport, timeout: seconds(settings.IdleStreamTimeout)}
+	}
+	return client, nil
+}
+
+func newChannelTLSConfig(settings *dto.ChannelTransportSettings, clientKey string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{ServerName: settings.ServerName}
+	if settings.CACert != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM([]byte(settings.CACert)) {
+			return nil, errors.New("invalid CA certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if settings.ClientCert != "" || clientKey != "" {
+		cert, err := tls.X509KeyPair([]byte(settings.ClientCert), []byte(clientKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// newProxyTransport 创建经过代理的 Transport，settings 为 nil 时使用默认连接超时
+func newProxyTransport(proxyURL string, settings *dto.ChannelTransportSettings) (*http.Transport, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if settings != nil && settings.ConnectTimeout > 0 {
+		dialer.Timeout = seconds(settings.ConnectTimeout)
+		transport.TLSHandshakeTimeout = seconds(settings.ConnectTimeout)
+	}
+	transport.DialContext = dialer.DialContext
+	if proxyURL == "" {
+		return transport, nil
+	}
+
+	parsedURL, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	switch parsedURL.Scheme {
+	case "http", "https":
+		transport.Proxy = http.ProxyURL(parsedURL)
+	case "socks5", "socks5h":
+		// 获取认证信息
+		var auth *proxy.Auth
+		if parsedURL.User != nil {
+			auth = &proxy.Auth{
+				User:     parsedURL.User.Username(),
+				Password: "",
+			}
+			if password, ok := parsedURL.User.Password(); ok {
+				auth.Password = password
+			}
+		}
+		// proxy.SOCKS5 使用 tcp 参数，所有 TCP 连接包括 DNS 查询都将通过代理进行。行为与 socks5h 相同
+		socksDialer, err := proxy.SOCKS5("tcp", parsedURL.Host, auth, dialer)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = nil
+		if contextDialer, ok := socksDialer.(proxy.ContextDialer); ok {
+			transport.DialContext = contextDialer.DialContext
+		} else {
+			transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return socksDialer.Dial(network, addr)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme: %s", parsedURL.Scheme)
+	}
+	return transport, nil
+}
+
+// idleTimeoutTransport 读取响应体时超过 timeout 未收到数据则取消请求，避免上游流卡住时长期占用连接
+type idleTimeoutTransport struct {
+	base    http.RoundTripper
+	timeout time.Duration
+}
+
+func (t *idleTimeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	body := &idleTimeoutBody{body: resp.Body, timeout: t.timeout, cancel: cancel}
+	body.timer = time.AfterFunc(t.timeout, func() {
+		body.timedOut.Store(true)
+		cancel()
+	})
+	resp.Body = body
+	return resp, nil
+}
+
+func (t *idleTimeoutTransport) CloseIdleConnections() {
+	if closer, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+type idleTimeoutBody struct {
+	body     io.ReadCloser
+	timeout  time.Duration
+	timer    *time.Timer
+	timedOut atomic.Bool
+	cancel   context.CancelFunc
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if n > 0 {
+		b.timer.Reset(b.timeout)
+	}
+	if err != nil && err != io.EOF && b.timedOut.Load() {
+		return n, ErrStreamIdleTimeout
+	}
+	return n, err
+}
+
+func (b *idleTimeoutBody) Close() error {
+	b.timer.Stop()
+	err := b.body.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/logger"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/model_setting"
+	"one-api/types"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContextWindowResult 提示词超出模型上下文窗口时采用的处理结果，写入响应头与日志
+type ContextWindowResult struct {
+	Strategy        string `json:"strategy"`
+	ContextLength   int    `json:"context_length"`
+	PromptTokens    int    `json:"prompt_tokens"`
+	RemovedMessages int    `json:"removed_messages,omitempty"`
+	FromModel       string `json:"from_model,omitempty"`
+	ToModel         string `json:"to_model,omitempty"`
+}
+
+// ApplyContextWindowPolicy 在请求上游之前检查提示词是否超出模型上下文窗口（需预留 max_tokens），并按令牌或分组的策略处理：
+// reject 直接返回错误；truncate 丢弃最早的非系统消息，仅支持 OpenAI 格式的对话请求；
+// reroute 返回要改用的模型，由调用方重新选择渠道。策略无法执行时按 reject 处理。
+// 返回处理后的提示词 token 数
+func ApplyContextWindowPolicy(c *gin.Context, info *relaycommon.RelayInfo, meta *types.TokenCountMeta, tokens int) (int, *ContextWindowResult, *types.NewAPIError) {
+	modelName := info.OriginModelName
+	contextLength := model.GetModelContextLength(modelName)
+	if contextLength <= 0 || meta == nil {
+		return tokens, nil, nil
+	}
+	policy := model_setting.ResolveContextPolicy(common.GetContextKeyString(c, constant.ContextKeyTokenContextPolicy), common.GetContextKeyString(c, constant.ContextKeyUsingGroup))
+	if policy == model_setting.ContextPolicyOff {
+		return tokens, nil, nil
+	}
+
+	// 未开启提示词计数时按文本估算
+	estimated := tokens
+	if estimated <= 0 {
+		estimated = CountTextToken(meta.CombineText, modelName) + meta.MessagesCount*3 + 3
+	}
+	budget := contextBudget(contextLength, meta.MaxTokens)
+	if estimated <= budget {
+		return tokens, nil, nil
+	}
+
+	result := &ContextWindowResult{
+		Strategy:      policy,
+		ContextLength: contextLength,
+		PromptTokens:  estimated,
+	}
+	switch policy {
+	case model_setting.ContextPolicyTruncate:
+		if request, ok := info.Request.(*dto.GeneralOpenAIRequest); ok {
+			if removed, removedTokens := truncateContextMessages(request, estimated-budget, modelName); removed > 0 {
+				if err := updateRequestBody(c, request); err != nil {
+					return tokens, nil, types.NewError(err, types.ErrorCodeJsonMarshalFailed, types.ErrOptionWithSkipRetry())
+				}
+				result.RemovedMessages = removed
+				if tokens > 0 {
+					tokens = max(tokens-removedTokens, 0)
+				}
+				recordContextWindowResult(c, result)
+				return tokens, result, nil
+			}
+		}
+	case model_setting.ContextPolicyReroute:
+		if target, ok := overflowModelFor(c, modelName, estimated, meta.MaxTokens); ok {
+			result.FromModel = modelName
+			result.ToModel = target
+			recordContextWindowResult(c, result)
+			return tokens, result, nil
+		}
+	}
+
+	result.Strategy = model_setting.ContextPolicyReject
+	recordContextWindowResult(c, result)
+	err := fmt.Errorf("this model's maximum context length is %d tokens, but the request needs about %d tokens (%d in the messages, %d reserved for the completion)",
+		contextLength, estimated+contextLength-budget, estimated, contextLength-budget)
+	return tokens, result, types.NewErrorWithStatusCode(err, types.ErrorCodeContextLengthExceeded, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+}
+
+// contextBudget 上下文窗口中可以留给提示词的 token 数
+func contextBudget(contextLength int, maxTokens int) int {
+	if maxTokens > 0 && maxTokens < contextLength {
+		return contextLength - maxTokens
+	}
+	return contextLength
+}
+
+// overflowModelFor 返回可以容纳提示词的替代模型。指定渠道的请求不改换模型，令牌限制了可用模型时替代模型也需在其中
+func overflowModelFor(c *gin.Context, modelName string, promptTokens int, maxTokens int) (string, bool) {
+	if _, ok := c.Get("specific_channel_id"); ok {
+		return "", false
+	}
+	target, ok := model_setting.GetOverflowModel(modelName)
+	if !ok {
+		return "", false
+	}
+	if c.GetBool("token_model_limit_enabled") {
+		if limits, _ := c.Get("token_model_limit"); limits != nil {
+			if _, allowed := limits.(map[string]bool)[target]; !allowed {
+				return "", false
+			}
+		}
+	}
+	if targetLength := model.GetModelContextLength(target); targetLength > 0 && promptTokens > contextBudget(targetLength, maxTokens) {
+		return "", false
+	}
+	return target, true
+}
+
+func isContextPinnedRole(role string) bool {
+	return role == "system" || role == "developer"
+}
+
+func contextMessageTokens(message *dto.Message, modelName string) int {
+	return CountTextToken(message.StringContent()+string(message.ToolCalls), modelName) + 3
+}
+
+// truncateContextMessages 从最早的非系统消息开始丢弃，直到丢弃的 token 数不少于 excess。
+// 最后一条消息总是保留，丢弃工具调用时一并丢弃随后的工具结果。
+// 返回丢弃的消息数与 token 数，无法腾出足够空间时不修改请求并返回 0
+func truncateContextMessages(request *dto.GeneralOpenAIRequest, excess int, modelName string) (int, int) {
+	messages := request.Messages
+	if len(messages) < 2 {
+		return 0, 0
+	}
+	drop := make([]bool, len(messages))
+	removed, removedTokens := 0, 0
+	for i := 0; i < len(messages)-1; i++ {
+		if removedTokens >= excess && messages[i].Role != "tool" {
+			break
+		}
+		if isContextPinnedRole(messages[i].Role) {
+			continue
+		}
+		drop[i] = true
+		removed++
+		removedTokens += contextMessageTokens(&messages[i], modelName)
+	}
+	if removedTokens < excess {
+		return 0, 0
+	}
+	kept := make([]dto.Message, 0, len(messages)-removed)
+	for i, message := range messages {
+		if !drop[i] {
+			kept = append(kept, message)
+		}
+	}
+	request.Messages = kept
+	return removed, removedTokens
+}
+
+func updateRequestBody(c *gin.Context, request any) error {
+	body, err := common.Marshal(request)
+	if err != nil {
+		return err
+	}
+	c.Set(common.KeyRequestBody, body)
+	return nil
+}
+
+func recordContextWindowResult(c *gin.Context, result *ContextWindowResult) {
+	common.SetContextKey(c, constant.ContextKeyContextWindow, result)
+	logger.LogInfo(c, fmt.Sprintf("prompt of about %d tokens exceeds the context window of %d tokens, strategy: %s", result.PromptTokens, result.ContextLength, result.Strategy))
+	c.Header("X-Context-Window-Strategy", result.Strategy)
+	c.Header("X-Context-Window-Prompt-Tokens", strconv.Itoa(result.PromptTokens))
+	if result.RemovedMessages > 0 {
+		c.Header("X-Context-Window-Removed-Messages", strconv.Itoa(result.RemovedMessages))
+	}
+	if result.ToModel != "" {
+		c.Header("X-Context-Window-Model", result.ToModel)
+	}
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"encoding/json"
+	"one-api/dto"
+	"one-api/setting/model_setting"
+	"strings"
+	"testing"
+)
+
+func TestTruncateContextMessages(t *testing.T) {
+	long := strings.Repeat("lorem ipsum ", 200)
+	request := &dto.GeneralOpenAIRequest{
+		Messages: []dto.Message{
+			{Role: "system", Content: "be brief"},
+			{Role: "user", Content: long},
+			{Role: "assistant", ToolCalls: json.RawMessage(`[{"id":"call_1","type":"function","function":{"name":"search","arguments":"{}"}}]`)},
+			{Role: "tool", ToolCallId: "call_1", Content: "result"},
+			{Role: "user", Content: long},
+			{Role: "assistant", Content: "ok"},
+			{Role: "user", Content: "latest question"},
+		},
+	}
+	excess := contextMessageTokens(&request.Messages[1], "gpt-4o") + 1
+	removed, removedTokens := truncateContextMessages(request, excess, "gpt-4o")
+	if removed != 3 || removedTokens < excess {
+		t.Fatalf("expected the oldest user turn and its tool call to be dropped, removed %d (%d tokens)", removed, removedTokens)
+	}
+	roles := make([]string, 0, len(request.Messages))
+	for _, message := range request.Messages {
+		roles = append(roles, message.Role)
+	}
+	if strings.Join(roles, ",") != "system,user,assistant,user" {
+		t.Errorf("unexpected remaining roles %v", roles)
+	}
+
+	// 丢弃除最后一条外的所有消息仍不够时不修改请求
+	if removed, _ = truncateContextMessages(request, 1<<20, "gpt-4o"); removed != 0 || len(request.Messages) != 4 {
+		t.Errorf("expected request to be left untouched, removed %d", removed)
+	}
+}
+
+func TestResolveContextPolicy(t *testing.T) {
+	settings := model_setting.GetContextWindowSettings()
+	previous := *settings
+	t.Cleanup(func() { *settings = previous })
+	settings.DefaultPolicy = model_setting.ContextPolicyReject
+	settings.GroupPolicies = map[string]string{"vip": model_setting.ContextPolicyReroute}
+
+	cases := []struct{ token, group, expected string }{
+		{"", "default", model_setting.ContextPolicyReject},
+		{"", "vip", model_setting.ContextPolicyReroute},
+		{model_setting.ContextPolicyTruncate, "vip", model_setting.ContextPolicyTruncate},
+	}
+	for _, tc := range cases {
+		if policy := model_setting.ResolveContextPolicy(tc.token, tc.group); policy != tc.expected {
+			t.Errorf("ResolveContextPolicy(%q, %q) = %q, want %q", tc.token, tc.group, policy, tc.expected)
+		}
+	}
+	if budget := contextBudget(8192, 1024); budget != 7168 {
+		t.Errorf("expected max_tokens to be reserved, got %d", budget)
+	}
+}
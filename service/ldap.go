@@ -0,0 +1,227 @@
+package service
+
+import (
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"one-api/common"
+	"one-api/setting/system_setting"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// LDAPUser 目录中认证成功的用户
+type LDAPUser struct {
+	// Id 取自 IdAttribute 的唯一标识，用于关联本地账户
+	Id          string
+	DN          string
+	Username    string
+	Email       string
+	DisplayName string
+	Groups      []string
+}
+
+// ldapConn 抽象出需要的 LDAP 操作，便于测试时替换为进程内实现
+type ldapConn interface {
+	Bind(username, password string) error
+	Search(searchRequest *ldap.SearchRequest) (*ldap.SearchResult, error)
+	Close() error
+}
+
+var errLDAPInvalidCredentials = errors.New("用户名或密码错误")
+
+// ldapDial 建立到目录服务器的连接，测试中可替换
+var ldapDial = func(settings *system_setting.LDAPSettings) (ldapConn, error) {
+	timeout := time.Duration(settings.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: settings.InsecureSkipVerify}
+	conn, err := ldap.DialURL(settings.Url,
+		ldap.DialWithDialer(&net.Dialer{Timeout: timeout}),
+		ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(timeout)
+	if settings.StartTLS && strings.HasPrefix(strings.ToLower(settings.Url), "ldap://") {
+		if err = conn.StartTLS(tlsConfig); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func replaceLDAPPlaceholders(filter string, dn string, username string) string {
+	filter = strings.ReplaceAll(filter, "{username}", ldap.EscapeFilter(username))
+	return strings.ReplaceAll(filter, "{dn}", ldap.EscapeFilter(dn))
+}
+
+// AuthenticateLDAP 使用服务账号搜索用户，再以用户 DN 与密码绑定验证，并读取用户所属的组
+func AuthenticateLDAP(username string, password string) (*LDAPUser, error) {
+	settings := system_setting.GetLDAPSettings()
+	// 空密码在多数目录上会被视为匿名绑定并返回成功，必须拒绝
+	if strings.TrimSpace(username) == "" || password == "" {
+		return nil, errLDAPInvalidCredentials
+	}
+	if settings.Url == "" || settings.BaseDN == "" {
+		return nil, errors.New("LDAP 未配置")
+	}
+	conn, err := ldapDial(settings)
+	if err != nil {
+		common.SysLog("failed to connect to LDAP server: " + err.Error())
+		return nil, errors.New("无法连接至 LDAP 服务器，请稍后重试！")
+	}
+	defer conn.Close()
+
+	if settings.BindDN != "" {
+		err = conn.Bind(settings.BindDN, settings.BindPassword)
+	} else {
+		err = conn.Bind("", "")
+	}
+	if err != nil {
+		common.SysLog("failed to bind LDAP service account: " + err.Error())
+		return nil, errors.New("LDAP 服务账号绑定失败，请检查设置！")
+	}
+
+	attributes := []string{"dn"}
+	for _, attr := range []string{settings.IdAttribute, settings.UsernameAttribute, settings.EmailAttribute, settings.DisplayNameAttribute, settings.GroupAttribute} {
+		if attr != "" {
+			attributes = append(attributes, attr)
+		}
+	}
+	result, err := conn.Search(ldap.NewSearchRequest(
+		settings.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, settings.TimeoutSeconds, false,
+		replaceLDAPPlaceholders(settings.UserFilter, "", username), attributes, nil,
+	))
+	if err != nil {
+		common.SysLog("failed to search LDAP user: " + err.Error())
+		return nil, errors.New("LDAP 查询用户失败，请检查设置！")
+	}
+	if len(result.Entries) != 1 {
+		return nil, errLDAPInvalidCredentials
+	}
+	entry := result.Entries[0]
+	if err = conn.Bind(entry.DN, password); err != nil {
+		return nil, errLDAPInvalidCredentials
+	}
+	id := ldapEntryId(entry, settings.IdAttribute)
+	if id == "" {
+		common.SysLog(fmt.Sprintf("LDAP entry %s has no %s attribute", entry.DN, settings.IdAttribute))
+		return nil, errors.New("LDAP 用户缺少唯一标识属性，请检查设置！")
+	}
+
+	user := &LDAPUser{
+		Id:          id,
+		DN:          entry.DN,
+		Username:    entry.GetAttributeValue(settings.UsernameAttribute),
+		Email:       entry.GetAttributeValue(settings.EmailAttribute),
+		DisplayName: entry.GetAttributeValue(settings.DisplayNameAttribute),
+	}
+	if user.Username == "" {
+		user.Username = username
+	}
+	if settings.GroupAttribute != "" {
+		user.Groups = append(user.Groups, entry.GetAttributeValues(settings.GroupAttribute)...)
+	}
+	if settings.GroupBaseDN != "" && settings.GroupFilter != "" {
+		// 组搜索使用服务账号的权限，用户本身可能无权读取组条目
+		if settings.BindDN != "" {
+			err = conn.Bind(settings.BindDN, settings.BindPassword)
+		}
+		var groups *ldap.SearchResult
+		if err == nil {
+			groups, err = conn.Search(ldap.NewSearchRequest(
+				settings.GroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, settings.TimeoutSeconds, false,
+				replaceLDAPPlaceholders(settings.GroupFilter, entry.DN, user.Username), []string{"dn"}, nil,
+			))
+		}
+		// 组不完整时会按未映射用户同步角色与分组，导致管理员被降级，因此直接拒绝本次登录
+		if err != nil {
+			common.SysLog("failed to search LDAP groups: " + err.Error())
+			return nil, errors.New("LDAP 查询用户组失败，请稍后重试！")
+		}
+		for _, group := range groups.Entries {
+			user.Groups = append(user.Groups, group.DN)
+		}
+	}
+	return user, nil
+}
+
+// ldapEntryId 读取条目的唯一标识，objectGUID 等二进制属性以十六进制表示
+func ldapEntryId(entry *ldap.Entry, attribute string) string {
+	if attribute == "" {
+		return ""
+	}
+	raw := entry.GetRawAttributeValue(attribute)
+	if len(raw) == 0 {
+		return ""
+	}
+	if utf8.Valid(raw) && strings.IndexFunc(string(raw), func(r rune) bool { return !unicode.IsPrint(r) }) < 0 {
+		return string(raw)
+	}
+	return hex.EncodeToString(raw)
+}
+
+// ldapGroupCN 返回组 DN 第一个 RDN 的值，例如 cn=admins,ou=groups,dc=example,dc=com 返回 admins
+func ldapGroupCN(groupDN string) string {
+	dn, err := ldap.ParseDN(groupDN)
+	if err != nil || len(dn.RDNs) == 0 || len(dn.RDNs[0].Attributes) == 0 {
+		return groupDN
+	}
+	return dn.RDNs[0].Attributes[0].Value
+}
+
+func ldapGroupMatches(groups []string, target string) bool {
+	for _, group := range groups {
+		if strings.EqualFold(group, target) || strings.EqualFold(ldapGroupCN(group), target) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveLDAPMapping 根据目录组计算角色与用户分组：角色取所有命中映射中的最高值（不超过管理员），分组取第一个命中的映射
+func ResolveLDAPMapping(groups []string, mappings []system_setting.LDAPGroupMapping) (role int, group string, matched bool) {
+	for _, mapping := range mappings {
+		if mapping.LdapGroup == "" || !ldapGroupMatches(groups, mapping.LdapGroup) {
+			continue
+		}
+		matched = true
+		if mapping.Role > role {
+			role = mapping.Role
+		}
+		if group == "" && mapping.Group != "" {
+			group = mapping.Group
+		}
+	}
+	if role > common.RoleAdminUser {
+		role = common.RoleAdminUser
+	}
+	return role, group, matched
+}
+
+// ValidateLDAPSettings 保存设置前的基本校验
+func ValidateLDAPSettings(settings *system_setting.LDAPSettings) error {
+	if !settings.Enabled {
+		return nil
+	}
+	lower := strings.ToLower(settings.Url)
+	if !strings.HasPrefix(lower, "ldap://") && !strings.HasPrefix(lower, "ldaps://") {
+		return fmt.Errorf("无效的 LDAP 地址: %s", settings.Url)
+	}
+	if settings.BaseDN == "" || !strings.Contains(settings.UserFilter, "{username}") {
+		return errors.New("请填写 Base DN 以及包含 {username} 的用户过滤器")
+	}
+	if settings.IdAttribute == "" {
+		return errors.New("请填写用户唯一标识属性，例如 entryUUID 或 objectGUID")
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"errors"
+	"one-api/common"
+	"one-api/setting/system_setting"
+	"strings"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// fakeLDAPConn 进程内的目录实现，只支持按 uid 搜索用户与按 member 搜索组
+type fakeLDAPConn struct {
+	passwords map[string]string
+	users     []*ldap.Entry
+	groups    []*ldap.Entry
+	filters   []string
+	groupErr  error
+}
+
+func (f *fakeLDAPConn) Bind(username, password string) error {
+	if expected, ok := f.passwords[username]; ok && expected == password {
+		return nil
+	}
+	return ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+}
+
+func (f *fakeLDAPConn) Search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	f.filters = append(f.filters, req.Filter)
+	result := &ldap.SearchResult{}
+	if strings.HasPrefix(req.BaseDN, "ou=groups") {
+		if f.groupErr != nil {
+			return nil, f.groupErr
+		}
+		for _, group := range f.groups {
+			for _, member := range group.GetAttributeValues("member") {
+				if strings.Contains(req.Filter, "(member="+member+")") {
+					result.Entries = append(result.Entries, group)
+				}
+			}
+		}
+		return result, nil
+	}
+	for _, user := range f.users {
+		if strings.Contains(req.Filter, "(uid="+user.GetAttributeValue("uid")+")") {
+			result.Entries = append(result.Entries, user)
+		}
+	}
+	return result, nil
+}
+
+func (f *fakeLDAPConn) Close() error {
+	return nil
+}
+
+func withFakeLDAP(t *testing.T, conn *fakeLDAPConn, configure func(settings *system_setting.LDAPSettings)) {
+	t.Helper()
+	settings := system_setting.GetLDAPSettings()
+	original := *settings
+	originalDial := ldapDial
+	settings.Url = "ldap://directory.test:389"
+	settings.BaseDN = "ou=people,dc=example,dc=com"
+	settings.BindDN = "cn=svc,dc=example,dc=com"
+	settings.BindPassword = "svc-password"
+	if configure != nil {
+		configure(settings)
+	}
+	ldapDial = func(*system_setting.LDAPSettings) (ldapConn, error) {
+		return conn, nil
+	}
+	t.Cleanup(func() {
+		*settings = original
+		ldapDial = originalDial
+	})
+}
+
+func newFakeDirectory() *fakeLDAPConn {
+	aliceDN := "uid=alice,ou=people,dc=example,dc=com"
+	return &fakeLDAPConn{
+		passwords: map[string]string{
+			"cn=svc,dc=example,dc=com": "svc-password",
+			aliceDN:                    "alice-password",
+		},
+		users: []*ldap.Entry{
+			ldap.NewEntry(aliceDN, map[string][]string{
+				"entryUUID": {"0e3f2d4c-6d1a-4f7e-9a51-2b8f0c7d1e90"},
+				"uid":       {"alice"},
+				"mail":      {"alice@example.com"},
+				"cn":        {"Alice"},
+				"memberOf":  {"cn=developers,ou=groups,dc=example,dc=com"},
+			}),
+		},
+		groups: []*ldap.Entry{
+			ldap.NewEntry("cn=ops,ou=groups,dc=example,dc=com", map[string][]string{
+				"member": {aliceDN},
+			}),
+		},
+	}
+}
+
+func TestAuthenticateLDAP(t *testing.T) {
+	conn := newFakeDirectory()
+	withFakeLDAP(t, conn, func(settings *system_setting.LDAPSettings) {
+		settings.GroupBaseDN = "ou=groups,dc=example,dc=com"
+		settings.GroupFilter = "(member={dn})"
+	})
+
+	user, err := AuthenticateLDAP("alice", "alice-password")
+	if err != nil {
+		t.Fatalf("AuthenticateLDAP failed: %v", err)
+	}
+	if user.Id != "0e3f2d4c-6d1a-4f7e-9a51-2b8f0c7d1e90" || user.Username != "alice" || user.Email != "alice@example.com" || user.DisplayName != "Alice" {
+		t.Errorf("Unexpected user: %+v", user)
+	}
+	if len(user.Groups) != 2 || user.Groups[1] != "cn=ops,ou=groups,dc=example,dc=com" {
+		t.Errorf("Expected memberOf and searched groups, got %v", user.Groups)
+	}
+
+	if _, err = AuthenticateLDAP("alice", "wrong"); err != errLDAPInvalidCredentials {
+		t.Errorf("Expected invalid credentials, got %v", err)
+	}
+	if _, err = AuthenticateLDAP("alice", ""); err != errLDAPInvalidCredentials {
+		t.Errorf("Expected empty password to be rejected, got %v", err)
+	}
+	if _, err = AuthenticateLDAP("bob", "alice-password"); err != errLDAPInvalidCredentials {
+		t.Errorf("Expected unknown user to be rejected, got %v", err)
+	}
+}
+
+func TestAuthenticateLDAPEscapesFilter(t *testing.T) {
+	conn := newFakeDirectory()
+	withFakeLDAP(t, conn, nil)
+
+	if _, err := AuthenticateLDAP("*)(uid=alice", "alice-password"); err != errLDAPInvalidCredentials {
+		t.Errorf("Expected injected filter to be rejected, got %v", err)
+	}
+	if len(conn.filters) == 0 || strings.Contains(conn.filters[0], "(uid=*)") {
+		t.Errorf("Expected username to be escaped, got %v", conn.filters)
+	}
+}
+
+func TestResolveLDAPMapping(t *testing.T) {
+	mappings := []system_setting.LDAPGroupMapping{
+		{LdapGroup: "developers", Group: "vip"},
+		{LdapGroup: "CN=Admins,OU=Groups,DC=example,DC=com", Role: common.RoleAdminUser, Group: "svip"},
+		{LdapGroup: "root-group", Role: common.RoleRootUser},
+	}
+
+	role, group, matched := ResolveLDAPMapping([]string{"cn=developers,ou=groups,dc=example,dc=com"}, mappings)
+	if !matched || role != 0 || group != "vip" {
+		t.Errorf("Expected developers mapping, got %d %q %v", role, group, matched)
+	}
+
+	role, group, matched = ResolveLDAPMapping([]string{
+		"cn=admins,ou=groups,dc=example,dc=com",
+		"cn=developers,ou=groups,dc=example,dc=com",
+	}, mappings)
+	if !matched || role != common.RoleAdminUser || group != "vip" {
+		t.Errorf("Expected admin role with first group, got %d %q %v", role, group, matched)
+	}
+
+	role, _, _ = ResolveLDAPMapping([]string{"cn=root-group,dc=example,dc=com"}, mappings)
+	if role != common.RoleAdminUser {
+		t.Errorf("Expected root mapping to be capped to admin, got %d", role)
+	}
+
+	if _, _, matched = ResolveLDAPMapping([]string{"cn=sales,dc=example,dc=com"}, mappings); matched {
+		t.Error("Expected no mapping for sales")
+	}
+}
+
+func TestAuthenticateLDAPStableId(t *testing.T) {
+	conn := newFakeDirectory()
+	withFakeLDAP(t, conn, nil)
+
+	first, err := AuthenticateLDAP("alice", "alice-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 用户被移动到其他 OU 后 DN 改变，唯一标识保持不变
+	movedDN := "uid=alice,ou=contractors,dc=example,dc=com"
+	conn.users[0].DN = movedDN
+	conn.passwords[movedDN] = "alice-password"
+	moved, err := AuthenticateLDAP("alice", "alice-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved.DN != movedDN || moved.Id != first.Id {
+		t.Errorf("Expected the same id after moving OU, got %q and %q", first.Id, moved.Id)
+	}
+
+	settings := system_setting.GetLDAPSettings()
+	settings.IdAttribute = "objectGUID"
+	if _, err = AuthenticateLDAP("alice", "alice-password"); err == nil {
+		t.Error("Expected entry without id attribute to be rejected")
+	}
+}
+
+func TestLDAPEntryId(t *testing.T) {
+	guid := []byte{0x4c, 0x2d, 0x3f, 0x0e, 0x1a, 0x6d, 0x7e, 0x4f, 0x9a, 0x51, 0x2b, 0x8f, 0x0c, 0x7d, 0x1e, 0x90}
+	entry := ldap.NewEntry("cn=alice,ou=people,dc=example,dc=com", map[string][]string{
+		"objectGUID": {string(guid)},
+		"entryUUID":  {"0e3f2d4c-6d1a-4f7e-9a51-2b8f0c7d1e90"},
+	})
+	cases := []struct {
+		attribute string
+		expected  string
+	}{
+		{"objectGUID", "4c2d3f0e1a6d7e4f9a512b8f0c7d1e90"},
+		{"entryUUID", "0e3f2d4c-6d1a-4f7e-9a51-2b8f0c7d1e90"},
+		{"uid", ""},
+		{"", ""},
+	}
+	for _, tc := range cases {
+		if got := ldapEntryId(entry, tc.attribute); got != tc.expected {
+			t.Errorf("ldapEntryId(%q) = %q, want %q", tc.attribute, got, tc.expected)
+		}
+	}
+}
+
+func TestAuthenticateLDAPGroupSearchFailure(t *testing.T) {
+	conn := newFakeDirectory()
+	conn.groupErr = ldap.NewError(ldap.LDAPResultBusy, errors.New("busy"))
+	withFakeLDAP(t, conn, func(settings *system_setting.LDAPSettings) {
+		settings.GroupBaseDN = "ou=groups,dc=example,dc=com"
+		settings.GroupFilter = "(member={dn})"
+	})
+
+	// 只拿到部分组时不能继续登录，否则会按未映射用户降级
+	if user, err := AuthenticateLDAP("alice", "alice-password"); err == nil {
+		t.Errorf("Expected group search failure to reject login, got %+v", user)
+	}
+}
@@ -33,6 +33,15 @@ func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, m
 	if isSystemPromptOverwritten {
 		other["is_system_prompt_overwritten"] = true
 	}
+	if structuredOutput, ok := common.GetContextKey(ctx, constant.ContextKeyStructuredOutput); ok {
+		other["structured_output"] = structuredOutput
+	}
+	if contextWindow, ok := common.GetContextKey(ctx, constant.ContextKeyContextWindow); ok {
+		other["context_window"] = contextWindow
+	}
+	if relayInfo.StreamBudget.Exhausted() {
+		other["stream_budget"] = relayInfo.StreamBudget
+	}
 
 	adminInfo := make(map[string]interface{})
 	adminInfo["use_channel"] = ctx.GetStringSlice("use_channel")
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting/system_setting"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OAuthIdentity 从提供方用户信息中按映射提取的外部身份
+type OAuthIdentity struct {
+	ExternalId  string
+	Username    string
+	DisplayName string
+	Email       string
+}
+
+var oauthProviderNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+var oauthHttpClient = &http.Client{Timeout: 10 * time.Second}
+
+// OAuthRedirectURI 提供方的回调地址，需要在提供方处登记
+func OAuthRedirectURI(provider *system_setting.OAuthProvider) string {
+	return fmt.Sprintf("%s/oauth/%s", strings.TrimSuffix(system_setting.ServerAddress, "/"), provider.Name)
+}
+
+// ExtractOAuthClaim 按点号分隔的路径从用户信息中取值，数字会被转换为字符串
+func ExtractOAuthClaim(data map[string]interface{}, path string) string {
+	if path == "" {
+		return ""
+	}
+	var current interface{} = data
+	for _, key := range strings.Split(path, ".") {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		current = object[key]
+	}
+	switch value := current.(type) {
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	}
+	return ""
+}
+
+func exchangeOAuthCode(provider *system_setting.OAuthProvider, code string) (string, error) {
+	values := url.Values{}
+	values.Set("grant_type", "authorization_code")
+	values.Set("code", code)
+	values.Set("redirect_uri", OAuthRedirectURI(provider))
+	if provider.ClientAuthMethod != "client_secret_basic" {
+		values.Set("client_id", provider.ClientId)
+		values.Set("client_secret", provider.ClientSecret)
+	}
+	req, err := http.NewRequest(http.MethodPost, provider.TokenEndpoint, strings.NewReader(values.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if provider.ClientAuthMethod == "client_secret_basic" {
+		req.SetBasicAuth(url.QueryEscape(provider.ClientId), url.QueryEscape(provider.ClientSecret))
+	}
+	res, err := oauthHttpClient.Do(req)
+	if err != nil {
+		common.SysLog(fmt.Sprintf("oauth provider %s token request failed: %s", provider.Name, err.Error()))
+		return "", fmt.Errorf("无法连接至 %s 服务器，请稍后重试！", provider.DisplayName)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	var tokenResponse struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err = common.Unmarshal(body, &tokenResponse); err != nil {
+		// 部分提供方忽略 Accept 头，以表单格式返回
+		form, formErr := url.ParseQuery(string(body))
+		if formErr != nil {
+			return "", err
+		}
+		tokenResponse.AccessToken = form.Get("access_token")
+		tokenResponse.Error = form.Get("error")
+	}
+	if tokenResponse.AccessToken == "" {
+		common.SysLog(fmt.Sprintf("oauth provider %s token exchange failed: status %d, %s %s",
+			provider.Name, res.StatusCode, tokenResponse.Error, tokenResponse.ErrorDescription))
+		return "", fmt.Errorf("%s 获取 Token 失败，请检查设置！", provider.DisplayName)
+	}
+	return tokenResponse.AccessToken, nil
+}
+
+// FetchOAuthIdentity 使用授权码换取访问令牌，再从用户信息端点按声明映射提取外部身份
+func FetchOAuthIdentity(provider *system_setting.OAuthProvider, code string) (*OAuthIdentity, error) {
+	if code == "" {
+		return nil, errors.New("无效的参数")
+	}
+	accessToken, err := exchangeOAuthCode(provider, code)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, provider.UserInfoEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+	res, err := oauthHttpClient.Do(req)
+	if err != nil {
+		common.SysLog(fmt.Sprintf("oauth provider %s userinfo request failed: %s", provider.Name, err.Error()))
+		return nil, fmt.Errorf("无法连接至 %s 服务器，请稍后重试！", provider.DisplayName)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		common.SysLog(fmt.Sprintf("oauth provider %s userinfo returned status %d", provider.Name, res.StatusCode))
+		return nil, fmt.Errorf("%s 获取用户信息失败！请检查设置！", provider.DisplayName)
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	// 使用 UseNumber 避免较大的数字 ID 丢失精度
+	var claims map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err = decoder.Decode(&claims); err != nil {
+		return nil, err
+	}
+	mapping := provider.ClaimsMapping
+	identity := &OAuthIdentity{
+		ExternalId:  ExtractOAuthClaim(claims, mapping.Id),
+		Username:    ExtractOAuthClaim(claims, mapping.Username),
+		DisplayName: ExtractOAuthClaim(claims, mapping.DisplayName),
+		Email:       ExtractOAuthClaim(claims, mapping.Email),
+	}
+	if identity.ExternalId == "" {
+		common.SysLog(fmt.Sprintf("oauth provider %s userinfo has no claim %s", provider.Name, mapping.Id))
+		return nil, fmt.Errorf("%s 获取用户信息为空！请检查设置！", provider.DisplayName)
+	}
+	return identity, nil
+}
+
+// ValidateOAuthProviders 校验提供方配置，名称不能重复也不能与内置登录方式冲突
+func ValidateOAuthProviders(providers []system_setting.OAuthProvider) error {
+	names := make(map[string]bool, len(providers))
+	for _, provider := range providers {
+		if !oauthProviderNamePattern.MatchString(provider.Name) {
+			return fmt.Errorf("无效的提供方名称: %s", provider.Name)
+		}
+		if model.IsBuiltinIdentityProvider(provider.Name) || provider.Name == "state" || provider.Name == "email" {
+			return fmt.Errorf("提供方名称 %s 与内置登录方式冲突", provider.Name)
+		}
+		if names[provider.Name] {
+			return fmt.Errorf("提供方名称 %s 重复", provider.Name)
+		}
+		names[provider.Name] = true
+		if !provider.Enabled {
+			continue
+		}
+		if provider.ClientId == "" || provider.ClientSecret == "" {
+			return fmt.Errorf("%s 未填写 Client Id 或 Client Secret", provider.Name)
+		}
+		for _, endpoint := range []string{provider.AuthorizationEndpoint, provider.TokenEndpoint, provider.UserInfoEndpoint} {
+			parsed, err := url.Parse(endpoint)
+			if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+				return fmt.Errorf("%s 的端点地址无效: %s", provider.Name, endpoint)
+			}
+		}
+		if provider.ClaimsMapping.Id == "" {
+			return fmt.Errorf("%s 未配置用户标识的声明映射", provider.Name)
+		}
+		if provider.ClientAuthMethod != "" && provider.ClientAuthMethod != "client_secret_post" && provider.ClientAuthMethod != "client_secret_basic" {
+			return fmt.Errorf("%s 的客户端认证方式无效: %s", provider.Name, provider.ClientAuthMethod)
+		}
+	}
+	return nil
+}
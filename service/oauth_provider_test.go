@@ -0,0 +1,126 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/setting/system_setting"
+	"testing"
+)
+
+func TestExtractOAuthClaim(t *testing.T) {
+	var claims map[string]interface{}
+	if err := common.UnmarshalJsonStr(`{"sub":"abc","data":{"user":{"id":12345678901,"name":"Alice"}},"verified":true}`, &claims); err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]string{
+		"sub":            "abc",
+		"data.user.id":   "12345678901",
+		"data.user.name": "Alice",
+		"verified":       "true",
+		"data.missing":   "",
+		"sub.nested":     "",
+		"":               "",
+	}
+	for path, expected := range cases {
+		if got := ExtractOAuthClaim(claims, path); got != expected {
+			t.Errorf("ExtractOAuthClaim(%q) = %q, expected %q", path, got, expected)
+		}
+	}
+}
+
+func TestFetchOAuthIdentity(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			_ = r.ParseForm()
+			if r.PostForm.Get("code") != "good-code" || r.PostForm.Get("client_secret") != "secret" {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+				return
+			}
+			// 模拟忽略 Accept 头、以表单格式返回的提供方
+			_, _ = w.Write([]byte("access_token=token-1&token_type=bearer"))
+		case "/userinfo":
+			if r.Header.Get("Authorization") != "Bearer token-1" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(`{"data":{"open_id":"ou_123","name":"Alice","email":"alice@example.com","en_name":"alice"}}`))
+		}
+	}))
+	defer server.Close()
+
+	provider := &system_setting.OAuthProvider{
+		Name:             "feishu",
+		DisplayName:      "Feishu",
+		Enabled:          true,
+		ClientId:         "client",
+		ClientSecret:     "secret",
+		TokenEndpoint:    server.URL + "/token",
+		UserInfoEndpoint: server.URL + "/userinfo",
+		ClaimsMapping: system_setting.OAuthClaimsMapping{
+			Id:          "data.open_id",
+			Username:    "data.en_name",
+			DisplayName: "data.name",
+			Email:       "data.email",
+		},
+	}
+	identity, err := FetchOAuthIdentity(provider, "good-code")
+	if err != nil {
+		t.Fatalf("FetchOAuthIdentity failed: %v", err)
+	}
+	if identity.ExternalId != "ou_123" || identity.Username != "alice" || identity.DisplayName != "Alice" || identity.Email != "alice@example.com" {
+		t.Errorf("Unexpected identity: %+v", identity)
+	}
+
+	if _, err = FetchOAuthIdentity(provider, "bad-code"); err == nil {
+		t.Error("Expected token exchange to fail")
+	}
+
+	provider.ClaimsMapping.Id = "data.union_id"
+	if _, err = FetchOAuthIdentity(provider, "good-code"); err == nil {
+		t.Error("Expected missing id claim to fail")
+	}
+}
+
+func TestValidateOAuthProviders(t *testing.T) {
+	valid := system_setting.OAuthProvider{
+		Name:                  "gitlab",
+		Enabled:               true,
+		ClientId:              "id",
+		ClientSecret:          "secret",
+		AuthorizationEndpoint: "https://gitlab.example.com/oauth/authorize",
+		TokenEndpoint:         "https://gitlab.example.com/oauth/token",
+		UserInfoEndpoint:      "https://gitlab.example.com/api/v4/user",
+		ClaimsMapping:         system_setting.OAuthClaimsMapping{Id: "id"},
+	}
+	if err := ValidateOAuthProviders([]system_setting.OAuthProvider{valid}); err != nil {
+		t.Fatalf("Expected valid provider, got %v", err)
+	}
+
+	builtin := valid
+	builtin.Name = "github"
+	duplicate := valid
+	badEndpoint := valid
+	badEndpoint.Name = "google"
+	badEndpoint.TokenEndpoint = "ftp://example.com"
+	badName := valid
+	badName.Name = "Bad Name"
+	for name, providers := range map[string][]system_setting.OAuthProvider{
+		"builtin":      {builtin},
+		"duplicate":    {valid, duplicate},
+		"bad endpoint": {badEndpoint},
+		"bad name":     {badName},
+	} {
+		if err := ValidateOAuthProviders(providers); err == nil {
+			t.Errorf("Expected %s to be rejected", name)
+		}
+	}
+
+	disabled := badEndpoint
+	disabled.Enabled = false
+	if err := ValidateOAuthProviders([]system_setting.OAuthProvider{disabled}); err != nil {
+		t.Errorf("Expected disabled provider to skip endpoint checks, got %v", err)
+	}
+}
@@ -0,0 +1,335 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting/system_setting"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+const passkeyCeremonyTimeout = 5 * time.Minute
+
+// passkeyUser 将用户与其凭据适配为 webauthn.User
+type passkeyUser struct {
+	user        *model.User
+	passkeys    []*model.Passkey
+	credentials []webauthn.Credential
+}
+
+func (u *passkeyUser) WebAuthnID() []byte {
+	return passkeyUserHandle(u.user.Id)
+}
+
+func (u *passkeyUser) WebAuthnName() string {
+	return u.user.Username
+}
+
+func (u *passkeyUser) WebAuthnDisplayName() string {
+	if u.user.DisplayName != "" {
+		return u.user.DisplayName
+	}
+	return u.user.Username
+}
+
+func (u *passkeyUser) WebAuthnIcon() string {
+	return ""
+}
+
+func (u *passkeyUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+// passkeyUserHandle 用户句柄只包含用户 ID，不包含用户名等个人信息
+func passkeyUserHandle(userId int) []byte {
+	return []byte(strconv.Itoa(userId))
+}
+
+func loadPasskeyUser(user *model.User) (*passkeyUser, error) {
+	passkeys, err := model.GetUserPasskeys(user.Id)
+	if err != nil {
+		return nil, err
+	}
+	u := &passkeyUser{user: user, passkeys: passkeys}
+	for _, passkey := range passkeys {
+		var credential webauthn.Credential
+		if err = common.Unmarshal([]byte(passkey.Credential), &credential); err != nil {
+			return nil, err
+		}
+		u.credentials = append(u.credentials, credential)
+	}
+	return u, nil
+}
+
+func (u *passkeyUser) exclusions() []protocol.CredentialDescriptor {
+	descriptors := make([]protocol.CredentialDescriptor, 0, len(u.credentials))
+	for _, credential := range u.credentials {
+		descriptors = append(descriptors, credential.Descriptor())
+	}
+	return descriptors
+}
+
+func getWebAuthn() (*webauthn.WebAuthn, error) {
+	settings := system_setting.GetPasskeySettings()
+	if !settings.Enabled {
+		return nil, errors.New("管理员未开启通行密钥登录")
+	}
+	rpId := settings.RPID
+	origins := settings.RPOrigins
+	serverAddress := strings.TrimSuffix(system_setting.ServerAddress, "/")
+	if rpId == "" {
+		parsed, err := url.Parse(serverAddress)
+		if err != nil || parsed.Hostname() == "" {
+			return nil, errors.New("无法从服务器地址推断通行密钥 RP ID，请检查设置")
+		}
+		rpId = parsed.Hostname()
+	}
+	if len(origins) == 0 {
+		origins = []string{serverAddress}
+	}
+	displayName := settings.RPDisplayName
+	if displayName == "" {
+		displayName = common.SystemName
+	}
+	timeout := webauthn.TimeoutConfig{Enforce: true, Timeout: passkeyCeremonyTimeout, TimeoutUVD: passkeyCeremonyTimeout}
+	return webauthn.New(&webauthn.Config{
+		RPID:          rpId,
+		RPDisplayName: displayName,
+		RPOrigins:     origins,
+		Timeouts:      webauthn.TimeoutsConfig{Login: timeout, Registration: timeout},
+	})
+}
+
+func encodePasskeySession(session *webauthn.SessionData) (string, error) {
+	data, err := common.Marshal(session)
+	return string(data), err
+}
+
+func decodePasskeySession(data string) (*webauthn.SessionData, error) {
+	if data == "" {
+		return nil, errors.New("通行密钥验证会话已过期，请重试")
+	}
+	var session webauthn.SessionData
+	if err := common.Unmarshal([]byte(data), &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// BeginPasskeyRegistration 生成注册选项，返回给浏览器的选项与需要保存在会话中的状态
+func BeginPasskeyRegistration(user *model.User) (*protocol.CredentialCreation, string, error) {
+	w, err := getWebAuthn()
+	if err != nil {
+		return nil, "", err
+	}
+	u, err := loadPasskeyUser(user)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(u.credentials) >= model.MaxPasskeysPerUser {
+		return nil, "", errors.New("通行密钥数量已达上限")
+	}
+	creation, session, err := w.BeginRegistration(u,
+		webauthn.WithExclusions(u.exclusions()),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+	)
+	if err != nil {
+		return nil, "", err
+	}
+	sessionData, err := encodePasskeySession(session)
+	return creation, sessionData, err
+}
+
+// FinishPasskeyRegistration 校验浏览器返回的注册结果并保存凭据
+func FinishPasskeyRegistration(user *model.User, sessionData string, r *http.Request, name string) (*model.Passkey, error) {
+	w, err := getWebAuthn()
+	if err != nil {
+		return nil, err
+	}
+	session, err := decodePasskeySession(sessionData)
+	if err != nil {
+		return nil, err
+	}
+	u, err := loadPasskeyUser(user)
+	if err != nil {
+		return nil, err
+	}
+	credential, err := w.FinishRegistration(u, *session, r)
+	if err != nil {
+		return nil, errors.New("通行密钥注册失败：" + err.Error())
+	}
+	data, err := common.Marshal(credential)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = "Passkey " + time.Now().Format("2006-01-02")
+	}
+	passkey := &model.Passkey{
+		UserId:       user.Id,
+		Name:         name,
+		CredentialId: base64.RawURLEncoding.EncodeToString(credential.ID),
+		Credential:   string(data),
+		BackedUp:     credential.Flags.BackupState,
+	}
+	if err = passkey.Insert(); err != nil {
+		return nil, err
+	}
+	return passkey, nil
+}
+
+// BeginPasskeyLogin 开始无密码登录，由浏览器选择可发现凭据，不需要先提供用户名
+func BeginPasskeyLogin() (*protocol.CredentialAssertion, string, error) {
+	w, err := getWebAuthn()
+	if err != nil {
+		return nil, "", err
+	}
+	assertion, session, err := w.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		return nil, "", err
+	}
+	sessionData, err := encodePasskeySession(session)
+	return assertion, sessionData, err
+}
+
+// FinishPasskeyLogin 校验无密码登录结果，返回对应的用户
+func FinishPasskeyLogin(sessionData string, r *http.Request) (*model.User, error) {
+	w, err := getWebAuthn()
+	if err != nil {
+		return nil, err
+	}
+	session, err := decodePasskeySession(sessionData)
+	if err != nil {
+		return nil, err
+	}
+	var loginUser *passkeyUser
+	credential, err := w.FinishDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+		userId, err := strconv.Atoi(string(userHandle))
+		if err != nil {
+			return nil, err
+		}
+		user, err := model.GetUserById(userId, false)
+		if err != nil {
+			return nil, err
+		}
+		loginUser, err = loadPasskeyUser(user)
+		return loginUser, err
+	}, *session, r)
+	if err != nil {
+		return nil, errors.New("通行密钥验证失败")
+	}
+	if err = savePasskeyUsage(loginUser, credential); err != nil {
+		return nil, err
+	}
+	return loginUser.user, nil
+}
+
+// BeginPasskeyVerification 开始以通行密钥作为两步验证，只允许该用户已注册的凭据
+func BeginPasskeyVerification(user *model.User) (*protocol.CredentialAssertion, string, error) {
+	w, err := getWebAuthn()
+	if err != nil {
+		return nil, "", err
+	}
+	u, err := loadPasskeyUser(user)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(u.credentials) == 0 {
+		return nil, "", errors.New("用户未注册通行密钥")
+	}
+	assertion, session, err := w.BeginLogin(u)
+	if err != nil {
+		return nil, "", err
+	}
+	sessionData, err := encodePasskeySession(session)
+	return assertion, sessionData, err
+}
+
+// FinishPasskeyVerification 校验两步验证中的通行密钥断言
+func FinishPasskeyVerification(user *model.User, sessionData string, r *http.Request) error {
+	w, err := getWebAuthn()
+	if err != nil {
+		return err
+	}
+	session, err := decodePasskeySession(sessionData)
+	if err != nil {
+		return err
+	}
+	u, err := loadPasskeyUser(user)
+	if err != nil {
+		return err
+	}
+	credential, err := w.FinishLogin(u, *session, r)
+	if err != nil {
+		return errors.New("通行密钥验证失败")
+	}
+	return savePasskeyUsage(u, credential)
+}
+
+// savePasskeyUsage 更新签名计数，计数回退说明凭据可能被克隆，拒绝本次登录
+func savePasskeyUsage(u *passkeyUser, credential *webauthn.Credential) error {
+	if credential.Authenticator.CloneWarning {
+		return errors.New("检测到通行密钥签名计数异常，凭据可能已被复制，请联系管理员")
+	}
+	credentialId := base64.RawURLEncoding.EncodeToString(credential.ID)
+	for _, passkey := range u.passkeys {
+		if passkey.CredentialId != credentialId {
+			continue
+		}
+		data, err := common.Marshal(credential)
+		if err != nil {
+			return err
+		}
+		passkey.Credential = string(data)
+		passkey.BackedUp = credential.Flags.BackupState
+		return passkey.UpdateUsage()
+	}
+	return errors.New("通行密钥不存在")
+}
+
+// PasskeyRequiredForPermissions 具有任意管理权限的用户是否必须使用通行密钥
+func PasskeyRequiredForPermissions(permissions []string) bool {
+	settings := system_setting.GetPasskeySettings()
+	return settings.Enabled && settings.RequireForAdmin && len(permissions) > 0
+}
+
+// PasskeyRequiredForUser 按有效权限判断用户是否必须使用通行密钥，
+// 绑定了管理权限自定义角色的普通用户同样受要求
+func PasskeyRequiredForUser(userId int, role int) bool {
+	permissions, err := model.GetUserPermissions(userId, role, false)
+	if err != nil {
+		permissions = model.DefaultRolePermissions(role)
+	}
+	return PasskeyRequiredForPermissions(permissions)
+}
+
+// PasskeyVerifiedForUser 要求使用通行密钥的用户是否已通过通行密钥验证，不受要求的用户始终返回 true
+func PasskeyVerifiedForUser(userId int, role int, passkeyVerified bool) bool {
+	return passkeyVerified || !PasskeyRequiredForUser(userId, role)
+}
+
+// PasskeyChangeAllowed 必须使用通行密钥且已注册过通行密钥的用户，只能在通行密钥验证过的会话中注册或删除通行密钥，
+// 否则通过第三方登录等未经两步验证的会话即可注册新的通行密钥绕过要求
+func PasskeyChangeAllowed(required bool, hasPasskey bool, passkeyVerified bool) bool {
+	return !required || !hasPasskey || passkeyVerified
+}
+
+// TwoFAMethods 返回登录时可用的两步验证方式；必须使用通行密钥且已注册通行密钥时不接受 TOTP
+func TwoFAMethods(passkeyRequired bool, hasPasskey bool, totpEnabled bool) []string {
+	var methods []string
+	if hasPasskey {
+		methods = append(methods, "passkey")
+	}
+	if totpEnabled && !(hasPasskey && passkeyRequired) {
+		methods = append(methods, "totp")
+	}
+	return methods
+}
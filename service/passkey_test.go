@@ -0,0 +1,122 @@
+package service
+
+import (
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting/system_setting"
+	"reflect"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+func setPasskeySettings(t *testing.T, enabled bool, requireForAdmin bool) {
+	t.Helper()
+	settings := system_setting.GetPasskeySettings()
+	old := *settings
+	settings.Enabled = enabled
+	settings.RequireForAdmin = requireForAdmin
+	t.Cleanup(func() { *settings = old })
+}
+
+func TestPasskeyRequiredForPermissions(t *testing.T) {
+	setPasskeySettings(t, true, true)
+	if PasskeyRequiredForPermissions(nil) {
+		t.Error("Expected users without management permissions not to require passkey")
+	}
+	if !PasskeyRequiredForPermissions(model.DefaultRolePermissions(common.RoleAdminUser)) ||
+		!PasskeyRequiredForPermissions(model.DefaultRolePermissions(common.RoleRootUser)) {
+		t.Error("Expected admins to require passkey")
+	}
+	// 绑定了管理权限自定义角色的普通用户同样需要通行密钥
+	if !PasskeyRequiredForPermissions([]string{model.PermissionUsersManage}) {
+		t.Error("Expected custom role with management permissions to require passkey")
+	}
+
+	setPasskeySettings(t, true, false)
+	if PasskeyRequiredForPermissions(model.DefaultRolePermissions(common.RoleRootUser)) {
+		t.Error("Expected passkey not to be required without RequireForAdmin")
+	}
+	setPasskeySettings(t, false, true)
+	if PasskeyRequiredForPermissions(model.DefaultRolePermissions(common.RoleRootUser)) {
+		t.Error("Expected passkey not to be required when passkey login is disabled")
+	}
+}
+
+func TestPasskeyChangeAllowed(t *testing.T) {
+	cases := []struct {
+		required, hasPasskey, verified bool
+		expected                       bool
+	}{
+		{false, true, false, true},
+		// 尚未注册通行密钥的管理员需要先注册
+		{true, false, false, true},
+		// 通过第三方登录的管理员不能再注册或删除通行密钥
+		{true, true, false, false},
+		{true, true, true, true},
+	}
+	for _, tc := range cases {
+		if got := PasskeyChangeAllowed(tc.required, tc.hasPasskey, tc.verified); got != tc.expected {
+			t.Errorf("PasskeyChangeAllowed(%v, %v, %v) = %v, expected %v", tc.required, tc.hasPasskey, tc.verified, got, tc.expected)
+		}
+	}
+}
+
+func TestTwoFAMethods(t *testing.T) {
+	cases := []struct {
+		required    bool
+		hasPasskey  bool
+		totpEnabled bool
+		expected    []string
+	}{
+		{false, false, false, nil},
+		{false, true, true, []string{"passkey", "totp"}},
+		{true, true, true, []string{"passkey"}},
+		// 尚未注册通行密钥的管理员仍可使用 TOTP 登录，以便注册通行密钥
+		{true, false, true, []string{"totp"}},
+		{true, true, false, []string{"passkey"}},
+	}
+	for _, tc := range cases {
+		methods := TwoFAMethods(tc.required, tc.hasPasskey, tc.totpEnabled)
+		if !reflect.DeepEqual(methods, tc.expected) {
+			t.Errorf("TwoFAMethods(%v, %v, %v) = %v, expected %v", tc.required, tc.hasPasskey, tc.totpEnabled, methods, tc.expected)
+		}
+	}
+}
+
+func TestPasskeySessionRoundTrip(t *testing.T) {
+	if _, err := decodePasskeySession(""); err == nil {
+		t.Error("Expected empty session to fail")
+	}
+	data, err := encodePasskeySession(&webauthn.SessionData{Challenge: "challenge", UserID: passkeyUserHandle(42)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := decodePasskeySession(data)
+	if err != nil || session.Challenge != "challenge" || string(session.UserID) != "42" {
+		t.Errorf("Unexpected decoded session: %+v %v", session, err)
+	}
+}
+
+func TestGetWebAuthnConfig(t *testing.T) {
+	setPasskeySettings(t, false, false)
+	if _, err := getWebAuthn(); err == nil {
+		t.Error("Expected disabled passkey login to fail")
+	}
+
+	setPasskeySettings(t, true, false)
+	oldAddress := system_setting.ServerAddress
+	t.Cleanup(func() { system_setting.ServerAddress = oldAddress })
+	system_setting.ServerAddress = "not a url"
+	if _, err := getWebAuthn(); err == nil {
+		t.Error("Expected invalid server address to fail")
+	}
+	system_setting.ServerAddress = "https://api.example.com/"
+	w, err := getWebAuthn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Config.RPID != "api.example.com" || !reflect.DeepEqual(w.Config.RPOrigins, []string{"https://api.example.com"}) {
+		t.Errorf("Unexpected RP config: %s %v", w.Config.RPID, w.Config.RPOrigins)
+	}
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/logger"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+// checkPostpaidCreditLimit 后付费用户消费后按信用额度的使用比例发送预警，透支达到信用额度时自动禁用账户
+func checkPostpaidCreditLimit(relayInfo *relaycommon.RelayInfo, consumeQuota int, sendNotify bool) {
+	gopool.Go(func() {
+		balance, err := model.GetUserQuota(relayInfo.UserId, false)
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to get quota of postpaid user %d: %s", relayInfo.UserId, err.Error()))
+			return
+		}
+		if balance+relayInfo.UserCreditLimit <= 0 {
+			SuspendPostpaidUser(relayInfo)
+			return
+		}
+		if !sendNotify {
+			return
+		}
+		threshold := crossedCreditThreshold(relayInfo.UserCreditLimit, balance+consumeQuota, balance, operation_setting.GetPostpaidSetting().NotifyThresholds)
+		if threshold == 0 {
+			return
+		}
+		title := "您的信用额度即将用尽"
+		content := "{{value}}，已使用信用额度 {{value}}%（{{value}} / {{value}}），达到信用额度后账户将被暂停，请及时结清欠款。"
+		values := []interface{}{title, threshold, logger.FormatQuota(-balance), logger.FormatQuota(relayInfo.UserCreditLimit)}
+		err = NotifyUser(relayInfo.UserId, relayInfo.UserEmail, relayInfo.UserSetting, dto.NewNotify(dto.NotifyTypeCreditLimit, title, content, values))
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to send credit limit notify to user %d: %s", relayInfo.UserId, err.Error()))
+		}
+	})
+}
+
+// crossedCreditThreshold 返回余额从 before 变为 after 时越过的最高预警阈值（信用额度已使用的百分比），未越过时返回 0
+func crossedCreditThreshold(creditLimit int, before int, after int, thresholds []int) int {
+	if creditLimit <= 0 {
+		return 0
+	}
+	usedPercent := func(balance int) float64 {
+		return float64(max(-balance, 0)) * 100 / float64(creditLimit)
+	}
+	from, to := usedPercent(before), usedPercent(after)
+	crossed := 0
+	for _, threshold := range thresholds {
+		if threshold > crossed && from < float64(threshold) && to >= float64(threshold) {
+			crossed = threshold
+		}
+	}
+	return crossed
+}
+
+// SuspendPostpaidUser 后付费用户透支达到信用额度时按设置自动禁用账户，并记录日志、通知用户
+func SuspendPostpaidUser(relayInfo *relaycommon.RelayInfo) {
+	if !operation_setting.GetPostpaidSetting().AutoSuspend {
+		return
+	}
+	suspended, err := model.SuspendPostpaidUser(relayInfo.UserId)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to suspend postpaid user %d: %s", relayInfo.UserId, err.Error()))
+		return
+	}
+	if !suspended {
+		return
+	}
+	model.RecordLog(relayInfo.UserId, model.LogTypeSystem, fmt.Sprintf("后付费账户透支达到信用额度 %s，账户已自动暂停", logger.LogQuota(relayInfo.UserCreditLimit)))
+	title := "您的账户已暂停"
+	content := "{{value}}，透支已达到信用额度 {{value}}，结清欠款后请联系管理员恢复账户。"
+	values := []interface{}{title, logger.FormatQuota(relayInfo.UserCreditLimit)}
+	err = NotifyUser(relayInfo.UserId, relayInfo.UserEmail, relayInfo.UserSetting, dto.NewNotify(dto.NotifyTypeUserSuspended, title, content, values))
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to send suspend notify to user %d: %s", relayInfo.UserId, err.Error()))
+	}
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"one-api/setting/operation_setting"
+	"testing"
+)
+
+func TestCrossedCreditThreshold(t *testing.T) {
+	thresholds := []int{80, 50, 90}
+	cases := []struct {
+		before, after int
+		expected      int
+	}{
+		{100, 50, 0},     // 余额仍为正
+		{-400, -600, 50}, // 40% -> 60%
+		{-400, -950, 90}, // 一次越过多个阈值时取最高的
+		{-850, -880, 0},  // 已在 80% 之上，未达到 90%
+		{-900, -950, 0},  // 已经通知过 90%
+	}
+	for _, tc := range cases {
+		if got := crossedCreditThreshold(1000, tc.before, tc.after, thresholds); got != tc.expected {
+			t.Errorf("crossedCreditThreshold(%d -> %d) = %d, want %d", tc.before, tc.after, got, tc.expected)
+		}
+	}
+	if crossedCreditThreshold(0, 0, -100, thresholds) != 0 {
+		t.Error("expected prepaid users to never cross a credit threshold")
+	}
+}
+
+func TestResolveCreditLimit(t *testing.T) {
+	setting := operation_setting.GetPostpaidSetting()
+	previous := setting.GroupCreditLimits
+	setting.GroupCreditLimits = map[string]int{"enterprise": 5000}
+	t.Cleanup(func() { setting.GroupCreditLimits = previous })
+
+	cases := []struct {
+		mode     string
+		limit    int
+		group    string
+		expected int
+	}{
+		{operation_setting.BillingModeDefault, 0, "default", 0},
+		{operation_setting.BillingModeDefault, 0, "enterprise", 5000},
+		{operation_setting.BillingModeDefault, 8000, "enterprise", 8000},
+		{operation_setting.BillingModePrepaid, 8000, "enterprise", 0},
+		{operation_setting.BillingModePostpaid, 2000, "default", 2000},
+		{operation_setting.BillingModePostpaid, 0, "enterprise", 5000},
+	}
+	for _, tc := range cases {
+		if got := operation_setting.ResolveCreditLimit(tc.mode, tc.limit, tc.group); got != tc.expected {
+			t.Errorf("ResolveCreditLimit(%q, %d, %q) = %d, want %d", tc.mode, tc.limit, tc.group, got, tc.expected)
+		}
+	}
+}
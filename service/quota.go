@@ -89,7 +89,7 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 	if relayInfo.UsePrice {
 		return nil
 	}
-	userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
+	userQuota, err := GetBillingQuota(relayInfo)
 	if err != nil {
 		return err
 	}
@@ -490,9 +490,9 @@ func PreConsumeTokenQuota(relayInfo *relaycommon.RelayInfo, quota int) error {
 func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQuota int, sendEmail bool) (err error) {
 
 	if quota > 0 {
-		err = model.DecreaseUserQuota(relayInfo.UserId, quota)
+		err = decreaseBillingQuota(relayInfo, quota)
 	} else {
-		err = model.IncreaseUserQuota(relayInfo.UserId, -quota, false)
+		err = increaseBillingQuota(relayInfo, -quota)
 	}
 	if err != nil {
 		return err
@@ -509,7 +509,13 @@ func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQu
 		}
 	}
 
-	if sendEmail {
+	// 组织额度池的余量不属于成员个人，不发送额度预警
+	if relayInfo.OrgId == 0 && relayInfo.UserCreditLimit > 0 {
+		// 后付费用户按信用额度预警，透支达到上限时自动暂停
+		if quota+preConsumedQuota > 0 {
+			checkPostpaidCreditLimit(relayInfo, quota+preConsumedQuota, sendEmail)
+		}
+	} else if sendEmail && relayInfo.OrgId == 0 {
 		if (quota + preConsumedQuota) != 0 {
 			checkAndSendQuotaNotify(relayInfo, quota, preConsumedQuota)
 		}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"crypto/subtle"
+	"encoding/hex"
+	"one-api/common"
+	"one-api/setting/system_setting"
+)
+
+const scimTokenPrefix = "scim_"
+
+func hashScimToken(token string) string {
+	return hex.EncodeToString(common.Sha256Raw([]byte(token)))
+}
+
+// GenerateScimToken 生成新的 SCIM bearer token，返回明文与需要保存的哈希
+func GenerateScimToken() (token string, hash string) {
+	token = scimTokenPrefix + common.GetRandomString(48)
+	return token, hashScimToken(token)
+}
+
+// ValidateScimToken 校验 SCIM bearer token，未启用或未生成令牌时一律拒绝
+func ValidateScimToken(token string) bool {
+	settings := system_setting.GetSCIMSettings()
+	if !settings.Enabled || settings.TokenHash == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hashScimToken(token)), []byte(settings.TokenHash)) == 1
+}
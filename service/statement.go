@@ -0,0 +1,223 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"sync"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+type StatementRequest struct {
+	UserId      int    `json:"user_id"`
+	TokenId     int    `json:"token_id"`
+	Group       string `json:"group"`
+	PeriodStart int64  `json:"period_start"`
+	PeriodEnd   int64  `json:"period_end"`
+}
+
+// MonthPeriod 返回 t 所在月份的 [start, end) 时间戳
+func MonthPeriod(t time.Time) (int64, int64) {
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	return start.Unix(), start.AddDate(0, 1, 0).Unix()
+}
+
+// normalizePeriod 未指定周期时默认为上个自然月，并校验周期是否有效
+func (req *StatementRequest) normalizePeriod() error {
+	if req.PeriodStart == 0 && req.PeriodEnd == 0 {
+		req.PeriodStart, req.PeriodEnd = MonthPeriod(time.Now().AddDate(0, -1, 0))
+	}
+	if req.PeriodStart < 0 || req.PeriodEnd <= req.PeriodStart {
+		return errors.New("账单周期无效")
+	}
+	if req.PeriodEnd > common.GetTimestamp() {
+		return errors.New("账单周期尚未结束")
+	}
+	return nil
+}
+
+// CreateSelfServiceStatement 用户为自己生成账单，周期长度受 SelfServiceMaxDays 限制，
+// 避免一次请求扫描全部历史日志
+func CreateSelfServiceStatement(req StatementRequest) (*model.Statement, error) {
+	if err := req.normalizePeriod(); err != nil {
+		return nil, err
+	}
+	maxDays := operation_setting.GetStatementSetting().SelfServiceMaxDays
+	if maxDays > 0 && req.PeriodEnd-req.PeriodStart > int64(maxDays)*86400 {
+		return nil, fmt.Errorf("账单周期不能超过 %d 天", maxDays)
+	}
+	return CreateStatement(req)
+}
+
+// CreateStatement 创建一条待生成的账单并在后台生成
+func CreateStatement(req StatementRequest) (*model.Statement, error) {
+	if req.UserId == 0 {
+		return nil, errors.New("用户不能为空")
+	}
+	if err := req.normalizePeriod(); err != nil {
+		return nil, err
+	}
+	username, err := model.GetUsernameById(req.UserId, true)
+	if err != nil {
+		return nil, err
+	}
+	statement := &model.Statement{
+		UserId:      req.UserId,
+		Username:    username,
+		TokenId:     req.TokenId,
+		Group:       req.Group,
+		PeriodStart: req.PeriodStart,
+		PeriodEnd:   req.PeriodEnd,
+		Status:      model.StatementStatusPending,
+	}
+	if req.TokenId != 0 {
+		token, err := model.GetTokenByIds(req.TokenId, req.UserId)
+		if err != nil {
+			return nil, errors.New("令牌不存在")
+		}
+		statement.TokenName = token.Name
+	}
+	if err := statement.Insert(); err != nil {
+		return nil, err
+	}
+	gopool.Go(func() {
+		if err := GenerateStatement(statement); err != nil {
+			common.SysError(fmt.Sprintf("failed to generate statement %d: %s", statement.Id, err.Error()))
+		}
+	})
+	return statement, nil
+}
+
+// GenerateStatement 计算账单数据并归档
+// 期末余额由当前余额倒推：减去周期结束后的充值与兑换，加回周期结束后的消费；
+// 管理员直接修改额度等未记录流水的变动不在统计范围内。
+func GenerateStatement(statement *model.Statement) error {
+	err := fillStatement(statement)
+	if err != nil {
+		statement.Status = model.StatementStatusFailed
+		statement.Message = err.Error()
+	} else {
+		statement.Status = model.StatementStatusCompleted
+		statement.Message = ""
+	}
+	statement.CompletedTime = common.GetTimestamp()
+	if updateErr := statement.Update(); updateErr != nil {
+		return updateErr
+	}
+	return err
+}
+
+func fillStatement(statement *model.Statement) error {
+	now := common.GetTimestamp()
+	flow, err := model.GetStatementFlow(statement.UserId, statement.TokenId, statement.Group, statement.PeriodStart, statement.PeriodEnd)
+	if err != nil {
+		return err
+	}
+	userFlow := flow
+	if statement.TokenId != 0 || statement.Group != "" {
+		userFlow, err = model.GetStatementFlow(statement.UserId, 0, "", statement.PeriodStart, statement.PeriodEnd)
+		if err != nil {
+			return err
+		}
+	}
+	afterFlow, err := model.GetStatementFlow(statement.UserId, 0, "", statement.PeriodEnd, now+1)
+	if err != nil {
+		return err
+	}
+	items, err := model.GetStatementItems(statement.UserId, statement.TokenId, statement.Group, statement.PeriodStart, statement.PeriodEnd)
+	if err != nil {
+		return err
+	}
+	currentQuota, err := model.GetUserQuota(statement.UserId, true)
+	if err != nil {
+		return err
+	}
+	user, err := model.GetUserById(statement.UserId, false)
+	if err != nil {
+		return err
+	}
+	// 计费方式与信用额度取出账时的设置
+	statement.BillingMode = operation_setting.BillingModePrepaid
+	statement.CreditLimit = int64(user.ToBaseUser().GetCreditLimit())
+	if statement.CreditLimit > 0 {
+		statement.BillingMode = operation_setting.BillingModePostpaid
+	}
+
+	statement.OpeningQuota, statement.ClosingQuota = statementBalances(int64(currentQuota), userFlow, afterFlow)
+	statement.QuotaPerUnit = common.QuotaPerUnit
+	statement.TopUpCount = flow.TopUpCount
+	statement.TopUpQuota = flow.TopUpQuota
+	statement.TopUpMoney = flow.TopUpMoney
+	statement.RedemptionCount = flow.RedemptionCount
+	statement.RedemptionQuota = flow.RedemptionQuota
+	statement.RequestCount = flow.RequestCount
+	statement.ConsumedQuota = flow.ConsumedQuota
+	statement.PromptTokens = flow.PromptTokens
+	statement.CompletionTokens = flow.CompletionTokens
+	statement.SetItems(items)
+	return nil
+}
+
+// statementBalances 由当前余额倒推期初与期末余额
+// periodFlow 为周期内用户整体的流水，afterFlow 为周期结束至今的流水
+func statementBalances(currentQuota int64, periodFlow model.StatementFlow, afterFlow model.StatementFlow) (opening int64, closing int64) {
+	closing = currentQuota - afterFlow.TopUpQuota - afterFlow.RedemptionQuota + afterFlow.ConsumedQuota
+	opening = closing - periodFlow.TopUpQuota - periodFlow.RedemptionQuota + periodFlow.ConsumedQuota
+	return opening, closing
+}
+
+var statementTaskOnce sync.Once
+
+// AutomaticallyGenerateStatements 每月初为上月有账务变动的用户生成账单
+func AutomaticallyGenerateStatements() {
+	if !common.IsMasterNode {
+		return
+	}
+	statementTaskOnce.Do(func() {
+		for {
+			if operation_setting.GetStatementSetting().MonthlyEnabled {
+				generateLastMonthStatements()
+			}
+			time.Sleep(time.Hour)
+		}
+	})
+}
+
+func generateLastMonthStatements() {
+	periodStart, periodEnd := MonthPeriod(time.Now().AddDate(0, -1, 0))
+	userIds, err := model.GetStatementUserIds(periodStart, periodEnd)
+	if err != nil {
+		common.SysError("failed to get statement users: " + err.Error())
+		return
+	}
+	count := 0
+	for _, userId := range userIds {
+		if model.StatementExists(userId, 0, "", periodStart, periodEnd) {
+			continue
+		}
+		username, _ := model.GetUsernameById(userId, true)
+		statement := &model.Statement{
+			UserId:      userId,
+			Username:    username,
+			PeriodStart: periodStart,
+			PeriodEnd:   periodEnd,
+			Status:      model.StatementStatusPending,
+		}
+		if err := statement.Insert(); err != nil {
+			common.SysError(fmt.Sprintf("failed to create statement for user %d: %s", userId, err.Error()))
+			continue
+		}
+		if err := GenerateStatement(statement); err != nil {
+			common.SysError(fmt.Sprintf("failed to generate statement %d: %s", statement.Id, err.Error()))
+			continue
+		}
+		count++
+	}
+	if count > 0 {
+		common.SysLog(fmt.Sprintf("generated %d monthly statements", count))
+	}
+}
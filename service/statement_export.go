@@ -0,0 +1,251 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+const statementTimeLayout = "2006-01-02 15:04:05"
+
+func statementMoney(statement *model.Statement, quota int64) string {
+	quotaPerUnit := statement.QuotaPerUnit
+	if quotaPerUnit <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%s%.6f", operation_setting.GetStatementSetting().CurrencySymbol, float64(quota)/quotaPerUnit)
+}
+
+func statementScope(statement *model.Statement) string {
+	scope := "user"
+	if statement.TokenId != 0 {
+		scope += fmt.Sprintf(" / token %s (#%d)", statement.TokenName, statement.TokenId)
+	}
+	if statement.Group != "" {
+		scope += " / group " + statement.Group
+	}
+	return scope
+}
+
+func statementBillingMode(statement *model.Statement) string {
+	if statement.BillingMode == "" {
+		return operation_setting.BillingModePrepaid
+	}
+	return statement.BillingMode
+}
+
+// statementRows 账单的通用行表示，CSV 与 PDF 共用
+func statementRows(statement *model.Statement) [][]string {
+	rows := [][]string{
+		{"Statement", fmt.Sprintf("#%d", statement.Id)},
+		{"User", fmt.Sprintf("%s (#%d)", statement.Username, statement.UserId)},
+		{"Scope", statementScope(statement)},
+		{"Billing Mode", statementBillingMode(statement)},
+		{"Period Start", time.Unix(statement.PeriodStart, 0).Format(statementTimeLayout)},
+		{"Period End", time.Unix(statement.PeriodEnd, 0).Format(statementTimeLayout)},
+		{"Generated At", time.Unix(statement.CompletedTime, 0).Format(statementTimeLayout)},
+		{},
+		{"Item", "Count", "Quota", "Amount"},
+		{"Opening Balance", "", fmt.Sprintf("%d", statement.OpeningQuota), statementMoney(statement, statement.OpeningQuota)},
+		{"Top-ups", fmt.Sprintf("%d", statement.TopUpCount), fmt.Sprintf("%d", statement.TopUpQuota), statementMoney(statement, statement.TopUpQuota)},
+		{"Redemptions", fmt.Sprintf("%d", statement.RedemptionCount), fmt.Sprintf("%d", statement.RedemptionQuota), statementMoney(statement, statement.RedemptionQuota)},
+		{"Consumption", fmt.Sprintf("%d", statement.RequestCount), fmt.Sprintf("%d", statement.ConsumedQuota), statementMoney(statement, statement.ConsumedQuota)},
+		{"Closing Balance", "", fmt.Sprintf("%d", statement.ClosingQuota), statementMoney(statement, statement.ClosingQuota)},
+		{"Top-up Payments", "", "", fmt.Sprintf("%.2f", statement.TopUpMoney)},
+	}
+	if statement.BillingMode == operation_setting.BillingModePostpaid {
+		// 后付费账单的应付金额为期末欠款
+		amountDue := max(-statement.ClosingQuota, 0)
+		rows = append(rows,
+			[]string{"Credit Limit", "", fmt.Sprintf("%d", statement.CreditLimit), statementMoney(statement, statement.CreditLimit)},
+			[]string{"Amount Due", "", fmt.Sprintf("%d", amountDue), statementMoney(statement, amountDue)},
+		)
+	}
+	rows = append(rows, []string{}, []string{"Model", "Requests", "Prompt Tokens", "Completion Tokens", "Quota", "Amount"})
+	for _, item := range statement.GetItems() {
+		rows = append(rows, []string{
+			item.ModelName,
+			fmt.Sprintf("%d", item.RequestCount),
+			fmt.Sprintf("%d", item.PromptTokens),
+			fmt.Sprintf("%d", item.CompletionTokens),
+			fmt.Sprintf("%d", item.Quota),
+			statementMoney(statement, item.Quota),
+		})
+	}
+	return rows
+}
+
+// WriteStatementCSV 将账单导出为 CSV
+func WriteStatementCSV(w io.Writer, statement *model.Statement) error {
+	writer := csv.NewWriter(w)
+	for _, row := range statementRows(statement) {
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// RenderStatementPDF 将账单导出为 PDF
+// ASCII 使用内置的 Courier 字体，中文等其他字符使用 PDF 阅读器自带的 STSong-Light 字体，无需嵌入字体文件
+func RenderStatementPDF(statement *model.Statement) []byte {
+	var lines []string
+	for _, row := range statementRows(statement) {
+		if len(row) == 0 {
+			lines = append(lines, "")
+			continue
+		}
+		cols := make([]string, len(row))
+		for i, col := range row {
+			width := 16
+			if i == 0 {
+				width = 28
+			}
+			cols[i] = pdfPad(col, width)
+		}
+		lines = append(lines, strings.TrimRight(strings.Join(cols, " "), " "))
+	}
+	return renderTextPDF(lines)
+}
+
+const (
+	pdfLinesPerPage = 60
+	pdfFontSize     = 8
+	pdfLineHeight   = 12
+	pdfPageWidth    = 842 // A4 横向
+	pdfPageHeight   = 595
+)
+
+// pdfRuneWidth 返回字符占用的列数，非 ASCII 字符按 Courier 的两列宽度排版，见 renderTextPDF 中的 /DW
+func pdfRuneWidth(r rune) int {
+	if r < 0x80 {
+		return 1
+	}
+	return 2
+}
+
+// pdfPad 按显示列数在右侧补齐空格
+func pdfPad(s string, width int) string {
+	n := 0
+	for _, r := range s {
+		n += pdfRuneWidth(r)
+	}
+	if n >= width {
+		return s
+	}
+	return s + strings.Repeat(" ", width-n)
+}
+
+// pdfTextOps 生成一行文本的绘制指令，ASCII 片段使用 /F1 的字面量字符串，
+// 其他字符使用 /F2 的 UTF-16BE 十六进制字符串
+func pdfTextOps(line string) string {
+	var ops, run strings.Builder
+	font := "F1"
+	flush := func() {
+		if run.Len() == 0 {
+			return
+		}
+		if font == "F1" {
+			ops.WriteString("(" + run.String() + ") Tj ")
+		} else {
+			ops.WriteString("<" + run.String() + "> Tj ")
+		}
+		run.Reset()
+	}
+	for _, r := range line {
+		next := "F1"
+		if r >= 0x80 {
+			next = "F2"
+		}
+		if next != font {
+			flush()
+			font = next
+			ops.WriteString(fmt.Sprintf("/%s %d Tf ", font, pdfFontSize))
+		}
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			run.WriteByte('\\')
+			run.WriteRune(r)
+		case r < 32 || r == 127:
+			run.WriteByte('?')
+		case r < 0x80:
+			run.WriteRune(r)
+		default:
+			if r == '¥' {
+				// GB 字符集只有全角人民币符号
+				r = '￥'
+			}
+			for _, unit := range utf16.Encode([]rune{r}) {
+				run.WriteString(fmt.Sprintf("%04X", unit))
+			}
+		}
+	}
+	flush()
+	if font != "F1" {
+		// 下一行从 /F1 开始
+		ops.WriteString(fmt.Sprintf("/F1 %d Tf ", pdfFontSize))
+	}
+	return ops.String()
+}
+
+func renderTextPDF(lines []string) []byte {
+	var pages [][]string
+	for i := 0; i < len(lines); i += pdfLinesPerPage {
+		end := i + pdfLinesPerPage
+		if end > len(lines) {
+			end = len(lines)
+		}
+		pages = append(pages, lines[i:end])
+	}
+	if len(pages) == 0 {
+		pages = append(pages, []string{})
+	}
+
+	// 对象编号：1 Catalog，2 Pages，3 Courier，4-6 中文字体（Type0、CIDFont、FontDescriptor），
+	// 之后每页依次为 Page 与 Contents
+	var objects []string
+	objects = append(objects, "<< /Type /Catalog /Pages 2 0 R >>")
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 7+i*2)
+	}
+	objects = append(objects, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	objects = append(objects, "<< /Type /Font /Subtype /Type1 /BaseFont /Courier >>")
+	objects = append(objects, "<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light-UniGB-UTF16-H /Encoding /UniGB-UTF16-H /DescendantFonts [5 0 R] >>")
+	// 字宽固定为 Courier 两列（2 × 600），保证中英文混排时各列对齐
+	objects = append(objects, "<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light /CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 4 >> /FontDescriptor 6 0 R /DW 1200 >>")
+	objects = append(objects, "<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] /ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>")
+	for i, page := range pages {
+		var content strings.Builder
+		content.WriteString(fmt.Sprintf("BT /F1 %d Tf %d TL 36 %d Td\n", pdfFontSize, pdfLineHeight, pdfPageHeight-36))
+		for _, line := range page {
+			content.WriteString(pdfTextOps(line) + "T*\n")
+		}
+		content.WriteString("ET")
+		objects = append(objects, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, 8+i*2))
+		objects = append(objects, fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()))
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		buf.WriteString(fmt.Sprintf("%d 0 obj\n%s\nendobj\n", i+1, obj))
+	}
+	xref := buf.Len()
+	buf.WriteString(fmt.Sprintf("xref\n0 %d\n0000000000 65535 f \n", len(objects)+1))
+	for _, offset := range offsets {
+		buf.WriteString(fmt.Sprintf("%010d 00000 n \n", offset))
+	}
+	buf.WriteString(fmt.Sprintf("trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref))
+	return buf.Bytes()
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestStatementBalances(t *testing.T) {
+	periodFlow := model.StatementFlow{TopUpQuota: 200, ConsumedQuota: 500}
+	afterFlow := model.StatementFlow{TopUpQuota: 300, RedemptionQuota: 100, ConsumedQuota: 50}
+	opening, closing := statementBalances(1000, periodFlow, afterFlow)
+	// 期末余额 = 当前余额 - 期后充值 - 期后兑换 + 期后消费
+	if closing != 650 {
+		t.Errorf("closing = %d, want 650", closing)
+	}
+	if opening != 950 {
+		t.Errorf("opening = %d, want 950", opening)
+	}
+	if opening+periodFlow.TopUpQuota+periodFlow.RedemptionQuota-periodFlow.ConsumedQuota != closing {
+		t.Error("expected opening balance plus period flow to equal closing balance")
+	}
+
+	// 后付费用户余额可以为负
+	opening, closing = statementBalances(-100, model.StatementFlow{ConsumedQuota: 400}, model.StatementFlow{ConsumedQuota: 100})
+	if closing != 0 || opening != 400 {
+		t.Errorf("postpaid balances = %d, %d, want 400, 0", opening, closing)
+	}
+}
+
+func readStatementCSV(t *testing.T, statement *model.Statement) map[string][]string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := WriteStatementCSV(&buf, statement); err != nil {
+		t.Fatal(err)
+	}
+	reader := csv.NewReader(&buf)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := make(map[string][]string)
+	for _, record := range records {
+		rows[record[0]] = record
+	}
+	return rows
+}
+
+func TestStatementRows(t *testing.T) {
+	statement := &model.Statement{
+		Id:            7,
+		UserId:        3,
+		Username:      "alice",
+		TokenId:       5,
+		TokenName:     "ci",
+		OpeningQuota:  1000,
+		TopUpCount:    1,
+		TopUpQuota:    500,
+		RequestCount:  4,
+		ConsumedQuota: 1750,
+		ClosingQuota:  -250,
+		QuotaPerUnit:  500,
+		BillingMode:   operation_setting.BillingModePostpaid,
+		CreditLimit:   5000,
+	}
+	statement.SetItems([]model.StatementItem{{ModelName: "gpt-4o", RequestCount: 4, PromptTokens: 10, CompletionTokens: 20, Quota: 1750}})
+
+	rows := readStatementCSV(t, statement)
+	expected := map[string][]string{
+		"Scope":           {"Scope", "user / token ci (#5)"},
+		"Opening Balance": {"Opening Balance", "", "1000", "$2.000000"},
+		"Top-ups":         {"Top-ups", "1", "500", "$1.000000"},
+		"Consumption":     {"Consumption", "4", "1750", "$3.500000"},
+		"Closing Balance": {"Closing Balance", "", "-250", "$-0.500000"},
+		"Amount Due":      {"Amount Due", "", "250", "$0.500000"},
+		"gpt-4o":          {"gpt-4o", "4", "10", "20", "1750", "$3.500000"},
+	}
+	for name, want := range expected {
+		if got := rows[name]; strings.Join(got, "|") != strings.Join(want, "|") {
+			t.Errorf("row %s = %v, want %v", name, got, want)
+		}
+	}
+
+	// 预付费账单没有应付金额，缺少换算比例时金额显示为 -
+	statement.BillingMode = operation_setting.BillingModePrepaid
+	statement.QuotaPerUnit = 0
+	rows = readStatementCSV(t, statement)
+	if _, ok := rows["Amount Due"]; ok {
+		t.Error("expected prepaid statement without amount due")
+	}
+	if got := rows["Closing Balance"]; got[3] != "-" {
+		t.Errorf("expected amount placeholder without quota per unit, got %v", got)
+	}
+}
+
+func TestRenderTextPDFOffsets(t *testing.T) {
+	lines := make([]string, 0, pdfLinesPerPage*2+10)
+	for i := 0; i < cap(lines); i++ {
+		lines = append(lines, fmt.Sprintf("line %d (model) \\ 测试", i))
+	}
+	pdf := renderTextPDF(lines)
+
+	match := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(pdf)
+	if match == nil {
+		t.Fatal("missing startxref trailer")
+	}
+	xref, _ := strconv.Atoi(string(match[1]))
+	if !bytes.HasPrefix(pdf[xref:], []byte("xref\n0 13\n")) {
+		t.Fatalf("startxref %d does not point to the xref table", xref)
+	}
+	// 3 页：Catalog、Pages、4 个字体对象加上每页的 Page 与 Contents
+	entries := strings.Split(string(pdf[xref:]), "\n")[3:15]
+	for i, entry := range entries {
+		offset, err := strconv.Atoi(entry[:10])
+		if err != nil {
+			t.Fatalf("invalid xref entry %q", entry)
+		}
+		if want := fmt.Sprintf("%d 0 obj\n", i+1); !bytes.HasPrefix(pdf[offset:], []byte(want)) {
+			t.Errorf("xref entry %d points to %q", i+1, pdf[offset:offset+10])
+		}
+	}
+	if !bytes.Contains(pdf, []byte("/Count 3")) {
+		t.Error("expected 3 pages")
+	}
+	if !bytes.Contains(pdf, []byte(`(line 0 \(model\) \\ ) Tj /F2 8 Tf <6D4B8BD5> Tj /F1 8 Tf T*`)) {
+		t.Error("expected escaped ASCII text followed by UTF-16 encoded Chinese text")
+	}
+}
+
+func TestPDFTextOps(t *testing.T) {
+	if got, want := pdfTextOps("¥1.00 €2"), `/F2 8 Tf <FFE5> Tj /F1 8 Tf (1.00 ) Tj /F2 8 Tf <20AC> Tj /F1 8 Tf (2) Tj `; got != want {
+		t.Errorf("pdfTextOps = %q, want %q", got, want)
+	}
+	if got := pdfTextOps("😀"); got != `/F2 8 Tf <D83DDE00> Tj /F1 8 Tf ` {
+		t.Errorf("expected surrogate pair, got %q", got)
+	}
+	if got := pdfPad("张三", 6) + "|"; got != "张三  |" {
+		t.Errorf("pdfPad = %q", got)
+	}
+}
+
+func TestCreateSelfServiceStatementPeriodCap(t *testing.T) {
+	setting := operation_setting.GetStatementSetting()
+	previous := setting.SelfServiceMaxDays
+	setting.SelfServiceMaxDays = 31
+	t.Cleanup(func() { setting.SelfServiceMaxDays = previous })
+
+	now := common.GetTimestamp()
+	cases := []StatementRequest{
+		{UserId: 1, PeriodStart: 0, PeriodEnd: now - 100},
+		{UserId: 1, PeriodStart: now - 32*86400, PeriodEnd: now - 100},
+		{UserId: 1, PeriodStart: -1, PeriodEnd: now - 100},
+	}
+	for _, req := range cases {
+		if _, err := CreateSelfServiceStatement(req); err == nil {
+			t.Errorf("expected period %d - %d to be rejected", req.PeriodStart, req.PeriodEnd)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"one-api/dto"
+	"one-api/logger"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
+	"one-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InitStreamBudget 在预扣费之后为流式请求换算补全 token 预算。
+// 预算取令牌剩余额度与计费额度（用户额度，组织令牌为组织额度池及成员上限）中较小者，扣除提示部分后按补全单价换算；
+// 按次计费与免费模型不设预算
+func InitStreamBudget(c *gin.Context, info *relaycommon.RelayInfo) {
+	info.StreamBudget = nil
+	if !info.IsStream || info.PriceData.UsePrice || !operation_setting.GetStreamBudgetSetting().Enabled {
+		return
+	}
+	budgetQuota := info.UserQuota
+	if !info.TokenUnlimited {
+		budgetQuota = min(budgetQuota, c.GetInt("token_quota"))
+	}
+	maxTokens, ok := streamBudgetTokens(budgetQuota, info.PromptTokens, info.PriceData)
+	if !ok {
+		return
+	}
+	info.StreamBudget = relaycommon.NewStreamBudget(maxTokens)
+	logger.LogDebug(c, fmt.Sprintf("stream budget: %d completion tokens for remaining quota %d", maxTokens, budgetQuota))
+}
+
+// streamBudgetTokens 把剩余额度换算为可输出的补全 token 数，模型免费或预算超过 int 范围时返回 false
+func streamBudgetTokens(quota int, promptTokens int, priceData types.PriceData) (int, bool) {
+	ratio := priceData.ModelRatio * priceData.GroupRatioInfo.GroupRatio
+	completionRatio := ratio * priceData.CompletionRatio
+	if ratio <= 0 || completionRatio <= 0 {
+		return 0, false
+	}
+	remaining := float64(quota) - float64(promptTokens)*ratio
+	if remaining <= 0 {
+		return 0, true
+	}
+	tokens := math.Floor(remaining / completionRatio)
+	if tokens >= math.MaxInt32 {
+		return 0, false
+	}
+	return int(tokens), true
+}
+
+// ConsumeStreamBudget 在转发流式分片前累计其中文本的 token 数，预算耗尽时返回 false，调用方应停止转发并结束响应
+func ConsumeStreamBudget(info *relaycommon.RelayInfo, text string) bool {
+	if info.StreamBudget == nil {
+		return true
+	}
+	if info.StreamBudget.Exhausted() {
+		return false
+	}
+	return info.StreamBudget.Consume(CountTextToken(text, info.UpstreamModelName))
+}
+
+// ApplyStreamBudgetUsage 预算耗尽时按已转发内容的 token 数计费，保留上游返回的提示用量
+func ApplyStreamBudgetUsage(info *relaycommon.RelayInfo, usage *dto.Usage) {
+	if usage.PromptTokens == 0 {
+		usage.PromptTokens = info.PromptTokens
+	}
+	usage.CompletionTokens = info.StreamBudget.CompletionTokens
+	usage.CompletionTokenDetails = dto.OutputTokenDetails{}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	relaycommon "one-api/relay/common"
+	"one-api/types"
+	"testing"
+)
+
+func TestStreamBudgetTokens(t *testing.T) {
+	priceData := types.PriceData{ModelRatio: 2, CompletionRatio: 4, GroupRatioInfo: types.GroupRatioInfo{GroupRatio: 0.5}}
+	// 提示部分 100 * 2 * 0.5 = 100，每个补全 token 2 * 4 * 0.5 = 4
+	if tokens, ok := streamBudgetTokens(1000, 100, priceData); !ok || tokens != 225 {
+		t.Errorf("expected 225 tokens, got %d, %v", tokens, ok)
+	}
+	if tokens, ok := streamBudgetTokens(50, 100, priceData); !ok || tokens != 0 {
+		t.Errorf("expected an empty budget when the prompt exceeds the quota, got %d, %v", tokens, ok)
+	}
+	priceData.GroupRatioInfo.GroupRatio = 0
+	if _, ok := streamBudgetTokens(1000, 100, priceData); ok {
+		t.Error("expected free models to have no budget")
+	}
+}
+
+func TestStreamBudgetConsume(t *testing.T) {
+	info := &relaycommon.RelayInfo{StreamBudget: relaycommon.NewStreamBudget(5)}
+	if !info.StreamBudget.Consume(3) || !info.StreamBudget.Consume(2) {
+		t.Fatal("expected tokens within the budget to be accepted")
+	}
+	if info.StreamBudget.Consume(1) || !info.StreamBudget.Exhausted() {
+		t.Fatal("expected the budget to be exhausted")
+	}
+	if info.StreamBudget.Consume(0) {
+		t.Error("expected an exhausted budget to reject further chunks")
+	}
+	if info.StreamBudget.CompletionTokens != 5 {
+		t.Errorf("expected only delivered tokens to be counted, got %d", info.StreamBudget.CompletionTokens)
+	}
+
+	var unlimited *relaycommon.StreamBudget
+	if !unlimited.Consume(1<<20) || unlimited.Exhausted() {
+		t.Error("expected a nil budget to be unlimited")
+	}
+}
@@ -0,0 +1,439 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/common/jsonschema"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/logger"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
+	"one-api/types"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	StructuredOutputStatusValid    = "valid"
+	StructuredOutputStatusRepaired = "repaired"
+	StructuredOutputStatusInvalid  = "invalid"
+)
+
+// StructuredOutputResult 结构化输出校验结果，记录在日志的 Other 字段中
+type StructuredOutputResult struct {
+	Status  string `json:"status"`
+	Target  string `json:"target"` // response_format 或 tool_calls
+	Error   string `json:"error,omitempty"`
+	Retries int    `json:"retries,omitempty"` // 因校验失败而重试的次数
+}
+
+// StructuredOutputValidator 校验一次上游请求返回的最终内容或工具调用参数。
+// 需要重试或修复时缓存写给客户端的内容，校验通过后再写出
+type StructuredOutputValidator struct {
+	c        *gin.Context
+	setting  operation_setting.StructuredOutputSetting
+	schema   any  // response_format 为 json_schema 时的 schema
+	jsonMode bool // response_format 为 json_object
+	tools    map[string]any
+	info     *relaycommon.RelayInfo
+	writer   *structuredOutputWriter
+}
+
+// NewStructuredOutputValidator 未开启校验或请求未要求结构化输出时返回 nil，否则替换 c.Writer，
+// 需要在请求结束前调用 Finish 或 Release 恢复原 Writer
+func NewStructuredOutputValidator(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) *StructuredOutputValidator {
+	setting := *operation_setting.GetStructuredOutputSetting()
+	if !setting.Enabled || request == nil {
+		return nil
+	}
+	v := &StructuredOutputValidator{c: c, setting: setting, info: info}
+	if request.ResponseFormat != nil {
+		switch request.ResponseFormat.Type {
+		case "json_object":
+			v.jsonMode = true
+		case "json_schema":
+			var format dto.FormatJsonSchema
+			if err := common.Unmarshal(request.ResponseFormat.JsonSchema, &format); err == nil && format.Schema != nil {
+				v.schema = normalizeSchema(format.Schema)
+			} else {
+				v.jsonMode = true
+			}
+		}
+	}
+	for _, tool := range request.Tools {
+		if tool.Function.Name == "" {
+			continue
+		}
+		if v.tools == nil {
+			v.tools = make(map[string]any)
+		}
+		v.tools[tool.Function.Name] = normalizeSchema(tool.Function.Parameters)
+	}
+	if v.schema == nil && !v.jsonMode && len(v.tools) == 0 {
+		return nil
+	}
+	buffering := setting.Action != operation_setting.StructuredOutputActionLog && (!info.IsStream || setting.BufferStream)
+	v.writer = &structuredOutputWriter{
+		ResponseWriter: c.Writer,
+		buffering:      buffering,
+		header:         c.Writer.Header().Clone(),
+	}
+	c.Writer = v.writer
+	return v
+}
+
+// normalizeSchema 把 schema 转换为 map[string]any 形式，便于校验
+func normalizeSchema(schema any) any {
+	if schema == nil {
+		return nil
+	}
+	data, err := common.Marshal(schema)
+	if err != nil {
+		return nil
+	}
+	var normalized any
+	if err = common.Unmarshal(data, &normalized); err != nil {
+		return nil
+	}
+	return normalized
+}
+
+// Release 丢弃缓存并恢复原 Writer，用于请求出错的情况，可以重复调用
+func (v *StructuredOutputValidator) Release() {
+	if v == nil || v.writer.released {
+		return
+	}
+	v.writer.released = true
+	v.c.Writer = v.writer.ResponseWriter
+	if v.writer.buffering {
+		v.writer.resetHeader()
+	}
+}
+
+// Finish 校验本次响应并写出缓存的内容。需要重试时丢弃缓存并返回错误，交给外层的重试逻辑
+func (v *StructuredOutputValidator) Finish() *types.NewAPIError {
+	if v == nil || v.writer.released {
+		return nil
+	}
+	v.writer.released = true
+	v.c.Writer = v.writer.ResponseWriter
+	data := v.writer.buffer.Bytes()
+
+	output := extractStructuredOutput(data, v.info.IsStream)
+	target := "response_format"
+	if len(output.toolCalls) > 0 {
+		target = "tool_calls"
+	}
+	result := &StructuredOutputResult{Target: target, Status: StructuredOutputStatusValid}
+	validateErr := v.validate(output)
+	if validateErr == nil {
+		if !output.empty() {
+			v.record(result)
+		}
+		v.flush(data)
+		return nil
+	}
+	result.Status = StructuredOutputStatusInvalid
+	result.Error = validateErr.Error()
+	logger.LogWarn(v.c, "structured output validation failed: "+validateErr.Error())
+	if !v.writer.buffering {
+		v.record(result)
+		return nil
+	}
+
+	action := v.setting.Action
+	if action == operation_setting.StructuredOutputActionRepair {
+		if repaired, ok := v.repair(data, output); ok {
+			result.Status = StructuredOutputStatusRepaired
+			v.record(result)
+			v.flush(repaired)
+			return nil
+		}
+		if v.setting.RetryOnRepairFailure {
+			action = operation_setting.StructuredOutputActionRetry
+		}
+	}
+	if action == operation_setting.StructuredOutputActionRetry && v.canRetry() {
+		common.SetContextKey(v.c, constant.ContextKeyStructuredOutputRetries, common.GetContextKeyInt(v.c, constant.ContextKeyStructuredOutputRetries)+1)
+		v.writer.resetHeader()
+		return types.NewOpenAIError(fmt.Errorf("structured output validation failed: %w", validateErr), types.ErrorCodeStructuredOutputInvalid, http.StatusBadGateway)
+	}
+	// 无法重试时仍然返回上游的内容，只记录校验失败
+	v.record(result)
+	v.flush(data)
+	return nil
+}
+
+func (v *StructuredOutputValidator) canRetry() bool {
+	if _, ok := v.c.Get("specific_channel_id"); ok {
+		return false
+	}
+	return len(v.c.GetStringSlice("use_channel")) <= common.RetryTimes
+}
+
+func (v *StructuredOutputValidator) record(result *StructuredOutputResult) {
+	result.Retries = common.GetContextKeyInt(v.c, constant.ContextKeyStructuredOutputRetries)
+	common.SetContextKey(v.c, constant.ContextKeyStructuredOutput, result)
+}
+
+func (v *StructuredOutputValidator) flush(data []byte) {
+	if !v.writer.buffering || len(data) == 0 {
+		return
+	}
+	w := v.writer.ResponseWriter
+	if w.Header().Get("Content-Length") != "" {
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	}
+	if _, err := w.Write(data); err != nil {
+		logger.LogError(v.c, "failed to write validated response: "+err.Error())
+	}
+	w.Flush()
+}
+
+func (v *StructuredOutputValidator) validate(output *structuredOutput) error {
+	if len(output.toolCalls) > 0 && len(v.tools) > 0 {
+		for _, call := range output.toolCalls {
+			if err := v.validateToolCall(call.name, call.arguments); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if output.content == "" {
+		return nil
+	}
+	return v.validateContent(output.content)
+}
+
+func (v *StructuredOutputValidator) validateToolCall(name string, arguments string) error {
+	schema, ok := v.tools[name]
+	if !ok {
+		return fmt.Errorf("tool call %q does not match any requested tool", name)
+	}
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+	if err := jsonschema.ValidateJSON(schema, []byte(arguments)); err != nil {
+		return fmt.Errorf("arguments of tool call %q: %w", name, err)
+	}
+	return nil
+}
+
+func (v *StructuredOutputValidator) validateContent(content string) error {
+	if v.schema != nil {
+		return jsonschema.ValidateJSON(v.schema, []byte(content))
+	}
+	if v.jsonMode && !gjson.Valid(content) {
+		return errors.New("content is not valid json")
+	}
+	return nil
+}
+
+// repair 修复内容或工具调用参数并写回响应，修复后仍不合法时返回 false
+func (v *StructuredOutputValidator) repair(data []byte, output *structuredOutput) ([]byte, bool) {
+	if len(output.toolCalls) > 0 && len(v.tools) > 0 {
+		arguments := make(map[int]string)
+		for index, call := range output.toolCalls {
+			if v.validateToolCall(call.name, call.arguments) == nil {
+				continue
+			}
+			repaired, ok := jsonschema.Repair(call.arguments)
+			if !ok || v.validateToolCall(call.name, repaired) != nil {
+				return nil, false
+			}
+			arguments[index] = repaired
+		}
+		return rewriteStructuredOutput(data, v.info.IsStream, nil, arguments)
+	}
+	repaired, ok := jsonschema.Repair(output.content)
+	if !ok || v.validateContent(repaired) != nil {
+		return nil, false
+	}
+	return rewriteStructuredOutput(data, v.info.IsStream, &repaired, nil)
+}
+
+type structuredToolCall struct {
+	name      string
+	arguments string
+}
+
+type structuredOutput struct {
+	content   string
+	toolCalls map[int]*structuredToolCall // 按工具调用的 index 聚合
+}
+
+func (o *structuredOutput) empty() bool {
+	return o.content == "" && len(o.toolCalls) == 0
+}
+
+// forEachStreamChunk 遍历 SSE 中 data: 行的 JSON 数据
+func forEachStreamChunk(data []byte, fn func(chunk gjson.Result)) {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		payload, ok := bytes.CutPrefix(bytes.TrimRight(line, "\r"), []byte("data:"))
+		if !ok {
+			continue
+		}
+		payload = bytes.TrimSpace(payload)
+		if gjson.ValidBytes(payload) {
+			fn(gjson.ParseBytes(payload))
+		}
+	}
+}
+
+// extractStructuredOutput 提取第一个候选的最终内容与工具调用参数
+func extractStructuredOutput(data []byte, stream bool) *structuredOutput {
+	output := &structuredOutput{toolCalls: make(map[int]*structuredToolCall)}
+	addToolCall := func(index int, call gjson.Result) {
+		tc, ok := output.toolCalls[index]
+		if !ok {
+			tc = &structuredToolCall{}
+			output.toolCalls[index] = tc
+		}
+		if name := call.Get("function.name").String(); name != "" {
+			tc.name = name
+		}
+		tc.arguments += call.Get("function.arguments").String()
+	}
+	if !stream {
+		message := gjson.GetBytes(data, "choices.0.message")
+		output.content = message.Get("content").String()
+		for i, call := range message.Get("tool_calls").Array() {
+			addToolCall(i, call)
+		}
+		return output
+	}
+	var content strings.Builder
+	forEachStreamChunk(data, func(chunk gjson.Result) {
+		delta := chunk.Get("choices.0.delta")
+		content.WriteString(delta.Get("content").String())
+		for i, call := range delta.Get("tool_calls").Array() {
+			index := i
+			if idx := call.Get("index"); idx.Exists() {
+				index = int(idx.Int())
+			}
+			addToolCall(index, call)
+		}
+	})
+	output.content = content.String()
+	return output
+}
+
+// rewriteStructuredOutput 用修复后的内容替换响应中的内容或工具调用参数。
+// 流式响应中第一个数据块携带完整内容，之后的数据块内容置空
+func rewriteStructuredOutput(data []byte, stream bool, content *string, arguments map[int]string) ([]byte, bool) {
+	var err error
+	if !stream {
+		if content != nil {
+			data, err = sjson.SetBytes(data, "choices.0.message.content", *content)
+		}
+		for index, args := range arguments {
+			if err != nil {
+				break
+			}
+			data, err = sjson.SetBytes(data, fmt.Sprintf("choices.0.message.tool_calls.%d.function.arguments", index), args)
+		}
+		return data, err == nil
+	}
+
+	contentWritten := false
+	argumentsWritten := make(map[int]bool)
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		payload, ok := bytes.CutPrefix(bytes.TrimRight(line, "\r"), []byte("data:"))
+		if !ok {
+			continue
+		}
+		payload = bytes.TrimSpace(payload)
+		if !gjson.ValidBytes(payload) {
+			continue
+		}
+		chunk := string(payload)
+		delta := gjson.Get(chunk, "choices.0.delta")
+		if content != nil && delta.Get("content").Exists() {
+			value := ""
+			if !contentWritten && delta.Get("content").String() != "" {
+				value, contentWritten = *content, true
+			}
+			if chunk, err = sjson.Set(chunk, "choices.0.delta.content", value); err != nil {
+				return nil, false
+			}
+		}
+		for j, call := range delta.Get("tool_calls").Array() {
+			index := j
+			if idx := call.Get("index"); idx.Exists() {
+				index = int(idx.Int())
+			}
+			args, ok := arguments[index]
+			if !ok || !call.Get("function.arguments").Exists() {
+				continue
+			}
+			value := ""
+			if !argumentsWritten[index] {
+				value, argumentsWritten[index] = args, true
+			}
+			if chunk, err = sjson.Set(chunk, fmt.Sprintf("choices.0.delta.tool_calls.%d.function.arguments", j), value); err != nil {
+				return nil, false
+			}
+		}
+		lines[i] = []byte("data: " + chunk)
+	}
+	return bytes.Join(lines, []byte("\n")), true
+}
+
+// structuredOutputWriter 记录写给客户端的内容，buffering 为 true 时缓存到校验结束，否则同时写出
+type structuredOutputWriter struct {
+	gin.ResponseWriter
+	buffering bool
+	released  bool
+	header    http.Header // 安装时的响应头，丢弃缓存时恢复，避免流式响应头影响后续的错误响应
+	buffer    bytes.Buffer
+}
+
+func (w *structuredOutputWriter) Write(data []byte) (int, error) {
+	w.buffer.Write(data)
+	if w.buffering {
+		return len(data), nil
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *structuredOutputWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *structuredOutputWriter) WriteHeaderNow() {
+	if !w.buffering {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *structuredOutputWriter) Written() bool {
+	if w.buffering {
+		return w.buffer.Len() > 0
+	}
+	return w.ResponseWriter.Written()
+}
+
+func (w *structuredOutputWriter) Flush() {
+	if !w.buffering {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *structuredOutputWriter) resetHeader() {
+	header := w.ResponseWriter.Header()
+	for key := range header {
+		delete(header, key)
+	}
+	for key, values := range w.header {
+		header[key] = values
+	}
+}
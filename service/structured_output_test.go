@@ -0,0 +1,117 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
+	"one-api/types"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func withStructuredOutputSetting(t *testing.T, setting operation_setting.StructuredOutputSetting) {
+	t.Helper()
+	current := operation_setting.GetStructuredOutputSetting()
+	previous := *current
+	*current = setting
+	t.Cleanup(func() { *current = previous })
+}
+
+func newStructuredOutputRequest() *dto.GeneralOpenAIRequest {
+	return &dto.GeneralOpenAIRequest{
+		ResponseFormat: &dto.ResponseFormat{
+			Type:       "json_schema",
+			JsonSchema: json.RawMessage(`{"name":"answer","schema":{"type":"object","properties":{"answer":{"type":"string"}},"required":["answer"]}}`),
+		},
+		Tools: []dto.ToolCallRequest{{
+			Type:     "function",
+			Function: dto.FunctionRequest{Name: "get_weather", Parameters: map[string]any{"type": "object", "required": []any{"city"}}},
+		}},
+	}
+}
+
+func newStructuredOutputContext() (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Set("use_channel", []string{"1"})
+	return c, recorder
+}
+
+func TestStructuredOutputRetry(t *testing.T) {
+	withStructuredOutputSetting(t, operation_setting.StructuredOutputSetting{Enabled: true, Action: operation_setting.StructuredOutputActionRetry})
+	retryTimes := common.RetryTimes
+	common.RetryTimes = 1
+	defer func() { common.RetryTimes = retryTimes }()
+
+	c, recorder := newStructuredOutputContext()
+	info := &relaycommon.RelayInfo{}
+	validator := NewStructuredOutputValidator(c, info, newStructuredOutputRequest())
+	c.Writer.Header().Set("Content-Type", "application/json")
+	_, _ = c.Writer.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"{\"answer\": 42}"}}]}`))
+	apiErr := validator.Finish()
+	if apiErr == nil || apiErr.GetErrorCode() != types.ErrorCodeStructuredOutputInvalid || apiErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected retryable validation error, got %v", apiErr)
+	}
+	if recorder.Body.Len() != 0 || recorder.Header().Get("Content-Type") != "" {
+		t.Errorf("expected invalid response to be discarded, got %q", recorder.Body.String())
+	}
+
+	// 最后一次尝试时不再重试，返回上游内容并记录校验失败
+	c.Set("use_channel", []string{"1", "2"})
+	validator = NewStructuredOutputValidator(c, info, newStructuredOutputRequest())
+	_, _ = c.Writer.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"{\"answer\": 42}"}}]}`))
+	if apiErr = validator.Finish(); apiErr != nil {
+		t.Fatalf("expected no retry on last attempt, got %v", apiErr)
+	}
+	result, _ := common.GetContextKeyType[*StructuredOutputResult](c, constant.ContextKeyStructuredOutput)
+	if result == nil || result.Status != StructuredOutputStatusInvalid || result.Retries != 1 || recorder.Body.Len() == 0 {
+		t.Errorf("unexpected result %+v, body %q", result, recorder.Body.String())
+	}
+}
+
+func TestStructuredOutputRepairStream(t *testing.T) {
+	withStructuredOutputSetting(t, operation_setting.StructuredOutputSetting{Enabled: true, Action: operation_setting.StructuredOutputActionRepair, BufferStream: true})
+
+	c, recorder := newStructuredOutputContext()
+	info := &relaycommon.RelayInfo{IsStream: true}
+	validator := NewStructuredOutputValidator(c, info, newStructuredOutputRequest())
+	chunks := []string{
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\": "}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\","}}]}}]}`,
+	}
+	for _, chunk := range chunks {
+		_, _ = c.Writer.Write([]byte("data: " + chunk + "\n\n"))
+	}
+	_, _ = c.Writer.Write([]byte("data: [DONE]\n\n"))
+	if apiErr := validator.Finish(); apiErr != nil {
+		t.Fatalf("expected repair to succeed, got %v", apiErr)
+	}
+
+	output := extractStructuredOutput(recorder.Body.Bytes(), true)
+	if call := output.toolCalls[0]; call == nil || call.name != "get_weather" || call.arguments != `{"city": "Paris"}` {
+		t.Errorf("unexpected repaired stream: %q", recorder.Body.String())
+	}
+	if !strings.HasSuffix(recorder.Body.String(), "data: [DONE]\n\n") {
+		t.Errorf("expected stream terminator to be kept: %q", recorder.Body.String())
+	}
+	result, _ := common.GetContextKeyType[*StructuredOutputResult](c, constant.ContextKeyStructuredOutput)
+	if result == nil || result.Status != StructuredOutputStatusRepaired || result.Target != "tool_calls" {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestStructuredOutputDisabled(t *testing.T) {
+	withStructuredOutputSetting(t, operation_setting.StructuredOutputSetting{Enabled: true})
+	c, _ := newStructuredOutputContext()
+	if NewStructuredOutputValidator(c, &relaycommon.RelayInfo{}, &dto.GeneralOpenAIRequest{}) != nil {
+		t.Error("expected no validator for request without response format or tools")
+	}
+}
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/model"
+	"strconv"
+
+	"github.com/stripe/stripe-go/v81"
+)
+
+// StripeSubscriptionEvent 从 Stripe 账单或订阅对象中提取的订阅信息
+type StripeSubscriptionEvent struct {
+	InvoiceId         string
+	SubscriptionId    string
+	CustomerId        string
+	PriceId           string
+	Status            string
+	BillingReason     string
+	UserId            int // 来自创建订阅时写入的 metadata.user_id
+	PeriodStart       int64
+	PeriodEnd         int64
+	CancelAtPeriodEnd bool
+	AmountPaid        int64
+	Currency          string
+}
+
+func metadataUserId(metadata map[string]string) int {
+	userId, _ := strconv.Atoi(metadata["user_id"])
+	return userId
+}
+
+// ParseStripeInvoice 解析 invoice.* 事件中的账单对象
+func ParseStripeInvoice(raw []byte) (*StripeSubscriptionEvent, error) {
+	var invoice stripe.Invoice
+	if err := common.Unmarshal(raw, &invoice); err != nil {
+		return nil, err
+	}
+	event := &StripeSubscriptionEvent{
+		InvoiceId:     invoice.ID,
+		Status:        model.SubscriptionStatusActive,
+		BillingReason: string(invoice.BillingReason),
+		AmountPaid:    invoice.AmountPaid,
+		Currency:      string(invoice.Currency),
+	}
+	if invoice.Subscription != nil {
+		event.SubscriptionId = invoice.Subscription.ID
+	}
+	if invoice.Customer != nil {
+		event.CustomerId = invoice.Customer.ID
+	}
+	if invoice.SubscriptionDetails != nil {
+		event.UserId = metadataUserId(invoice.SubscriptionDetails.Metadata)
+	}
+	if invoice.Lines != nil {
+		// 换套餐产生的账单包含旧套餐的退款行，优先取非按比例计费的行，其次取金额为正的行
+		var selected *stripe.InvoiceLineItem
+		bestScore := -1
+		for _, line := range invoice.Lines.Data {
+			if line.Price == nil {
+				continue
+			}
+			score := 0
+			if !line.Proration {
+				score += 2
+			}
+			if line.Amount > 0 {
+				score++
+			}
+			if score > bestScore {
+				selected, bestScore = line, score
+			}
+		}
+		if selected != nil {
+			event.PriceId = selected.Price.ID
+			if selected.Period != nil {
+				event.PeriodStart = selected.Period.Start
+				event.PeriodEnd = selected.Period.End
+			}
+		}
+	}
+	return event, nil
+}
+
+// ParseStripeSubscription 解析 customer.subscription.* 事件中的订阅对象
+func ParseStripeSubscription(raw []byte) (*StripeSubscriptionEvent, error) {
+	var subscription stripe.Subscription
+	if err := common.Unmarshal(raw, &subscription); err != nil {
+		return nil, err
+	}
+	event := &StripeSubscriptionEvent{
+		SubscriptionId:    subscription.ID,
+		Status:            string(subscription.Status),
+		UserId:            metadataUserId(subscription.Metadata),
+		PeriodStart:       subscription.CurrentPeriodStart,
+		PeriodEnd:         subscription.CurrentPeriodEnd,
+		CancelAtPeriodEnd: subscription.CancelAtPeriodEnd,
+	}
+	if subscription.Customer != nil {
+		event.CustomerId = subscription.Customer.ID
+	}
+	if subscription.Items != nil && len(subscription.Items.Data) > 0 && subscription.Items.Data[0].Price != nil {
+		event.PriceId = subscription.Items.Data[0].Price.ID
+	}
+	return event, nil
+}
+
+// ShouldGrantQuota 仅在首期与每期续费的账单上发放套餐额度，换套餐产生的补差账单不发放
+func (event *StripeSubscriptionEvent) ShouldGrantQuota() bool {
+	return event.BillingReason == string(stripe.InvoiceBillingReasonSubscriptionCreate) ||
+		event.BillingReason == string(stripe.InvoiceBillingReasonSubscriptionCycle)
+}
+
+func resolveSubscriptionUser(event *StripeSubscriptionEvent) (int, error) {
+	if event.UserId != 0 {
+		return event.UserId, nil
+	}
+	if sub, err := model.GetUserSubscriptionByStripeId(event.SubscriptionId); err == nil {
+		return sub.UserId, nil
+	}
+	userId, err := model.GetUserIdByStripeCustomer(event.CustomerId)
+	if err != nil {
+		return 0, fmt.Errorf("无法确定订阅 %s 所属用户", event.SubscriptionId)
+	}
+	return userId, nil
+}
+
+// resolveSubscriptionPlan 优先按价格查找套餐，找不到时沿用本地记录的套餐
+func resolveSubscriptionPlan(event *StripeSubscriptionEvent) (*model.SubscriptionPlan, error) {
+	plan, err := model.GetSubscriptionPlanByPriceId(event.PriceId)
+	if err == nil {
+		return plan, nil
+	}
+	sub, subErr := model.GetUserSubscriptionByStripeId(event.SubscriptionId)
+	if subErr != nil {
+		return nil, fmt.Errorf("价格 %s 未对应任何订阅套餐", event.PriceId)
+	}
+	return model.GetSubscriptionPlanById(sub.PlanId)
+}
+
+func syncSubscription(event *StripeSubscriptionEvent) (*model.UserSubscription, *model.SubscriptionPlan, error) {
+	if event.SubscriptionId == "" {
+		return nil, nil, errors.New("缺少订阅 ID")
+	}
+	plan, err := resolveSubscriptionPlan(event)
+	if err != nil {
+		return nil, nil, err
+	}
+	userId, err := resolveSubscriptionUser(event)
+	if err != nil {
+		return nil, nil, err
+	}
+	sub := &model.UserSubscription{
+		UserId:               userId,
+		PlanId:               plan.Id,
+		StripeSubscriptionId: event.SubscriptionId,
+		StripeCustomerId:     event.CustomerId,
+		Status:               event.Status,
+		CurrentPeriodStart:   event.PeriodStart,
+		CurrentPeriodEnd:     event.PeriodEnd,
+		CancelAtPeriodEnd:    event.CancelAtPeriodEnd,
+	}
+	if err = model.SyncUserSubscription(sub, plan); err != nil {
+		return nil, nil, err
+	}
+	return sub, plan, nil
+}
+
+// HandleStripeInvoicePaid 订阅账单支付成功：激活订阅并发放当期额度
+func HandleStripeInvoicePaid(raw []byte) error {
+	event, err := ParseStripeInvoice(raw)
+	if err != nil {
+		return err
+	}
+	if event.SubscriptionId == "" {
+		// 非订阅账单
+		return nil
+	}
+	sub, plan, err := syncSubscription(event)
+	if err != nil {
+		return err
+	}
+	if !event.ShouldGrantQuota() {
+		return nil
+	}
+	granted, err := model.GrantSubscriptionQuota(&model.SubscriptionInvoice{
+		InvoiceId:            event.InvoiceId,
+		UserId:               sub.UserId,
+		PlanId:               plan.Id,
+		StripeSubscriptionId: sub.StripeSubscriptionId,
+		Quota:                plan.MonthlyQuota,
+		AmountPaid:           event.AmountPaid,
+		Currency:             event.Currency,
+	})
+	if err != nil {
+		return err
+	}
+	if !granted {
+		common.SysLog(fmt.Sprintf("subscription invoice %s already granted", event.InvoiceId))
+	}
+	return nil
+}
+
+// HandleStripeSubscriptionUpdated 订阅变更：换套餐时切换分组与限流档位，状态失效时恢复原分组
+func HandleStripeSubscriptionUpdated(raw []byte) error {
+	event, err := ParseStripeSubscription(raw)
+	if err != nil {
+		return err
+	}
+	_, _, err = syncSubscription(event)
+	return err
+}
+
+// HandleStripeSubscriptionDeleted 订阅结束：恢复原分组并清除限流档位
+func HandleStripeSubscriptionDeleted(raw []byte) error {
+	event, err := ParseStripeSubscription(raw)
+	if err != nil {
+		return err
+	}
+	event.Status = model.SubscriptionStatusCanceled
+	_, _, err = syncSubscription(event)
+	return err
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stripe/stripe-go/v81"
+)
+
+func loadStripeEvent(t *testing.T, name string) stripe.Event {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "stripe", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var event stripe.Event
+	if err := json.Unmarshal(data, &event); err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+func TestParseStripeInvoice(t *testing.T) {
+	tests := []struct {
+		fixture     string
+		priceId     string
+		reason      string
+		amountPaid  int64
+		shouldGrant bool
+	}{
+		{"invoice_paid.json", "price_1Q9xPro000000000000000", "subscription_cycle", 2000, true},
+		// 换套餐的补差账单：取新套餐的价格，不发放额度
+		{"invoice_paid_upgrade.json", "price_1Q9xTeam00000000000000", "subscription_update", 2333, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			event := loadStripeEvent(t, tt.fixture)
+			if event.Type != stripe.EventTypeInvoicePaid {
+				t.Fatalf("type = %s", event.Type)
+			}
+			parsed, err := ParseStripeInvoice(event.Data.Raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed.SubscriptionId != "sub_1Q9xQ2FzJ4rBm8d0AbCdEf01" {
+				t.Errorf("subscription = %s", parsed.SubscriptionId)
+			}
+			if parsed.CustomerId != "cus_R2q0sWu9bN3xLk" {
+				t.Errorf("customer = %s", parsed.CustomerId)
+			}
+			if parsed.UserId != 42 {
+				t.Errorf("user id = %d, want 42", parsed.UserId)
+			}
+			if parsed.PriceId != tt.priceId {
+				t.Errorf("price = %s, want %s", parsed.PriceId, tt.priceId)
+			}
+			if parsed.BillingReason != tt.reason {
+				t.Errorf("billing reason = %s, want %s", parsed.BillingReason, tt.reason)
+			}
+			if parsed.AmountPaid != tt.amountPaid || parsed.Currency != "usd" {
+				t.Errorf("amount = %d %s", parsed.AmountPaid, parsed.Currency)
+			}
+			if parsed.PeriodEnd != 1731912000 {
+				t.Errorf("period end = %d", parsed.PeriodEnd)
+			}
+			if parsed.ShouldGrantQuota() != tt.shouldGrant {
+				t.Errorf("should grant = %v, want %v", parsed.ShouldGrantQuota(), tt.shouldGrant)
+			}
+		})
+	}
+}
+
+func TestParseStripeSubscription(t *testing.T) {
+	tests := []struct {
+		fixture   string
+		eventType stripe.EventType
+		status    string
+	}{
+		{"subscription_updated.json", stripe.EventTypeCustomerSubscriptionUpdated, "active"},
+		{"subscription_deleted.json", stripe.EventTypeCustomerSubscriptionDeleted, "canceled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			event := loadStripeEvent(t, tt.fixture)
+			if event.Type != tt.eventType {
+				t.Fatalf("type = %s", event.Type)
+			}
+			parsed, err := ParseStripeSubscription(event.Data.Raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed.SubscriptionId != "sub_1Q9xQ2FzJ4rBm8d0AbCdEf01" || parsed.CustomerId != "cus_R2q0sWu9bN3xLk" {
+				t.Errorf("ids = %s %s", parsed.SubscriptionId, parsed.CustomerId)
+			}
+			if parsed.PriceId != "price_1Q9xTeam00000000000000" {
+				t.Errorf("price = %s", parsed.PriceId)
+			}
+			if parsed.Status != tt.status {
+				t.Errorf("status = %s, want %s", parsed.Status, tt.status)
+			}
+			if parsed.UserId != 42 || !parsed.CancelAtPeriodEnd {
+				t.Errorf("user id = %d, cancel at period end = %v", parsed.UserId, parsed.CancelAtPeriodEnd)
+			}
+			if parsed.PeriodStart != 1729233600 || parsed.PeriodEnd != 1731912000 {
+				t.Errorf("period = %d-%d", parsed.PeriodStart, parsed.PeriodEnd)
+			}
+		})
+	}
+}
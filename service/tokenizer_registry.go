@@ -0,0 +1,192 @@
+package service
+
+import (
+	"container/list"
+	"fmt"
+	"one-api/common"
+	"one-api/common/hftokenizer"
+	"one-api/setting/model_setting"
+	"sync"
+
+	"github.com/tiktoken-go/tokenizer"
+)
+
+// tokenCounter tiktoken 编码与 tokenizer.json 分词器的共同接口
+type tokenCounter interface {
+	Count(text string) (int, error)
+}
+
+// defaultTokenEncoder 没有配置分词器且 tiktoken 不认识的模型使用 cl100k_base
+var defaultTokenEncoder tokenizer.Codec
+
+// tokenEncoderMap 缓存 tiktoken 按模型名推断出的编码
+var tokenEncoderMap = make(map[string]tokenizer.Codec)
+
+// tokenEncoderMutex protects tokenEncoderMap for concurrent access
+var tokenEncoderMutex sync.RWMutex
+
+// builtinEncoders 内置的 tiktoken 编码，按需创建后常驻内存
+var (
+	builtinEncoders     = make(map[string]tokenizer.Codec)
+	builtinEncodersLock sync.Mutex
+)
+
+func InitTokenEncoders() {
+	common.SysLog("initializing token encoders")
+	defaultTokenEncoder = getBuiltinEncoder(string(tokenizer.Cl100kBase))
+	common.SysLog("token encoders initialized")
+}
+
+func getBuiltinEncoder(name string) tokenizer.Codec {
+	builtinEncodersLock.Lock()
+	defer builtinEncodersLock.Unlock()
+	if encoder, ok := builtinEncoders[name]; ok {
+		return encoder
+	}
+	encoder, err := tokenizer.Get(tokenizer.Encoding(name))
+	if err != nil {
+		return nil
+	}
+	builtinEncoders[name] = encoder
+	return encoder
+}
+
+// getTokenEncoder 按配置的模型映射选择分词器，未配置或加载失败时使用 tiktoken 按模型名推断的编码，最后回退到 cl100k_base
+func getTokenEncoder(model string) tokenCounter {
+	if name, ok := model_setting.GetModelTokenizer(model); ok {
+		if encoder := getNamedTokenizer(name); encoder != nil {
+			return encoder
+		}
+	}
+
+	// First, try to get the encoder from cache with read lock
+	tokenEncoderMutex.RLock()
+	if encoder, exists := tokenEncoderMap[model]; exists {
+		tokenEncoderMutex.RUnlock()
+		return encoder
+	}
+	tokenEncoderMutex.RUnlock()
+
+	// If not in cache, create new encoder with write lock
+	tokenEncoderMutex.Lock()
+	defer tokenEncoderMutex.Unlock()
+
+	// Double-check if another goroutine already created the encoder
+	if encoder, exists := tokenEncoderMap[model]; exists {
+		return encoder
+	}
+
+	// Create new encoder
+	modelCodec, err := tokenizer.ForModel(tokenizer.Model(model))
+	if err != nil {
+		if defaultTokenEncoder == nil {
+			defaultTokenEncoder = getBuiltinEncoder(string(tokenizer.Cl100kBase))
+		}
+		// Cache the default encoder for this model to avoid repeated failures
+		tokenEncoderMap[model] = defaultTokenEncoder
+		return defaultTokenEncoder
+	}
+
+	// Cache the new encoder
+	tokenEncoderMap[model] = modelCodec
+	return modelCodec
+}
+
+// getNamedTokenizer 返回内置编码或 tokenizer.json 分词器，名称未知或加载失败时返回 nil
+func getNamedTokenizer(name string) tokenCounter {
+	if path, ok := model_setting.GetTokenizerSettings().Files[name]; ok && path != "" {
+		if t := hfTokenizers.get(name, path); t != nil {
+			return t
+		}
+		return nil
+	}
+	if encoder := getBuiltinEncoder(name); encoder != nil {
+		return encoder
+	}
+	return nil
+}
+
+func getTokenNum(tokenEncoder tokenCounter, text string) int {
+	if text == "" {
+		return 0
+	}
+	tkm, _ := tokenEncoder.Count(text)
+	return tkm
+}
+
+// hfTokenizerCache 按需加载 tokenizer.json，总内存超过上限时淘汰最久未使用的分词器。
+// 加载失败的结果同样会被缓存，路径变更后才会重新加载
+type hfTokenizerCache struct {
+	lock    sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+	used    int64
+}
+
+type hfTokenizerEntry struct {
+	name      string
+	path      string
+	once      sync.Once
+	tokenizer *hftokenizer.Tokenizer
+	size      int64
+}
+
+var hfTokenizers = &hfTokenizerCache{
+	entries: make(map[string]*list.Element),
+	order:   list.New(),
+}
+
+func (cache *hfTokenizerCache) get(name string, path string) *hftokenizer.Tokenizer {
+	cache.lock.Lock()
+	var entry *hfTokenizerEntry
+	if elem, ok := cache.entries[name]; ok && elem.Value.(*hfTokenizerEntry).path == path {
+		cache.order.MoveToFront(elem)
+		entry = elem.Value.(*hfTokenizerEntry)
+	} else {
+		if ok {
+			cache.remove(elem)
+		}
+		entry = &hfTokenizerEntry{name: name, path: path}
+		cache.entries[name] = cache.order.PushFront(entry)
+	}
+	cache.lock.Unlock()
+
+	// 加载可能较慢，不持有全局锁，同一分词器只加载一次
+	entry.once.Do(func() {
+		t, err := hftokenizer.Load(name, path)
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to load tokenizer %s from %s: %s", name, path, err.Error()))
+			return
+		}
+		common.SysLog(fmt.Sprintf("tokenizer %s loaded from %s, about %d MB", name, path, t.MemorySize()>>20))
+		cache.lock.Lock()
+		defer cache.lock.Unlock()
+		entry.tokenizer = t
+		if elem, ok := cache.entries[name]; ok && elem.Value == entry {
+			entry.size = t.MemorySize()
+			cache.used += entry.size
+			cache.evict(int64(model_setting.GetTokenizerSettings().MaxMemoryMB) << 20)
+		}
+	})
+	return entry.tokenizer
+}
+
+// evict 从最久未使用的开始淘汰直到不超过 limit 字节，至少保留最近使用的一个
+func (cache *hfTokenizerCache) evict(limit int64) {
+	if limit <= 0 {
+		return
+	}
+	for cache.used > limit && cache.order.Len() > 1 {
+		cache.remove(cache.order.Back())
+	}
+}
+
+func (cache *hfTokenizerCache) remove(elem *list.Element) {
+	entry := elem.Value.(*hfTokenizerEntry)
+	cache.order.Remove(elem)
+	delete(cache.entries, entry.name)
+	cache.used -= entry.size
+	if entry.size > 0 {
+		common.SysLog(fmt.Sprintf("tokenizer %s evicted", entry.name))
+	}
+}
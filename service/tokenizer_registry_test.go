@@ -0,0 +1,97 @@
+package service
+
+import (
+	"container/list"
+	"one-api/common/hftokenizer"
+	"one-api/setting/model_setting"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tiktoken-go/tokenizer"
+)
+
+const testTokenizerJson = `{
+	"pre_tokenizer": {"type": "ByteLevel", "add_prefix_space": false, "use_regex": true},
+	"model": {"type": "BPE", "vocab": {"a": 0, "b": 1, "Ġ": 2, "ab": 3, "Ġab": 4}, "merges": ["a b", "Ġ ab"]}
+}`
+
+func withTokenizerSettings(t *testing.T, settings model_setting.TokenizerSettings) {
+	t.Helper()
+	current := model_setting.GetTokenizerSettings()
+	previous := *current
+	*current = settings
+	t.Cleanup(func() { *current = previous })
+}
+
+func TestGetTokenEncoder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokenizer.json")
+	if err := os.WriteFile(path, []byte(testTokenizerJson), 0644); err != nil {
+		t.Fatal(err)
+	}
+	withTokenizerSettings(t, model_setting.TokenizerSettings{
+		Files: map[string]string{"qwen": path, "broken": filepath.Join(t.TempDir(), "missing.json")},
+		ModelTokenizers: map[string]string{
+			"qwen*":        "qwen",
+			"qwen-legacy*": "cl100k_base",
+			"gpt-4o*":      "o200k_base",
+			"glm-4":        "broken",
+		},
+	})
+
+	if _, ok := getTokenEncoder("qwen-max").(*hftokenizer.Tokenizer); !ok {
+		t.Error("expected qwen models to use the tokenizer.json tokenizer")
+	}
+	if count := CountTextToken("ab ab", "qwen-max"); count != 2 {
+		t.Errorf("expected 2 tokens, got %d", count)
+	}
+	if encoder, ok := getTokenEncoder("qwen-legacy-7b").(tokenizer.Codec); !ok || encoder.GetName() != "cl100k_base" {
+		t.Error("expected the longest prefix to win")
+	}
+	if encoder, ok := getTokenEncoder("gpt-4o-mini").(tokenizer.Codec); !ok || encoder.GetName() != "o200k_base" {
+		t.Error("expected gpt-4o models to use o200k_base")
+	}
+	// 加载失败时回退到默认编码
+	if encoder, ok := getTokenEncoder("glm-4").(tokenizer.Codec); !ok || encoder.GetName() != "cl100k_base" {
+		t.Error("expected fallback to cl100k_base when tokenizer.json cannot be loaded")
+	}
+}
+
+func TestHfTokenizerCacheEviction(t *testing.T) {
+	dir := t.TempDir()
+	cache := &hfTokenizerCache{entries: make(map[string]*list.Element), order: list.New()}
+	for _, name := range []string{"a", "b", "c"} {
+		path := filepath.Join(dir, name+".json")
+		if err := os.WriteFile(path, []byte(testTokenizerJson), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if cache.get(name, path) == nil {
+			t.Fatalf("failed to load tokenizer %s", name)
+		}
+	}
+	cache.get("a", filepath.Join(dir, "a.json"))
+	size := cache.entries["a"].Value.(*hfTokenizerEntry).size
+	cache.lock.Lock()
+	cache.evict(size * 2)
+	cache.lock.Unlock()
+	if _, ok := cache.entries["b"]; ok || len(cache.entries) != 2 {
+		t.Errorf("expected the least recently used tokenizer to be evicted, got %d entries", len(cache.entries))
+	}
+	if cache.used != size*2 {
+		t.Errorf("expected used memory %d, got %d", size*2, cache.used)
+	}
+}
+
+func BenchmarkCountTextToken(b *testing.B) {
+	text := strings.Repeat("The quick brown fox jumps over the lazy dog. 敏捷的棕色狐狸跳过了懒狗。", 128)
+	for _, model := range []string{"gpt-3.5-turbo", "gpt-4o"} {
+		b.Run(model, func(b *testing.B) {
+			CountTextToken(text, model)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				CountTextToken(text, model)
+			}
+		})
+	}
+}
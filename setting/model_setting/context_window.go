@@ -0,0 +1,70 @@
+package model_setting
+
+import (
+	"one-api/setting/config"
+)
+
+// 超出模型上下文窗口时的处理策略
+const (
+	ContextPolicyOff      = "off"      // 不处理，交由上游报错
+	ContextPolicyReject   = "reject"   // 直接拒绝，不请求上游
+	ContextPolicyTruncate = "truncate" // 丢弃最早的非系统消息
+	ContextPolicyReroute  = "reroute"  // 改用配置的更大上下文模型
+)
+
+// ContextWindowSettings 上下文窗口管理配置，模型的上下文大小来自模型元数据。
+// 策略优先级：令牌 > 分组 > 默认
+type ContextWindowSettings struct {
+	DefaultPolicy  string            `json:"default_policy"`  // 默认策略，空等同于 off
+	GroupPolicies  map[string]string `json:"group_policies"`  // 分组 -> 策略
+	OverflowModels map[string]string `json:"overflow_models"` // 模型 -> 超长时改用的模型
+}
+
+// 默认配置
+var defaultContextWindowSettings = ContextWindowSettings{
+	DefaultPolicy:  ContextPolicyOff,
+	GroupPolicies:  map[string]string{},
+	OverflowModels: map[string]string{},
+}
+
+// 全局实例
+var contextWindowSettings = defaultContextWindowSettings
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("context_window", &contextWindowSettings)
+}
+
+// GetContextWindowSettings 获取上下文窗口管理配置
+func GetContextWindowSettings() *ContextWindowSettings {
+	return &contextWindowSettings
+}
+
+// IsValidContextPolicy 策略名是否合法，空字符串表示沿用上一级配置
+func IsValidContextPolicy(policy string) bool {
+	switch policy {
+	case "", ContextPolicyOff, ContextPolicyReject, ContextPolicyTruncate, ContextPolicyReroute:
+		return true
+	}
+	return false
+}
+
+// ResolveContextPolicy 按令牌、分组、默认的顺序确定策略
+func ResolveContextPolicy(tokenPolicy string, group string) string {
+	if tokenPolicy != "" {
+		return tokenPolicy
+	}
+	if policy := contextWindowSettings.GroupPolicies[group]; policy != "" {
+		return policy
+	}
+	if contextWindowSettings.DefaultPolicy != "" {
+		return contextWindowSettings.DefaultPolicy
+	}
+	return ContextPolicyOff
+}
+
+// GetOverflowModel 返回模型超长时改用的模型
+func GetOverflowModel(model string) (string, bool) {
+	target, ok := contextWindowSettings.OverflowModels[model]
+	return target, ok && target != "" && target != model
+}
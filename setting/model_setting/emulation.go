@@ -0,0 +1,51 @@
+package model_setting
+
+import (
+	"one-api/setting/config"
+	"strings"
+)
+
+// EmulationSettings 为不支持工具调用或结构化输出的模型在网关侧模拟这些能力。
+// 模型名按实际请求上游的模型匹配，支持以 * 结尾的前缀匹配
+type EmulationSettings struct {
+	ToolsModels          []string `json:"tools_models"`           // 模拟 tools / tool_choice 的模型
+	ResponseFormatModels []string `json:"response_format_models"` // 模拟 response_format 的模型
+}
+
+// 默认配置
+var defaultEmulationSettings = EmulationSettings{
+	ToolsModels:          []string{},
+	ResponseFormatModels: []string{},
+}
+
+// 全局实例
+var emulationSettings = defaultEmulationSettings
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("emulation", &emulationSettings)
+}
+
+// GetEmulationSettings 获取能力模拟配置
+func GetEmulationSettings() *EmulationSettings {
+	return &emulationSettings
+}
+
+func matchEmulationModel(patterns []string, model string) bool {
+	for _, pattern := range patterns {
+		if pattern == model || (strings.HasSuffix(pattern, "*") && strings.HasPrefix(model, strings.TrimSuffix(pattern, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// ShouldEmulateTools 模型是否需要模拟工具调用
+func ShouldEmulateTools(model string) bool {
+	return matchEmulationModel(emulationSettings.ToolsModels, model)
+}
+
+// ShouldEmulateResponseFormat 模型是否需要模拟结构化输出
+func ShouldEmulateResponseFormat(model string) bool {
+	return matchEmulationModel(emulationSettings.ResponseFormatModels, model)
+}
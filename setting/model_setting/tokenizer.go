@@ -0,0 +1,60 @@
+package model_setting
+
+import (
+	"one-api/setting/config"
+	"strings"
+)
+
+// TokenizerSettings 提示词计数使用的分词器配置。
+// 分词器名称可以是内置的 tiktoken 编码（cl100k_base、o200k_base、p50k_base、r50k_base），
+// 也可以是 Files 中配置的 HuggingFace tokenizer.json，后者在首次使用时加载
+type TokenizerSettings struct {
+	Files           map[string]string `json:"files"`            // 分词器名称 -> tokenizer.json 路径
+	ModelTokenizers map[string]string `json:"model_tokenizers"` // 模型 -> 分词器名称，支持以 * 结尾的前缀匹配
+	MaxMemoryMB     int               `json:"max_memory_mb"`    // 已加载的 tokenizer.json 占用内存上限，超出后淘汰最久未使用的
+}
+
+// 默认配置
+var defaultTokenizerSettings = TokenizerSettings{
+	Files: map[string]string{},
+	ModelTokenizers: map[string]string{
+		"gpt-4o*":     "o200k_base",
+		"chatgpt-4o*": "o200k_base",
+		"gpt-4.1*":    "o200k_base",
+		"gpt-4.5*":    "o200k_base",
+		"gpt-5*":      "o200k_base",
+		"gpt-oss*":    "o200k_base",
+		"o1*":         "o200k_base",
+		"o3*":         "o200k_base",
+		"o4*":         "o200k_base",
+	},
+	MaxMemoryMB: 512,
+}
+
+// 全局实例
+var tokenizerSettings = defaultTokenizerSettings
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("tokenizer", &tokenizerSettings)
+}
+
+// GetTokenizerSettings 获取分词器配置
+func GetTokenizerSettings() *TokenizerSettings {
+	return &tokenizerSettings
+}
+
+// GetModelTokenizer 返回模型配置的分词器名称，精确匹配优先，其次是最长的前缀
+func GetModelTokenizer(model string) (string, bool) {
+	if name, ok := tokenizerSettings.ModelTokenizers[model]; ok && name != "" {
+		return name, true
+	}
+	matched, matchedLen := "", 0
+	for pattern, name := range tokenizerSettings.ModelTokenizers {
+		prefix, isPrefix := strings.CutSuffix(pattern, "*")
+		if isPrefix && len(prefix) >= matchedLen && strings.HasPrefix(model, prefix) && name != "" {
+			matched, matchedLen = name, len(prefix)
+		}
+	}
+	return matched, matched != ""
+}
@@ -0,0 +1,25 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type AnalyticsSetting struct {
+	// RollupEnabled 后台按小时预聚合消费日志，加速用量分析查询
+	RollupEnabled bool `json:"rollup_enabled"`
+	// MaxRangeDays 单次分析查询允许的最大时间跨度，0 表示不限制
+	MaxRangeDays int `json:"max_range_days"`
+}
+
+// 默认配置
+var analyticsSetting = AnalyticsSetting{
+	RollupEnabled: true,
+	MaxRangeDays:  366,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("analytics_setting", &analyticsSetting)
+}
+
+func GetAnalyticsSetting() *AnalyticsSetting {
+	return &analyticsSetting
+}
@@ -0,0 +1,36 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+// BodyCaptureRule 请求体捕获规则，所有非零字段均匹配时生效
+type BodyCaptureRule struct {
+	UserId     int     `json:"user_id"`
+	TokenId    int     `json:"token_id"`
+	ChannelId  int     `json:"channel_id"`
+	ModelName  string  `json:"model_name"`
+	SampleRate float64 `json:"sample_rate"` // 0-1，命中规则后按比例采样
+}
+
+type BodyCaptureSetting struct {
+	Enabled        bool              `json:"enabled"`
+	Rules          []BodyCaptureRule `json:"rules"`
+	MaxBodyBytes   int               `json:"max_body_bytes"`  // 单个请求/响应体最多保存的字节数
+	RetentionHours int               `json:"retention_hours"` // 保存时长，过期自动清理
+}
+
+// 默认配置
+var bodyCaptureSetting = BodyCaptureSetting{
+	Enabled:        false,
+	Rules:          []BodyCaptureRule{},
+	MaxBodyBytes:   1024 * 1024,
+	RetentionHours: 72,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("body_capture_setting", &bodyCaptureSetting)
+}
+
+func GetBodyCaptureSetting() *BodyCaptureSetting {
+	return &bodyCaptureSetting
+}
@@ -0,0 +1,27 @@
+package operation_setting
+
+import (
+	"one-api/common/logsink"
+	"one-api/setting/config"
+)
+
+type LogSinkSetting struct {
+	// SQLEnabled 是否继续写入 logs 表；关闭后仅在存在启用的投递目标时生效
+	SQLEnabled bool             `json:"sql_enabled"`
+	Sinks      []logsink.Config `json:"sinks"`
+}
+
+// 默认配置
+var logSinkSetting = LogSinkSetting{
+	SQLEnabled: true,
+	Sinks:      []logsink.Config{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("log_sink_setting", &logSinkSetting)
+}
+
+func GetLogSinkSetting() *LogSinkSetting {
+	return &logSinkSetting
+}
@@ -0,0 +1,72 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+const (
+	BillingModeDefault  = ""         // 跟随分组配置
+	BillingModePrepaid  = "prepaid"  // 预付费，余额用尽即停止服务
+	BillingModePostpaid = "postpaid" // 后付费，余额可透支到信用额度，按月结算
+)
+
+// PostpaidSetting 后付费账户设置。后付费用户的可用额度为余额加信用额度，余额可以为负，
+// 透支达到信用额度时拒绝请求，并可自动禁用账户（结清后需管理员手动启用）
+type PostpaidSetting struct {
+	// GroupCreditLimits 按分组开启后付费，值为该分组用户的默认信用额度
+	GroupCreditLimits map[string]int `json:"group_credit_limits"`
+	// NotifyThresholds 信用额度已使用的百分比达到这些阈值时通知用户
+	NotifyThresholds []int `json:"notify_thresholds"`
+	// AutoSuspend 透支达到信用额度时自动禁用用户
+	AutoSuspend bool `json:"auto_suspend"`
+}
+
+// 默认配置
+var postpaidSetting = PostpaidSetting{
+	GroupCreditLimits: map[string]int{},
+	NotifyThresholds:  []int{80, 90},
+	AutoSuspend:       true,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("postpaid_setting", &postpaidSetting)
+}
+
+func GetPostpaidSetting() *PostpaidSetting {
+	return &postpaidSetting
+}
+
+func IsValidBillingMode(mode string) bool {
+	switch mode {
+	case BillingModeDefault, BillingModePrepaid, BillingModePostpaid:
+		return true
+	}
+	return false
+}
+
+// ResolveCreditLimit 返回用户生效的信用额度，0 表示预付费。
+// 用户单独设置的计费方式优先于分组配置，后付费用户设置了信用额度时覆盖分组的默认值
+func ResolveCreditLimit(billingMode string, userCreditLimit int, group string) int {
+	groupLimit, groupPostpaid := postpaidSetting.GroupCreditLimits[group]
+	switch billingMode {
+	case BillingModePrepaid:
+		return 0
+	case BillingModePostpaid:
+	default:
+		if !groupPostpaid {
+			return 0
+		}
+	}
+	if userCreditLimit > 0 {
+		return userCreditLimit
+	}
+	return max(groupLimit, 0)
+}
+
+// PostpaidGroups 返回按分组开启了后付费的分组
+func PostpaidGroups() []string {
+	groups := make([]string, 0, len(postpaidSetting.GroupCreditLimits))
+	for group := range postpaidSetting.GroupCreditLimits {
+		groups = append(groups, group)
+	}
+	return groups
+}
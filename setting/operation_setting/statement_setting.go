@@ -0,0 +1,28 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type StatementSetting struct {
+	// MonthlyEnabled 每月初自动为上月有账务变动的用户生成账单
+	MonthlyEnabled bool `json:"monthly_enabled"`
+	// CurrencySymbol 导出账单时展示的货币符号
+	CurrencySymbol string `json:"currency_symbol"`
+	// SelfServiceMaxDays 用户自助生成账单允许的最大周期天数，0 表示不限制
+	SelfServiceMaxDays int `json:"self_service_max_days"`
+}
+
+// 默认配置
+var statementSetting = StatementSetting{
+	MonthlyEnabled:     false,
+	CurrencySymbol:     "$",
+	SelfServiceMaxDays: 93,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("statement_setting", &statementSetting)
+}
+
+func GetStatementSetting() *StatementSetting {
+	return &statementSetting
+}
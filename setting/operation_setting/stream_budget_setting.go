@@ -0,0 +1,23 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+// StreamBudgetSetting 流式响应的额度预算设置。开启后按请求开始时的剩余额度换算补全 token 上限，
+// 流式输出超出上限时提前结束响应（finish_reason 为 length），只对已转发的内容计费，避免令牌、用户或组织额度被扣成负数
+type StreamBudgetSetting struct {
+	Enabled bool `json:"enabled"`
+}
+
+// 默认配置
+var streamBudgetSetting = StreamBudgetSetting{
+	Enabled: true,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("stream_budget_setting", &streamBudgetSetting)
+}
+
+func GetStreamBudgetSetting() *StreamBudgetSetting {
+	return &streamBudgetSetting
+}
@@ -0,0 +1,36 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+const (
+	StructuredOutputActionLog    = "log"    // 仅记录校验结果
+	StructuredOutputActionRetry  = "retry"  // 校验失败时通过重试换下一个渠道
+	StructuredOutputActionRepair = "repair" // 校验失败时先尝试修复 JSON，修复后仍不合法再按 RetryOnRepairFailure 处理
+)
+
+// StructuredOutputSetting 结构化输出与工具调用参数的校验设置，仅对文本对话请求中
+// 指定了 response_format（json_schema / json_object）或 tools 的请求生效
+type StructuredOutputSetting struct {
+	Enabled              bool   `json:"enabled"`
+	Action               string `json:"action"`
+	RetryOnRepairFailure bool   `json:"retry_on_repair_failure"`
+	// BufferStream 缓存完整的流式响应后再写给客户端，以便流式请求也能重试或修复；关闭时流式响应只记录校验结果
+	BufferStream bool `json:"buffer_stream"`
+}
+
+// 默认配置
+var structuredOutputSetting = StructuredOutputSetting{
+	Enabled:              false,
+	Action:               StructuredOutputActionRetry,
+	RetryOnRepairFailure: true,
+	BufferStream:         false,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("structured_output_setting", &structuredOutputSetting)
+}
+
+func GetStructuredOutputSetting() *StructuredOutputSetting {
+	return &structuredOutputSetting
+}
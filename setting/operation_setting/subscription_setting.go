@@ -0,0 +1,34 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+type SubscriptionSetting struct {
+	// Enabled 是否开放订阅套餐购买
+	Enabled bool `json:"enabled"`
+	// RateLimitTiers 限流档位，格式与分组限流相同：[最多请求次数, 最多请求完成次数]
+	RateLimitTiers map[string][2]int `json:"rate_limit_tiers"`
+}
+
+// 默认配置
+var subscriptionSetting = SubscriptionSetting{
+	Enabled:        false,
+	RateLimitTiers: map[string][2]int{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("subscription_setting", &subscriptionSetting)
+}
+
+func GetSubscriptionSetting() *SubscriptionSetting {
+	return &subscriptionSetting
+}
+
+// GetRateLimitTier 返回限流档位的配置
+func GetRateLimitTier(tier string) (totalCount, successCount int, found bool) {
+	limits, found := subscriptionSetting.RateLimitTiers[tier]
+	if !found {
+		return 0, 0, false
+	}
+	return limits[0], limits[1], true
+}
@@ -0,0 +1,61 @@
+package system_setting
+
+import "one-api/setting/config"
+
+// LDAPGroupMapping 目录组到角色与用户分组的映射，按顺序匹配
+type LDAPGroupMapping struct {
+	LdapGroup string `json:"ldap_group"` // 组的完整 DN 或 CN，不区分大小写
+	Role      int    `json:"role"`       // 1 普通用户，10 管理员；为 0 时不影响角色
+	Group     string `json:"group"`      // 用户分组（对应价格倍率与限流），为空时不影响分组
+}
+
+type LDAPSettings struct {
+	Enabled            bool   `json:"enabled"`
+	Url                string `json:"url"`       // ldap://host:389 或 ldaps://host:636
+	StartTLS           bool   `json:"start_tls"` // 在 ldap:// 连接上升级为 TLS
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+	BindDN             string `json:"bind_dn"` // 用于搜索用户的服务账号，为空时匿名绑定
+	BindPassword       string `json:"bind_password"`
+	BaseDN             string `json:"base_dn"`
+	// UserFilter 搜索用户的过滤器，{username} 会被替换为转义后的登录名
+	UserFilter string `json:"user_filter"`
+	// IdAttribute 用户的唯一标识属性，用户在 OU 之间移动时 DN 会变化，因此不以 DN 作为账户标识
+	// OpenLDAP 使用 entryUUID，Active Directory 使用 objectGUID
+	IdAttribute          string `json:"id_attribute"`
+	UsernameAttribute    string `json:"username_attribute"`
+	EmailAttribute       string `json:"email_attribute"`
+	DisplayNameAttribute string `json:"display_name_attribute"`
+	// GroupAttribute 用户条目上记录所属组的属性，例如 AD 的 memberOf
+	GroupAttribute string `json:"group_attribute"`
+	// GroupBaseDN 与 GroupFilter 用于 OpenLDAP groupOfNames 等不在用户条目上记录组的场景，{dn} 与 {username} 会被替换
+	GroupBaseDN        string             `json:"group_base_dn"`
+	GroupFilter        string             `json:"group_filter"`
+	GroupMappings      []LDAPGroupMapping `json:"group_mappings"`
+	AutoProvision      bool               `json:"auto_provision"`       // 首次登录时自动创建用户
+	AllowUnmappedUsers bool               `json:"allow_unmapped_users"` // 是否允许不属于任何映射组的用户登录
+	TimeoutSeconds     int                `json:"timeout_seconds"`
+}
+
+// 默认配置
+var defaultLDAPSettings = LDAPSettings{
+	UserFilter:           "(&(objectClass=person)(uid={username}))",
+	IdAttribute:          "entryUUID",
+	UsernameAttribute:    "uid",
+	EmailAttribute:       "mail",
+	DisplayNameAttribute: "cn",
+	GroupAttribute:       "memberOf",
+	GroupFilter:          "(|(member={dn})(uniqueMember={dn})(memberUid={username}))",
+	GroupMappings:        []LDAPGroupMapping{},
+	AutoProvision:        true,
+	AllowUnmappedUsers:   true,
+	TimeoutSeconds:       10,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("ldap", &defaultLDAPSettings)
+}
+
+func GetLDAPSettings() *LDAPSettings {
+	return &defaultLDAPSettings
+}
@@ -0,0 +1,58 @@
+package system_setting
+
+import "one-api/setting/config"
+
+// OAuthClaimsMapping 用户信息字段到本地用户属性的映射，使用点号分隔的 JSON 路径，例如 data.user.id
+type OAuthClaimsMapping struct {
+	Id          string `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	Email       string `json:"email"`
+}
+
+// OAuthProvider 一个可配置的 OAuth2 / OIDC 登录提供方
+type OAuthProvider struct {
+	Name                  string   `json:"name"` // 唯一标识，只能包含小写字母、数字、- 与 _，回调地址为 /oauth/{name}
+	DisplayName           string   `json:"display_name"`
+	Icon                  string   `json:"icon"`
+	Enabled               bool     `json:"enabled"`
+	ClientId              string   `json:"client_id"`
+	ClientSecret          string   `json:"client_secret"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	UserInfoEndpoint      string   `json:"user_info_endpoint"`
+	Scopes                []string `json:"scopes"`
+	// ClientAuthMethod 令牌端点的客户端认证方式：client_secret_post（默认）或 client_secret_basic
+	ClientAuthMethod string             `json:"client_auth_method"`
+	ClaimsMapping    OAuthClaimsMapping `json:"claims_mapping"`
+	AllowRegister    bool               `json:"allow_register"` // 未绑定的外部账户首次登录时是否自动注册
+}
+
+type OAuthProviderSettings struct {
+	Providers []OAuthProvider `json:"providers"`
+}
+
+// 默认配置
+var defaultOAuthProviderSettings = OAuthProviderSettings{
+	Providers: []OAuthProvider{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("oauth_providers", &defaultOAuthProviderSettings)
+}
+
+func GetOAuthProviderSettings() *OAuthProviderSettings {
+	return &defaultOAuthProviderSettings
+}
+
+// GetOAuthProvider 按名称查找已启用的提供方
+func GetOAuthProvider(name string) (*OAuthProvider, bool) {
+	for i := range defaultOAuthProviderSettings.Providers {
+		provider := &defaultOAuthProviderSettings.Providers[i]
+		if provider.Name == name && provider.Enabled {
+			return provider, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,28 @@
+package system_setting
+
+import "one-api/setting/config"
+
+type PasskeySettings struct {
+	Enabled bool `json:"enabled"`
+	// RPID 依赖方标识，一般为站点域名，为空时使用 ServerAddress 的主机名
+	RPID          string `json:"rp_id"`
+	RPDisplayName string `json:"rp_display_name"`
+	// RPOrigins 允许的来源，为空时使用 ServerAddress
+	RPOrigins []string `json:"rp_origins"`
+	// RequireForAdmin 管理员与超级管理员必须通过通行密钥登录（或以通行密钥完成两步验证）才能访问管理接口
+	RequireForAdmin bool `json:"require_for_admin"`
+}
+
+// 默认配置
+var defaultPasskeySettings = PasskeySettings{
+	RPOrigins: []string{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("passkey", &defaultPasskeySettings)
+}
+
+func GetPasskeySettings() *PasskeySettings {
+	return &defaultPasskeySettings
+}
@@ -0,0 +1,21 @@
+package system_setting
+
+import "one-api/setting/config"
+
+type SCIMSettings struct {
+	Enabled bool `json:"enabled"`
+	// TokenHash SCIM bearer token 的 SHA-256，明文只在生成时返回一次
+	TokenHash string `json:"token_hash"`
+}
+
+// 默认配置
+var defaultSCIMSettings = SCIMSettings{}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("scim", &defaultSCIMSettings)
+}
+
+func GetSCIMSettings() *SCIMSettings {
+	return &defaultSCIMSettings
+}